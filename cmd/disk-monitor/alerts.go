@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Alert kinds
+const (
+	alertThreshold = "threshold"
+	alertAnomaly   = "anomaly"
+	alertHealth    = "health"
+	alertPool      = "pool"
+	// alertFragmentation is only raised for spinning disks, SSDs don't seek
+	alertFragmentation = "fragmentation"
+	// alertFiles is raised when a volume runs short of file records
+	alertFiles = "files"
+	// alertOneDrive is raised when online-only files are downloaded in bulk
+	alertOneDrive = "onedrive"
+	// alertRule prefixes the name of an alert rule in the kind of its alerts
+	alertRule = "rule:"
+)
+
+// Alert severities, in rising order
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+	// severityResolved marks the notice that an alert recovered
+	severityResolved = "resolved"
+)
+
+// severityRank orders severities, -1 for resolved and unknown ones
+func severityRank(severity string) int {
+	switch severity {
+	case severityWarning:
+		return 0
+	case severityCritical:
+		return 1
+	}
+	return -1
+}
+
+// Alert is a condition worth notifying the user about
+type Alert struct {
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	Drive    string    `json:"drive"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+}
+
+// key identifies the condition across collections
+func (a Alert) key() string {
+	return a.Kind + "|" + a.Drive
+}
+
+// evaluateAlerts checks the latest snapshot against the configured alert rules
+func evaluateAlerts(hist *history.History, cfg *Config) []Alert {
+	if len(hist.Snapshots) == 0 {
+		return nil
+	}
+
+	var alerts []Alert
+	latest := hist.Snapshots[len(hist.Snapshots)-1]
+
+	for _, disk := range latest.Disks {
+		threshold, critical, anomaly := cfg.Alerts.rules(disk)
+		if threshold > 0 && disk.TotalSpace > 0 {
+			usedPercent := float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+			if usedPercent >= threshold {
+				severity := severityWarning
+				if critical > 0 && usedPercent >= critical {
+					severity = severityCritical
+				}
+				alerts = append(alerts, Alert{
+					Kind:     alertThreshold,
+					Severity: severity,
+					Drive:    disk.Drive,
+					Time:     latest.Timestamp,
+					Message: fmt.Sprintf("%s is %s full (%s free)",
+						disk.Drive, locale.Percent(usedPercent, 1), diskinfo.FormatBytes(disk.FreeSpace)),
+				})
+			}
+		}
+
+		if pct := disk.FilesPercent(); cfg.Alerts.FilesPercent > 0 && pct >= cfg.Alerts.FilesPercent {
+			severity := severityWarning
+			if cfg.Alerts.FilesCriticalPercent > 0 && pct >= cfg.Alerts.FilesCriticalPercent {
+				severity = severityCritical
+			}
+			alerts = append(alerts, Alert{
+				Kind:     alertFiles,
+				Severity: severity,
+				Drive:    disk.Drive,
+				Time:     latest.Timestamp,
+				Message: fmt.Sprintf("%s uses %s of its file records (%s of %s)",
+					disk.Drive, locale.Percent(pct, 1), locale.Int(disk.Files), locale.Int(disk.MaxFiles)),
+			})
+		}
+
+		if anomaly {
+			points := hist.Series(disk.Drive, time.Time{})
+			unit := diskinfo.GigabyteUnit()
+			for _, a := range analysis.DetectAnomalies(points, cfg.Anomaly) {
+				// Only the newest sample is news
+				if a.Index != len(points)-1 {
+					continue
+				}
+				alerts = append(alerts, Alert{
+					Kind:     alertAnomaly,
+					Severity: severityWarning,
+					Drive:    disk.Drive,
+					Time:     a.Time,
+					Message: fmt.Sprintf("%s: %s of %s %s (typical %s %s)", disk.Drive, a.Kind(),
+						locale.Signed(a.Change/diskinfo.Gigabyte(), 1), unit, locale.Signed(a.Typical/diskinfo.Gigabyte(), 1), unit),
+				})
+			}
+		}
+	}
+
+	for _, rule := range cfg.Alerts.Rules {
+		f, severity, err := rule.parse()
+		if err != nil {
+			slog.Warn("skipped alert rule", "rule", rule.Name, "err", err)
+			continue
+		}
+		for _, disk := range latest.Disks {
+			if !f.match(&filterRow{snapshot: &latest, disk: disk, hist: hist, forecast: cfg.Forecast}) {
+				continue
+			}
+			alerts = append(alerts, Alert{
+				Kind:     alertRule + rule.Name,
+				Severity: severity,
+				Drive:    disk.Drive,
+				Time:     latest.Timestamp,
+				Message: fmt.Sprintf("%s meets %s: %s (%s full, %s free)",
+					disk.Drive, rule.Name, f, locale.Percent(percentOf(disk.UsedSpace, disk.TotalSpace), 1), diskinfo.FormatBytes(disk.FreeSpace)),
+			})
+		}
+	}
+
+	for _, health := range latest.Health {
+		for _, problem := range cfg.Health.Problems(health) {
+			alerts = append(alerts, Alert{
+				Kind:     alertHealth,
+				Severity: severityCritical,
+				Drive:    health.Label(),
+				Time:     latest.Timestamp,
+				Message:  fmt.Sprintf("%s: %s", health.Label(), problem),
+			})
+		}
+	}
+
+	for _, pool := range latest.Pools {
+		if cfg.Pools.UsedPercent > 0 && pool.Size > 0 && pool.UsedPercent() >= cfg.Pools.UsedPercent {
+			alerts = append(alerts, Alert{
+				Kind:     alertPool,
+				Severity: severityWarning,
+				Drive:    pool.Name,
+				Time:     latest.Timestamp,
+				Message: fmt.Sprintf("pool %s is %s allocated (%s free)",
+					pool.Name, locale.Percent(pool.UsedPercent(), 1), diskinfo.FormatBytes(pool.Free())),
+			})
+		}
+		if cfg.Pools.Overcommit && pool.Overcommitted() {
+			alerts = append(alerts, Alert{
+				Kind:     alertPool,
+				Severity: severityWarning,
+				Drive:    pool.Name,
+				Time:     latest.Timestamp,
+				Message: fmt.Sprintf("pool %s is overcommitted: thin disks can grow by %s, %s is free",
+					pool.Name, diskinfo.FormatBytes(pool.Growth()), diskinfo.FormatBytes(pool.Free())),
+			})
+		}
+	}
+
+	for _, frag := range latest.Fragmentation {
+		if cfg.Fragmentation.AlertPercent > 0 && frag.SeekPenalty && frag.Percent() >= cfg.Fragmentation.AlertPercent {
+			alerts = append(alerts, Alert{
+				Kind:     alertFragmentation,
+				Severity: severityWarning,
+				Drive:    frag.Drive,
+				Time:     latest.Timestamp,
+				Message: fmt.Sprintf("%s free space is %s fragmented on a spinning disk (largest free extent %s)",
+					frag.Drive, locale.Percent(frag.Percent(), 1), diskinfo.FormatBytes(frag.LargestFree)),
+			})
+		}
+	}
+
+	if limit, err := diskinfo.ParseSize(cfg.OneDrive.DownloadAlert); err == nil && limit > 0 && len(hist.Snapshots) > 1 {
+		prev := hist.Snapshots[len(hist.Snapshots)-2]
+		for _, od := range latest.OneDrive {
+			for _, before := range prev.OneDrive {
+				if before.Folder != od.Folder || before.OnlineOnlySize() <= od.OnlineOnlySize() || before.OnDisk >= od.OnDisk {
+					continue
+				}
+				// Downloads move size from online-only to on disk. New local files
+				// only add to the latter and deleted placeholders only take from the former.
+				downloaded := min(before.OnlineOnlySize()-od.OnlineOnlySize(), od.OnDisk-before.OnDisk)
+				if downloaded >= limit {
+					alerts = append(alerts, Alert{
+						Kind:     alertOneDrive,
+						Severity: severityWarning,
+						Drive:    od.Folder,
+						Time:     latest.Timestamp,
+						Message: fmt.Sprintf("%s: %s of online-only files downloaded since the last collection, %s on disk now",
+							od.Folder, diskinfo.FormatBytes(downloaded), diskinfo.FormatBytes(od.OnDisk)),
+					})
+				}
+			}
+		}
+	}
+
+	return alerts
+}
+
+// alertLookBack returns how far before a snapshot its alert rules read the
+// history, and how much further back the escalation steps check for how long
+// the alerts have been firing
+func (c *Config) alertLookBack() (rules, escalation time.Duration) {
+	for _, rule := range c.Alerts.Rules {
+		f, _, err := rule.parse()
+		if err != nil {
+			continue
+		}
+		rules = max(rules, f.lookBack)
+		if window, err := analysis.ParseDuration(c.Forecast.Window); err == nil && f.forecast {
+			rules = max(rules, window)
+		}
+	}
+	policies, _ := c.Alerts.escalation(c.Sinks)
+	for _, p := range policies {
+		for _, st := range p.steps {
+			escalation = max(escalation, st.after)
+		}
+	}
+	return rules, escalation
+}
+
+// alertSamples reports whether hist has the samples the alerts of its latest
+// snapshot need besides the rules' windows: the previous snapshot, and for
+// anomaly alerts the changes of each drive the latest one is compared with.
+// When it doesn't, it returns the window that should have them going by how
+// far apart the samples are, 0 when there are too few to tell.
+func alertSamples(hist *history.History, cfg *Config) (bool, time.Duration) {
+	n := len(hist.Snapshots)
+	if n < 2 {
+		return false, 0
+	}
+	enough, window := true, time.Duration(0)
+	// DetectAnomalies compares a change with the Window changes before it
+	need := max(cfg.Anomaly.Window, 5) + 2
+	for _, disk := range hist.Snapshots[n-1].Disks {
+		if _, _, anomaly := cfg.Alerts.rules(disk); !anomaly {
+			continue
+		}
+		points := hist.Series(disk.Drive, time.Time{})
+		if len(points) >= need {
+			continue
+		}
+		enough = false
+		if len(points) >= 2 {
+			spacing := points[len(points)-1].Time.Sub(points[0].Time) / time.Duration(len(points)-1)
+			window = max(window, spacing*time.Duration(need))
+		}
+	}
+	return enough, window
+}
+
+// loadAlertHistory loads the part of the history the alerts of a snapshot
+// taken at now read, instead of all of it: the rules' windows with the
+// escalation steps on top, widened until the samples alertSamples asks for
+// are in it. After three tries the whole history is read, which a short one is.
+func loadAlertHistory(ctx context.Context, cfg *Config, zone *time.Location, store history.Store, now time.Time) (*history.History, error) {
+	span, escalation := cfg.alertLookBack()
+	for tries := 0; ; tries++ {
+		var from time.Time
+		if tries < 3 {
+			from = now.Add(-escalation - span)
+		}
+		hist, err := history.LoadWindow(ctx, store, from)
+		if err != nil {
+			return nil, err
+		}
+		addJournaled(cfg, zone, hist, from)
+		enough, window := alertSamples(hist, cfg)
+		if enough || from.IsZero() {
+			return hist, nil
+		}
+		span = max(window, 2*span, 24*time.Hour)
+	}
+}
+
+// parse returns the condition and severity of the rule
+func (r AlertRule) parse() (*filter, string, error) {
+	if r.Name == "" {
+		return nil, "", fmt.Errorf("alert rule %q has no name", r.When)
+	}
+	severity := r.Severity
+	switch severity {
+	case "":
+		severity = severityWarning
+	case severityWarning, severityCritical:
+	default:
+		return nil, "", fmt.Errorf("invalid severity %q, use %s or %s", r.Severity, severityWarning, severityCritical)
+	}
+	f, err := parseFilter(r.When)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid condition %q: %v", r.When, err)
+	}
+	return f, severity, nil
+}
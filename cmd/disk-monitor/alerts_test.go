@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+func TestLoadAlertHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	base, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(base, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// A snapshot a day for 30 days
+	ctx := context.Background()
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	now := start.AddDate(0, 0, 29)
+	for day := 0; day < 30; day++ {
+		if err := store.Append(ctx, history.Snapshot{Timestamp: start.AddDate(0, 0, day), Host: "nas",
+			Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: uint64(80 - day)}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func(cfg *Config)
+		// days is how many days of snapshots are loaded
+		days int
+	}{
+		{name: "only the previous snapshot", setup: func(cfg *Config) {}, days: 2},
+		{name: "growth window", setup: func(cfg *Config) {
+			cfg.Alerts.Rules = []AlertRule{{Name: "fast", When: "growth_7d > 1"}}
+		}, days: 8},
+		{name: "forecast window", setup: func(cfg *Config) {
+			cfg.Forecast = analysis.ForecastConfig{Window: "10d", Model: analysis.ModelLinear}
+			cfg.Alerts.Rules = []AlertRule{{Name: "soon", When: "days_until_full < 30"}, {Name: "fast", When: "growth_7d > 1"}}
+		}, days: 11},
+		{name: "escalation steps", setup: func(cfg *Config) {
+			cfg.Alerts.Rules = []AlertRule{{Name: "fast", When: "growth_7d > 1"}}
+			cfg.Sinks = []SinkConfig{{Type: "webhook", Name: "pager"}}
+			cfg.Alerts.Policies = []EscalationPolicy{{Name: "disks", Steps: []EscalationStep{{After: "48h", Sinks: []string{"pager"}}}}}
+		}, days: 10},
+		{name: "anomaly samples", setup: func(cfg *Config) {
+			cfg.Alerts.Anomaly = true
+			cfg.Anomaly.Window = 10
+		}, days: 13},
+		{name: "longer than the history", setup: func(cfg *Config) {
+			cfg.Alerts.Anomaly = true
+			cfg.Anomaly.Window = 40
+		}, days: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *base
+			tt.setup(&cfg)
+			hist, err := loadAlertHistory(ctx, &cfg, time.UTC, store, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(hist.Snapshots); got != tt.days {
+				t.Errorf("loaded %d days, want %d", got, tt.days)
+			}
+			if n := len(hist.Snapshots); n > 0 && !hist.Snapshots[n-1].Timestamp.Equal(now) {
+				t.Errorf("latest snapshot = %s, want %s", hist.Snapshots[n-1].Timestamp, now)
+			}
+		})
+	}
+}
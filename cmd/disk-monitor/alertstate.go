@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// alertTracker keeps the state of one host's alerts across collections, so a
+// sink is notified of an alert once, again when it turns critical or every
+// repeat, and of its recovery
+type alertTracker struct {
+	host   string
+	now    time.Time
+	repeat time.Duration
+	// states are the host's alert states by Alert.key, others those of the other hosts
+	states map[string]*history.AlertState
+	others []history.AlertState
+	// escalated are the alerts that became more severe, notified again everywhere
+	escalated map[string]bool
+	// resolved are the sinks to tell about each recovered alert
+	resolved map[string][]string
+	// notified are the alerts a sink was notified of by this collection
+	notified map[string]bool
+	// held are the drives this collection skipped, whose alerts are kept as they are
+	held []string
+	// saved is what was loaded, to skip writing an unchanged state
+	saved []byte
+}
+
+// loadAlertTracker reads the alert states of the store
+func loadAlertTracker(ctx context.Context, store history.Store, host string, now time.Time, repeat time.Duration) (*alertTracker, error) {
+	states, err := store.AlertStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &alertTracker{
+		host:      host,
+		now:       now,
+		repeat:    repeat,
+		states:    make(map[string]*history.AlertState),
+		escalated: make(map[string]bool),
+		resolved:  make(map[string][]string),
+		notified:  make(map[string]bool),
+	}
+	t.saved, _ = json.Marshal(states)
+	for i := range states {
+		st := &states[i]
+		if st.Host != host {
+			t.others = append(t.others, *st)
+			continue
+		}
+		t.states[Alert{Kind: st.Kind, Drive: st.Drive}.key()] = st
+	}
+	return t, nil
+}
+
+// hold keeps the alerts of drives that weren't collected this time from
+// counting as recovered
+func (t *alertTracker) hold(drives []string) {
+	if t == nil {
+		return
+	}
+	t.held = append(t.held, drives...)
+}
+
+// update records the alerts raised now, those sent to the sinks and those
+// held back by silences, and returns an alert for each one that recovered,
+// which only goes to the sinks notified of it
+func (t *alertTracker) update(sent, silenced []Alert) []Alert {
+	if t == nil {
+		return nil
+	}
+	raised := make(map[string]bool)
+	record := func(a Alert, status string) {
+		key := a.key()
+		raised[key] = true
+		st, ok := t.states[key]
+		if !ok {
+			st = &history.AlertState{Host: t.host, Kind: a.Kind, Drive: a.Drive, Status: status, Since: a.Time}
+			t.states[key] = st
+		}
+		switch {
+		case status == history.AlertSilenced:
+			st.Status = status
+		case severityRank(a.Severity) > severityRank(st.Severity) && ok:
+			// Turning critical is news even to acknowledged alerts
+			t.escalated[key] = true
+			st.Status = status
+		case st.Status == history.AlertSilenced:
+			st.Status = status
+		}
+		st.Severity, st.Message = a.Severity, a.Message
+	}
+	for _, a := range sent {
+		record(a, history.AlertFiring)
+	}
+	for _, a := range silenced {
+		record(a, history.AlertSilenced)
+	}
+
+	var recovered []Alert
+	for key, st := range t.states {
+		if raised[key] || slices.ContainsFunc(t.held, func(d string) bool { return strings.EqualFold(d, st.Drive) }) {
+			continue
+		}
+		if len(st.Sinks) > 0 {
+			recovered = append(recovered, Alert{
+				Kind:     st.Kind,
+				Severity: severityResolved,
+				Drive:    st.Drive,
+				Time:     t.now,
+				Message:  "Resolved: " + st.Message,
+			})
+			t.resolved[key] = st.Sinks
+		}
+		delete(t.states, key)
+	}
+	sort.Slice(recovered, func(i, j int) bool { return recovered[i].key() < recovered[j].key() })
+	return recovered
+}
+
+// due returns the alerts a sink hasn't been notified of yet, or should be
+// reminded of, and the recoveries meant for it
+func (t *alertTracker) due(sink string, alerts []Alert) []Alert {
+	if t == nil {
+		return alerts
+	}
+	var result []Alert
+	for _, a := range alerts {
+		key := a.key()
+		if a.Severity == severityResolved {
+			if slices.Contains(t.resolved[key], sink) {
+				result = append(result, a)
+			}
+			continue
+		}
+		st, ok := t.states[key]
+		switch {
+		case !ok || t.escalated[key]:
+			result = append(result, a)
+		case st.Status == history.AlertAcknowledged:
+		case !slices.Contains(st.Sinks, sink):
+			result = append(result, a)
+		case t.repeat > 0 && t.now.Sub(st.LastNotified) >= t.repeat:
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// sent records that a sink was notified of alerts
+func (t *alertTracker) sent(sink string, alerts []Alert) {
+	if t == nil {
+		return
+	}
+	for _, a := range alerts {
+		st, ok := t.states[a.key()]
+		if !ok {
+			continue
+		}
+		if !slices.Contains(st.Sinks, sink) {
+			st.Sinks = append(st.Sinks, sink)
+		}
+		t.notified[a.key()] = true
+	}
+}
+
+// save writes the alert states back to the store, unless nothing changed
+func (t *alertTracker) save(ctx context.Context, store history.Store) error {
+	if t == nil {
+		return nil
+	}
+	states := slices.Clone(t.others)
+	for key, st := range t.states {
+		if t.notified[key] {
+			st.LastNotified = t.now
+		}
+		states = append(states, *st)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Host != states[j].Host {
+			return states[i].Host < states[j].Host
+		}
+		return states[i].Drive+states[i].Kind < states[j].Drive+states[j].Kind
+	})
+
+	data, _ := json.Marshal(states)
+	if string(data) == string(t.saved) {
+		return nil
+	}
+	return store.SaveAlertStates(ctx, states)
+}
+
+// runAlerts lists the alerts raised by the latest collections, or acknowledges
+// the local machine's so they aren't notified again until they recover
+func runAlerts(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("alerts", flag.ExitOnError)
+	ack := fs.Bool("ack", false, "Acknowledge the firing alerts of this machine")
+	drive := fs.String("drive", "", "Only acknowledge the alerts of this drive, pool or folder")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	states, err := store.AlertStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *ack {
+		host := history.LocalHost()
+		n := 0
+		for i := range states {
+			st := &states[i]
+			if st.Host != host || st.Status != history.AlertFiring {
+				continue
+			}
+			if *drive != "" && !matchesDrive([]string{*drive}, st.Drive) {
+				continue
+			}
+			st.Status = history.AlertAcknowledged
+			n++
+		}
+		if n == 0 {
+			fmt.Println("No firing alerts to acknowledge")
+			return nil
+		}
+		if err := store.SaveAlertStates(ctx, states); err != nil {
+			return err
+		}
+		fmt.Printf("%d alerts acknowledged\n", n)
+		return nil
+	}
+	if *drive != "" {
+		return fmt.Errorf("-drive needs -ack")
+	}
+
+	if len(states) == 0 {
+		fmt.Println("No alerts raised")
+		return nil
+	}
+	fmt.Printf("%-16s %-13s %-9s %-16s %-16s %s\n", "Host", "Status", "Severity", "Since", "Last notified", "Alert")
+	for _, st := range states {
+		notified := "-"
+		if !st.LastNotified.IsZero() {
+			notified = locale.DateTime(st.LastNotified)
+		}
+		fmt.Printf("%-16s %-13s %-9s %-16s %-16s %s\n", st.Host, st.Status, st.Severity,
+			locale.DateTime(st.Since), notified, st.Message)
+		if len(st.Sinks) > 0 {
+			fmt.Printf("%-16s notified: %s\n", "", strings.Join(st.Sinks, ", "))
+		}
+	}
+	return nil
+}
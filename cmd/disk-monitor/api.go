@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// triggerRequest asks the daemon loop for a collection right away
+type triggerRequest struct {
+	note   string
+	result chan triggerResult
+}
+
+// triggerResult is the outcome of a triggered collection
+type triggerResult struct {
+	snapshot history.Snapshot
+	err      error
+}
+
+// daemonStatus is the reply of GET /api/status and of the GetStatus call
+type daemonStatus struct {
+	Version string `json:"version"`
+	Host    string `json:"host"`
+	// Latest is nil before the first collection
+	Latest    *history.Snapshot `json:"latest"`
+	Alerts    []Alert           `json:"alerts"`
+	Forecasts []driveForecast   `json:"forecasts"`
+}
+
+// driveForecast is when a drive of the latest snapshot will be full
+type driveForecast struct {
+	Drive string `json:"drive"`
+	// Rate is the change of free space in bytes per day
+	Rate float64 `json:"rate"`
+	// Filling is false when the trend never reaches the reserve, which leaves
+	// the fields below unset
+	Filling bool `json:"filling"`
+	// DaysUntilFull counts from the time of the latest snapshot
+	DaysUntilFull     float64   `json:"days_until_full"`
+	EstimatedFullDate time.Time `json:"estimated_full_date,omitzero"`
+	// EarliestFullDate and LatestFullDate bound the estimate at 95% confidence,
+	// LatestFullDate is zero when the slow end never fills up
+	EarliestFullDate time.Time `json:"earliest_full_date,omitzero"`
+	LatestFullDate   time.Time `json:"latest_full_date,omitzero"`
+}
+
+// currentStatus returns the latest snapshot of this machine with its alerts
+// and the forecasts of its drives
+func currentStatus(ctx context.Context, zone *time.Location) (*daemonStatus, error) {
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	host := history.LocalHost()
+	hostHist := hist.ForHost(host, host)
+	st := &daemonStatus{Version: buildInfo().Version, Host: host, Alerts: []Alert{}, Forecasts: []driveForecast{}}
+	n := len(hostHist.Snapshots)
+	if n == 0 {
+		return st, nil
+	}
+	latest := hostHist.Snapshots[n-1]
+	st.Latest = &latest
+	st.Alerts = append(st.Alerts, evaluateAlerts(hostHist, cfg)...)
+	for _, disk := range latest.Disks {
+		// Drives without enough history have no forecast
+		f, err := analysis.ForecastDrive(hostHist, disk.Drive, cfg.Forecast, latest.Timestamp)
+		if err != nil {
+			continue
+		}
+		df := driveForecast{Drive: disk.Drive, Rate: f.Rate, Filling: f.Filling}
+		if f.Filling {
+			df.DaysUntilFull = f.DaysUntilFull(latest.Timestamp)
+			df.EstimatedFullDate, df.EarliestFullDate, df.LatestFullDate = f.EstimatedFullDate, f.EarliestFullDate, f.LatestFullDate
+		}
+		st.Forecasts = append(st.Forecasts, df)
+	}
+	return st, nil
+}
+
+// apiError is the body of a failed API request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as the response body with the status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// requireToken rejects requests without the bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, apiError{"missing or wrong token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newAPIHandler serves the daemon's REST and gRPC APIs, which hand collections
+// to the daemon loop through triggers so they never overlap the scheduled ones.
+// The times they return are in zone.
+func newAPIHandler(token string, zone *time.Location, triggers chan<- triggerRequest, uploads chan<- uploadRequest, feed *snapshotFeed) http.Handler {
+	mux := http.NewServeMux()
+	// POST /api/trigger collects now and returns the snapshot. The optional
+	// JSON body {"note": "..."} is attached to it.
+	mux.HandleFunc("POST /api/trigger", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Note string `json:"note"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid body: " + err.Error()})
+			return
+		}
+
+		req := triggerRequest{note: body.Note, result: make(chan triggerResult, 1)}
+		select {
+		case triggers <- req:
+		case <-r.Context().Done():
+			return
+		}
+		// The collection finishes even if the client gives up waiting
+		select {
+		case res := <-req.result:
+			if res.err != nil && len(res.snapshot.Disks) == 0 {
+				writeJSON(w, http.StatusInternalServerError, apiError{res.err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, res.snapshot)
+		case <-r.Context().Done():
+		}
+	})
+	// GET /api/status returns the latest snapshot, its alerts and when the
+	// drives will be full, like the GetStatus call
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, r *http.Request) {
+		st, err := currentStatus(r.Context(), zone)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+	// POST /api/upload stores the snapshots of an agent, a gzipped protobuf
+	// diskmonitor.v1.UploadRequest, see uploadSnapshots
+	mux.HandleFunc("POST /api/upload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != uploadContentType {
+			writeJSON(w, http.StatusUnsupportedMediaType, apiError{"uploads are " + uploadContentType})
+			return
+		}
+		snapshots, err := readUpload(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid upload: " + err.Error()})
+			return
+		}
+		res, ok := submitUpload(r.Context(), uploads, snapshots)
+		if !ok {
+			return
+		}
+		if res.err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{res.err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", uploadContentType)
+		w.Write(encodeUploadResult(res))
+	})
+	rest := requireToken(token, mux)
+	grpc := newGRPCServer(token, zone, triggers, uploads, feed)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPC(r) {
+			grpc.ServeHTTP(w, r)
+			return
+		}
+		rest.ServeHTTP(w, r)
+	})
+}
+
+// submitUpload hands uploaded snapshots to the daemon loop and waits for them
+// to be stored, false if the client gave up first
+func submitUpload(ctx context.Context, uploads chan<- uploadRequest, snapshots []history.Snapshot) (uploadResult, bool) {
+	req := uploadRequest{snapshots: snapshots, result: make(chan uploadResult, 1)}
+	select {
+	case uploads <- req:
+	case <-ctx.Done():
+		return uploadResult{}, false
+	}
+	select {
+	case res := <-req.result:
+		return res, true
+	case <-ctx.Done():
+		return uploadResult{}, false
+	}
+}
+
+// serveAPI serves handler on addr until ctx is done
+func serveAPI(ctx context.Context, addr string, handler http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	// gRPC clients speak HTTP/2 without TLS
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("API server failed", "err", err)
+		}
+	}()
+	return nil
+}
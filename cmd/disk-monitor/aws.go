@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// awsMetadataURL is the EC2 instance metadata service
+const awsMetadataURL = "http://169.254.169.254/latest"
+
+// awsCredentials sign AWS requests. Token and Expires are set for the
+// temporary credentials of an instance role.
+type awsCredentials struct {
+	AccessKey string
+	SecretKey string
+	Token     string
+	Expires   time.Time
+}
+
+// expired reports whether temporary credentials are due for a refresh
+func (c *awsCredentials) expired() bool {
+	return c == nil || !c.Expires.IsZero() && time.Until(c.Expires) < 5*time.Minute
+}
+
+// hmacSHA256 returns the HMAC of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsSign adds the Signature Version 4 headers to req. Only the host and the
+// x-amz headers are signed, the query must already be in s3Query form.
+func awsSign(req *http.Request, payload []byte, creds awsCredentials, region, service string, t time.Time) {
+	sum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	headers := []string{
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+	}
+	if creds.Token != "" {
+		req.Header.Set("x-amz-security-token", creds.Token)
+		signedHeaders += ";x-amz-security-token"
+		headers = append(headers, "x-amz-security-token:"+creds.Token)
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		s3Escape(path, true),
+		req.URL.RawQuery,
+		strings.Join(headers, "\n"),
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+	key := hmacSHA256([]byte("AWS4"+creds.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKey, scope, signedHeaders, signature))
+}
+
+// loadAWSCredentials looks for credentials the way the AWS tools do: the given
+// keys, the AWS_ACCESS_KEY_ID environment variables, the profile of the shared
+// credentials file and finally the role of the EC2 instance
+func loadAWSCredentials(ctx context.Context, accessKey, secretKey, profile string) (*awsCredentials, error) {
+	if accessKey != "" && secretKey != "" {
+		return &awsCredentials{AccessKey: accessKey, SecretKey: secretKey}, nil
+	}
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &awsCredentials{AccessKey: id, SecretKey: secret, Token: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if creds, err := awsSharedCredentials(profile); err != nil {
+		return nil, err
+	} else if creds != nil {
+		return creds, nil
+	}
+	creds, err := awsInstanceCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials in the config, the environment or ~/.aws/credentials, and no instance role: %v", err)
+	}
+	return creds, nil
+}
+
+// awsSharedCredentials reads a profile of ~/.aws/credentials, nil when the file
+// or the profile doesn't exist
+func awsSharedCredentials(profile string) (*awsCredentials, error) {
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.Token = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return nil, nil
+	}
+	return &creds, nil
+}
+
+// awsMetadata reads a path of the instance metadata service with an IMDSv2
+// session token
+func awsMetadata(ctx context.Context, path string) ([]byte, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, awsMetadataURL+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	token, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata token request returned %s", resp.Status)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, awsMetadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata %s returned %s", path, resp.Status)
+	}
+	return data, nil
+}
+
+// awsInstanceCredentials returns the temporary credentials of the instance role
+func awsInstanceCredentials(ctx context.Context) (*awsCredentials, error) {
+	const roles = "/meta-data/iam/security-credentials/"
+	data, err := awsMetadata(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	if role == "" {
+		return nil, fmt.Errorf("the instance has no role")
+	}
+	if data, err = awsMetadata(ctx, roles+role); err != nil {
+		return nil, err
+	}
+	var c struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid instance credentials: %v", err)
+	}
+	return &awsCredentials{AccessKey: c.AccessKeyID, SecretKey: c.SecretAccessKey, Token: c.Token, Expires: c.Expiration}, nil
+}
+
+// awsRegion returns the region of the environment or, on EC2, of the instance
+func awsRegion(ctx context.Context) (string, error) {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region, nil
+		}
+	}
+	data, err := awsMetadata(ctx, "/meta-data/placement/region")
+	if err != nil {
+		return "", fmt.Errorf("no region configured and not on EC2: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
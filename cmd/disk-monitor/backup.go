@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// backupPrefix returns where the backups of this host are kept in the bucket
+func backupPrefix(cfg BackupConfig) string {
+	host, _ := os.Hostname()
+	return cfg.Prefix + host + "/"
+}
+
+// uploadBackup uploads the history as gzipped JSON, whatever the backend, and
+// deletes the oldest backups past cfg.Keep. Returns the object name.
+func uploadBackup(ctx context.Context, cfg BackupConfig, hist *history.History, now time.Time) (string, error) {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(hist); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	prefix := backupPrefix(cfg)
+	key := prefix + "history-" + now.UTC().Format("20060102T150405Z") + ".json.gz"
+	if err := client.Put(ctx, key, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to upload backup: %v", err)
+	}
+
+	if cfg.Keep > 0 {
+		objects, err := client.List(ctx, prefix)
+		if err != nil {
+			return key, fmt.Errorf("failed to list old backups: %v", err)
+		}
+		// Names hold the time, so they sort oldest first
+		for len(objects) > cfg.Keep {
+			if err := client.Delete(ctx, objects[0].Key); err != nil {
+				return key, fmt.Errorf("failed to delete old backup: %v", err)
+			}
+			objects = objects[1:]
+		}
+	}
+	return key, nil
+}
+
+// sendScheduledBackup uploads a backup when the configured interval has passed
+// since the last one, of the history load returns. Returns the object name,
+// empty when none was due.
+func sendScheduledBackup(ctx context.Context, load func() (*history.History, error), cfg *Config, now time.Time) (string, error) {
+	if cfg.Backup.Bucket == "" {
+		return "", nil
+	}
+	interval, err := analysis.ParseDuration(cfg.Backup.Interval)
+	if err != nil || interval <= 0 {
+		return "", fmt.Errorf("invalid backup interval %q", cfg.Backup.Interval)
+	}
+
+	state, err := loadScheduleState()
+	if err != nil {
+		return "", err
+	}
+	if !state.LastBackup.IsZero() && now.Sub(state.LastBackup) < interval {
+		return "", nil
+	}
+
+	hist, err := load()
+	if err != nil {
+		return "", err
+	}
+	key, err := uploadBackup(ctx, cfg.Backup, hist, now)
+	if key == "" {
+		return "", err
+	}
+	// A failed cleanup still counts as a backup
+	state.LastBackup = now
+	if err := saveScheduleState(state); err != nil {
+		return key, err
+	}
+	return key, err
+}
+
+// runBackup uploads the history to the configured bucket, lists the backups
+// or restores one into a JSON history file
+func runBackup(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	list := fs.Bool("list", false, "List the backups of this host")
+	restore := fs.String("restore", "", "Download this backup, e.g. history-20240601T120000Z.json.gz")
+	out := fs.String("out", "disk_monitor_history-restored.json", "File the restored history is written to, in the json backend's format")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Backup.Bucket == "" {
+		return fmt.Errorf("no backup bucket configured, set backup.bucket in the config")
+	}
+	prefix := backupPrefix(cfg.Backup)
+
+	switch {
+	case *list:
+		client, err := newS3Client(cfg.Backup)
+		if err != nil {
+			return err
+		}
+		objects, err := client.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			fmt.Printf("No backups in %s/%s\n", cfg.Backup.Bucket, prefix)
+			return nil
+		}
+		for _, o := range objects {
+			fmt.Printf("%-44s %10s  %s\n", strings.TrimPrefix(o.Key, prefix),
+				diskinfo.FormatBytes(uint64(o.Size)), locale.DateTime(o.LastModified.In(zone)))
+		}
+		return nil
+
+	case *restore != "":
+		client, err := newS3Client(cfg.Backup)
+		if err != nil {
+			return err
+		}
+		key := *restore
+		if !strings.Contains(key, "/") {
+			key = prefix + key
+		}
+		data, err := client.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("invalid backup: %v", err)
+		}
+		raw, err := io.ReadAll(zr)
+		if err != nil {
+			return fmt.Errorf("invalid backup: %v", err)
+		}
+		var hist history.History
+		if err := json.Unmarshal(raw, &hist); err != nil {
+			return fmt.Errorf("invalid backup: %v", err)
+		}
+		if _, err := os.Stat(*out); err == nil {
+			return fmt.Errorf("%s already exists", *out)
+		}
+		if err := history.Save(*out, &hist); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %d snapshots to %s\n", len(hist.Snapshots), *out)
+		fmt.Printf("Use it with \"convert -from json -in %s\", or as storage.path of the json backend\n", *out)
+		return nil
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+	key, err := uploadBackup(ctx, cfg.Backup, hist, time.Now())
+	if key != "" {
+		fmt.Printf("Backed up %d snapshots to %s/%s\n", len(hist.Snapshots), cfg.Backup.Bucket, key)
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// saveBaselines replaces the baselines in the configured store
+func saveBaselines(ctx context.Context, zone *time.Location, baselines []history.Baseline) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.SaveBaselines(ctx, baselines)
+}
+
+// runBaseline manages named baselines
+func runBaseline(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("baseline", flag.ExitOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: baseline save <name> | baseline list | baseline delete <name>")
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	switch positional[0] {
+	case "save":
+		if len(positional) != 2 {
+			return fmt.Errorf("usage: baseline save <name>")
+		}
+		if len(hist.Snapshots) == 0 {
+			return fmt.Errorf("no snapshots yet, run collect first")
+		}
+		name := positional[1]
+		latest := hist.Snapshots[len(hist.Snapshots)-1]
+
+		// Saving an existing name moves it to the latest snapshot
+		replaced := false
+		for i := range hist.Baselines {
+			if hist.Baselines[i].Name == name {
+				hist.Baselines[i].Timestamp = latest.Timestamp
+				replaced = true
+			}
+		}
+		if !replaced {
+			hist.Baselines = append(hist.Baselines, history.Baseline{Name: name, Timestamp: latest.Timestamp})
+		}
+
+		if err := saveBaselines(ctx, zone, hist.Baselines); err != nil {
+			return err
+		}
+		fmt.Printf("Baseline %q saved at %s\n", name, locale.Timestamp(latest.Timestamp))
+
+	case "list":
+		if len(hist.Baselines) == 0 {
+			fmt.Println("No baselines saved")
+		}
+		for _, b := range hist.Baselines {
+			fmt.Printf("%-20s %s\n", b.Name, locale.Timestamp(b.Timestamp))
+		}
+
+	case "delete":
+		if len(positional) != 2 {
+			return fmt.Errorf("usage: baseline delete <name>")
+		}
+		kept := hist.Baselines[:0]
+		for _, b := range hist.Baselines {
+			if b.Name != positional[1] {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == len(hist.Baselines) {
+			return fmt.Errorf("baseline %q not found", positional[1])
+		}
+		hist.Baselines = kept
+		if err := saveBaselines(ctx, zone, hist.Baselines); err != nil {
+			return err
+		}
+		fmt.Printf("Baseline %q deleted\n", positional[1])
+
+	default:
+		return fmt.Errorf("unknown baseline action %q", positional[0])
+	}
+
+	return nil
+}
+
+// runCompare shows the change on every drive since a baseline
+func runCompare(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	name := fs.String("baseline", "", "Name of the baseline to compare against")
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("-baseline is required")
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	b, base, err := hist.FindBaseline(*name)
+	if err != nil {
+		return err
+	}
+	latest := hist.Snapshots[len(hist.Snapshots)-1]
+
+	fmt.Printf("Changes since baseline %q (%s):\n\n", b.Name, locale.Timestamp(b.Timestamp))
+	selected := make(map[string]bool)
+	for _, d := range selectDrives(hist, drives) {
+		selected[d] = true
+	}
+
+	for _, disk := range latest.Disks {
+		if !selected[disk.Drive] {
+			continue
+		}
+		delta, ok := history.BaselineDelta(base, disk)
+		if !ok {
+			fmt.Printf("Drive %s: not in baseline\n", disk.Drive)
+			continue
+		}
+		fmt.Printf("Drive %s:  Free: %s  Change: %s\n", disk.Drive, diskinfo.FormatBytes(disk.FreeSpace), diskinfo.FormatChange(delta))
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// benchChange formats how a result compares with an earlier one, e.g. "-12.5%"
+func benchChange(now, before float64) string {
+	if before <= 0 {
+		return ""
+	}
+	return locale.Signed((now-before)/before*100, 1) + "%"
+}
+
+// printBenchmark prints one result, compared with the first recorded run if given
+func printBenchmark(b diskinfo.Benchmark, first *history.BenchPoint) {
+	rows := []struct {
+		name      string
+		now, then float64
+		format    func(float64) string
+	}{
+		{"Seq read", b.SeqRead, 0, diskinfo.FormatThroughput},
+		{"Seq write", b.SeqWrite, 0, diskinfo.FormatThroughput},
+		{"4K read", b.RandRead, 0, diskinfo.FormatIOPS},
+		{"4K write", b.RandWrite, 0, diskinfo.FormatIOPS},
+	}
+	if first != nil {
+		rows[0].then, rows[1].then = first.SeqRead, first.SeqWrite
+		rows[2].then, rows[3].then = first.RandRead, first.RandWrite
+	}
+	for _, r := range rows {
+		fmt.Printf("  %-10s %14s", r.name+":", r.format(r.now))
+		if change := benchChange(r.now, r.then); change != "" {
+			fmt.Printf("  %s since %s", change, locale.Date(first.Time))
+		}
+		fmt.Println()
+	}
+}
+
+// runBench benchmarks a drive with a temporary file and saves the result to
+// history, so a drive getting slower shows up next to it filling up
+func runBench(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	size := fs.String("size", cfg.Bench.Size, "Size of the test file, e.g. 1GB")
+	duration := fs.String("duration", cfg.Bench.Duration, "How long each random test runs, e.g. 5s")
+	list := fs.Bool("history", false, "List the recorded results instead of running a benchmark")
+	args, err = parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: bench [-size 1GB] [-duration 5s] [-history] <drive>")
+	}
+	drive := normalizeDrive(args[0])
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+	points := hist.BenchmarkSeries(drive)
+	if *list {
+		if len(points) == 0 {
+			fmt.Printf("No benchmarks recorded for %s.\n", drive)
+			return nil
+		}
+		fmt.Printf("%-16s %10s %14s %14s %12s %12s\n", "Time", "File", "Seq read", "Seq write", "4K read", "4K write")
+		for _, p := range points {
+			fmt.Printf("%-16s %10s %14s %14s %12s %12s\n", locale.DateTime(p.Time), diskinfo.FormatBytes(p.Size),
+				diskinfo.FormatThroughput(p.SeqRead), diskinfo.FormatThroughput(p.SeqWrite),
+				diskinfo.FormatIOPS(p.RandRead), diskinfo.FormatIOPS(p.RandWrite))
+		}
+		return nil
+	}
+
+	opts := diskinfo.BenchOptions{}
+	if opts.Size, err = diskinfo.ParseSize(*size); err != nil {
+		return fmt.Errorf("invalid -size %q", *size)
+	}
+	if opts.Duration, err = time.ParseDuration(*duration); err != nil {
+		return fmt.Errorf("invalid -duration %q", *duration)
+	}
+
+	// The reading is taken before the test file takes up space
+	info, err := diskinfo.QueryDrive(ctx, drive)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Benchmarking %s with a %s test file...\n", drive, diskinfo.FormatBytes(opts.Size))
+	b, err := diskinfo.RunBenchmark(ctx, drive, opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	snapshot := history.Snapshot{
+		Timestamp:  time.Now(),
+		Disks:      []diskinfo.DiskInfo{*info},
+		Benchmarks: []diskinfo.Benchmark{*b},
+		Host:       history.LocalHost(),
+	}
+	if err := store.Append(ctx, snapshot); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s:\n", drive)
+	var first *history.BenchPoint
+	if len(points) > 0 {
+		first = &points[0]
+	}
+	printBenchmark(*b, first)
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/valsaven/disk-monitor/internal/tui"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// scanBrowser is a Bubble Tea model for browsing a scan result as a tree
+type scanBrowser struct {
+	result *ScanResult
+	// stack holds the path from the root to the current directory
+	stack   []*DirNode
+	cursors []int
+	width   int
+	height  int
+	// treemap shows the directory as nested blocks instead of a list
+	treemap bool
+}
+
+// newScanBrowser creates a browser positioned at the scan root
+func newScanBrowser(result *ScanResult) scanBrowser {
+	return scanBrowser{
+		result:  result,
+		stack:   []*DirNode{result.Tree},
+		cursors: []int{0},
+	}
+}
+
+// Init initializes the browser
+func (b scanBrowser) Init() tea.Cmd {
+	return tea.WindowSize()
+}
+
+// current returns the directory being shown
+func (b scanBrowser) current() *DirNode {
+	return b.stack[len(b.stack)-1]
+}
+
+// currentPath returns the full path of the directory being shown
+func (b scanBrowser) currentPath() string {
+	path := b.result.Root
+	for _, node := range b.stack[1:] {
+		path = filepath.Join(path, node.Name)
+	}
+	return path
+}
+
+// Update handles key presses
+func (b scanBrowser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width = msg.Width
+		b.height = msg.Height
+	case tea.KeyMsg:
+		cursor := &b.cursors[len(b.cursors)-1]
+		children := b.current().Children
+
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return b, tea.Quit
+		case "up", "k":
+			if *cursor > 0 {
+				*cursor--
+			}
+		case "down", "j":
+			if *cursor < len(children)-1 {
+				*cursor++
+			}
+		case "enter", "right", "l":
+			if *cursor < len(children) && len(children[*cursor].Children) > 0 {
+				b.stack = append(b.stack, children[*cursor])
+				b.cursors = append(b.cursors, 0)
+			}
+		case "backspace", "left", "h":
+			if len(b.stack) > 1 {
+				b.stack = b.stack[:len(b.stack)-1]
+				b.cursors = b.cursors[:len(b.cursors)-1]
+			}
+		case "t":
+			b.treemap = !b.treemap
+		}
+	}
+
+	return b, nil
+}
+
+// View renders the current directory listing
+func (b scanBrowser) View() string {
+	var s strings.Builder
+
+	s.WriteString(tui.TitleStyle.Render("Directory usage"))
+	s.WriteString("\n\n")
+
+	node := b.current()
+	s.WriteString(tui.HeaderStyle.Render(fmt.Sprintf("%s  %s", b.currentPath(), diskinfo.FormatBytes(node.Size))))
+	s.WriteString("\n")
+	s.WriteString(tui.HelpStyle.Render(fmt.Sprintf("Scanned %s", locale.Timestamp(b.result.Timestamp))))
+	s.WriteString("\n\n")
+
+	if len(node.Children) == 0 {
+		s.WriteString("No subdirectories stored for this directory.\n")
+	}
+
+	cursor := b.cursors[len(b.cursors)-1]
+	if b.treemap && len(node.Children) > 0 {
+		s.WriteString(renderTreemap(node, b.width, max(b.height-9, 5), cursor))
+		if cursor < len(node.Children) {
+			child := node.Children[cursor]
+			s.WriteString(fmt.Sprintf("\n%s  %s (%s)\n", child.Name, diskinfo.FormatBytes(child.Size), locale.Percent(percentOf(child.Size, node.Size), 1)))
+		}
+		s.WriteString(tui.HelpStyle.Render("↑↓: select • enter/→: open • backspace/←: up • t: list • q: quit"))
+		return s.String()
+	}
+
+	// Keep the cursor visible on small terminals
+	visible := b.height - 10
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if cursor >= visible {
+		start = cursor - visible + 1
+	}
+
+	const barWidth = 20
+	for i := start; i < len(node.Children) && i < start+visible; i++ {
+		child := node.Children[i]
+		percent := percentOf(child.Size, node.Size)
+		filled := int(percent / 100 * barWidth)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+
+		name := child.Name
+		if len(child.Children) > 0 {
+			name += string(filepath.Separator)
+		}
+		line := fmt.Sprintf("%10s %7s %s %s", diskinfo.FormatBytes(child.Size), locale.Percent(percent, 1),
+			lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Render(bar), name)
+
+		if i == cursor {
+			s.WriteString(tui.SelectedStyle.Render(line))
+		} else {
+			s.WriteString(line)
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	s.WriteString(tui.HelpStyle.Render("↑↓: select • enter/→: open • backspace/←: up • t: treemap • q: quit"))
+
+	return s.String()
+}
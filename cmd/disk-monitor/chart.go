@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// Chart image formats
+const (
+	chartPNG = "png"
+	chartSVG = "svg"
+)
+
+// chartColors are the line colors of successive drives, starting with the TUI accent color
+var chartColors = []drawing.Color{
+	drawing.ColorFromHex("7D56F4"),
+	drawing.ColorFromHex("04B575"),
+	drawing.ColorFromHex("FF6B6B"),
+	drawing.ColorFromHex("FFB347"),
+	drawing.ColorFromHex("4A90D9"),
+}
+
+// chartSeries is the history of one drive to plot
+type chartSeries struct {
+	Drive  string
+	Points []history.Point
+}
+
+// renderChart draws the free space of each drive over time as a PNG or SVG image
+func renderChart(w io.Writer, series []chartSeries, format string, width, height int) error {
+	graph := chart.Chart{
+		Width:  width,
+		Height: height,
+		Background: chart.Style{
+			Padding: chart.Box{Top: 20, Left: 20, Right: 20, Bottom: 20},
+		},
+		XAxis: chart.XAxis{
+			ValueFormatter: chart.TimeDateValueFormatter,
+		},
+		YAxis: chart.YAxis{
+			Name: "Free space",
+			ValueFormatter: func(v interface{}) string {
+				if f, ok := v.(float64); ok && f > 0 {
+					return diskinfo.FormatBytes(uint64(f))
+				}
+				return "0"
+			},
+		},
+	}
+
+	for i, s := range series {
+		if len(s.Points) < 2 {
+			continue
+		}
+
+		ts := chart.TimeSeries{
+			Name: s.Drive,
+			Style: chart.Style{
+				StrokeColor: chartColors[i%len(chartColors)],
+				StrokeWidth: 2,
+			},
+		}
+		for _, p := range s.Points {
+			ts.XValues = append(ts.XValues, p.Time)
+			ts.YValues = append(ts.YValues, float64(p.Free))
+		}
+		graph.Series = append(graph.Series, ts)
+	}
+	if len(graph.Series) == 0 {
+		return fmt.Errorf("not enough data to draw a chart")
+	}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+
+	switch format {
+	case chartPNG:
+		return graph.Render(chart.PNG, w)
+	case chartSVG:
+		return graph.Render(chart.SVG, w)
+	}
+	return fmt.Errorf("unknown chart format %q", format)
+}
+
+// runChart renders the history of one or more drives to an image file
+func runChart(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("chart", flag.ExitOnError)
+	since := fs.String("since", "", "Only plot this much recent history, e.g. 30d (default all)")
+	out := fs.String("out", "", "Image file to write, .png or .svg")
+	format := fs.String("format", "", "Image format: png or svg (default from the file extension)")
+	width := fs.Int("width", 1200, "Image width in pixels")
+	height := fs.Int("height", 600, "Image height in pixels")
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("usage: chart [-since 30d] -out chart.png [drive...]")
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+	}
+	if *format != chartPNG && *format != chartSVG {
+		return fmt.Errorf("unknown chart format %q, use png or svg", *format)
+	}
+
+	var from time.Time
+	if *since != "" {
+		window, err := analysis.ParseDuration(*since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %v", err)
+		}
+		from = time.Now().Add(-window)
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	var series []chartSeries
+	for _, drive := range selectDrives(hist, drives) {
+		series = append(series, chartSeries{Drive: drive, Points: hist.Series(drive, from)})
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create chart: %v", err)
+	}
+	if err := renderChart(f, series, *format, *width, *height); err != nil {
+		f.Close()
+		os.Remove(*out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Chart written to %s\n", *out)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Plugin states of Nagios and Icinga, the exit code of check
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// checkStates names the plugin states in the output
+var checkStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// exitStatus is returned by commands that end with an exit code of their own
+// rather than 1 for a failure
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// worseState returns the more severe of two plugin states. UNKNOWN is less
+// severe than WARNING and CRITICAL, so a full drive isn't hidden by another
+// drive that didn't answer.
+func worseState(a, b int) int {
+	rank := func(s int) int {
+		if s == checkUnknown {
+			return 1
+		}
+		if s == checkOK {
+			return 0
+		}
+		return s + 1
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// perfLabel quotes a perfdata label when needed
+func perfLabel(label string) string {
+	if strings.ContainsAny(label, " '=") {
+		return "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	return label
+}
+
+// perfGB formats bytes as gigabytes for perfdata, binary ones unless the
+// units are decimal. Perfdata has no GiB unit.
+func perfGB(bytes float64) string {
+	return fmt.Sprintf("%.2f", bytes/diskinfo.Gigabyte())
+}
+
+// runCheck queries the drives like a Nagios or Icinga plugin: one status line
+// with perfdata, the state of the fullest drive as exit code
+func runCheck(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	warning := fs.Float64("warning", 80, "Used percent at which a drive is WARNING")
+	critical := fs.Float64("critical", 90, "Used percent at which a drive is CRITICAL")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("DISK UNKNOWN - %v\n", err)
+		return exitStatus(checkUnknown)
+	}
+	if *warning > *critical {
+		fmt.Printf("DISK UNKNOWN - warning %s is above critical %s\n", locale.Percent(*warning, 0), locale.Percent(*critical, 0))
+		return exitStatus(checkUnknown)
+	}
+
+	disks, errs := diskinfo.CollectAll(ctx, cfg.Collection.Workers, cfg.Paths)
+	if len(cfg.Cloud) > 0 {
+		cloud, cloudErrs := diskinfo.CollectCloud(ctx, cfg.Cloud)
+		disks = append(disks, cloud...)
+		errs = append(errs, cloudErrs...)
+	}
+
+	// Named drives must answer, otherwise the drives that do are checked
+	wanted, missing := make(map[string]bool), make(map[string]bool)
+	for _, name := range names {
+		wanted[normalizeDrive(name)] = true
+		missing[normalizeDrive(name)] = true
+	}
+	state := checkOK
+	var summary, perfdata, details []string
+	for _, disk := range disks {
+		if len(wanted) > 0 && !wanted[disk.Drive] {
+			continue
+		}
+		delete(missing, disk.Drive)
+		label := strings.TrimSuffix(disk.Drive, `\`)
+		if disk.TotalSpace == 0 {
+			continue
+		}
+		used := float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+		driveState := checkOK
+		switch {
+		case used >= *critical:
+			driveState = checkCritical
+		case used >= *warning:
+			driveState = checkWarning
+		}
+		state = worseState(state, driveState)
+
+		text := fmt.Sprintf("%s %s used (%s free)", label, locale.Percent(used, 0), diskinfo.FormatBytes(disk.FreeSpace))
+		if driveState != checkOK {
+			text = fmt.Sprintf("%s %s", text, checkStates[driveState])
+		}
+		summary = append(summary, text)
+		total := float64(disk.TotalSpace)
+		perfdata = append(perfdata, fmt.Sprintf("%s=%sGB;%s;%s;0;%s", perfLabel(driveKey(disk.Drive)+"_used"),
+			perfGB(float64(disk.UsedSpace)), perfGB(total**warning/100), perfGB(total**critical/100), perfGB(total)))
+	}
+	for _, err := range errs {
+		var de *diskinfo.DriveError
+		if !errors.As(err, &de) || (len(wanted) > 0 && !wanted[de.Drive]) {
+			continue
+		}
+		delete(missing, de.Drive)
+		if len(wanted) > 0 {
+			state = worseState(state, checkUnknown)
+		}
+		details = append(details, err.Error())
+	}
+	for _, drive := range slices.Sorted(maps.Keys(missing)) {
+		state = worseState(state, checkUnknown)
+		details = append(details, drive+" not found")
+	}
+	if len(summary) == 0 {
+		state = worseState(state, checkUnknown)
+		summary = append(summary, "no drives answered")
+	}
+
+	line := fmt.Sprintf("DISK %s - %s", checkStates[state], strings.Join(summary, ", "))
+	if len(perfdata) > 0 {
+		line += " | " + strings.Join(perfdata, " ")
+	}
+	fmt.Println(line)
+	// Further lines are the plugin's long output
+	for _, d := range details {
+		fmt.Println(d)
+	}
+	if state != checkOK {
+		return exitStatus(state)
+	}
+	return nil
+}
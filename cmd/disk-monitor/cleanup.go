@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// getCleanupLogPath returns the path of the deletion log
+func getCleanupLogPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_cleanup.log")
+}
+
+// logCleanup appends a deleted path to the deletion log
+func logCleanup(action, path string, size uint64) error {
+	f, err := os.OpenFile(getCleanupLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cleanup log: %v", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%d\t%s\n", time.Now().Format(time.RFC3339), action, size, path)
+	return err
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// moveToRecycleBin deletes a file or directory so that it can be restored from the Recycle Bin
+func moveToRecycleBin(path string, size uint64, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("Would move %s (%s) to the Recycle Bin\n", path, diskinfo.FormatBytes(size))
+		return nil
+	}
+
+	if err := recycle(path); err != nil {
+		return err
+	}
+
+	return logCleanup("recycle", path, size)
+}
+
+// emptyRecycleBin permanently deletes the contents of a drive's Recycle Bin
+func emptyRecycleBin(drive string, size uint64, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("Would empty the Recycle Bin on %s (%s)\n", drive, diskinfo.FormatBytes(size))
+		return nil
+	}
+
+	if err := emptyBin(drive); err != nil {
+		return err
+	}
+
+	return logCleanup("empty-recycle-bin", drive, size)
+}
+
+// clearTempFiles permanently deletes files older than minAge below dir.
+// Files that are in use can't be deleted and are counted as errors.
+func clearTempFiles(dir string, minAge time.Duration, dryRun bool) (freed uint64, errors int) {
+	cutoff := time.Now().Add(-minAge)
+
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errors++
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			errors++
+			return nil
+		}
+		if minAge > 0 && !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		size := uint64(info.Size())
+		if dryRun {
+			freed += size
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			errors++
+			return nil
+		}
+		freed += size
+		if err := logCleanup("delete", p, size); err != nil {
+			errors++
+		}
+		return nil
+	})
+
+	return freed, errors
+}
+
+// isTempLocation reports whether a reclaim item is a TEMP folder that clearTempFiles may empty
+func isTempLocation(item ReclaimItem) bool {
+	return item.Name == "User TEMP" || item.Name == "Windows TEMP"
+}
+
+// runGuidedCleanup offers to empty Recycle Bins and clear TEMP folders found
+// by the reclaim report, asking before each action
+func runGuidedCleanup(items []ReclaimItem, dryRun bool) {
+	for _, item := range items {
+		if item.Reclaimable == 0 {
+			continue
+		}
+
+		switch {
+		case item.Name == "Recycle Bin":
+			drive := normalizeDrive(filepath.VolumeName(item.Path))
+			if !dryRun && !confirm(fmt.Sprintf("Empty the Recycle Bin on %s (%s)?", drive, diskinfo.FormatBytes(item.Size))) {
+				continue
+			}
+			if err := emptyRecycleBin(drive, item.Size, dryRun); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+
+		case isTempLocation(item):
+			if !dryRun && !confirm(fmt.Sprintf("Delete %s of old files in %s?", diskinfo.FormatBytes(item.Reclaimable), item.Path)) {
+				continue
+			}
+			freed, errors := clearTempFiles(item.Path, item.MinAge, dryRun)
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			fmt.Printf("%s %s from %s", verb, diskinfo.FormatBytes(freed), item.Path)
+			if errors > 0 {
+				fmt.Printf(", %d files skipped", errors)
+			}
+			fmt.Println()
+		}
+	}
+
+	if !dryRun {
+		fmt.Printf("Deletions are logged to %s\n", getCleanupLogPath())
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/internal/clipboard"
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// command is a CLI subcommand
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, zone *time.Location, args []string) error
+}
+
+// commands lists the available subcommands
+var commands = []command{
+	{"collect", "Collect and save current disk data (default)", runCollect},
+	{"daemon", "Collect at a fixed interval until stopped", runDaemon},
+	{"check", "Check drive usage like a Nagios or Icinga plugin, with perfdata", runCheck},
+	{"history", "List recorded snapshots", runHistory},
+	{"tail", "Watch the drives and print each change in free space as it happens", runTail},
+	{"alerts", "List the raised alerts and who was notified, or acknowledge them", runAlerts},
+	{"silence", "Keep a drive's alerts from the sinks for a while, or list silences", runSilence},
+	{"fleet", "Show one row per machine with its fullest drive and alerts", runFleet},
+	{"forecast", "Estimate when each drive will be full", runForecast},
+	{"stats", "Show statistics and growth rates per drive", runStats},
+	{"report", "Summarize history per week or month", runReport},
+	{"chart", "Render the history to a PNG or SVG image", runChart},
+	{"plan", "Show how much capacity each drive needs for the next months", runPlan},
+	{"health", "Show NVMe wear, spare capacity and media errors per disk", runHealth},
+	{"pools", "Show Storage Spaces pool allocation and thin disk growth", runPools},
+	{"topology", "Show which drives live on which physical disks", runTopology},
+	{"bench", "Benchmark a drive and track its speed over time", runBench},
+	{"fragmentation", "Show free space fragmentation per drive and how it changed", runFragmentation},
+	{"onedrive", "Show the OneDrive folders' size on disk and online-only files", runOneDrive},
+	{"patterns", "Show average change by day of week and hour of day", runPatterns},
+	{"baseline", "Save, list or delete named baselines", runBaseline},
+	{"compare", "Show changes since a baseline", runCompare},
+	{"convert", "Copy the history into another storage backend", runConvert},
+	{"import", "Backfill the history from perfmon logs and SMART records", runImport},
+	{"merge", "Combine history files from reinstalls or other machines", runMerge},
+	{"compact", "Delete old snapshots and shrink the history store", runCompact},
+	{"backup", "Back up the history to S3-compatible storage, or restore it", runBackup},
+	{"scan", "Scan a drive or directory and show where the space went", runScan},
+	{"top-files", "List the largest files on a drive or directory", runTopFiles},
+	{"cleanup-candidates", "List large files that haven't been used for months", runCleanupCandidates},
+	{"reclaim", "Show how much space temp folders and caches use", runReclaim},
+	{"profiles", "Show the size of each user profile", runProfiles},
+	{"explain", "Show which directories grew since an earlier scan", runExplain},
+	{"version", "Show the version, build and compiled-in features", runVersion},
+}
+
+// findCommand looks up a subcommand by name
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// printUsage prints the list of subcommands
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: disk-monitor [-graph] [-log-level level] [-log-format text|json] [-log-file path] [-simulate fixture.json] [-utc] [-profile name] [command] [options]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// listFlag is a flag that can be given multiple times
+type listFlag []string
+
+// String returns the values joined by commas
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a value
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseArgs parses flags that may appear before or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// normalizeDrive turns user input like "c", "C:" or "c:\" into "C:\",
+// and a monitored path like \\nas\backups into the name it is stored under
+func normalizeDrive(drive string) string {
+	return diskinfo.NormalizePath(drive)
+}
+
+// driveKey names a drive or path in file names and topics: "C" for C:\, "nas_backups" for \\nas\backups\
+func driveKey(drive string) string {
+	key := strings.Trim(strings.ReplaceAll(drive, ":", ""), `\`)
+	return strings.ReplaceAll(key, `\`, "_")
+}
+
+// Drive selectors that can be given instead of drive names
+const (
+	selectDevDrives  = "dev"
+	selectDataDrives = "data"
+)
+
+// selectDrives returns the requested drives, or all drives in history.
+// "dev" selects the Dev Drives and "data" the other drives.
+func selectDrives(hist *history.History, args []string) []string {
+	if len(args) == 0 {
+		return hist.Drives()
+	}
+
+	drives := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case selectDevDrives, selectDataDrives:
+			dev := strings.EqualFold(arg, selectDevDrives)
+			for _, drive := range hist.Drives() {
+				if hist.DevDrive(drive) == dev {
+					drives = append(drives, drive)
+				}
+			}
+		default:
+			drives = append(drives, normalizeDrive(arg))
+		}
+	}
+	return drives
+}
+
+// runCollect collects and saves current disk data
+func runCollect(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	note := fs.String("note", "", "Free-text note to attach to the snapshot")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be saved and removed")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	_, _, err := collectAndSave(ctx, zone, *note, nil, nil, *dryRun)
+	return err
+}
+
+// runHistory lists recorded snapshots
+func runHistory(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	last := fs.Int("last", 20, "Number of most recent snapshots to show (0 = all)")
+	where := fs.String("filter", "", "Only show the drives meeting a condition, e.g. \"used_pct > 80 && drive == 'C:\\'\"")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	var f *filter
+	if *where != "" {
+		var err error
+		if f, err = parseFilter(*where); err != nil {
+			return fmt.Errorf("invalid -filter: %v", err)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	snapshots := hist.Snapshots
+	if f != nil {
+		snapshots = filterDrives(hist, f, cfg.Forecast)
+	}
+	if *last > 0 && len(snapshots) > *last {
+		snapshots = snapshots[len(snapshots)-*last:]
+	}
+
+	for _, snapshot := range snapshots {
+		fmt.Printf("%s ", locale.Timestamp(snapshot.Timestamp))
+		for _, disk := range snapshot.Disks {
+			fmt.Printf(" %s %s free", disk.Drive, diskinfo.FormatBytes(disk.FreeSpace))
+		}
+		// Imported health readings come without drives
+		if len(snapshot.Disks) == 0 && len(snapshot.Health) > 0 {
+			fmt.Printf(" health of %d disks", len(snapshot.Health))
+		}
+		if snapshot.Note != "" {
+			fmt.Printf("  # %s", snapshot.Note)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// filterDrives returns the snapshots of hist with only the drives meeting f,
+// leaving out those without any. forecast fits days_until_full.
+func filterDrives(hist *history.History, f *filter, forecast analysis.ForecastConfig) []history.Snapshot {
+	var result []history.Snapshot
+	for i := range hist.Snapshots {
+		s := &hist.Snapshots[i]
+		var disks []diskinfo.DiskInfo
+		for _, disk := range s.Disks {
+			if f.match(&filterRow{snapshot: s, disk: disk, hist: hist, forecast: forecast}) {
+				disks = append(disks, disk)
+			}
+		}
+		if len(disks) > 0 {
+			matched := *s
+			matched.Disks = disks
+			result = append(result, matched)
+		}
+	}
+	return result
+}
+
+// runForecast prints days-until-full estimates
+func runForecast(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("forecast", flag.ExitOnError)
+	fs.StringVar(&cfg.Forecast.Window, "window", cfg.Forecast.Window, "History window to fit, e.g. 30d")
+	fs.StringVar(&cfg.Forecast.Model, "model", cfg.Forecast.Model, "Regression model: linear or exp")
+	fs.StringVar(&cfg.Forecast.Reserve, "reserve", cfg.Forecast.Reserve, "Free space at which a drive counts as full, e.g. 10GB")
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// Only the fitted window is read
+	window, err := analysis.ParseDuration(cfg.Forecast.Window)
+	if err != nil {
+		return fmt.Errorf("invalid forecast window: %v", err)
+	}
+	now := time.Now()
+	hist, err := loadHistorySince(ctx, zone, now.Add(-window))
+	if err != nil {
+		return err
+	}
+
+	for _, drive := range selectDrives(hist, drives) {
+		f, err := analysis.ForecastDrive(hist, drive, cfg.Forecast, now)
+		if err != nil {
+			fmt.Printf("Drive %s: %v\n\n", drive, err)
+			continue
+		}
+
+		fmt.Printf("Drive %s (%s, %s samples over %s days):\n",
+			drive, f.Model, locale.Int(f.Samples), locale.Float(f.Span.Hours()/24, 1))
+		fmt.Printf("  Free:      %s\n", diskinfo.FormatBytes(f.FreeSpace))
+		fmt.Printf("  Trend:     %s\n", analysis.FormatRate(f.Rate))
+		fmt.Printf("  Full:      %s\n", analysis.FormatForecast(f, now))
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// runStats prints statistics and growth rates per drive
+func runStats(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	copyOut := fs.Bool("clipboard", false, "Also copy the output to the clipboard")
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	now := time.Now()
+	for _, drive := range selectDrives(hist, drives) {
+		st, err := analysis.ComputeStats(hist, drive, now)
+		if err != nil {
+			fmt.Fprintf(&out, "Drive %s: %v\n\n", drive, err)
+			continue
+		}
+		analysis.WriteStats(&out, st)
+		fmt.Fprintln(&out)
+	}
+
+	fmt.Print(out.String())
+	if *copyOut {
+		return clipboard.Copy(out.String())
+	}
+	return nil
+}
+
+// runPatterns prints free space change aggregated by weekday and hour
+func runPatterns(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("patterns", flag.ExitOnError)
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	for _, drive := range selectDrives(hist, drives) {
+		analysis.WritePatterns(os.Stdout, drive, analysis.AnalyzePatterns(hist.Series(drive, time.Time{})))
+		fmt.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// ComponentStoreInfo describes the size of the Windows component store (WinSxS)
+type ComponentStoreInfo struct {
+	Path string
+	// Source is "dism" when reported by DISM, "heuristic" when estimated by walking WinSxS
+	Source string
+	// ReportedSize is what Explorer shows, counting hard-linked files in full
+	ReportedSize uint64
+	// ActualSize counts files shared with Windows only once
+	ActualSize uint64
+	// SharedWithWindows is hard-linked into the Windows directory and not reclaimable
+	SharedWithWindows uint64
+	// Reclaimable is backups, disabled features and cache that cleanup could remove
+	Reclaimable        uint64
+	CleanupRecommended bool
+}
+
+// getComponentStoreInfo reports the component store size, using DISM when possible
+func getComponentStoreInfo(ctx context.Context) (*ComponentStoreInfo, error) {
+	path := filepath.Join(os.Getenv("SystemRoot"), "WinSxS")
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	// DISM needs an elevated prompt, fall back to walking the folder
+	if info, err := analyzeComponentStoreDism(ctx); err == nil {
+		info.Path = path
+		return info, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return estimateComponentStore(path), nil
+}
+
+// analyzeComponentStoreDism runs DISM's component store analysis and parses its report
+func analyzeComponentStoreDism(ctx context.Context) (*ComponentStoreInfo, error) {
+	out, err := exec.CommandContext(ctx, "dism.exe", "/Online", "/Cleanup-Image", "/AnalyzeComponentStore", "/English").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ComponentStoreInfo{Source: "dism"}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Windows Explorer Reported Size of Component Store":
+			info.ReportedSize = parseDismSize(value)
+		case "Actual Size of Component Store":
+			info.ActualSize = parseDismSize(value)
+		case "Shared with Windows":
+			info.SharedWithWindows = parseDismSize(value)
+		case "Backups and Disabled Features", "Cache and Temporary Data":
+			info.Reclaimable += parseDismSize(value)
+		case "Component Store Cleanup Recommended":
+			info.CleanupRecommended = strings.EqualFold(value, "Yes")
+		}
+	}
+
+	if info.ActualSize == 0 {
+		return nil, os.ErrNotExist
+	}
+	// Only suggest reclaiming when DISM would actually clean up
+	if !info.CleanupRecommended {
+		info.Reclaimable = 0
+	}
+
+	return info, nil
+}
+
+// parseDismSize parses sizes like "7.89 GB" or "189.25 MB"
+func parseDismSize(s string) uint64 {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	size, err := diskinfo.ParseSize("1" + fields[1])
+	if err != nil {
+		return 0
+	}
+	return uint64(n * float64(size))
+}
+
+// estimateComponentStore walks WinSxS and treats hard-linked files as shared with Windows
+func estimateComponentStore(path string) *ComponentStoreInfo {
+	info := &ComponentStoreInfo{Path: path, Source: "heuristic"}
+
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		size := uint64(fi.Size())
+		info.ReportedSize += size
+		if fileLinkCount(p) > 1 {
+			info.SharedWithWindows += size
+		}
+		return nil
+	})
+
+	info.ActualSize = info.ReportedSize
+	return info
+}
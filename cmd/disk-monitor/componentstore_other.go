@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// fileLinkCount can't count hard links, every file counts once
+func fileLinkCount(path string) uint32 {
+	return 1
+}
@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+// fileLinkCount returns the number of hard links to a file, or 1 if unknown
+func fileLinkCount(path string) uint32 {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 1
+	}
+
+	h, err := syscall.CreateFile(pathPtr, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 1
+	}
+	defer syscall.CloseHandle(h)
+
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &data); err != nil {
+		return 1
+	}
+	return data.NumberOfLinks
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// Config holds user settings loaded from the config file
+type Config struct {
+	Forecast   analysis.ForecastConfig `json:"forecast"`
+	Anomaly    analysis.AnomalyConfig  `json:"anomaly"`
+	Alerts     AlertConfig             `json:"alerts"`
+	API        APIConfig               `json:"api"`
+	Backup     BackupConfig            `json:"backup"`
+	Bench      BenchConfig             `json:"bench"`
+	Chart      ChartConfig             `json:"chart"`
+	Collection CollectionConfig        `json:"collection"`
+	Display    DisplayConfig           `json:"display"`
+	Files      FilesConfig             `json:"files"`
+	Health     HealthConfig            `json:"health"`
+	OneDrive   OneDriveConfig          `json:"onedrive"`
+	Pools      PoolConfig              `json:"pools"`
+	ReFS       ReFSConfig              `json:"refs"`
+	Trim       TrimConfig              `json:"trim"`
+	Updates    UpdatesConfig           `json:"updates"`
+	Upload     UploadConfig            `json:"upload"`
+	Reports    ReportsConfig           `json:"reports"`
+	Scan       ScanConfig              `json:"scan"`
+	SMTP       SMTPConfig              `json:"smtp"`
+	Storage    StorageConfig           `json:"storage"`
+	Log        LogConfig               `json:"log"`
+	// Fragmentation reads the free space fragmentation of local volumes
+	Fragmentation FragmentationConfig `json:"fragmentation"`
+	// Paths are directories and UNC shares tracked as their own series next to the drives
+	Paths []string `json:"paths"`
+	// Cloud are cloud storage accounts whose quota is tracked like a drive
+	Cloud []diskinfo.CloudAccount `json:"cloud"`
+	// Sinks are the alert notifiers and metric outputs fed after each collection
+	Sinks []SinkConfig `json:"sinks"`
+	// Profiles are named sets of settings applied over the rest with -profile,
+	// so one config file serves machines with different roles
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+}
+
+// AlertConfig holds the alert rules checked after each collection
+type AlertConfig struct {
+	// UsedPercent fires a threshold alert at this usage, 0 disables it
+	UsedPercent float64 `json:"used_percent"`
+	// CriticalPercent makes the threshold alert critical at this usage, 0 disables it
+	CriticalPercent float64 `json:"critical_percent"`
+	// Anomaly fires an alert when the latest change is abnormal
+	Anomaly bool `json:"anomaly"`
+	// FilesPercent fires a files alert when this share of a volume's file
+	// records is in use, FilesCriticalPercent makes it critical; 0 disables them
+	FilesPercent         float64 `json:"files_percent"`
+	FilesCriticalPercent float64 `json:"files_critical_percent"`
+	// DevDrive replaces the rules above for Dev Drives, nil applies them as they are
+	DevDrive *DevDriveAlertConfig `json:"dev_drive,omitempty"`
+	// Policies route alerts to sinks by severity and how long they have been
+	// firing. Alerts no policy matches go to every sink.
+	Policies []EscalationPolicy `json:"policies,omitempty"`
+	// Maintenance lists recurring windows in which alerts aren't sent
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+	// Repeat reminds the sinks of alerts still firing this often, e.g. "24h".
+	// Empty notifies them once until the alert recovers.
+	Repeat string `json:"repeat,omitempty"`
+	// Rules fire alerts on drives meeting conditions written as filter expressions
+	Rules []AlertRule `json:"rules,omitempty"`
+}
+
+// AlertRule fires an alert for each drive whose latest reading meets a condition
+type AlertRule struct {
+	// Name tells the rule's alerts apart, they are of kind "rule:<name>"
+	Name string `json:"name"`
+	// When is the condition, e.g. "free < 10GB && growth_7d > 1GB/day"
+	When string `json:"when"`
+	// Severity is "warning" (default) or "critical"
+	Severity string `json:"severity,omitempty"`
+}
+
+// repeatInterval parses Repeat, 0 when it is empty
+func (c AlertConfig) repeatInterval() (time.Duration, error) {
+	if c.Repeat == "" {
+		return 0, nil
+	}
+	d, err := analysis.ParseDuration(c.Repeat)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid alerts.repeat %q", c.Repeat)
+	}
+	return d, nil
+}
+
+// DevDriveAlertConfig holds the alert rules of Dev Drives, whose caches and build
+// output fill up and get cleared as a matter of course
+type DevDriveAlertConfig struct {
+	// UsedPercent fires a threshold alert at this usage, 0 disables it
+	UsedPercent float64 `json:"used_percent"`
+	// CriticalPercent makes the threshold alert critical at this usage, 0 disables it
+	CriticalPercent float64 `json:"critical_percent"`
+	// Anomaly fires an alert when the latest change is abnormal
+	Anomaly bool `json:"anomaly"`
+}
+
+// rules returns the threshold and anomaly rule that apply to a drive
+func (c AlertConfig) rules(disk diskinfo.DiskInfo) (usedPercent, criticalPercent float64, anomaly bool) {
+	if disk.DevDrive && c.DevDrive != nil {
+		return c.DevDrive.UsedPercent, c.DevDrive.CriticalPercent, c.DevDrive.Anomaly
+	}
+	return c.UsedPercent, c.CriticalPercent, c.Anomaly
+}
+
+// EscalationPolicy routes the alerts of some drives or hosts through steps
+type EscalationPolicy struct {
+	// Name identifies the policy in error messages
+	Name string `json:"name"`
+	// Drives and Hosts limit the policy to these drives, pools or folders and
+	// machines, empty matches all
+	Drives []string `json:"drives,omitempty"`
+	Hosts  []string `json:"hosts,omitempty"`
+	// Steps each notify their sinks, so a later step adds sinks rather than replacing them
+	Steps []EscalationStep `json:"steps"`
+}
+
+// EscalationStep notifies sinks of alerts of at least a severity that have
+// been firing for a while
+type EscalationStep struct {
+	// Severity is "warning" (default) or "critical"
+	Severity string `json:"severity"`
+	// After is how long the alert has to be firing, e.g. "2h", empty notifies right away
+	After string `json:"after"`
+	// Sinks are the names of the sinks notified, see SinkConfig.Name
+	Sinks []string `json:"sinks"`
+}
+
+// MaintenanceWindow is a weekly period of planned work, in local time, during
+// which alerts are logged but not sent to the sinks
+type MaintenanceWindow struct {
+	// Drives limits the window to these drives, pools or folders, empty matches all
+	Drives []string `json:"drives,omitempty"`
+	// Days are the weekdays the window starts on ("mon" to "sun"), empty is every day
+	Days []string `json:"days,omitempty"`
+	// Start and End are "15:04" times, an End before Start ends the next day
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Reason is logged with the alerts the window silences
+	Reason string `json:"reason"`
+}
+
+// BackupConfig holds the S3-compatible bucket the history is backed up to
+type BackupConfig struct {
+	// Endpoint of the S3 API, e.g. https://s3.eu-central-1.amazonaws.com, a MinIO
+	// server or the S3 endpoint of Backblaze B2
+	Endpoint string `json:"endpoint"`
+	// Region the requests are signed for
+	Region string `json:"region"`
+	// Bucket receives the backups, empty disables them
+	Bucket string `json:"bucket"`
+	// Prefix goes before the object names, <prefix><host>/history-<time>.json.gz
+	Prefix string `json:"prefix"`
+	// AccessKey and SecretKey default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	// Interval is the time between backups made by collections, e.g. "1d"
+	Interval string `json:"interval"`
+	// Keep is how many backups of this host are kept, 0 keeps all of them
+	Keep int `json:"keep"`
+}
+
+// BenchConfig holds the defaults of the bench command
+type BenchConfig struct {
+	// Size is the size of the test file, e.g. "1GB"
+	Size string `json:"size"`
+	// Duration is how long each random test runs, e.g. "5s"
+	Duration string `json:"duration"`
+}
+
+// ChartConfig holds settings for the graph view
+type ChartConfig struct {
+	// Smoothing is a moving average of N points ("5") or a time window ("6h"), empty disables it
+	Smoothing string `json:"smoothing"`
+	// Window is how much recent history the interactive view loads, e.g. "90d", empty loads all of it
+	Window string `json:"window"`
+}
+
+// CollectionConfig holds settings for querying the drives
+type CollectionConfig struct {
+	// Workers is how many drives are queried at once
+	Workers int `json:"workers"`
+	// Timeout is how long each drive query waits, e.g. "2s"
+	Timeout string `json:"timeout"`
+	// Retries is how many more times a drive that timed out or failed is queried
+	Retries int `json:"retries"`
+	// Drives overrides the timeout, retries and daemon interval by drive or monitored path
+	Drives map[string]DriveCollectionConfig `json:"drives,omitempty"`
+	// SessionEvents makes the daemon also collect on logon, unlock and resume from sleep
+	SessionEvents bool `json:"session_events"`
+	// Images is what happens to volumes mounted from ISO, VHD and VHDX files:
+	// include, exclude or group
+	Images string `json:"images,omitempty"`
+}
+
+// DriveCollectionConfig holds the query settings of one drive, unset fields
+// keep those of the collection
+type DriveCollectionConfig struct {
+	Timeout string `json:"timeout,omitempty"`
+	Retries *int   `json:"retries,omitempty"`
+	// Interval is how often the daemon collects the drive, e.g. "1m" or "6h",
+	// instead of every -interval
+	Interval string `json:"interval,omitempty"`
+}
+
+// queryPolicies returns the drive query policy and the exceptions per drive
+func (c CollectionConfig) queryPolicies() (diskinfo.QueryPolicy, map[string]diskinfo.QueryPolicy, error) {
+	var def diskinfo.QueryPolicy
+	if c.Timeout != "" {
+		var err error
+		if def.Timeout, err = time.ParseDuration(c.Timeout); err != nil || def.Timeout <= 0 {
+			return def, nil, fmt.Errorf("invalid collection timeout %q", c.Timeout)
+		}
+	}
+	if c.Retries < 0 {
+		return def, nil, fmt.Errorf("invalid collection retries %d", c.Retries)
+	}
+	def.Retries = c.Retries
+
+	drives := make(map[string]diskinfo.QueryPolicy, len(c.Drives))
+	for drive, dc := range c.Drives {
+		p := def
+		if dc.Timeout != "" {
+			var err error
+			if p.Timeout, err = time.ParseDuration(dc.Timeout); err != nil || p.Timeout <= 0 {
+				return def, nil, fmt.Errorf("invalid timeout %q for drive %s", dc.Timeout, drive)
+			}
+		}
+		if dc.Retries != nil {
+			if *dc.Retries < 0 {
+				return def, nil, fmt.Errorf("invalid retries %d for drive %s", *dc.Retries, drive)
+			}
+			p.Retries = *dc.Retries
+		}
+		drives[drive] = p
+	}
+	return def, drives, nil
+}
+
+// intervals returns the daemon intervals of the drives that have their own,
+// by lowercase normalized drive or path
+func (c CollectionConfig) intervals() (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for drive, dc := range c.Drives {
+		if dc.Interval == "" {
+			continue
+		}
+		d, err := analysis.ParseDuration(dc.Interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q for drive %s", dc.Interval, drive)
+		}
+		intervals[strings.ToLower(diskinfo.NormalizePath(drive))] = d
+	}
+	return intervals, nil
+}
+
+// DisplayConfig holds settings for how results are shown
+type DisplayConfig struct {
+	// TimeZone is local or utc, history is always stored in UTC
+	TimeZone string `json:"time_zone"`
+	// Units is binary (GiB, 1024) or decimal (GB, 1000), history is always in bytes
+	Units string `json:"units"`
+	// Locale formats numbers and dates, e.g. de-DE, auto follows the user's locale
+	Locale string `json:"locale"`
+}
+
+// FragmentationConfig holds settings for free space fragmentation collection
+type FragmentationConfig struct {
+	// Enabled reads the volume bitmap of local drives with each collection,
+	// which takes administrator rights and a while on large volumes
+	Enabled bool `json:"enabled"`
+	// AlertPercent fires a fragmentation alert for drives on spinning disks at this
+	// share of free space outside the largest free extent, 0 disables it
+	AlertPercent float64 `json:"alert_percent"`
+}
+
+// FilesConfig holds settings for counting the file records of NTFS volumes
+type FilesConfig struct {
+	// Enabled reads the size of the MFT of NTFS drives with each collection,
+	// which takes administrator rights
+	Enabled bool `json:"enabled"`
+}
+
+// HealthConfig holds settings for NVMe health collection
+type HealthConfig struct {
+	// Enabled reads the health log of NVMe disks with each collection
+	Enabled bool `json:"enabled"`
+	// The limits fire a health alert when reached
+	diskinfo.HealthLimits
+}
+
+// ReFSConfig holds settings for ReFS volumes
+type ReFSConfig struct {
+	// Savings reads the space deduplication saves on ReFS volumes with each collection
+	Savings bool `json:"savings"`
+}
+
+// OneDriveConfig holds settings for the Files On-Demand state of OneDrive folders
+type OneDriveConfig struct {
+	// Enabled sizes the OneDrive folders with each collection
+	Enabled bool `json:"enabled"`
+	// Folders are the folders to size, empty finds those of the signed in accounts
+	Folders []string `json:"folders,omitempty"`
+	// DownloadAlert fires a OneDrive alert when this much of the online-only files
+	// was downloaded since the previous collection, e.g. "5GB", empty disables it
+	DownloadAlert string `json:"download_alert"`
+}
+
+// TrimConfig holds settings for the TRIM status check
+type TrimConfig struct {
+	// Enabled reads whether each drive sends TRIM and when it was last optimized
+	// with each collection
+	Enabled bool `json:"enabled"`
+}
+
+// UpdatesConfig holds settings for recording Windows update installations
+type UpdatesConfig struct {
+	// Enabled reads the updates installed since the previous collection from the
+	// event log, they are marked in the graph view
+	Enabled bool `json:"enabled"`
+}
+
+// PoolConfig holds settings for Storage Spaces pool collection
+type PoolConfig struct {
+	// Enabled reads the pools with each collection
+	Enabled bool `json:"enabled"`
+	// UsedPercent fires a pool alert at this share of the pool allocated, 0 disables it
+	UsedPercent float64 `json:"used_percent"`
+	// Overcommit fires a pool alert when thin disks can grow past what the pool has free
+	Overcommit bool `json:"overcommit"`
+}
+
+// ReportsConfig holds settings for generated reports
+type ReportsConfig struct {
+	// IncludeProfiles adds the user profile size breakdown to reports
+	IncludeProfiles bool `json:"include_profiles"`
+	// Schedule emails a report after each "weekly" or "monthly" period, empty disables it
+	Schedule string `json:"schedule"`
+	// Format of the emailed report: text, html, markdown or pdf
+	Format string `json:"format"`
+	// To is a comma-separated list of recipients
+	To string `json:"to"`
+	// Last limits emailed reports to the last N periods, 0 for all
+	Last int `json:"last"`
+}
+
+// SMTPConfig holds the mail server used to send reports
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// From is the sender address, defaults to Username
+	From string `json:"from"`
+}
+
+// StorageConfig selects where history is kept
+type StorageConfig struct {
+	// Backend is json, jsonl, sqlite or bolt
+	Backend string `json:"backend"`
+	// Path of the history file, empty for the backend's default in the home directory
+	Path string `json:"path"`
+	// Shard is "month" to keep each month's snapshots in a file of their own
+	// next to Path, empty for one file
+	Shard string `json:"shard"`
+	// RemovedDrives is keep, archive or purge: what happens to the series of a
+	// drive that hasn't been attached for RemovedAfter
+	RemovedDrives string `json:"removed_drives"`
+	RemovedAfter  string `json:"removed_after"`
+	// MaxSize and MaxSnapshots cap the history, e.g. "50MB" and 100000. Past
+	// either, older snapshots are thinned out and then the oldest pruned.
+	MaxSize      string `json:"max_size"`
+	MaxSnapshots int    `json:"max_snapshots"`
+}
+
+// APIConfig holds the daemon's HTTP API settings
+type APIConfig struct {
+	// Listen is the address served, e.g. "127.0.0.1:8787", empty disables the API
+	Listen string `json:"listen"`
+	// Token authenticates requests, sent as "Authorization: Bearer <token>"
+	Token string `json:"token"`
+}
+
+// UploadConfig makes this machine an agent that sends its snapshots to the
+// daemon of another machine, see uploadSnapshots
+type UploadConfig struct {
+	// URL is the other daemon's API, e.g. "http://monitor:8787", empty disables uploads
+	URL string `json:"url"`
+	// Token is the other daemon's api.token
+	Token string `json:"token"`
+	// Batch is the most snapshots sent in one request, 0 means 100
+	Batch int `json:"batch"`
+	// Timeout of each request, "30s" when empty
+	Timeout string `json:"timeout"`
+}
+
+// LogConfig holds the diagnostic log settings
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// Format is text or json
+	Format string `json:"format"`
+	// File receives the log instead of stderr
+	File string `json:"file"`
+	// MaxSize rotates the file when it grows past this size, e.g. "10MB", empty never rotates
+	MaxSize string `json:"max_size"`
+	// MaxFiles is how many rotated files are kept
+	MaxFiles int `json:"max_files"`
+}
+
+// ScanConfig holds settings for the directory scanner and file finders
+type ScanConfig struct {
+	// Exclude lists globs matched against names and paths, or regular expressions prefixed with "re:"
+	Exclude []string `json:"exclude"`
+	// DefaultExcludes skips pagefile.sys, System Volume Information and the like
+	DefaultExcludes bool `json:"default_excludes"`
+	// SkipReparsePoints doesn't descend into junctions and symlinks, to avoid counting data twice
+	SkipReparsePoints bool `json:"skip_reparse_points"`
+}
+
+// defaultConfig returns the settings used when no config file exists
+func defaultConfig() *Config {
+	return &Config{
+		Forecast: analysis.ForecastConfig{
+			Window:  "30d",
+			Model:   analysis.ModelLinear,
+			Reserve: "0",
+		},
+		Anomaly: analysis.AnomalyConfig{
+			Window:      20,
+			Sensitivity: 5,
+			MinChange:   "1GB",
+		},
+		Collection: CollectionConfig{
+			Workers: diskinfo.DefaultWorkers,
+			Timeout: diskinfo.QueryTimeout.String(),
+		},
+		Backup: BackupConfig{
+			Region:   "us-east-1",
+			Prefix:   "disk-monitor/",
+			Interval: "1d",
+			Keep:     14,
+		},
+		Bench: BenchConfig{
+			Size:     "1GB",
+			Duration: "5s",
+		},
+		Chart: ChartConfig{
+			Window: "90d",
+		},
+		Display: DisplayConfig{
+			TimeZone: timeZoneLocal,
+			Units:    string(diskinfo.UnitsBinary),
+			Locale:   "auto",
+		},
+		Fragmentation: FragmentationConfig{
+			AlertPercent: 80,
+		},
+		Health: HealthConfig{
+			Enabled: true,
+			HealthLimits: diskinfo.HealthLimits{
+				PercentageUsed: 90,
+				MediaErrors:    1,
+			},
+		},
+		OneDrive: OneDriveConfig{
+			Enabled:       true,
+			DownloadAlert: "5GB",
+		},
+		Pools: PoolConfig{
+			Enabled:     true,
+			UsedPercent: 85,
+			Overcommit:  true,
+		},
+		ReFS: ReFSConfig{
+			Savings: true,
+		},
+		Trim: TrimConfig{
+			Enabled: true,
+		},
+		Updates: UpdatesConfig{
+			Enabled: true,
+		},
+		Reports: ReportsConfig{
+			Format: formatText,
+			Last:   8,
+		},
+		Scan: ScanConfig{
+			DefaultExcludes:   true,
+			SkipReparsePoints: true,
+		},
+		SMTP: SMTPConfig{
+			Port: 587,
+		},
+		Storage: StorageConfig{
+			Backend:       history.BackendJSON,
+			RemovedDrives: removedKeep,
+			RemovedAfter:  "30d",
+		},
+		Log: LogConfig{
+			Level:    "info",
+			Format:   logFormatText,
+			MaxSize:  "10MB",
+			MaxFiles: 3,
+		},
+		Sinks: []SinkConfig{
+			{Type: "console"},
+		},
+	}
+}
+
+// getConfigFilePath returns path to config file
+func getConfigFilePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_config.json")
+}
+
+// configProfile is the profile selected with -profile, applied by loadConfig
+var configProfile string
+
+// loadConfig loads config from file, falling back to defaults, and applies
+// the selected profile
+func loadConfig() (*Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(getConfigFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Without a file there are no profiles to select
+			return cfg, cfg.applyProfile(configProfile)
+		}
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return defaultConfig(), fmt.Errorf("invalid config file: %v", err)
+	}
+	if err := cfg.applyProfile(configProfile); err != nil {
+		return defaultConfig(), err
+	}
+
+	return cfg, nil
+}
+
+// applyProfile overlays a named profile: the settings it names replace the
+// ones of the file, everything else stays as it is
+func (c *Config) applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	if err := json.Unmarshal(profile, c); err != nil {
+		return fmt.Errorf("invalid profile %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// runDaemon collects disk data at a fixed interval until interrupted.
+// Alerts and scheduled reports are handled by each collection. A drive locked
+// by BitLocker is collected as soon as it is unlocked, without waiting for the
+// next interval, and with session events so is every logon, unlock and resume
+// from sleep. With -listen, POST /api/trigger and the TriggerCollect gRPC
+// call collect right away too, and the snapshots agents upload are stored.
+// Snapshots go to a journal next to the store first, which is folded into the
+// store every -fold, on start and on exit, so a crash or power loss between
+// collections loses nothing and a half-written store is never the only copy.
+func runDaemon(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	intervalFlag := fs.String("interval", "1h", "Time between collections, e.g. 15m or 1h")
+	foldFlag := fs.String("fold", "6h", "How often journaled snapshots are folded into the store, 0 writes each one to the store")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	sessionFlag := fs.Bool("session-events", cfg.Collection.SessionEvents, "Also collect on logon, unlock and resume from sleep")
+	listen := fs.String("listen", cfg.API.Listen, "Address of the HTTP API, e.g. 127.0.0.1:8787, empty disables it")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	interval, err := analysis.ParseDuration(*intervalFlag)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid -interval %q", *intervalFlag)
+	}
+	fold, err := analysis.ParseDuration(*foldFlag)
+	if err != nil || fold < 0 {
+		return fmt.Errorf("invalid -fold %q", *foldFlag)
+	}
+
+	var journal *history.Journal
+	if fold > 0 {
+		journal = history.OpenJournal(journalPath(cfg))
+		// A journal left by a crash goes into the store before anything else
+		foldJournal(ctx, zone, journal)
+		// Folding on exit has to outlive the cancelled context
+		defer foldJournal(context.WithoutCancel(ctx), zone, journal)
+	}
+	lastFold := time.Now()
+
+	var sessions <-chan string
+	if *sessionFlag {
+		if sessions, err = watchSessionEvents(ctx); err != nil {
+			slog.Warn("not collecting on session events", "err", err)
+		}
+	}
+
+	var triggers chan triggerRequest
+	var uploads chan uploadRequest
+	feed := newSnapshotFeed()
+	defer feed.close()
+	if *listen != "" {
+		if cfg.API.Token == "" {
+			return fmt.Errorf("the API needs api.token in the config file")
+		}
+		triggers = make(chan triggerRequest)
+		uploads = make(chan uploadRequest)
+		if err := serveAPI(ctx, *listen, newAPIHandler(cfg.API.Token, zone, triggers, uploads, feed)); err != nil {
+			return fmt.Errorf("failed to start the API: %v", err)
+		}
+		fmt.Printf("API listening on %s\n", *listen)
+	}
+
+	intervals, err := cfg.Collection.intervals()
+	if err != nil {
+		return err
+	}
+	schedule := newDriveSchedule(interval, intervals)
+	ticker := time.NewTicker(schedule.tick)
+	defer ticker.Stop()
+
+	fmt.Printf("Collecting every %s, press Ctrl+C to stop\n", interval)
+	for drive, d := range cfg.Collection.Drives {
+		if d.Interval != "" {
+			fmt.Printf("Collecting %s every %s\n", diskinfo.NormalizePath(drive), d.Interval)
+		}
+	}
+	var trigger *triggerRequest
+	var locked []string
+	// The first collection and those triggered by an event take every drive
+	force := true
+	for {
+		// A tick without a drive that is due collects nothing
+		if drives := schedule.due(diskinfo.Targets(cfg.Paths), time.Now(), force); len(drives) > 0 || force {
+			note := ""
+			if trigger != nil {
+				note = trigger.note
+			}
+			var snapshot history.Snapshot
+			snapshot, locked, err = collectAndSave(ctx, zone, note, drives, journal, false)
+			if err != nil {
+				slog.Error("collection failed", "err", err)
+			}
+			if len(snapshot.Disks) > 0 {
+				feed.publish(snapshot)
+			}
+			if trigger != nil {
+				trigger.result <- triggerResult{snapshot: snapshot, err: err}
+				trigger = nil
+			}
+		}
+		force = false
+		if journal != nil && time.Since(lastFold) >= fold {
+			foldJournal(ctx, zone, journal)
+			lastFold = time.Now()
+		}
+
+		wait, stop := context.WithCancel(ctx)
+		unlocked := waitUnlocked(wait, locked)
+		for {
+			select {
+			case <-ticker.C:
+			case <-unlocked:
+				slog.Info("drive unlocked, collecting")
+				force = true
+			case req := <-triggers:
+				slog.Info("collection triggered over the API")
+				trigger = &req
+				force = true
+			case req := <-uploads:
+				// Uploads are stored between collections without starting one
+				res := storeUploads(ctx, zone, req.snapshots)
+				if res.err != nil {
+					slog.Error("failed to store upload", "err", res.err)
+				} else {
+					slog.Info("snapshots uploaded", "stored", res.stored, "duplicates", res.duplicates)
+				}
+				req.result <- res
+				continue
+			case event := <-sessions:
+				slog.Info("session event, collecting", "event", event)
+				force = true
+				// Drives and shares take a moment to come back after a resume
+				select {
+				case <-time.After(sessionSettle):
+				case <-ctx.Done():
+					stop()
+					return nil
+				}
+			case <-ctx.Done():
+				stop()
+				return nil
+			}
+			break
+		}
+		stop()
+	}
+}
+
+// driveSchedule picks the drives a daemon tick collects, when some have their
+// own collection.drives interval. It ticks at the shortest interval, and a
+// drive is due once its interval has passed, less half a tick so one that took
+// a moment to collect doesn't miss its turn.
+type driveSchedule struct {
+	tick      time.Duration
+	interval  time.Duration
+	intervals map[string]time.Duration
+	// last is when each drive was last collected, by lowercase drive or path
+	last map[string]time.Time
+}
+
+// newDriveSchedule returns the schedule of drives collected every interval,
+// except those in intervals
+func newDriveSchedule(interval time.Duration, intervals map[string]time.Duration) *driveSchedule {
+	s := &driveSchedule{tick: interval, interval: interval, intervals: intervals, last: make(map[string]time.Time)}
+	for _, d := range intervals {
+		s.tick = min(s.tick, d)
+	}
+	return s
+}
+
+// due returns the drives and paths of targets to collect at now, all of them
+// when force is set, and records them as collected
+func (s *driveSchedule) due(targets []string, now time.Time, force bool) []string {
+	var due []string
+	for _, target := range targets {
+		key := strings.ToLower(target)
+		interval, ok := s.intervals[key]
+		if !ok {
+			interval = s.interval
+		}
+		last, seen := s.last[key]
+		if force || !seen || now.Sub(last) >= interval-s.tick/2 {
+			due = append(due, target)
+			s.last[key] = now
+		}
+	}
+	return due
+}
+
+// Session events the daemon collects on
+const (
+	sessionLogon  = "logon"
+	sessionUnlock = "unlock"
+	sessionResume = "resume"
+)
+
+// sessionSettle is how long the daemon waits after a session event before collecting
+const sessionSettle = 10 * time.Second
+
+// unlockPollInterval is how often the daemon checks whether a locked drive was unlocked
+const unlockPollInterval = 30 * time.Second
+
+// waitUnlocked returns a channel that is closed once one of the locked drives
+// answers, nil when there are none
+func waitUnlocked(ctx context.Context, locked []string) <-chan struct{} {
+	if len(locked) == 0 {
+		return nil
+	}
+	unlocked := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(unlockPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			for _, drive := range locked {
+				_, err := diskinfo.QueryDrive(ctx, drive)
+				if err == nil {
+					close(unlocked)
+					return
+				}
+			}
+		}
+	}()
+	return unlocked
+}
+
+// journalPath returns the daemon's journal of the configured store
+func journalPath(cfg *Config) string {
+	return history.JournalPath(historyPath(cfg))
+}
+
+// foldJournal appends the journaled snapshots to the configured store.
+// A failed fold leaves the journal in place for the next one.
+func foldJournal(ctx context.Context, zone *time.Location, journal *history.Journal) {
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("failed to fold journal", "err", err)
+		return
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		slog.Error("failed to fold journal", "err", err)
+		return
+	}
+	defer store.Close()
+
+	n, err := journal.Fold(ctx, store)
+	if err != nil {
+		slog.Error("failed to fold journal", "err", err)
+		return
+	}
+	slog.Debug("journal folded", "snapshots", n, "backend", cfg.Storage.Backend)
+}
+
+// addJournaled adds the snapshots taken since from that are still in the
+// daemon's journal, so commands see them before they are folded. Their times
+// are converted to zone like those of the store.
+func addJournaled(cfg *Config, zone *time.Location, hist *history.History, from time.Time) {
+	snapshots, err := history.OpenJournal(journalPath(cfg)).Snapshots()
+	if err != nil {
+		slog.Warn("failed to read journal", "err", err)
+		return
+	}
+
+	var last time.Time
+	if n := len(hist.Snapshots); n > 0 {
+		last = hist.Snapshots[n-1].Timestamp
+	}
+	// A fold cut short may have stored some of them already
+	localSnapshots(snapshots, zone)
+	for _, snapshot := range snapshots {
+		if snapshot.Timestamp.After(last) && !snapshot.Timestamp.Before(from) {
+			hist.Snapshots = append(hist.Snapshots, snapshot)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailAttachment is a file attached to an email
+type mailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// parseRecipients splits a comma-separated address list
+func parseRecipients(list string) []string {
+	var to []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// buildMessage assembles a MIME message with a plain text body and optional attachments
+func buildMessage(from string, to []string, subject, body string, attachments []mailAttachment) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	// writeBase64 writes a part wrapped at 76 characters per line
+	writeBase64 := func(header textproto.MIMEHeader, data []byte) {
+		header.Set("Content-Transfer-Encoding", "base64")
+		w, _ := mw.CreatePart(header)
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(w, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(w, "%s\r\n", encoded)
+	}
+
+	writeBase64(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}}, []byte(body))
+	for _, a := range attachments {
+		writeBase64(textproto.MIMEHeader{
+			"Content-Type":        {a.ContentType},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		}, a.Data)
+	}
+	mw.Close()
+
+	msg.Write(parts.Bytes())
+	return msg.Bytes()
+}
+
+// sendMail sends a message through the configured SMTP server. Port 465 uses
+// implicit TLS, other ports upgrade with STARTTLS when the server offers it.
+// Cancelling ctx closes the connection.
+func sendMail(ctx context.Context, cfg SMTPConfig, to []string, subject, body string, attachments []mailAttachment) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("no SMTP server configured")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	msg := buildMessage(from, to, subject, body, attachments)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	if cfg.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+	defer c.Close()
+
+	if cfg.Port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("failed to start TLS with %s: %v", addr, err)
+			}
+		}
+	}
+
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("failed to send mail to %s: %v", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	return c.Quit()
+}
+
+// emailSink mails alerts through the configured SMTP server
+type emailSink struct {
+	smtp SMTPConfig
+	to   []string
+}
+
+// newEmailSink creates an email sink. "to" is a comma-separated list of recipients.
+func newEmailSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		To string `json:"to"`
+	}
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	to := parseRecipients(c.To)
+	if len(to) == 0 {
+		return nil, fmt.Errorf("email sink needs recipients in \"to\"")
+	}
+	return &emailSink{smtp: cfg.SMTP, to: to}, nil
+}
+
+// Send mails all alerts of the event in one message
+func (s *emailSink) Send(ctx context.Context, ev *Event) error {
+	if len(ev.Alerts) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	for _, alert := range ev.Alerts {
+		fmt.Fprintf(&body, "[%s] %s\n", alert.Kind, alert.Message)
+	}
+	subject := fmt.Sprintf("Disk space alert on %s", ev.Host)
+	if len(ev.Alerts) == 1 {
+		subject += ": " + ev.Alerts[0].Message
+	}
+	return sendMail(ctx, s.smtp, s.to, subject, body.String(), nil)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// escalationStep is a parsed EscalationStep
+type escalationStep struct {
+	severity int
+	after    time.Duration
+	sinks    []string
+}
+
+// escalationPolicy is a parsed EscalationPolicy
+type escalationPolicy struct {
+	drives []string
+	hosts  []string
+	steps  []escalationStep
+}
+
+// matches reports whether the policy applies to an alert of a host
+func (p escalationPolicy) matches(a Alert, host string) bool {
+	if len(p.hosts) > 0 && !containsFold(p.hosts, host) {
+		return false
+	}
+	return matchesDrive(p.drives, a.Drive)
+}
+
+// escalation parses the escalation policies, checking that the sinks they
+// name are configured
+func (c AlertConfig) escalation(sinks []SinkConfig) ([]escalationPolicy, error) {
+	var names []string
+	for _, sc := range sinks {
+		name := sc.Name
+		if name == "" {
+			name = sc.Type
+		}
+		names = append(names, name)
+	}
+
+	var policies []escalationPolicy
+	for i, p := range c.Policies {
+		label := p.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		policy := escalationPolicy{drives: p.Drives, hosts: p.Hosts}
+		for _, st := range p.Steps {
+			step := escalationStep{sinks: st.Sinks}
+			severity := st.Severity
+			if severity == "" {
+				severity = severityWarning
+			}
+			if step.severity = severityRank(severity); step.severity < 0 {
+				return nil, fmt.Errorf("alert policy %s: invalid severity %q, use warning or critical", label, st.Severity)
+			}
+			if st.After != "" {
+				d, err := analysis.ParseDuration(st.After)
+				if err != nil || d < 0 {
+					return nil, fmt.Errorf("alert policy %s: invalid after %q", label, st.After)
+				}
+				step.after = d
+			}
+			for _, sink := range st.Sinks {
+				if !slices.Contains(names, sink) {
+					return nil, fmt.Errorf("alert policy %s: no sink named %q", label, sink)
+				}
+			}
+			policy.steps = append(policy.steps, step)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// alertRouting decides which sinks are notified of each alert
+type alertRouting struct {
+	policies []escalationPolicy
+	host     string
+	// since is when each alert started firing, by Alert.key
+	since map[string]time.Time
+	now   time.Time
+}
+
+// newAlertRouting routes the alerts raised by the latest snapshot of host in hist
+func newAlertRouting(policies []escalationPolicy, hist *history.History, cfg *Config, host string, alerts []Alert) *alertRouting {
+	if len(policies) == 0 {
+		return nil
+	}
+	var lookBack time.Duration
+	for _, p := range policies {
+		for _, st := range p.steps {
+			lookBack = max(lookBack, st.after)
+		}
+	}
+	hostHist := hist.ForHost(host, host)
+	r := &alertRouting{policies: policies, host: host, since: firingSince(hostHist, cfg, alerts, lookBack)}
+	if n := len(hostHist.Snapshots); n > 0 {
+		r.now = hostHist.Snapshots[n-1].Timestamp
+	}
+	return r
+}
+
+// firingSince finds when each alert of the latest snapshot started firing, by
+// checking earlier snapshots until it wasn't, going back no further than
+// needed to tell whether it has been firing for lookBack
+func firingSince(hist *history.History, cfg *Config, alerts []Alert, lookBack time.Duration) map[string]time.Time {
+	since := make(map[string]time.Time)
+	n := len(hist.Snapshots)
+	if n == 0 {
+		return since
+	}
+	latest := hist.Snapshots[n-1].Timestamp
+	for _, a := range alerts {
+		since[a.key()] = latest
+	}
+
+	active := make(map[string]bool)
+	for key := range since {
+		active[key] = true
+	}
+	for i := n - 2; i >= 0 && len(active) > 0; i-- {
+		t := hist.Snapshots[i].Timestamp
+		firing := make(map[string]bool)
+		for _, a := range evaluateAlerts(&history.History{Snapshots: hist.Snapshots[:i+1]}, cfg) {
+			firing[a.key()] = true
+		}
+		for key := range active {
+			if firing[key] {
+				since[key] = t
+			} else {
+				delete(active, key)
+			}
+		}
+		// The first snapshot past lookBack settles whether it was firing for all of it
+		if latest.Sub(t) >= lookBack {
+			break
+		}
+	}
+	return since
+}
+
+// alertsFor returns the alerts a sink is notified of: those no policy matches,
+// and those a step of their policy sends to it. Recoveries go to the sinks
+// that were notified, which the alert state decides.
+func (r *alertRouting) alertsFor(sink string, alerts []Alert) []Alert {
+	if r == nil {
+		return alerts
+	}
+	var routed []Alert
+	for _, a := range alerts {
+		if a.Severity == severityResolved {
+			routed = append(routed, a)
+			continue
+		}
+		i := slices.IndexFunc(r.policies, func(p escalationPolicy) bool { return p.matches(a, r.host) })
+		if i < 0 {
+			routed = append(routed, a)
+			continue
+		}
+		firing := r.now.Sub(r.since[a.key()])
+		for _, st := range r.policies[i].steps {
+			if severityRank(a.Severity) >= st.severity && firing >= st.after && slices.Contains(st.sinks, sink) {
+				routed = append(routed, a)
+				break
+			}
+		}
+	}
+	return routed
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+var escalationSinks = []SinkConfig{{Type: "webhook", Name: "chat"}, {Type: "webhook", Name: "pager"}, {Type: "email"}}
+
+func TestEscalation(t *testing.T) {
+	tests := []struct {
+		name string
+		step EscalationStep
+		err  string
+	}{
+		{name: "defaults", step: EscalationStep{Sinks: []string{"chat"}}},
+		{name: "sink named by type", step: EscalationStep{Severity: "critical", After: "2h", Sinks: []string{"email"}}},
+		{name: "bad severity", step: EscalationStep{Severity: "urgent", Sinks: []string{"chat"}}, err: `invalid severity "urgent"`},
+		{name: "bad after", step: EscalationStep{After: "soon", Sinks: []string{"chat"}}, err: `invalid after "soon"`},
+		{name: "negative after", step: EscalationStep{After: "-1h", Sinks: []string{"chat"}}, err: `invalid after "-1h"`},
+		{name: "unknown sink", step: EscalationStep{Sinks: []string{"sms"}}, err: `no sink named "sms"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := AlertConfig{Policies: []EscalationPolicy{{Name: "disks", Steps: []EscalationStep{tt.step}}}}
+			policies, err := cfg.escalation(escalationSinks)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), "policy disks") {
+					t.Fatalf("escalation() error = %v, want %q of policy disks", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(policies) != 1 || len(policies[0].steps) != 1 {
+				t.Fatalf("escalation() = %+v, want one policy of one step", policies)
+			}
+			if st := policies[0].steps[0]; (tt.step.Severity == "") != (st.severity == severityRank(severityWarning)) {
+				t.Errorf("severity %d, want warning by default", st.severity)
+			}
+		})
+	}
+}
+
+func TestAlertRouting(t *testing.T) {
+	// C:\ has been over 80% for 5 hours, D:\ just got there, E:\ is over 90%
+	// and F:\, which no policy covers, over 80%
+	start := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+	hist := &history.History{}
+	for hour := 0; hour <= 5; hour++ {
+		usedD := uint64(50)
+		if hour == 5 {
+			usedD = 85
+		}
+		hist.Snapshots = append(hist.Snapshots, history.Snapshot{
+			Timestamp: start.Add(time.Duration(hour) * time.Hour),
+			Disks: []diskinfo.DiskInfo{
+				{Drive: `C:\`, TotalSpace: 100, UsedSpace: 85, FreeSpace: 15},
+				{Drive: `D:\`, TotalSpace: 100, UsedSpace: usedD, FreeSpace: 100 - usedD},
+				{Drive: `E:\`, TotalSpace: 100, UsedSpace: 95, FreeSpace: 5},
+				{Drive: `F:\`, TotalSpace: 100, UsedSpace: 85, FreeSpace: 15},
+			},
+		})
+	}
+
+	cfg := &Config{Alerts: AlertConfig{UsedPercent: 80, CriticalPercent: 90}}
+	cfg.Alerts.Policies = []EscalationPolicy{
+		{Name: "elsewhere", Hosts: []string{"nas"}, Steps: []EscalationStep{{Sinks: []string{"email"}}}},
+		{Name: "disks", Drives: []string{"c", `D:\`, "E:"}, Steps: []EscalationStep{
+			{Sinks: []string{"chat"}},
+			{After: "4h", Sinks: []string{"pager"}},
+			{Severity: "critical", Sinks: []string{"pager"}},
+		}},
+	}
+	policies, err := cfg.Alerts.escalation(escalationSinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := evaluateAlerts(hist, cfg)
+	routing := newAlertRouting(policies, hist, cfg, "desktop", alerts)
+	// Going back 4 hours is enough to tell C:\ is past the pager's step
+	if since := routing.since[alerts[0].key()]; !since.Equal(start.Add(time.Hour)) {
+		t.Errorf("C:\\ firing since %s, want %s", since, start.Add(time.Hour))
+	}
+
+	tests := []struct {
+		sink string
+		want []string
+	}{
+		{"chat", []string{`C:\`, `D:\`, `E:\`, `F:\`}},
+		{"pager", []string{`C:\`, `E:\`, `F:\`}},
+		{"email", []string{`F:\`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sink, func(t *testing.T) {
+			if got := alertDrives(routing.alertsFor(tt.sink, alerts)); !slices.Equal(got, tt.want) {
+				t.Errorf("alertsFor(%q) = %q, want %q", tt.sink, got, tt.want)
+			}
+		})
+	}
+
+	if got := newAlertRouting(nil, hist, cfg, "desktop", alerts).alertsFor("email", alerts); len(got) != len(alerts) {
+		t.Errorf("without policies email gets %d alerts, want all %d", len(got), len(alerts))
+	}
+}
+
+// alertDrives returns the drive of each alert
+func alertDrives(alerts []Alert) []string {
+	var drives []string
+	for _, a := range alerts {
+		drives = append(drives, a.Drive)
+	}
+	return drives
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultExcludes are skipped by the scanner unless disabled in the config
+var defaultExcludes = []string{
+	"pagefile.sys",
+	"hiberfil.sys",
+	"swapfile.sys",
+	"System Volume Information",
+}
+
+// maxReparseDepth stops runaway recursion through junction loops when following reparse points
+const maxReparseDepth = 64
+
+// excludeMatcher matches paths against glob patterns and regular expressions
+type excludeMatcher struct {
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+// newExcludeMatcher compiles exclusion patterns. Patterns prefixed with "re:" are
+// regular expressions matched against the full path, all others are globs matched
+// against both the entry name and the full path, ignoring case.
+func newExcludeMatcher(patterns []string) (*excludeMatcher, error) {
+	m := &excludeMatcher{}
+	for _, p := range patterns {
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile("(?i)" + expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exclude pattern %q: %v", p, err)
+			}
+			m.regexps = append(m.regexps, re)
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %v", p, err)
+		}
+		m.globs = append(m.globs, strings.ToLower(p))
+	}
+	return m, nil
+}
+
+// match reports whether the entry at path should be skipped
+func (m *excludeMatcher) match(path, name string) bool {
+	if m == nil {
+		return false
+	}
+
+	lowerName := strings.ToLower(name)
+	lowerPath := strings.ToLower(path)
+	for _, g := range m.globs {
+		if ok, _ := filepath.Match(g, lowerName); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, lowerPath); ok {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanOptions controls how the directory scanner and file finders walk a tree
+type scanOptions struct {
+	// Depth limits how deep the stored tree goes
+	Depth int
+	// Workers is the number of parallel walkers, 0 for 2 per CPU
+	Workers int
+	Exclude *excludeMatcher
+	// FollowReparsePoints descends into junctions and symlinks, which may count data twice
+	FollowReparsePoints bool
+}
+
+// newScanOptions builds scan options from the config and extra exclusions
+func newScanOptions(cfg ScanConfig, extraExcludes []string) (scanOptions, error) {
+	var patterns []string
+	if cfg.DefaultExcludes {
+		patterns = append(patterns, defaultExcludes...)
+	}
+	patterns = append(patterns, cfg.Exclude...)
+	patterns = append(patterns, extraExcludes...)
+
+	exclude, err := newExcludeMatcher(patterns)
+	if err != nil {
+		return scanOptions{}, err
+	}
+
+	return scanOptions{
+		Depth:               defaultScanDepth,
+		Exclude:             exclude,
+		FollowReparsePoints: !cfg.SkipReparsePoints,
+	}, nil
+}
+
+// Kinds of directory entries as seen by the walkers
+const (
+	entrySkip = iota
+	entryDir
+	entryFile
+)
+
+// resolveEntry decides how a directory entry is counted: descended into as a
+// directory, counted as a file, or skipped because it is excluded or a reparse
+// point that isn't followed.
+func (o *scanOptions) resolveEntry(path string, entry fs.DirEntry) (int, fs.FileInfo, error) {
+	if o.Exclude.match(path, entry.Name()) {
+		return entrySkip, nil, nil
+	}
+	if entry.IsDir() {
+		return entryDir, nil, nil
+	}
+
+	t := entry.Type()
+	if t.IsRegular() {
+		info, err := entry.Info()
+		if err != nil {
+			return entrySkip, nil, err
+		}
+		return entryFile, info, nil
+	}
+
+	// Symlinks and junctions show up as symlink or irregular entries
+	if t&(fs.ModeSymlink|fs.ModeIrregular) != 0 && o.FollowReparsePoints {
+		info, err := os.Stat(path)
+		if err != nil {
+			return entrySkip, nil, err
+		}
+		if info.IsDir() {
+			return entryDir, nil, nil
+		}
+		if info.Mode().IsRegular() {
+			return entryFile, info, nil
+		}
+	}
+
+	return entrySkip, nil, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// DirChange is the size change attributed to one directory
+type DirChange struct {
+	Path  string
+	Delta float64
+}
+
+// diffTrees attributes size changes between two scans to directories.
+// Each directory is credited only with the change not explained by its stored
+// subdirectories, so the attributed deltas add up to the total change.
+func diffTrees(path string, before, after *DirNode, changes *[]DirChange) float64 {
+	var beforeSize, afterSize float64
+	children := make(map[string][2]*DirNode)
+	if before != nil {
+		beforeSize = float64(before.Size)
+		for _, c := range before.Children {
+			pair := children[c.Name]
+			pair[0] = c
+			children[c.Name] = pair
+		}
+	}
+	if after != nil {
+		afterSize = float64(after.Size)
+		for _, c := range after.Children {
+			pair := children[c.Name]
+			pair[1] = c
+			children[c.Name] = pair
+		}
+	}
+
+	total := afterSize - beforeSize
+	var explained float64
+	for name, pair := range children {
+		explained += diffTrees(filepath.Join(path, name), pair[0], pair[1], changes)
+	}
+
+	if own := total - explained; own != 0 {
+		*changes = append(*changes, DirChange{Path: path, Delta: own})
+	}
+
+	return total
+}
+
+// scanRoot turns a drive letter or path argument into a scan root
+func scanRoot(arg string) string {
+	if len(arg) <= 3 {
+		return normalizeDrive(arg)
+	}
+	return filepath.Clean(arg)
+}
+
+// runExplain ranks the directories responsible for growth since an earlier scan
+func runExplain(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	since := fs.String("since", "7d", "Compare against the newest scan at least this old")
+	limit := fs.Int("limit", 20, "Number of directories to list")
+	depth := fs.Int("depth", defaultScanDepth, "Directory depth to attribute changes to")
+	var excludes listFlag
+	fs.Var(&excludes, "exclude", "Glob or re:regexp to skip, can be repeated")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: explain [-since 7d] <drive or path>")
+	}
+
+	age, err := analysis.ParseDuration(*since)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := newScanOptions(cfg.Scan, excludes)
+	if err != nil {
+		return err
+	}
+	opts.Depth = *depth
+
+	root := scanRoot(positional[0])
+	store, err := loadScans()
+	if err != nil {
+		return err
+	}
+
+	current, _, err := refreshScan(ctx, store, root, opts, false)
+	if err != nil {
+		return err
+	}
+
+	previous := store.findScanBefore(root, current.Timestamp.Add(-age))
+	if previous == nil {
+		// Fall back to the oldest scan we have
+		for i := range store.Scans {
+			if store.Scans[i].Root == root && (previous == nil || store.Scans[i].Timestamp.Before(previous.Timestamp)) {
+				previous = &store.Scans[i]
+			}
+		}
+	}
+
+	if previous == nil {
+		store.addScan(*current, defaultScansKept)
+		if err := saveScans(store); err != nil {
+			return err
+		}
+		fmt.Printf("No earlier scan of %s found. Saved this scan, run explain again later to see what grew.\n", root)
+		return nil
+	}
+
+	var changes []DirChange
+	total := diffTrees(root, previous.Tree, current.Tree, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return math.Abs(changes[i].Delta) > math.Abs(changes[j].Delta)
+	})
+
+	fmt.Printf("\nChanges in %s since %s (%s):\n", root,
+		locale.Timestamp(previous.Timestamp), durationSince(previous.Timestamp))
+	fmt.Printf("  Scanned size: %s\n", diskinfo.FormatChange(total))
+
+	// Cross-check with the free space history of the drive
+	hist, err := loadHistory(ctx, zone)
+	if err == nil {
+		points := hist.Series(normalizeDrive(filepath.VolumeName(root)), previous.Timestamp)
+		if len(points) >= 2 {
+			used := float64(points[0].Free) - float64(points[len(points)-1].Free)
+			fmt.Printf("  Used space:   %s (history)\n", diskinfo.FormatChange(used))
+		}
+	}
+	fmt.Println()
+
+	for i, c := range changes {
+		if i >= *limit {
+			break
+		}
+		fmt.Printf("  %12s  %s\n", diskinfo.FormatChange(c.Delta), c.Path)
+	}
+
+	store.addScan(*current, defaultScansKept)
+	return saveScans(store)
+}
+
+// durationSince formats how long ago t was
+func durationSince(t time.Time) string {
+	d := time.Since(t)
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%s days ago", locale.Float(d.Hours()/24, 0))
+	}
+	return fmt.Sprintf("%s hours ago", locale.Float(d.Hours(), 0))
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// filter is a condition on a drive in a snapshot, parsed from an expression
+// like "used_pct > 80 && drive == 'C:\'" by parseFilter
+type filter struct {
+	source string
+	eval   func(r *filterRow) any
+	// lookBack is the longest growth_<window> and forecast is set when the
+	// filter uses days_until_full, which reads forecast.window: how far back
+	// from the snapshot it reads the history
+	lookBack time.Duration
+	forecast bool
+}
+
+// filterRow is what a filter is evaluated on
+type filterRow struct {
+	snapshot *history.Snapshot
+	disk     diskinfo.DiskInfo
+	// hist has the series growth_<window> and days_until_full are fitted to,
+	// nil makes growth 0 and the drive never full
+	hist *history.History
+	// forecast fits days_until_full
+	forecast analysis.ForecastConfig
+}
+
+// Types of the values in a filter
+const (
+	filterNumber = "number"
+	filterString = "string"
+	filterBool   = "boolean"
+)
+
+// filterVar is a name a filter can use
+type filterVar struct {
+	typ string
+	get func(r *filterRow) any
+}
+
+// filterVars are the names of a filter besides growth_<window>. Sizes are in
+// bytes, percents of the drive's capacity.
+var filterVars = map[string]filterVar{
+	"drive":           {filterString, func(r *filterRow) any { return r.disk.Drive }},
+	"host":            {filterString, func(r *filterRow) any { return r.snapshot.Host }},
+	"file_system":     {filterString, func(r *filterRow) any { return r.disk.FileSystem }},
+	"note":            {filterString, func(r *filterRow) any { return r.snapshot.Note }},
+	"free":            {filterNumber, func(r *filterRow) any { return float64(r.disk.FreeSpace) }},
+	"used":            {filterNumber, func(r *filterRow) any { return float64(r.disk.UsedSpace) }},
+	"total":           {filterNumber, func(r *filterRow) any { return float64(r.disk.TotalSpace) }},
+	"used_pct":        {filterNumber, func(r *filterRow) any { return percentOf(r.disk.UsedSpace, r.disk.TotalSpace) }},
+	"free_pct":        {filterNumber, func(r *filterRow) any { return percentOf(r.disk.FreeSpace, r.disk.TotalSpace) }},
+	"dev_drive":       {filterBool, func(r *filterRow) any { return r.disk.DevDrive }},
+	"image":           {filterString, func(r *filterRow) any { return r.disk.Image }},
+	"files":           {filterNumber, func(r *filterRow) any { return float64(r.disk.Files) }},
+	"max_files":       {filterNumber, func(r *filterRow) any { return float64(r.disk.MaxFiles) }},
+	"files_pct":       {filterNumber, func(r *filterRow) any { return r.disk.FilesPercent() }},
+	"days_until_full": {filterNumber, daysUntilFull},
+}
+
+// daysUntilFull is days_until_full, the forecast's days left until the drive
+// is full as of the snapshot, +Inf when it isn't filling up or has too few samples
+func daysUntilFull(r *filterRow) any {
+	if r.hist == nil {
+		return math.Inf(1)
+	}
+	// Later snapshots aren't known at the time
+	n, _ := slices.BinarySearchFunc(r.hist.Snapshots, r.snapshot.Timestamp, func(s history.Snapshot, t time.Time) int {
+		if s.Timestamp.After(t) {
+			return 1
+		}
+		return -1
+	})
+	f, err := analysis.ForecastDrive(&history.History{Snapshots: r.hist.Snapshots[:n]}, r.disk.Drive, r.forecast, r.snapshot.Timestamp)
+	if err != nil {
+		return math.Inf(1)
+	}
+	return f.DaysUntilFull(r.snapshot.Timestamp)
+}
+
+// growthVar returns growth_<window>, the trend of the drive's used space in
+// bytes per day over the window up to the snapshot, 0 without enough samples
+func growthVar(window time.Duration) filterVar {
+	return filterVar{filterNumber, func(r *filterRow) any {
+		if r.hist == nil {
+			return 0.0
+		}
+		var points []history.Point
+		for _, p := range r.hist.Series(r.disk.Drive, r.snapshot.Timestamp.Add(-window)) {
+			if !p.Time.After(r.snapshot.Timestamp) {
+				points = append(points, p)
+			}
+		}
+		rate, _ := analysis.FitRate(points)
+		return rate
+	}}
+}
+
+// match reports whether the drive of the row meets the condition
+func (f *filter) match(r *filterRow) bool {
+	return f.eval(r).(bool)
+}
+
+// String returns the expression the filter was parsed from
+func (f *filter) String() string {
+	return f.source
+}
+
+// parseFilter parses a filter expression: names compared with ==, !=, <, <=,
+// > or >= to numbers, sizes like 10GB, rates like 1GB/day or 'quoted' strings,
+// joined with &&, || and !, and grouped with parentheses. Strings compare
+// without regard to case.
+func parseFilter(source string) (*filter, error) {
+	tokens, err := lexFilter(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	typ, eval, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	if typ != filterBool {
+		return nil, fmt.Errorf("%q is a %s, not a condition", source, typ)
+	}
+	return &filter{source: source, eval: eval, lookBack: p.lookBack, forecast: p.forecast}, nil
+}
+
+// filterToken is a token of a filter expression
+type filterToken struct {
+	// kind is "ident", "number", "string" or the operator itself
+	kind   string
+	text   string
+	number float64
+}
+
+// String describes the token for error messages
+func (t filterToken) String() string {
+	if t.kind == "string" {
+		return fmt.Sprintf("'%s'", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// filterOperators are the operators of a filter, longest first
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// lexFilter splits a filter expression into tokens
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'' || c == '"':
+			// No escapes, so Windows paths are written as they are
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %q", s[i:])
+			}
+			tokens = append(tokens, filterToken{kind: "string", text: s[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || unicode.IsLetter(rune(s[j]))) {
+				j++
+			}
+			// A rate, like 1GB/day
+			if j+1 < len(s) && s[j] == '/' && unicode.IsLetter(rune(s[j+1])) {
+				for j++; j < len(s) && unicode.IsLetter(rune(s[j])); j++ {
+				}
+			}
+			n, err := parseFilterNumber(s[i:j])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, filterToken{kind: "number", text: s[i:j], number: n})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: "ident", text: s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range filterOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q", s[i:i+1])
+			}
+			tokens = append(tokens, filterToken{kind: op, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// parseFilterNumber parses a number, a size in bytes or a rate in bytes per day
+func parseFilterNumber(s string) (float64, error) {
+	size, per, isRate := strings.Cut(s, "/")
+	var n float64
+	if strings.IndexFunc(size, unicode.IsLetter) < 0 {
+		v, err := strconv.ParseFloat(size, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+		n = v
+	} else {
+		v, err := diskinfo.ParseSize(size)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		n = float64(v)
+	}
+	if !isRate {
+		return n, nil
+	}
+	units := map[string]string{"h": "1h", "hour": "1h", "d": "1d", "day": "1d", "w": "1w", "week": "1w"}
+	d, err := analysis.ParseDuration(units[strings.ToLower(per)])
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q, use e.g. 1GB/day", s)
+	}
+	return n * float64(24*time.Hour) / float64(d), nil
+}
+
+// filterParser parses tokens by precedence: ||, &&, !, comparisons
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	// lookBack and forecast are those of the filter, see filter
+	lookBack time.Duration
+	forecast bool
+}
+
+// accept consumes the next token if it is of the kind
+func (p *filterParser) accept(kind string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// or parses conditions joined with ||
+func (p *filterParser) or() (string, func(*filterRow) any, error) {
+	typ, left, err := p.and()
+	for err == nil && p.accept("||") {
+		var rtyp string
+		var right func(*filterRow) any
+		if rtyp, right, err = p.and(); err == nil {
+			if err = bothBool(typ, rtyp, "||"); err == nil {
+				l := left
+				left = func(r *filterRow) any { return l(r).(bool) || right(r).(bool) }
+			}
+		}
+	}
+	return typ, left, err
+}
+
+// and parses conditions joined with &&
+func (p *filterParser) and() (string, func(*filterRow) any, error) {
+	typ, left, err := p.not()
+	for err == nil && p.accept("&&") {
+		var rtyp string
+		var right func(*filterRow) any
+		if rtyp, right, err = p.not(); err == nil {
+			if err = bothBool(typ, rtyp, "&&"); err == nil {
+				l := left
+				left = func(r *filterRow) any { return l(r).(bool) && right(r).(bool) }
+			}
+		}
+	}
+	return typ, left, err
+}
+
+// bothBool checks the operands of a logical operator
+func bothBool(left, right, op string) error {
+	if left != filterBool || right != filterBool {
+		return fmt.Errorf("%s needs conditions on both sides, not a %s and a %s", op, left, right)
+	}
+	return nil
+}
+
+// not parses a condition negated with !
+func (p *filterParser) not() (string, func(*filterRow) any, error) {
+	if !p.accept("!") {
+		return p.comparison()
+	}
+	typ, operand, err := p.not()
+	if err != nil {
+		return "", nil, err
+	}
+	if typ != filterBool {
+		return "", nil, fmt.Errorf("! needs a condition, not a %s", typ)
+	}
+	return filterBool, func(r *filterRow) any { return !operand(r).(bool) }, nil
+}
+
+// comparison parses a value, compared with another one if an operator follows
+func (p *filterParser) comparison() (string, func(*filterRow) any, error) {
+	typ, left, err := p.primary()
+	if err != nil || p.pos >= len(p.tokens) {
+		return typ, left, err
+	}
+	op := p.tokens[p.pos].kind
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return typ, left, nil
+	}
+	p.pos++
+	rtyp, right, err := p.primary()
+	if err != nil {
+		return "", nil, err
+	}
+	if typ != rtyp {
+		return "", nil, fmt.Errorf("can't compare a %s with a %s", typ, rtyp)
+	}
+	if typ != filterNumber && op != "==" && op != "!=" {
+		return "", nil, fmt.Errorf("%s only compares numbers", op)
+	}
+	return filterBool, func(r *filterRow) any {
+		var c int
+		switch l := left(r).(type) {
+		case float64:
+			c = cmp.Compare(l, right(r).(float64))
+		case string:
+			if !strings.EqualFold(l, right(r).(string)) {
+				c = 1
+			}
+		case bool:
+			if l != right(r).(bool) {
+				c = 1
+			}
+		}
+		switch op {
+		case "==":
+			return c == 0
+		case "!=":
+			return c != 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}, nil
+}
+
+// primary parses a name, a literal or a parenthesized condition
+func (p *filterParser) primary() (string, func(*filterRow) any, error) {
+	if p.pos >= len(p.tokens) {
+		return "", nil, fmt.Errorf("unexpected end of the expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case "number":
+		return filterNumber, func(*filterRow) any { return t.number }, nil
+	case "string":
+		return filterString, func(*filterRow) any { return t.text }, nil
+	case "(":
+		typ, eval, err := p.or()
+		if err != nil {
+			return "", nil, err
+		}
+		if !p.accept(")") {
+			return "", nil, fmt.Errorf("missing )")
+		}
+		return typ, eval, nil
+	case "ident":
+		switch name := strings.ToLower(t.text); {
+		case name == "true" || name == "false":
+			return filterBool, func(*filterRow) any { return name == "true" }, nil
+		case strings.HasPrefix(name, "growth_"):
+			window, err := analysis.ParseDuration(strings.TrimPrefix(name, "growth_"))
+			if err != nil || window <= 0 {
+				return "", nil, fmt.Errorf("invalid window of %s, use e.g. growth_7d", t.text)
+			}
+			p.lookBack = max(p.lookBack, window)
+			v := growthVar(window)
+			return v.typ, v.get, nil
+		default:
+			v, ok := filterVars[name]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown name %s", t.text)
+			}
+			p.forecast = p.forecast || name == "days_until_full"
+			return v.typ, v.get, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unexpected %s", t)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+func TestLexFilter(t *testing.T) {
+	tests := []struct {
+		source string
+		// want is the text of each token, strings without their quotes
+		want    []string
+		numbers []float64
+		err     string
+	}{
+		{source: "used_pct>80", want: []string{"used_pct", ">", "80"}, numbers: []float64{80}},
+		{source: "free <= 1.5GiB && !dev_drive", want: []string{"free", "<=", "1.5GiB", "&&", "!", "dev_drive"},
+			numbers: []float64{1.5 * (1 << 30)}},
+		{source: `drive == 'C:\' || drive != "D:\"`, want: []string{"drive", "==", `C:\`, "||", "drive", "!=", `D:\`}},
+		{source: "growth_7d > 1GiB/day", want: []string{"growth_7d", ">", "1GiB/day"}, numbers: []float64{1 << 30}},
+		{source: "growth_7d > 7GiB/week", want: []string{"growth_7d", ">", "7GiB/week"}, numbers: []float64{1 << 30}},
+		{source: "growth_7d > 1KiB/h", want: []string{"growth_7d", ">", "1KiB/h"}, numbers: []float64{24 << 10}},
+		{source: "(a||b)&&c", want: []string{"(", "a", "||", "b", ")", "&&", "c"}},
+		{source: "", want: nil},
+		{source: "note == 'unterminated", err: "unterminated string"},
+		{source: "free > 10XB", err: "invalid size"},
+		{source: "growth_7d > 1GiB/fortnight", err: "invalid rate"},
+		{source: "1.2.3", err: "invalid number"},
+		{source: "free = 10", err: `unexpected "="`},
+		{source: "free & used", err: `unexpected "&"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := lexFilter(tt.source)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("lexFilter(%q) error = %v, want %q", tt.source, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			var numbers []float64
+			for _, tok := range tokens {
+				texts = append(texts, tok.text)
+				if tok.kind == "number" {
+					numbers = append(numbers, tok.number)
+				}
+			}
+			if !slices.Equal(texts, tt.want) {
+				t.Errorf("lexFilter(%q) = %q, want %q", tt.source, texts, tt.want)
+			}
+			if !slices.Equal(numbers, tt.numbers) {
+				t.Errorf("lexFilter(%q) numbers = %v, want %v", tt.source, numbers, tt.numbers)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	// C:\ fills up by 2 GiB a day for a week, up to 85 of 100 GiB used
+	now := time.Date(2026, 9, 8, 0, 0, 0, 0, time.UTC)
+	hist := &history.History{}
+	for day := 7; day >= 0; day-- {
+		used := uint64(85-2*day) << 30
+		hist.Snapshots = append(hist.Snapshots, history.Snapshot{
+			Timestamp: now.AddDate(0, 0, -day),
+			Host:      "nas",
+			Note:      "after cleanup",
+			Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100 << 30, FreeSpace: 100<<30 - used,
+				UsedSpace: used, FileSystem: "NTFS", Files: 900, MaxFiles: 1000}},
+		})
+	}
+	latest := &hist.Snapshots[len(hist.Snapshots)-1]
+	row := &filterRow{snapshot: latest, disk: latest.Disks[0], hist: hist,
+		forecast: analysis.ForecastConfig{Window: "30d", Model: analysis.ModelLinear, Reserve: "0"}}
+
+	tests := []struct {
+		source string
+		want   bool
+		err    string
+	}{
+		{source: "used_pct > 80", want: true},
+		{source: "used_pct > 80 && drive == 'c:\\'", want: true},
+		{source: "free < 10GiB", want: false},
+		{source: "free < 20GiB", want: true},
+		{source: "free_pct >= 15 && free_pct <= 15", want: true},
+		{source: "!dev_drive && file_system == 'ntfs'", want: true},
+		{source: "dev_drive == false", want: true},
+		{source: "image == ''", want: true},
+		{source: "host == 'NAS' || used_pct < 10", want: true},
+		{source: "note != 'after cleanup'", want: false},
+		{source: "files_pct == 90 && max_files > files", want: true},
+		// && binds tighter than ||, ! tighter than both
+		{source: "true || false && false", want: true},
+		{source: "(true || false) && false", want: false},
+		{source: "!true || true", want: true},
+		{source: "!(true || true)", want: false},
+		{source: "growth_7d > 1GiB/day", want: true},
+		{source: "growth_7d > 14GiB/week", want: false},
+		{source: "GROWTH_7D < 3GiB/day && Used_Pct > 80", want: true},
+		// 15 GiB free at 2 GiB a day
+		{source: "days_until_full > 7 && days_until_full < 8", want: true},
+		{source: "days_until_full < 7 || growth_7d < 0", want: false},
+
+		{source: "used_pct", err: "not a condition"},
+		{source: "used_pct > 'x'", err: "can't compare a number with a string"},
+		{source: "drive < 'C:\\'", err: "only compares numbers"},
+		{source: "used_pct > 80 &&", err: "unexpected end"},
+		{source: "(used_pct > 80", err: "missing )"},
+		{source: "used_pct > 80)", err: `unexpected ")"`},
+		{source: "bogus == 1", err: "unknown name bogus"},
+		{source: "growth_soon > 0", err: "invalid window"},
+		{source: "used_pct && true", err: "&& needs conditions"},
+		{source: "true || free", err: "|| needs conditions"},
+		{source: "!used_pct", err: "! needs a condition"},
+		{source: "note == 'open", err: "unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			f, err := parseFilter(tt.source)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseFilter(%q) error = %v, want %q", tt.source, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.String() != tt.source {
+				t.Errorf("String() = %q, want %q", f.String(), tt.source)
+			}
+			if got := f.match(row); got != tt.want {
+				t.Errorf("parseFilter(%q) matches %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysUntilFull(t *testing.T) {
+	// D:\ fills up by 1 GiB a day for 10 days, then stops
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	hist := &history.History{}
+	for day := 0; day <= 20; day++ {
+		used := uint64(min(day, 10)+50) << 30
+		hist.Snapshots = append(hist.Snapshots, history.Snapshot{
+			Timestamp: start.AddDate(0, 0, day),
+			Disks:     []diskinfo.DiskInfo{{Drive: `D:\`, TotalSpace: 100 << 30, FreeSpace: 100<<30 - used, UsedSpace: used}},
+		})
+	}
+	forecast := analysis.ForecastConfig{Window: "5d", Model: analysis.ModelLinear, Reserve: "0"}
+
+	tests := []struct {
+		name string
+		day  int
+		hist *history.History
+		want float64
+	}{
+		// Later snapshots don't count: on day 10 the drive was still filling
+		{name: "filling", day: 10, hist: hist, want: 40},
+		{name: "stopped", day: 20, hist: hist, want: math.Inf(1)},
+		{name: "first snapshot", day: 0, hist: hist, want: math.Inf(1)},
+		{name: "no history", day: 10, want: math.Inf(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &hist.Snapshots[tt.day]
+			got := daysUntilFull(&filterRow{snapshot: s, disk: s.Disks[0], hist: tt.hist, forecast: forecast}).(float64)
+			if math.Abs(got-tt.want) > 1e-6 && !(math.IsInf(got, 1) && math.IsInf(tt.want, 1)) {
+				t.Errorf("days_until_full on day %d = %v, want %v", tt.day, got, tt.want)
+			}
+		})
+	}
+}
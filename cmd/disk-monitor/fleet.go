@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// fleetRow sums up one machine of the history
+type fleetRow struct {
+	Host        string
+	Worst       string
+	UsedPercent float64
+	Free        uint64
+	LastReport  time.Time
+	Alerts      []Alert
+}
+
+// state names the alert kinds raised for the host, "OK" when there are none
+func (r fleetRow) state() string {
+	if len(r.Alerts) == 0 {
+		return "OK"
+	}
+	var kinds []string
+	for _, a := range r.Alerts {
+		if !containsFold(kinds, a.Kind) {
+			kinds = append(kinds, a.Kind)
+		}
+	}
+	return fmt.Sprintf("%d alerts: %s", len(r.Alerts), strings.Join(kinds, ", "))
+}
+
+// fleetRows evaluates the latest snapshot of every host in the history
+func fleetRows(hist *history.History, cfg *Config) []fleetRow {
+	local := history.LocalHost()
+	var rows []fleetRow
+	for _, host := range hist.Hosts(local) {
+		hostHist := hist.ForHost(host, local)
+		latest := hostHist.Snapshots[len(hostHist.Snapshots)-1]
+		row := fleetRow{Host: host, LastReport: latest.Timestamp, Alerts: evaluateAlerts(hostHist, cfg)}
+		for _, disk := range latest.Disks {
+			if disk.TotalSpace == 0 {
+				continue
+			}
+			used := float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+			if row.Worst == "" || used > row.UsedPercent {
+				row.Worst, row.UsedPercent, row.Free = disk.Drive, used, disk.FreeSpace
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// sortFleet orders the rows by "severity" (most alerts, then fullest drive),
+// "host" or "time" (longest silent first)
+func sortFleet(rows []fleetRow, by string) error {
+	var less func(a, b fleetRow) bool
+	switch by {
+	case "severity":
+		less = func(a, b fleetRow) bool {
+			if len(a.Alerts) != len(b.Alerts) {
+				return len(a.Alerts) > len(b.Alerts)
+			}
+			return a.UsedPercent > b.UsedPercent
+		}
+	case "host":
+		less = func(a, b fleetRow) bool { return a.Host < b.Host }
+	case "time":
+		less = func(a, b fleetRow) bool { return a.LastReport.Before(b.LastReport) }
+	default:
+		return fmt.Errorf("invalid -sort %q, use severity, host or time", by)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	return nil
+}
+
+// fleetDrive is the growth of one drive of one machine
+type fleetDrive struct {
+	Host  string
+	Drive string
+	Total uint64
+	// Rate is the growth of used space in bytes per day
+	Rate float64
+}
+
+// sizeClass groups drives of similar size: those between the same two powers of two
+func sizeClass(total uint64) int {
+	return bits.Len64(total)
+}
+
+// peerRate returns the average growth of the other machines' drives of the
+// same size class and how many there are
+func peerRate(d fleetDrive, drives []fleetDrive) (float64, int) {
+	var sum float64
+	n := 0
+	for _, other := range drives {
+		if other.Host == d.Host || sizeClass(other.Total) != sizeClass(d.Total) {
+			continue
+		}
+		sum += other.Rate
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// fleetGrowth fits the growth of every drive of every machine over the window
+// before its last report
+func fleetGrowth(hist *history.History, window time.Duration) []fleetDrive {
+	local := history.LocalHost()
+	var drives []fleetDrive
+	for _, host := range hist.Hosts(local) {
+		hostHist := hist.ForHost(host, local)
+		latest := hostHist.Snapshots[len(hostHist.Snapshots)-1]
+		for _, disk := range latest.Disks {
+			rate, ok := analysis.FitRate(hostHist.Series(disk.Drive, latest.Timestamp.Add(-window)))
+			if !ok || disk.TotalSpace == 0 {
+				continue
+			}
+			drives = append(drives, fleetDrive{Host: host, Drive: disk.Drive, Total: disk.TotalSpace, Rate: rate})
+		}
+	}
+	return drives
+}
+
+// printFleetGrowth compares each drive's growth with the average of similarly
+// sized drives on the other machines, flagging those growing factor times as fast
+func printFleetGrowth(hist *history.History, window time.Duration, factor float64) {
+	drives := fleetGrowth(hist, window)
+	if len(drives) == 0 {
+		fmt.Println("Not enough history to fit growth rates.")
+		return
+	}
+
+	fmt.Printf("%-20s %-12s %10s %16s %16s %5s\n", "Host", "Drive", "Size", "Growth", "Peer average", "Peers")
+	outliers := 0
+	for _, d := range drives {
+		avg, n := peerRate(d, drives)
+		peer, flag := "-", ""
+		if n > 0 {
+			peer = analysis.FormatRate(avg)
+			if d.Rate > 0 && d.Rate >= factor*max(avg, 0) {
+				flag = "  outlier"
+				if avg > 0 {
+					flag = fmt.Sprintf("  outlier, %sx the fleet", locale.Float(d.Rate/avg, 1))
+				}
+				outliers++
+			}
+		}
+		fmt.Printf("%-20s %-12s %10s %16s %16s %5d%s\n", d.Host, d.Drive, diskinfo.FormatBytes(d.Total),
+			analysis.FormatRate(d.Rate), peer, n, flag)
+	}
+	if outliers == 0 {
+		fmt.Println("\nNo drive grows much faster than its peers.")
+	}
+}
+
+// runFleet prints one row per machine in the history: its fullest drive, when
+// it last reported and which alerts its latest snapshot raises. With -growth it
+// compares the growth of each drive with the rest of the fleet instead.
+func runFleet(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("fleet", flag.ExitOnError)
+	by := fs.String("sort", "severity", "Order of the rows: severity, host or time")
+	growth := fs.Bool("growth", false, "Compare each drive's growth with similarly sized drives of the other machines")
+	windowFlag := fs.String("window", "30d", "History window the growth rates are fitted to")
+	factor := fs.Float64("factor", 3, "Flag drives growing this many times as fast as their peers")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	window, err := analysis.ParseDuration(*windowFlag)
+	if err != nil || window <= 0 {
+		return fmt.Errorf("invalid -window %q", *windowFlag)
+	}
+	if *factor <= 1 {
+		return fmt.Errorf("invalid -factor %g, it must be more than 1", *factor)
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+	if len(hist.Snapshots) == 0 {
+		fmt.Println("No history recorded yet.")
+		return nil
+	}
+	if *growth {
+		printFleetGrowth(hist, window, *factor)
+		return nil
+	}
+
+	rows := fleetRows(hist, cfg)
+	if err := sortFleet(rows, *by); err != nil {
+		return err
+	}
+
+	fmt.Printf("%-20s %-12s %7s %12s %-16s %s\n", "Host", "Worst drive", "Used", "Free", "Last report", "Alerts")
+	for _, r := range rows {
+		used := "-"
+		if r.Worst != "" {
+			used = locale.Percent(r.UsedPercent, 1)
+		}
+		fmt.Printf("%-20s %-12s %7s %12s %-16s %s\n", r.Host, r.Worst, used, diskinfo.FormatBytes(r.Free),
+			locale.DateTime(r.LastReport), r.state())
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// fragmentationSummary describes a fragmentation reading on one line
+func fragmentationSummary(f diskinfo.Fragmentation) string {
+	return fmt.Sprintf("%s of free space, %s free extents, largest %s (%s)",
+		locale.Percent(f.Percent(), 1), locale.Int(f.FreeExtents), diskinfo.FormatBytes(f.LargestFree), f.Media())
+}
+
+// runFragmentation prints the latest fragmentation reading of each drive and
+// how it changed since the first one recorded
+func runFragmentation(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("fragmentation", flag.ExitOnError)
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for _, drive := range selectDrives(hist, names) {
+		points := hist.FragmentationSeries(drive)
+		if len(points) == 0 {
+			continue
+		}
+		found = true
+		first, last := points[0], points[len(points)-1]
+		fmt.Printf("Drive %s:\n", drive)
+		fmt.Printf("  Fragment:  %s\n", fragmentationSummary(last.Fragmentation))
+		fmt.Printf("  Read:      %s\n", locale.DateTime(last.Time))
+		if len(points) > 1 {
+			fmt.Printf("  Change:    %s points, %s extents since %s\n",
+				locale.Signed(last.Percent()-first.Percent(), 1), locale.Signed(float64(int64(last.FreeExtents)-int64(first.FreeExtents)), 0),
+				locale.Date(first.Time))
+		}
+		fmt.Println()
+	}
+	if !found {
+		fmt.Println("No fragmentation recorded. Set fragmentation.enabled in the config and collect as administrator.")
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+	pb "github.com/valsaven/disk-monitor/proto/diskmonitor/v1"
+)
+
+// isGRPC reports whether a request is a gRPC call rather than a REST one
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// snapshotFeed hands every snapshot the daemon collects to the StreamSnapshots calls
+type snapshotFeed struct {
+	mu     sync.Mutex
+	subs   map[chan history.Snapshot]bool
+	closed bool
+}
+
+// newSnapshotFeed returns a feed without subscribers
+func newSnapshotFeed() *snapshotFeed {
+	return &snapshotFeed{subs: make(map[chan history.Snapshot]bool)}
+}
+
+// subscribe returns a channel of the snapshots published from now on, closed
+// with the feed, and a function to unsubscribe
+func (f *snapshotFeed) subscribe() (<-chan history.Snapshot, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan history.Snapshot, 8)
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	f.subs[ch] = true
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.subs[ch] {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends a snapshot to the subscribers. One that falls behind misses
+// it rather than holding up the daemon.
+func (f *snapshotFeed) publish(s history.Snapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- s:
+		default:
+		}
+	}
+}
+
+// close ends every subscription
+func (f *snapshotFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		close(ch)
+	}
+	f.subs = nil
+	f.closed = true
+}
+
+// grpcServer implements the DiskMonitor service of proto/diskmonitor/v1
+type grpcServer struct {
+	pb.UnimplementedDiskMonitorServer
+	// zone is the display zone of the history the calls read
+	zone     *time.Location
+	triggers chan<- triggerRequest
+	uploads  chan<- uploadRequest
+	feed     *snapshotFeed
+}
+
+// newGRPCServer returns the DiskMonitor service, served over the daemon's
+// HTTP/2 connections through its ServeHTTP. Every call needs the token as
+// "authorization: Bearer <token>" metadata.
+func newGRPCServer(token string, zone *time.Location, triggers chan<- triggerRequest, uploads chan<- uploadRequest, feed *snapshotFeed) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkGRPCToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkGRPCToken(ss.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	pb.RegisterDiskMonitorServer(srv, &grpcServer{zone: zone, triggers: triggers, uploads: uploads, feed: feed})
+	return srv
+}
+
+// checkGRPCToken rejects calls without the bearer token
+func checkGRPCToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(auth, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or wrong token")
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, _ *pb.GetStatusRequest) (*pb.Status, error) {
+	st, err := currentStatus(ctx, s.zone)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return statusToProto(st), nil
+}
+
+func (s *grpcServer) StreamSnapshots(_ *pb.StreamSnapshotsRequest, stream grpc.ServerStreamingServer[pb.Snapshot]) error {
+	snapshots, unsubscribe := s.feed.subscribe()
+	defer unsubscribe()
+	// Headers go out right away, so the client knows the stream is open
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case snapshot, ok := <-snapshots:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(snapshotToProto(snapshot)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *grpcServer) QueryHistory(ctx context.Context, req *pb.QueryHistoryRequest) (*pb.QueryHistoryResponse, error) {
+	from, err := timeFromProto(req.GetFrom())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "from: %v", err)
+	}
+	to, err := timeFromProto(req.GetTo())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "to: %v", err)
+	}
+	hist, err := loadHistorySince(ctx, s.zone, from)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if req.GetHost() != "" {
+		hist = hist.ForHost(req.GetHost(), history.LocalHost())
+	}
+	drive := ""
+	if req.GetDrive() != "" {
+		drive = normalizeDrive(req.GetDrive())
+	}
+	resp := &pb.QueryHistoryResponse{}
+	for _, snapshot := range hist.Snapshots {
+		if snapshot.Timestamp.Before(from) || (!to.IsZero() && !snapshot.Timestamp.Before(to)) {
+			continue
+		}
+		if drive != "" {
+			disks := snapshot.Disks
+			snapshot.Disks = nil
+			for _, d := range disks {
+				if strings.EqualFold(d.Drive, drive) {
+					snapshot.Disks = append(snapshot.Disks, d)
+				}
+			}
+			if len(snapshot.Disks) == 0 {
+				continue
+			}
+		}
+		resp.Snapshots = append(resp.Snapshots, snapshotToProto(snapshot))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) TriggerCollect(ctx context.Context, req *pb.TriggerCollectRequest) (*pb.Snapshot, error) {
+	trigger := triggerRequest{note: req.GetNote(), result: make(chan triggerResult, 1)}
+	select {
+	case s.triggers <- trigger:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	// The collection finishes even if the client gives up waiting
+	select {
+	case res := <-trigger.result:
+		if res.err != nil && len(res.snapshot.Disks) == 0 {
+			return nil, status.Error(codes.Internal, res.err.Error())
+		}
+		return snapshotToProto(res.snapshot), nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (s *grpcServer) Upload(ctx context.Context, req *pb.UploadRequest) (*pb.UploadResponse, error) {
+	snapshots, err := uploadFromProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res, ok := submitUpload(ctx, s.uploads, snapshots)
+	if !ok {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if res.err != nil {
+		return nil, status.Error(codes.Internal, res.err.Error())
+	}
+	return &pb.UploadResponse{Stored: uint32(res.stored), Duplicates: uint32(res.duplicates)}, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	pb "github.com/valsaven/disk-monitor/proto/diskmonitor/v1"
+)
+
+// testAPI serves the API of a daemon whose history is snapshots, in a home
+// directory of its own, and answers triggered collections with their note.
+// It returns a context carrying the token, a client of the gRPC API and the
+// URL of the REST API.
+func testAPI(t *testing.T, snapshots ...history.Snapshot) (context.Context, pb.DiskMonitorClient, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	config := `{"alerts": {"used_percent": 80}, "api": {"token": "secret"}}`
+	if err := os.WriteFile(filepath.Join(home, "disk_monitor_config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(cfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append(context.Background(), snapshots...); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	triggers := make(chan triggerRequest)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for {
+			select {
+			case req := <-triggers:
+				req.result <- triggerResult{snapshot: history.Snapshot{
+					Timestamp: time.Date(2026, 9, 8, 12, 0, 0, 0, time.UTC),
+					Host:      history.LocalHost(),
+					Note:      req.note,
+					Disks:     []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: 40, UsedSpace: 60}},
+				}}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	srv := httptest.NewUnstartedServer(newAPIHandler("secret", time.UTC, triggers, nil, newSnapshotFeed()))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(srv.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"), pb.NewDiskMonitorClient(conn), srv.URL
+}
+
+// hourAt is the time of the snapshot taken hour hours into 2026-09-07
+func hourAt(hour int) time.Time {
+	return time.Date(2026, 9, 7, hour, 0, 0, 0, time.UTC)
+}
+
+// wireFields returns the numbers of the fields a message encodes, in order
+func wireFields(t *testing.T, m proto.Message) []protowire.Number {
+	t.Helper()
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nums []protowire.Number
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		data = data[n:]
+		nums = append(nums, num)
+	}
+	return nums
+}
+
+func TestGRPCGetStatus(t *testing.T) {
+	host := history.LocalHost()
+	ctx, client, _ := testAPI(t,
+		history.Snapshot{Timestamp: hourAt(0), Host: host, Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: 50, UsedSpace: 50}}},
+		history.Snapshot{Timestamp: hourAt(1), Host: "nas", Disks: []diskinfo.DiskInfo{{Drive: `D:\`, TotalSpace: 100, FreeSpace: 1, UsedSpace: 99}}},
+		history.Snapshot{Timestamp: hourAt(2), Host: host, Note: "latest", Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: 10, UsedSpace: 90}}},
+	)
+
+	st, err := client.GetStatus(ctx, &pb.GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// version = 1, host = 2, latest = 3, alerts = 4, forecasts = 5
+	if got, want := wireFields(t, st), []protowire.Number{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("Status fields %v, want %v", got, want)
+	}
+	if st.GetHost() != host || st.GetVersion() != buildInfo().Version {
+		t.Errorf("GetStatus = %q, %q, want %q, %q", st.GetHost(), st.GetVersion(), host, buildInfo().Version)
+	}
+	if latest := st.GetLatest(); latest.GetNote() != "latest" || !latest.GetTimestamp().AsTime().Equal(hourAt(2)) {
+		t.Errorf("latest = %v, want the snapshot of %s", latest, hourAt(2))
+	}
+	if alerts := st.GetAlerts(); len(alerts) != 1 || alerts[0].GetDrive() != `C:\` || alerts[0].GetKind() != alertThreshold {
+		t.Errorf("alerts = %v, want the threshold alert of C:\\", alerts)
+	}
+	// C:\ went from 50 to 10 free in two hours
+	forecasts := st.GetForecasts()
+	if len(forecasts) != 1 || forecasts[0].GetDrive() != `C:\` || !forecasts[0].GetFilling() ||
+		math.Abs(forecasts[0].GetDaysUntilFull()-0.5/24) > 1e-9 || math.Abs(forecasts[0].GetRate()+20*24) > 1e-9 ||
+		!forecasts[0].GetEstimatedFullDate().AsTime().Equal(hourAt(2).Add(30*time.Minute)) {
+		t.Errorf("forecasts = %v, want C:\\ full half an hour after the latest snapshot", forecasts)
+	}
+	// drive = 1, rate = 2, filling = 3, days_until_full = 4, estimated_full_date = 5
+	if got := wireFields(t, forecasts[0]); len(got) < 5 || !slices.Equal(got[:5], []protowire.Number{1, 2, 3, 4, 5}) {
+		t.Errorf("Forecast fields %v, want 1 to 5 first", got)
+	}
+	// timestamp = 1, host = 2, note = 3, drives = 4
+	if got, want := wireFields(t, st.GetLatest()), []protowire.Number{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("Snapshot fields %v, want %v", got, want)
+	}
+	// drive = 1, total_space = 2, free_space = 3, used_space = 4
+	if got, want := wireFields(t, st.GetLatest().GetDrives()[0]), []protowire.Number{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("Drive fields %v, want %v", got, want)
+	}
+}
+
+func TestGRPCQueryHistory(t *testing.T) {
+	ctx, client, _ := testAPI(t,
+		history.Snapshot{Timestamp: hourAt(0), Host: "nas", Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100}}},
+		history.Snapshot{Timestamp: hourAt(1), Host: "nas", Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100}, {Drive: `D:\`, TotalSpace: 200}}},
+		history.Snapshot{Timestamp: hourAt(2), Host: "desktop", Disks: []diskinfo.DiskInfo{{Drive: `D:\`, TotalSpace: 200}}},
+		history.Snapshot{Timestamp: hourAt(3), Host: "nas", Disks: []diskinfo.DiskInfo{{Drive: `D:\`, TotalSpace: 200}}},
+	)
+
+	tests := []struct {
+		name string
+		req  *pb.QueryHistoryRequest
+		// want are the hours of the snapshots returned and drives their drives
+		want   []int
+		drives []string
+	}{
+		{name: "everything", req: &pb.QueryHistoryRequest{}, want: []int{0, 1, 2, 3},
+			drives: []string{`C:\`, `C:\`, `D:\`, `D:\`, `D:\`}},
+		{name: "from and to", req: &pb.QueryHistoryRequest{From: timestamppb.New(hourAt(1)), To: timestamppb.New(hourAt(3))},
+			want: []int{1, 2}, drives: []string{`C:\`, `D:\`, `D:\`}},
+		{name: "drive", req: &pb.QueryHistoryRequest{Drive: "d:"}, want: []int{1, 2, 3},
+			drives: []string{`D:\`, `D:\`, `D:\`}},
+		{name: "host and drive", req: &pb.QueryHistoryRequest{Host: "nas", Drive: "C"}, want: []int{0, 1},
+			drives: []string{`C:\`, `C:\`}},
+		{name: "nothing", req: &pb.QueryHistoryRequest{From: timestamppb.New(hourAt(4))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.QueryHistory(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// snapshots = 1
+			for _, num := range wireFields(t, resp) {
+				if num != 1 {
+					t.Fatalf("QueryHistoryResponse has field %d, want only snapshots = 1", num)
+				}
+			}
+			var hours []int
+			var drives []string
+			for _, s := range resp.GetSnapshots() {
+				hours = append(hours, int(s.GetTimestamp().AsTime().Sub(hourAt(0))/time.Hour))
+				for _, d := range s.GetDrives() {
+					drives = append(drives, d.GetDrive())
+				}
+			}
+			if !slices.Equal(hours, tt.want) || !slices.Equal(drives, tt.drives) {
+				t.Errorf("QueryHistory = hours %v drives %q, want %v %q", hours, drives, tt.want, tt.drives)
+			}
+		})
+	}
+
+	_, err := client.QueryHistory(ctx, &pb.QueryHistoryRequest{From: &timestamppb.Timestamp{Nanos: -1}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("QueryHistory of an invalid time = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestGRPCTriggerCollect(t *testing.T) {
+	ctx, client, _ := testAPI(t)
+
+	s, err := client.TriggerCollect(ctx, &pb.TriggerCollectRequest{Note: "after deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// timestamp = 1, host = 2, note = 3, drives = 4
+	if got, want := wireFields(t, s), []protowire.Number{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("Snapshot fields %v, want %v", got, want)
+	}
+	if s.GetNote() != "after deploy" || len(s.GetDrives()) != 1 || s.GetDrives()[0].GetUsedSpace() != 60 {
+		t.Errorf("TriggerCollect = %v, want the collected snapshot with its note", s)
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	_, client, _ := testAPI(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"no token", context.Background()},
+		{"wrong token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer guess")},
+		{"not a bearer token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "secret")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.GetStatus(tt.ctx, &pb.GetStatusRequest{}); status.Code(err) != codes.Unauthenticated {
+				t.Errorf("GetStatus = %v, want %v", err, codes.Unauthenticated)
+			}
+			stream, err := client.StreamSnapshots(tt.ctx, &pb.StreamSnapshotsRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("StreamSnapshots = %v, want %v", err, codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestAPIStatus(t *testing.T) {
+	host := history.LocalHost()
+	_, _, url := testAPI(t,
+		history.Snapshot{Timestamp: hourAt(0), Host: host, Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: 50, UsedSpace: 50}}},
+		history.Snapshot{Timestamp: hourAt(2), Host: host, Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: 10, UsedSpace: 90}}},
+	)
+
+	req, err := http.NewRequest(http.MethodGet, url+"/api/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/status = %s", resp.Status)
+	}
+	var st daemonStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Host != host || st.Latest == nil || !st.Latest.Timestamp.Equal(hourAt(2)) || len(st.Alerts) != 1 {
+		t.Errorf("GET /api/status = %+v, want the latest snapshot of %s and its alert", st, host)
+	}
+	if len(st.Forecasts) != 1 || !st.Forecasts[0].Filling || !st.Forecasts[0].EstimatedFullDate.Equal(hourAt(2).Add(30*time.Minute)) ||
+		st.Forecasts[0].EarliestFullDate.After(st.Forecasts[0].EstimatedFullDate) {
+		t.Errorf("forecasts = %+v, want C:\\ full half an hour after the latest snapshot", st.Forecasts)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// printHealth prints one health reading and the limits it breaks
+func printHealth(h diskinfo.Health, limits diskinfo.HealthLimits) {
+	if h.Serial != "" {
+		fmt.Printf("  Serial:    %s\n", h.Serial)
+	}
+	fmt.Printf("  Used:      %s of rated endurance\n", locale.Percent(float64(h.PercentageUsed), 0))
+	fmt.Printf("  Spare:     %s (threshold %s)\n", locale.Percent(float64(h.AvailableSpare), 0), locale.Percent(float64(h.SpareThreshold), 0))
+	fmt.Printf("  Errors:    %d media errors\n", h.MediaErrors)
+	if h.DataWritten > 0 {
+		fmt.Printf("  Written:   %s\n", diskinfo.FormatBytes(h.DataWritten))
+	}
+	for _, problem := range limits.Problems(h) {
+		fmt.Printf("  Warning:   %s\n", problem)
+	}
+}
+
+// runHealth prints the latest NVMe health of each disk, how it changed since
+// the first reading and how fast it is written to
+func runHealth(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	disks, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	names := hist.HealthDisks()
+	if len(names) == 0 {
+		fmt.Println("No health readings recorded, only NVMe disks report them.")
+		return nil
+	}
+	for _, name := range names {
+		points := hist.HealthSeries(name)
+		first, last := points[0], points[len(points)-1]
+		if !selectedDisk(last.Health, disks) {
+			continue
+		}
+
+		fmt.Printf("%s, %s:\n", last.Label(), locale.DateTime(last.Time))
+		printHealth(last.Health, cfg.Health.HealthLimits)
+		if len(points) > 1 {
+			fmt.Printf("  Since %s: %+d%% used, %+d%% spare, %+d media errors\n",
+				locale.Date(first.Time), last.PercentageUsed-first.PercentageUsed,
+				last.AvailableSpare-first.AvailableSpare, int64(last.MediaErrors-first.MediaErrors))
+		}
+		if rates := analysis.WriteRates(points, time.Now()); len(rates) > 0 {
+			fmt.Print("  Writes:   ")
+			for _, r := range rates {
+				fmt.Printf(" %s %s", r.Window, analysis.FormatWriteRate(r.BytesPerDay))
+			}
+			fmt.Println()
+			// The longest window smooths out busy days
+			if days, ok := analysis.EnduranceDaysLeft(last.Health, rates[len(rates)-1].BytesPerDay); ok {
+				fmt.Printf("  Endurance: %s\n", analysis.FormatEndurance(days))
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// selectedDisk reports whether a disk is picked by serial or number, all are without args
+func selectedDisk(h diskinfo.Health, args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	for _, arg := range args {
+		if strings.EqualFold(arg, h.Name()) || arg == fmt.Sprint(h.Disk) {
+			return true
+		}
+	}
+	return false
+}
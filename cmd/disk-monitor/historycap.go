@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// thinningSteps are tried in order until the history fits its cap: snapshots
+// older than age are kept at one per every
+var thinningSteps = []struct {
+	age, every time.Duration
+}{
+	{7 * 24 * time.Hour, time.Hour},
+	{30 * 24 * time.Hour, 6 * time.Hour},
+	{90 * 24 * time.Hour, 24 * time.Hour},
+	{365 * 24 * time.Hour, 7 * 24 * time.Hour},
+}
+
+// capHeadroom is the share of the cap the history is cut down to, so it
+// isn't thinned again on the very next collection
+const capHeadroom = 0.9
+
+// enforceHistoryCap thins and then prunes the oldest snapshots once the history
+// grows past storage.max_size or storage.max_snapshots. A dry run is given a
+// store in memory and only reports what was removed from it. load returns the
+// whole history, which is only read with a cap set.
+func enforceHistoryCap(ctx context.Context, cfg *Config, store history.Store, load func() (*history.History, error), now time.Time, dryRun bool) error {
+	var maxSize uint64
+	if cfg.Storage.MaxSize != "" {
+		var err error
+		if maxSize, err = diskinfo.ParseSize(cfg.Storage.MaxSize); err != nil {
+			return fmt.Errorf("invalid max_size: %v", err)
+		}
+	}
+	maxSnapshots := cfg.Storage.MaxSnapshots
+	if maxSize == 0 && maxSnapshots <= 0 {
+		return nil
+	}
+	hist, err := load()
+	if err != nil {
+		return err
+	}
+	count := len(hist.Snapshots)
+	if count == 0 {
+		return nil
+	}
+
+	// A sharded history is as large as all its files
+	var size uint64
+	files, err := history.ShardFiles(cfg.Storage.Backend, cfg.Storage.Path, cfg.Storage.Shard)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			size += uint64(info.Size())
+		}
+	}
+
+	overSize := maxSize > 0 && size > maxSize
+	overCount := maxSnapshots > 0 && count > maxSnapshots
+	if !overSize && !overCount {
+		return nil
+	}
+
+	// The size cap becomes a snapshot count by the average size of a snapshot
+	target := count
+	if maxSnapshots > 0 {
+		target = min(target, int(float64(maxSnapshots)*capHeadroom))
+	}
+	if overSize {
+		perSnapshot := float64(size) / float64(count)
+		target = min(target, int(float64(maxSize)*capHeadroom/perSnapshot))
+	}
+	target = max(target, 1)
+
+	thinned, left := 0, count
+	for _, step := range thinningSteps {
+		if left <= target {
+			break
+		}
+		n, err := store.Thin(ctx, now.Add(-step.age), step.every)
+		if err != nil {
+			return fmt.Errorf("failed to thin history: %v", err)
+		}
+		thinned += n
+		left -= n
+	}
+
+	// Still too much even at a week apart, the oldest go
+	pruned := 0
+	if left > target {
+		snapshots, err := store.Query(ctx, time.Time{}, time.Time{}, "")
+		if err != nil {
+			return err
+		}
+		if len(snapshots) > target {
+			if pruned, err = store.Prune(ctx, snapshots[len(snapshots)-target].Timestamp); err != nil {
+				return fmt.Errorf("failed to prune history: %v", err)
+			}
+		}
+	}
+
+	if err := store.Compact(ctx); err != nil {
+		return fmt.Errorf("failed to compact history: %v", err)
+	}
+	if dryRun {
+		fmt.Printf("History over its cap, would keep %d snapshots: %d thinned out, %d oldest pruned\n",
+			count-thinned-pruned, thinned, pruned)
+		return nil
+	}
+	fmt.Printf("History over its cap, kept %d snapshots: %d thinned out, %d oldest pruned\n",
+		count-thinned-pruned, thinned, pruned)
+	return nil
+}
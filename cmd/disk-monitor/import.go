@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Formats the import command reads
+const (
+	formatPerfmon         = "perfmon"
+	formatSmartctl        = "smartctl"
+	formatCrystalDiskInfo = "crystaldiskinfo"
+)
+
+// importFormats lists the formats for messages
+var importFormats = strings.Join([]string{formatPerfmon, formatSmartctl, formatCrystalDiskInfo}, ", ")
+
+// runImport backfills the history from the records of other tools
+func runImport(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "Format of the files: "+importFormats+" (default detected from each file)")
+	host := fs.String("host", "", "Machine the records are from (default the one named in the file)")
+	every := fs.String("every", "", "Keep one sample per period, e.g. 1h (default all)")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be imported")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("usage: import [-format format] [-host name] [-every 1h] <file>...")
+	}
+	var period time.Duration
+	if *every != "" {
+		if period, err = analysis.ParseDuration(*every); err != nil || period <= 0 {
+			return fmt.Errorf("invalid -every %q", *every)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var store history.Store
+	if *dryRun {
+		store, err = openDryRunStore(ctx, cfg)
+	} else {
+		store, err = openStore(cfg, zone)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	// Readings the records lack, like the size of a drive, come from the history
+	stored, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var snapshots []history.Snapshot
+	for _, path := range files {
+		f := *format
+		if f == "" {
+			if f, err = detectImportFormat(path); err != nil {
+				return err
+			}
+		}
+		var imported []history.Snapshot
+		switch f {
+		case formatPerfmon:
+			imported, err = importPerfmonLog(ctx, path, *host, stored)
+		case formatSmartctl:
+			imported, err = importSmartctl(path, *host, stored)
+		case formatCrystalDiskInfo:
+			imported, err = importCrystalDiskInfo(path, *host, stored)
+		default:
+			return fmt.Errorf("unknown -format %q, use one of: %s", f, importFormats)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		snapshots = append(snapshots, imported...)
+	}
+	snapshots = mergeSameTime(snapshots)
+	if period > 0 {
+		snapshots = onePerPeriod(snapshots, period)
+	}
+
+	pending, duplicates, err := unstoredSnapshots(ctx, store, snapshots)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		if duplicates > 0 {
+			fmt.Printf("Nothing to import, the history has all %d snapshots\n", duplicates)
+		} else {
+			fmt.Println("Nothing to import")
+		}
+		return nil
+	}
+	first, last := pending[0].Timestamp, pending[0].Timestamp
+	for _, s := range pending {
+		if s.Timestamp.Before(first) {
+			first = s.Timestamp
+		}
+		if s.Timestamp.After(last) {
+			last = s.Timestamp
+		}
+	}
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	} else if err := store.Append(ctx, pending...); err != nil {
+		return err
+	}
+	fmt.Printf("%s %d snapshots from %s to %s", verb, len(pending), locale.DateTime(first.In(zone)), locale.DateTime(last.In(zone)))
+	if duplicates > 0 {
+		fmt.Printf(", %d were already in the history", duplicates)
+	}
+	fmt.Println()
+	return nil
+}
+
+// detectImportFormat tells the format of a file from its extension or first bytes
+func detectImportFormat(path string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".blg") {
+		return formatPerfmon, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 1024)
+	n, _ := f.Read(head)
+	head = decodeText(head[:n])
+	switch {
+	case bytes.HasPrefix(head, []byte(`"(PDH-CSV`)):
+		return formatPerfmon, nil
+	case bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")) && bytes.Contains(head, []byte(`"smartctl"`)):
+		return formatSmartctl, nil
+	case bytes.Contains(head, []byte("CrystalDiskInfo")):
+		return formatCrystalDiskInfo, nil
+	}
+	return "", fmt.Errorf("can't tell the format of %s, give -format", path)
+}
+
+// readText reads a text file, converting the UTF-16 Windows tools may write to UTF-8
+func readText(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeText(data), nil
+}
+
+// decodeText converts UTF-16 with a byte order mark to UTF-8 and drops a UTF-8 one
+func decodeText(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	}
+	units := make([]uint16, (len(data)-2)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2+2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// importHost returns the machine imported records are stored under, the local
+// one when the file and -host name none
+func importHost(host string) string {
+	if host == "" || strings.EqualFold(host, history.LocalHost()) {
+		return history.LocalHost()
+	}
+	return host
+}
+
+// mergeSameTime joins the snapshots of a host taken at the same time, e.g. the
+// readings of two disks imported from separate files
+func mergeSameTime(snapshots []history.Snapshot) []history.Snapshot {
+	index := make(map[string]int)
+	var merged []history.Snapshot
+	for _, s := range snapshots {
+		key := fmt.Sprintf("%s %d", s.Host, s.Timestamp.UnixNano())
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, s)
+			continue
+		}
+		merged[i].Disks = append(merged[i].Disks, s.Disks...)
+		merged[i].Health = append(merged[i].Health, s.Health...)
+	}
+	return merged
+}
+
+// onePerPeriod keeps the first snapshot of each host in each period
+func onePerPeriod(snapshots []history.Snapshot, period time.Duration) []history.Snapshot {
+	slices.SortStableFunc(snapshots, func(a, b history.Snapshot) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	seen := make(map[string]bool)
+	var kept []history.Snapshot
+	for _, s := range snapshots {
+		key := fmt.Sprintf("%s %d", s.Host, s.Timestamp.UnixNano()/int64(period))
+		if !seen[key] {
+			seen[key] = true
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// The LogicalDisk counters a perfmon log is read for
+const (
+	perfmonFreeMB  = "Free Megabytes"
+	perfmonFreePct = "% Free Space"
+)
+
+// perfmonCounter matches the columns of those counters, e.g.
+// \\DESKTOP-4F2K9\LogicalDisk(C:)\Free Megabytes
+var perfmonCounter = regexp.MustCompile(`^(?:\\\\([^\\]+))?\\LogicalDisk\(([A-Za-z]:)\)\\(Free Megabytes|% Free Space)$`)
+
+// perfmonBias matches the time zone bias ending the first header of a perfmon
+// CSV, "(PDH-CSV 4.0) (W. Europe Standard Time)(-60)"
+var perfmonBias = regexp.MustCompile(`\((-?\d+)\)\s*$`)
+
+// perfmonDrive is where a drive's counters are in the rows of a perfmon CSV
+type perfmonDrive struct {
+	drive           string
+	freeMB, freePct int
+	// total is the size of the drive from the history or estimated from the
+	// log, 0 when neither has it
+	total      uint64
+	fileSystem string
+}
+
+// importPerfmonLog reads the free space of the drives from a perfmon CSV, or
+// a BLG log converted with relog. Perfmon doesn't record the size of a drive;
+// the latest snapshot of the drive in stored has it, else % Free Space.
+func importPerfmonLog(ctx context.Context, path, host string, stored *history.History) ([]history.Snapshot, error) {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".blg") {
+		data, err = relogCSV(ctx, path)
+	} else {
+		data, err = readText(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("not a perfmon CSV: %v", err)
+	}
+	if !strings.HasPrefix(header[0], "(PDH-CSV") {
+		return nil, fmt.Errorf("not a perfmon CSV, it starts with %q", header[0])
+	}
+	zone := time.Local
+	if m := perfmonBias.FindStringSubmatch(header[0]); m != nil {
+		// The bias is the minutes to add to the local time to get UTC
+		bias, _ := strconv.Atoi(m[1])
+		zone = time.FixedZone("", -bias*60)
+	}
+
+	byDrive := make(map[string]*perfmonDrive)
+	machine := ""
+	for i, column := range header {
+		m := perfmonCounter.FindStringSubmatch(column)
+		if m == nil {
+			continue
+		}
+		if machine == "" {
+			machine = m[1]
+		}
+		drive := normalizeDrive(m[2])
+		d := byDrive[drive]
+		if d == nil {
+			d = &perfmonDrive{drive: drive, freeMB: -1, freePct: -1}
+			byDrive[drive] = d
+		}
+		if m[3] == perfmonFreeMB {
+			d.freeMB = i
+		} else {
+			d.freePct = i
+		}
+	}
+	if host == "" {
+		host = machine
+	}
+	host = importHost(host)
+
+	var drives []*perfmonDrive
+	for _, d := range byDrive {
+		if d.freeMB >= 0 {
+			drives = append(drives, d)
+		}
+	}
+	if len(drives) == 0 {
+		return nil, fmt.Errorf("no \\LogicalDisk(*)\\%s counters", perfmonFreeMB)
+	}
+	sort.Slice(drives, func(i, j int) bool { return drives[i].drive < drives[j].drive })
+	hostHist := stored.ForHost(host, history.LocalHost())
+	for _, d := range drives {
+		for i := len(hostHist.Snapshots) - 1; i >= 0 && d.total == 0; i-- {
+			for _, disk := range hostHist.Snapshots[i].Disks {
+				if strings.EqualFold(disk.Drive, d.drive) {
+					d.total, d.fileSystem = disk.TotalSpace, disk.FileSystem
+				}
+			}
+		}
+	}
+
+	// Rows are read first, the sizes to estimate need all of them
+	type row struct {
+		time time.Time
+		free map[*perfmonDrive]uint64
+	}
+	var rows []row
+	estimates := make(map[*perfmonDrive][]float64)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.ParseInLocation("01/02/2006 15:04:05.000", record[0], zone)
+		if err != nil {
+			if t, err = time.ParseInLocation("01/02/2006 15:04:05", record[0], zone); err != nil {
+				return nil, fmt.Errorf("invalid time %q", record[0])
+			}
+		}
+		rw := row{time: t, free: make(map[*perfmonDrive]uint64)}
+		for _, d := range drives {
+			mb, ok := perfmonValue(record, d.freeMB)
+			if !ok {
+				// Blank while the drive wasn't there
+				continue
+			}
+			free := uint64(mb) << 20
+			rw.free[d] = free
+			if pct, ok := perfmonValue(record, d.freePct); ok && pct > 0 && d.total == 0 {
+				estimates[d] = append(estimates[d], float64(free)*100/pct)
+			}
+		}
+		if len(rw.free) > 0 {
+			rows = append(rows, rw)
+		}
+	}
+
+	for _, d := range drives {
+		if d.total > 0 || len(estimates[d]) == 0 {
+			continue
+		}
+		// The average size, in whole megabytes like the free space
+		var sum float64
+		for _, e := range estimates[d] {
+			sum += e
+		}
+		d.total = uint64(sum/float64(len(estimates[d]))+(1<<19)) >> 20 << 20
+	}
+
+	var snapshots []history.Snapshot
+	skipped := make(map[string]bool)
+	for _, rw := range rows {
+		s := history.Snapshot{Timestamp: rw.time, Host: host}
+		for _, d := range drives {
+			free, ok := rw.free[d]
+			if !ok {
+				continue
+			}
+			if d.total == 0 {
+				skipped[d.drive] = true
+				continue
+			}
+			total := max(d.total, free)
+			s.Disks = append(s.Disks, diskinfo.DiskInfo{
+				Drive:      d.drive,
+				TotalSpace: total,
+				FreeSpace:  free,
+				UsedSpace:  total - free,
+				FileSystem: d.fileSystem,
+			})
+		}
+		if len(s.Disks) > 0 {
+			snapshots = append(snapshots, s)
+		}
+	}
+	for _, d := range drives {
+		if skipped[d.drive] {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %s, the log has no %q for it and the history doesn't know its size\n", d.drive, perfmonFreePct)
+		}
+	}
+	return snapshots, nil
+}
+
+// perfmonValue parses a column of a row, blank when the counter had no value
+func perfmonValue(record []string, column int) (float64, bool) {
+	if column < 0 || column >= len(record) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// relogCSV converts a binary perfmon log to CSV with relog, which comes with
+// Windows, keeping only the LogicalDisk counters
+func relogCSV(ctx context.Context, path string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "disk-monitor-relog")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "log.csv")
+	cmd := exec.CommandContext(ctx, "relog.exe", path,
+		"-c", `\LogicalDisk(*)\`+perfmonFreeMB, `\LogicalDisk(*)\`+perfmonFreePct,
+		"-f", "csv", "-o", out, "-y")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("reading a .blg log needs relog.exe, which comes with Windows; convert it there with \"relog %s -f csv -o log.csv\"", filepath.Base(path))
+		}
+		return nil, fmt.Errorf("relog failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.ReadFile(out)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// smartctlOutput is the part of "smartctl -j -a" output an import reads
+type smartctlOutput struct {
+	Device struct {
+		Name     string `json:"name"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	LocalTime    struct {
+		TimeT int64 `json:"time_t"`
+	} `json:"local_time"`
+	NVMeLog *struct {
+		CriticalWarning         uint8  `json:"critical_warning"`
+		AvailableSpare          int    `json:"available_spare"`
+		AvailableSpareThreshold int    `json:"available_spare_threshold"`
+		PercentageUsed          int    `json:"percentage_used"`
+		DataUnitsWritten        uint64 `json:"data_units_written"`
+		MediaErrors             uint64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// smartctlDisk matches the device names smartctl gives Windows disks, /dev/pd0
+// or /dev/sda for PhysicalDrive0
+var smartctlDisk = regexp.MustCompile(`^/dev/(?:pd(\d+)|sd([a-z]))$`)
+
+// importSmartctl reads the NVMe health of smartctl JSON output, one run or
+// several appended to the file. Runs at the same time form one snapshot.
+func importSmartctl(path, host string, stored *history.History) ([]history.Snapshot, error) {
+	data, err := readText(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []history.Snapshot
+	byTime := make(map[int64]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var out smartctlOutput
+		if err := dec.Decode(&out); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("not smartctl JSON output: %v", err)
+		}
+		if out.NVMeLog == nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %s, only NVMe health is recorded\n", out.Device.Name)
+			continue
+		}
+		if out.LocalTime.TimeT == 0 {
+			return nil, fmt.Errorf("%s has no local_time, run smartctl with -a", out.Device.Name)
+		}
+
+		h := diskinfo.Health{
+			Model:           out.ModelName,
+			Serial:          out.SerialNumber,
+			CriticalWarning: out.NVMeLog.CriticalWarning,
+			PercentageUsed:  out.NVMeLog.PercentageUsed,
+			AvailableSpare:  out.NVMeLog.AvailableSpare,
+			SpareThreshold:  out.NVMeLog.AvailableSpareThreshold,
+			MediaErrors:     out.NVMeLog.MediaErrors,
+			DataWritten:     out.NVMeLog.DataUnitsWritten * diskinfo.NVMeDataUnit,
+		}
+		if m := smartctlDisk.FindStringSubmatch(out.Device.Name); m != nil {
+			if m[1] != "" {
+				h.Disk, _ = strconv.Atoi(m[1])
+			} else {
+				h.Disk = int(m[2][0] - 'a')
+			}
+		}
+		i, ok := byTime[out.LocalTime.TimeT]
+		if !ok {
+			i = len(snapshots)
+			byTime[out.LocalTime.TimeT] = i
+			snapshots = append(snapshots, history.Snapshot{Timestamp: time.Unix(out.LocalTime.TimeT, 0), Host: importHost(host)})
+		}
+		snapshots[i].Health = append(snapshots[i].Health, h)
+	}
+	numberDisks(snapshots, stored)
+	return snapshots, nil
+}
+
+// crystalDiskHeader matches the line starting a disk of a CrystalDiskInfo
+// export, " (1) Samsung SSD 980 PRO 1TB"; the disk list has " : <size>" after it
+var crystalDiskHeader = regexp.MustCompile(`^\s*\((\d+)\)\s+[^:]+$`)
+
+// crystalAttribute matches a row of the S.M.A.R.T. table of an NVMe disk,
+// "05 000000000003 Percentage Used"
+var crystalAttribute = regexp.MustCompile(`^([0-9A-F]{2})\s+([0-9A-F]+)\s+\S`)
+
+// importCrystalDiskInfo reads the NVMe health of a CrystalDiskInfo text
+// export. Its date is the local time of the machine it was saved on.
+func importCrystalDiskInfo(path, host string, stored *history.History) ([]history.Snapshot, error) {
+	data, err := readText(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var taken time.Time
+	var health []diskinfo.Health
+	// disk is the disk being read, nil outside of an NVMe disk's section
+	var disk *diskinfo.Health
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if m := crystalDiskHeader.FindStringSubmatch(line); m != nil {
+			number, _ := strconv.Atoi(m[1])
+			health = append(health, diskinfo.Health{Disk: number - 1})
+			disk = &health[len(health)-1]
+			continue
+		}
+		if key, value, ok := strings.Cut(line, " : "); ok {
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "Date":
+				if taken, err = time.ParseInLocation("2006/01/02 15:04:05", value, time.Local); err != nil {
+					return nil, fmt.Errorf("invalid date %q", value)
+				}
+			case "Model":
+				if disk != nil {
+					disk.Model = value
+				}
+			case "Serial Number":
+				if disk != nil {
+					disk.Serial = value
+				}
+			case "Interface":
+				if disk != nil && value != "NVM Express" {
+					fmt.Fprintf(os.Stderr, "Warning: skipped %s, only NVMe health is recorded\n", disk.Label())
+					health, disk = health[:len(health)-1], nil
+				}
+			}
+			continue
+		}
+		m := crystalAttribute.FindStringSubmatch(line)
+		if m == nil || disk == nil {
+			continue
+		}
+		raw, err := strconv.ParseUint(m[2], 16, 64)
+		if err != nil {
+			continue
+		}
+		// The IDs are the fields of the NVMe health log in order
+		switch m[1] {
+		case "01":
+			disk.CriticalWarning = uint8(raw)
+		case "03":
+			disk.AvailableSpare = int(raw)
+		case "04":
+			disk.SpareThreshold = int(raw)
+		case "05":
+			disk.PercentageUsed = int(raw)
+		case "07":
+			disk.DataWritten = raw * diskinfo.NVMeDataUnit
+		case "0E":
+			disk.MediaErrors = raw
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if taken.IsZero() {
+		return nil, fmt.Errorf("not a CrystalDiskInfo export, it has no Date")
+	}
+	if len(health) == 0 {
+		return nil, nil
+	}
+
+	snapshots := []history.Snapshot{{Timestamp: taken, Host: importHost(host), Health: health}}
+	numberDisks(snapshots, stored)
+	return snapshots, nil
+}
+
+// numberDisks gives the imported readings of a disk the number it has in the
+// stored readings with the same serial, the files may count disks differently
+func numberDisks(snapshots []history.Snapshot, stored *history.History) {
+	numbers := make(map[string]int)
+	for _, s := range stored.Snapshots {
+		for _, h := range s.Health {
+			if h.Serial != "" {
+				numbers[h.Serial] = h.Disk
+			}
+		}
+	}
+	for i := range snapshots {
+		for j := range snapshots[i].Health {
+			h := &snapshots[i].Health[j]
+			if n, ok := numbers[h.Serial]; ok && h.Serial != "" {
+				h.Disk = n
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// Log formats
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogging installs the default slog logger described by cfg.
+// The returned closer closes the log file, if any.
+func setupLogging(cfg LogConfig) (io.Closer, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q, use debug, info, warn or error", cfg.Level)
+		}
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = io.NopCloser(nil)
+	if cfg.File != "" {
+		var maxSize uint64
+		if cfg.MaxSize != "" {
+			var err error
+			if maxSize, err = diskinfo.ParseSize(cfg.MaxSize); err != nil {
+				return nil, fmt.Errorf("invalid log max_size: %v", err)
+			}
+		}
+		f, err := openRotatingFile(cfg.File, int64(maxSize), cfg.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		out, closer = f, f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", logFormatText:
+		handler = slog.NewTextHandler(out, opts)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("invalid log format %q, use text or json", cfg.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// rotatingFile is a log file that is renamed to .1, .2, ... when it grows past maxSize.
+// Only maxFiles rotated files are kept.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// openRotatingFile opens the log file for appending, 0 maxSize never rotates
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current file and picks up its size
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would take the file past maxSize
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up by one and starts a new file.
+// Windows can't rename an open file, so the current one is closed first.
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	if r.maxFiles < 1 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		os.Rename(r.path, r.path+".1")
+	}
+	return r.open()
+}
+
+// Close closes the current file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
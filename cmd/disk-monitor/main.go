@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/valsaven/disk-monitor/internal/tui"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// loadHistory loads the full history from the configured store
+func loadHistory(ctx context.Context, zone *time.Location) (*history.History, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	hist, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addJournaled(cfg, zone, hist, time.Time{})
+	return hist, nil
+}
+
+// loadHistorySince loads the snapshots taken since from, and all baselines
+func loadHistorySince(ctx context.Context, zone *time.Location, from time.Time) (*history.History, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	hist, err := history.LoadWindow(ctx, store, from)
+	if err != nil {
+		return nil, err
+	}
+	addJournaled(cfg, zone, hist, from)
+	return hist, nil
+}
+
+// collectAndSave collects data and saves to history (CLI mode), returning the
+// snapshot and the drives locked by BitLocker.
+// Non-nil drives collects only those of the drives and monitored paths, the
+// others keep their alerts and count as present, see driveSchedule.
+// A non-nil journal receives the snapshot instead of the store, see runDaemon.
+// A dry run prints what would be saved and removed without writing anything
+// or notifying the sinks.
+// It returns the drives that are locked by BitLocker, which aren't saved.
+func collectAndSave(ctx context.Context, zone *time.Location, note string, drives []string, journal *history.Journal, dryRun bool) (history.Snapshot, []string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return history.Snapshot{}, nil, err
+	}
+
+	var targets, skipped []string
+	for _, target := range diskinfo.Targets(cfg.Paths) {
+		if drives == nil || slices.ContainsFunc(drives, func(d string) bool { return strings.EqualFold(d, target) }) {
+			targets = append(targets, target)
+		} else {
+			skipped = append(skipped, target)
+		}
+	}
+	disks, errs := diskinfo.CollectDrives(ctx, cfg.Collection.Workers, targets)
+	if err := ctx.Err(); err != nil {
+		return history.Snapshot{}, nil, err
+	}
+	if len(cfg.Cloud) > 0 {
+		cloud, cloudErrs := diskinfo.CollectCloud(ctx, cfg.Cloud)
+		disks = append(disks, cloud...)
+		errs = append(errs, cloudErrs...)
+	}
+	if len(disks) == 0 {
+		if len(errs) > 0 {
+			return history.Snapshot{}, lockedDrives(errs), errors.Join(errs...)
+		}
+		return history.Snapshot{}, nil, fmt.Errorf("no drives found")
+	}
+	// The drives that answered are still saved
+	for _, err := range errs {
+		if errors.Is(err, diskinfo.ErrDriveLocked) {
+			slog.Info("drive locked", "err", err)
+			continue
+		}
+		slog.Warn("drive skipped", "err", err)
+	}
+	if cfg.ReFS.Savings {
+		if err := diskinfo.CollectSavings(ctx, disks); err != nil {
+			slog.Warn("space savings skipped", "err", err)
+		}
+	}
+	if cfg.Files.Enabled {
+		for _, err := range diskinfo.CollectFiles(ctx, disks) {
+			slog.Warn("file records skipped", "err", err)
+		}
+	}
+
+	snapshot := history.Snapshot{
+		Timestamp: time.Now(),
+		Disks:     disks,
+		Note:      note,
+		Host:      history.LocalHost(),
+	}
+	if cfg.Health.Enabled {
+		health, errs := diskinfo.CollectHealth(ctx)
+		for _, err := range errs {
+			slog.Warn("health skipped", "err", err)
+		}
+		snapshot.Health = health
+	}
+	if cfg.Pools.Enabled {
+		pools, err := diskinfo.CollectPools(ctx)
+		if err != nil {
+			slog.Warn("storage pools skipped", "err", err)
+		}
+		snapshot.Pools = pools
+	}
+	if cfg.Fragmentation.Enabled {
+		frags, errs := diskinfo.CollectFragmentation(ctx, disks)
+		for _, err := range errs {
+			slog.Warn("fragmentation skipped", "err", err)
+		}
+		snapshot.Fragmentation = frags
+	}
+	if cfg.Trim.Enabled {
+		statuses, errs := diskinfo.CollectTrim(ctx, disks)
+		for _, err := range errs {
+			slog.Warn("TRIM status skipped", "err", err)
+		}
+		snapshot.Trim = statuses
+	}
+	if cfg.OneDrive.Enabled {
+		folders, errs := diskinfo.CollectOneDrive(ctx, cfg.OneDrive.Folders)
+		for _, err := range errs {
+			slog.Warn("OneDrive folder skipped", "err", err)
+		}
+		snapshot.OneDrive = folders
+	}
+
+	var store history.Store
+	if dryRun {
+		store, err = openDryRunStore(ctx, cfg)
+	} else {
+		store, err = openStore(cfg, zone)
+	}
+	if err != nil {
+		return snapshot, nil, err
+	}
+	defer store.Close()
+
+	if cfg.Updates.Enabled {
+		updates, err := collectUpdates(ctx, cfg, zone, store, snapshot.Timestamp)
+		if err != nil {
+			slog.Warn("update events skipped", "err", err)
+		}
+		snapshot.Updates = updates
+	}
+
+	if journal != nil && !dryRun {
+		err = journal.Write(snapshot)
+	} else {
+		err = store.Append(ctx, snapshot)
+	}
+	if err != nil {
+		return snapshot, nil, err
+	}
+	slog.Debug("snapshot saved", "drives", len(disks), "backend", cfg.Storage.Backend, "journal", journal != nil, "dry_run", dryRun)
+	// The alerts only read the recent history. Retiring drives, the size cap,
+	// reports and backups read all of it, when they have something to do.
+	hist, err := loadAlertHistory(ctx, cfg, zone, store, snapshot.Timestamp)
+	if err != nil {
+		return snapshot, nil, err
+	}
+	loadAll := sync.OnceValues(func() (*history.History, error) {
+		all, err := store.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		addJournaled(cfg, zone, all, time.Time{})
+		return all, nil
+	})
+
+	if dryRun {
+		fmt.Printf("Dry run, disk data that would be saved to %s:\n", historyPath(cfg))
+	} else {
+		fmt.Println("Disk data saved:")
+	}
+	fmt.Printf("Time: %s\n", locale.Timestamp(snapshot.Timestamp.In(zone)))
+	if note != "" {
+		fmt.Printf("Note: %s\n", note)
+	}
+	fmt.Println("----------------------------------------")
+	others, images := diskinfo.GroupImages(disks)
+	for i, disk := range slices.Concat(others, images) {
+		if i == len(others) {
+			fmt.Println("Mounted images:")
+			fmt.Println()
+		}
+		fmt.Printf("Drive %s:\n", disk.Drive)
+		fmt.Printf("  Total:     %s\n", diskinfo.FormatBytes(disk.TotalSpace))
+		fmt.Printf("  Free:      %s\n", diskinfo.FormatBytes(disk.FreeSpace))
+		if disk.QuotaLimited() {
+			fmt.Printf("  Volume:    %s free, limited by a quota\n", diskinfo.FormatBytes(disk.VolumeFree))
+		}
+		fmt.Printf("  Used:      %s\n", diskinfo.FormatBytes(disk.UsedSpace))
+		fmt.Printf("  Used:      %s\n", locale.Percent(float64(disk.UsedSpace)/float64(disk.TotalSpace)*100, 1))
+		if disk.IsReFS() {
+			fmt.Printf("  File sys:  %s\n", disk.FileSystemLabel())
+		}
+		if disk.IsImage() {
+			fmt.Printf("  Image:     mounted from a %s file\n", strings.ToUpper(disk.Image))
+		}
+		if disk.Savings > 0 {
+			fmt.Printf("  Savings:   %s by deduplication, %s of data\n",
+				diskinfo.FormatBytes(disk.Savings), diskinfo.FormatBytes(disk.LogicalUsed()))
+		}
+		if disk.MaxFiles > 0 {
+			fmt.Printf("  Files:     %s of %s (%s)\n", locale.Int(disk.Files), locale.Int(disk.MaxFiles), locale.Percent(disk.FilesPercent(), 1))
+		}
+		for _, frag := range snapshot.Fragmentation {
+			if frag.Drive == disk.Drive {
+				fmt.Printf("  Fragment:  %s\n", fragmentationSummary(frag))
+			}
+		}
+		for _, t := range snapshot.Trim {
+			if t.Drive == disk.Drive {
+				t.LastOptimized = t.LastOptimized.In(zone)
+				fmt.Printf("  TRIM:      %s\n", t.Summary())
+			}
+		}
+		for _, od := range snapshot.OneDrive {
+			if strings.EqualFold(od.Drive(), disk.Drive) {
+				fmt.Printf("  OneDrive:  %s\n", od.Folder)
+				fmt.Printf("             %s\n", od.Summary())
+			}
+		}
+		fmt.Println()
+	}
+	for _, err := range errs {
+		fmt.Printf("Drive %v\n\n", err)
+	}
+	for _, health := range snapshot.Health {
+		fmt.Printf("%s:\n", health.Label())
+		printHealth(health, cfg.Health.HealthLimits)
+		fmt.Println()
+	}
+	for _, pool := range snapshot.Pools {
+		printPool(pool)
+		fmt.Println()
+	}
+	if len(snapshot.Updates) > 0 {
+		fmt.Println("Updates installed since the last collection:")
+		for _, u := range snapshot.Updates {
+			fmt.Printf("  %s  %s\n", locale.Short(u.Time.In(zone)), u.Title)
+		}
+		fmt.Println()
+	}
+
+	// Drives that failed to answer are still attached
+	present := make(map[string]bool)
+	for _, disk := range disks {
+		present[disk.Drive] = true
+	}
+	for _, drive := range skipped {
+		present[drive] = true
+	}
+	for _, err := range errs {
+		var de *diskinfo.DriveError
+		if errors.As(err, &de) {
+			present[de.Drive] = true
+		}
+	}
+	if err := retireRemovedDrives(ctx, cfg, store, loadAll, present, snapshot.Timestamp, dryRun); err != nil {
+		slog.Error("failed to retire removed drives", "err", err)
+	}
+	if err := enforceHistoryCap(ctx, cfg, store, loadAll, snapshot.Timestamp, dryRun); err != nil {
+		slog.Error("failed to enforce history cap", "err", err)
+	}
+	if dryRun {
+		fmt.Println("Nothing was written; sinks, scheduled reports and backups were skipped")
+		return snapshot, lockedDrives(errs), nil
+	}
+
+	// Broken sinks don't fail the collection
+	sinks, err := loadSinks(cfg)
+	if err != nil {
+		slog.Error("failed to load sinks", "err", err)
+	}
+	ev := newEvent(snapshot, evaluateAlerts(hist, cfg))
+	// Silenced alerts are only logged, so planned work doesn't notify anyone
+	silences, err := loadSilences(snapshot.Timestamp)
+	if err != nil {
+		slog.Error("failed to load silences", "err", err)
+	}
+	windows, err := cfg.Alerts.maintenance()
+	if err != nil {
+		slog.Error("maintenance windows ignored", "err", err)
+	}
+	sent, silenced := silenceAlerts(ev.Alerts, silences, windows, snapshot.Timestamp)
+	var held []Alert
+	for reason, alerts := range silenced {
+		for _, a := range alerts {
+			slog.Info("alert silenced", "kind", a.Kind, "drive", a.Drive, "reason", reason, "message", a.Message)
+		}
+		held = append(held, alerts...)
+	}
+	// The alert state keeps restarts from notifying of the same alerts again
+	repeat, err := cfg.Alerts.repeatInterval()
+	if err != nil {
+		slog.Error("alert repeat ignored", "err", err)
+	}
+	tracker, err := loadAlertTracker(ctx, store, snapshot.Host, snapshot.Timestamp, repeat)
+	if err != nil {
+		slog.Error("alert state ignored", "err", err)
+	}
+	tracker.hold(skipped)
+	ev.Alerts = append(sent, tracker.update(sent, held)...)
+	policies, err := cfg.Alerts.escalation(cfg.Sinks)
+	if err != nil {
+		slog.Error("alert policies ignored", "err", err)
+	}
+	routing := newAlertRouting(policies, hist, cfg, ev.Host, ev.Alerts)
+	for _, err := range dispatch(ctx, sinks, ev, routing, tracker) {
+		slog.Error("sink failed", "err", err)
+	}
+	if err := tracker.save(ctx, store); err != nil {
+		slog.Error("failed to save alert state", "err", err)
+	}
+
+	// A failed report shouldn't fail the collection, it is retried next time
+	if sent, err := sendScheduledReport(ctx, zone, loadAll, cfg, snapshot.Timestamp); err != nil {
+		slog.Error("scheduled report failed", "err", err)
+	} else if sent {
+		fmt.Printf("Scheduled %s report sent to %s\n", cfg.Reports.Schedule, cfg.Reports.To)
+	}
+	if key, err := sendScheduledBackup(ctx, loadAll, cfg, snapshot.Timestamp); err != nil {
+		slog.Error("history backup failed", "err", err)
+	} else if key != "" {
+		fmt.Printf("History backed up to %s/%s\n", cfg.Backup.Bucket, key)
+	}
+	// The local history keeps the snapshot when the upload fails, and so does the queue
+	if cfg.Upload.URL != "" {
+		if n, err := uploadSnapshots(ctx, cfg.Upload, snapshot); err != nil {
+			slog.Error("upload failed", "err", err, "sent", n)
+		} else {
+			slog.Debug("snapshots uploaded", "sent", n, "url", cfg.Upload.URL)
+		}
+	}
+
+	return snapshot, lockedDrives(errs), nil
+}
+
+// lockedDrives returns the drives among errs that are locked by BitLocker
+func lockedDrives(errs []error) []string {
+	var locked []string
+	for _, err := range errs {
+		var de *diskinfo.DriveError
+		if errors.As(err, &de) && errors.Is(err, diskinfo.ErrDriveLocked) {
+			locked = append(locked, de.Drive)
+		}
+	}
+	return locked
+}
+
+// runInteractive shows the graph view until the user quits
+func runInteractive(ctx context.Context, zone *time.Location) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	tcfg := tui.Config{
+		Store:        store,
+		Smoothing:    cfg.Chart.Smoothing,
+		Window:       cfg.Chart.Window,
+		Forecast:     cfg.Forecast,
+		Anomaly:      cfg.Anomaly,
+		Workers:      cfg.Collection.Workers,
+		Paths:        cfg.Paths,
+		Cloud:        cfg.Cloud,
+		UTC:          zone == time.UTC,
+		LocalZone:    time.Local,
+		Health:       cfg.Health.Enabled,
+		HealthLimits: cfg.Health.HealthLimits,
+		Pools:        cfg.Pools.Enabled,
+		Savings:      cfg.ReFS.Savings,
+	}
+	model, err := tui.New(ctx, tcfg)
+	if err != nil {
+		return err
+	}
+	// Loading may have recovered a corrupt history, which the view keeps pointing out
+	if store.recovery != nil {
+		tcfg.Warning = store.recovery.String()
+		if model, err = tui.New(ctx, tcfg); err != nil {
+			return err
+		}
+	}
+
+	p := tea.NewProgram(
+		model,
+		tea.WithAltScreen(),
+		tea.WithContext(ctx),
+	)
+	_, err = p.Run()
+	return err
+}
+
+// Display time zones
+const (
+	timeZoneLocal = "local"
+	timeZoneUTC   = "utc"
+)
+
+// location returns the zone times are shown in, the machine's or UTC, and the
+// machine's with the error of an invalid time_zone. utc is set with -utc,
+// which overrides a local time_zone. main resolves it once and passes it
+// down: history loaded through openStore is already in it, other times are
+// converted with In, so every printed time and day or week boundary follows it.
+func (c DisplayConfig) location(utc bool) (*time.Location, error) {
+	switch strings.ToLower(c.TimeZone) {
+	case "", timeZoneLocal:
+		if utc {
+			return time.UTC, nil
+		}
+		return time.Local, nil
+	case timeZoneUTC:
+		return time.UTC, nil
+	}
+	return time.Local, fmt.Errorf("invalid time_zone %q, use local or utc", c.TimeZone)
+}
+
+// fail logs the error that ends the program and exits with status 1.
+// Log writes aren't buffered, so nothing is lost by skipping deferred calls.
+func fail(msg string, err error, args ...any) {
+	if errors.Is(err, history.ErrHistoryCorrupt) {
+		args = append(args, "hint", "automatic recovery failed, restore the history file from a backup or move it away to start a new one")
+	}
+	slog.Error(msg, append(args, "err", err)...)
+	os.Exit(1)
+}
+
+func main() {
+	showGraphFlag := flag.Bool("graph", false, "Show interactive graph")
+	logLevel := flag.String("log-level", "", "Log level: debug, info, warn or error (default from config)")
+	logFormat := flag.String("log-format", "", "Log format: text or json (default from config)")
+	logFile := flag.String("log-file", "", "Write the log to this file instead of stderr")
+	simulate := flag.String("simulate", "", "Query the simulated drives of a fixture file instead of the real ones")
+	utc := flag.Bool("utc", false, "Show times in UTC instead of local time")
+	flag.StringVar(&configProfile, "profile", "", "Apply the named profile of the config file")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	// A broken config is reported by the command that loads it
+	cfg, _ := loadConfig()
+	if *logLevel != "" {
+		cfg.Log.Level = *logLevel
+	}
+	if *logFormat != "" {
+		cfg.Log.Format = *logFormat
+	}
+	if *logFile != "" {
+		cfg.Log.File = *logFile
+	}
+	logCloser, err := setupLogging(cfg.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	defer logCloser.Close()
+
+	zone, err := cfg.Display.location(*utc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if err := locale.Set(cfg.Display.Locale); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if err := diskinfo.SetUnits(diskinfo.Units(cfg.Display.Units)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	policy, drivePolicies, err := cfg.Collection.queryPolicies()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	diskinfo.SetQueryPolicies(policy, drivePolicies)
+	if err := diskinfo.SetImages(diskinfo.Images(cfg.Collection.Images)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := cfg.Collection.intervals(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := cfg.Alerts.escalation(cfg.Sinks); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := cfg.Alerts.maintenance(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := cfg.Alerts.repeatInterval(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *simulate != "" {
+		fake, err := diskinfo.LoadFake(*simulate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		diskinfo.SetSystem(fake)
+	}
+
+	// Ctrl+C cancels drive queries, network calls and scans in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if flag.NArg() > 0 {
+		cmd := findCommand(flag.Arg(0))
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", flag.Arg(0))
+			printUsage()
+			os.Exit(2)
+		}
+		if err := cmd.run(ctx, zone, flag.Args()[1:]); err != nil {
+			var status exitStatus
+			if errors.As(err, &status) {
+				os.Exit(int(status))
+			}
+			fail("command failed", err, "command", cmd.name)
+		}
+		return
+	}
+
+	if *showGraphFlag {
+		// Run interactive mode
+		if err := runInteractive(ctx, zone); err != nil {
+			fail("interactive mode failed", err)
+		}
+	} else {
+		// Just collect and save data
+		if _, _, err := collectAndSave(ctx, zone, "", nil, nil, false); err != nil {
+			fail("collection failed", err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// runMerge combines history files, e.g. from before a reinstall or from
+// several machines, into a new one
+func runMerge(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("out", "", "History file to write, its extension picks the backend (.json, .jsonl, .db or .bolt)")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be merged")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 || *out == "" {
+		return fmt.Errorf("usage: merge -out merged.json <file>[=host] <file>[=host]...")
+	}
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("%s already exists", *out)
+	}
+
+	merged := &history.History{}
+	seen := make(map[string]bool)
+	baselines := make(map[string]bool)
+	duplicates := 0
+	for _, arg := range files {
+		path, host := mergeArg(arg)
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		h, err := loadHistoryFile(ctx, path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, s := range h.Snapshots {
+			// Untagged snapshots are the machine's that kept the file
+			if s.Host == "" {
+				s.Host = host
+			}
+			key := fmt.Sprintf("%s %d", s.Host, s.Timestamp.UnixNano())
+			if seen[key] {
+				duplicates++
+				continue
+			}
+			seen[key] = true
+			merged.Snapshots = append(merged.Snapshots, s)
+		}
+		for _, b := range h.Baselines {
+			if baselines[b.Name] {
+				fmt.Fprintf(os.Stderr, "Warning: skipped baseline %q of %s, an earlier file has one by that name\n", b.Name, path)
+				continue
+			}
+			baselines[b.Name] = true
+			merged.Baselines = append(merged.Baselines, b)
+		}
+	}
+
+	slices.SortStableFunc(merged.Snapshots, func(a, b history.Snapshot) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	verb := "Merged"
+	if *dryRun {
+		verb = "Would merge"
+	} else if err := saveHistoryFile(ctx, *out, merged); err != nil {
+		return err
+	}
+	fmt.Printf("%s %d snapshots of %s from %d files into %s", verb, len(merged.Snapshots),
+		strings.Join(merged.Hosts(history.LocalHost()), ", "), len(files), *out)
+	if duplicates > 0 {
+		fmt.Printf(", %d duplicates skipped", duplicates)
+	}
+	fmt.Println()
+	return nil
+}
+
+// mergeArg splits a merge argument into the file and the machine its untagged
+// snapshots are from, "old-pc.json=OLD-PC", the local one without a name
+func mergeArg(arg string) (string, string) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, history.LocalHost()
+	}
+	path, host, ok := strings.Cut(arg, "=")
+	if !ok || host == "" {
+		return arg, history.LocalHost()
+	}
+	return path, host
+}
+
+// loadHistoryFile reads an existing history file of any backend, told by its extension
+func loadHistoryFile(ctx context.Context, path string) (*history.History, error) {
+	store, err := history.Open(history.BackendOf(path), path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.Load(ctx)
+}
+
+// saveHistoryFile writes a history into a new file of the backend its extension names
+func saveHistoryFile(ctx context.Context, path string, h *history.History) error {
+	store, err := history.Open(history.BackendOf(path), path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Append(ctx, h.Snapshots...); err != nil {
+		return err
+	}
+	if len(h.Baselines) > 0 {
+		return store.SaveBaselines(ctx, h.Baselines)
+	}
+	return nil
+}
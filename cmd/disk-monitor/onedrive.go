@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// runOneDrive prints the latest reading of each OneDrive folder and how much
+// of it was downloaded since the first one recorded
+func runOneDrive(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("onedrive", flag.ExitOnError)
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	folders := hist.OneDriveFolders()
+	if len(folders) == 0 {
+		fmt.Println("No OneDrive folders recorded.")
+		return nil
+	}
+	for _, folder := range folders {
+		points := hist.OneDriveSeries(folder)
+		first, last := points[0], points[len(points)-1]
+		fmt.Printf("OneDrive %s:\n", folder)
+		fmt.Printf("  Logical:   %s in %d files\n", diskinfo.FormatBytes(last.Logical), last.Files)
+		fmt.Printf("  On disk:   %s\n", diskinfo.FormatBytes(last.OnDisk))
+		fmt.Printf("  Online:    %s in %d online-only files\n", diskinfo.FormatBytes(last.OnlineOnlySize()), last.OnlineOnly)
+		if last.Pinned > 0 {
+			fmt.Printf("  Pinned:    %s always kept on this device\n", diskinfo.FormatBytes(last.Pinned))
+		}
+		fmt.Printf("  Read:      %s\n", locale.DateTime(last.Time))
+		if len(points) > 1 {
+			fmt.Printf("  Change:    %s on disk, %s online-only since %s\n",
+				diskinfo.FormatChange(float64(last.OnDisk)-float64(first.OnDisk)),
+				diskinfo.FormatChange(float64(last.OnlineOnlySize())-float64(first.OnlineOnlySize())),
+				locale.Date(first.Time))
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// upgradeSizes are common drive capacities, suggested as the smallest upgrade that covers the need
+var upgradeSizes = []uint64{
+	128 << 30, 256 << 30, 500 << 30,
+	1 << 40, 2 << 40, 4 << 40, 8 << 40, 12 << 40, 16 << 40, 20 << 40, 24 << 40,
+}
+
+// CapacityPlan is the budgeting summary of one drive
+type CapacityPlan struct {
+	Drive    string
+	Used     uint64
+	Total    uint64
+	Forecast *analysis.Forecast
+	// Growth is the increase of used space in bytes per day
+	Growth float64
+	// Needed is the extra capacity required to stay above the headroom until the horizon
+	Needed uint64
+	// Upgrade is the smallest common size covering Needed, 0 if it exceeds all of them
+	Upgrade uint64
+	// NeededBy is when free space drops below the headroom, zero if not within the horizon
+	NeededBy time.Time
+}
+
+// smallestUpgrade returns the smallest common drive size of at least needed bytes
+func smallestUpgrade(needed uint64) uint64 {
+	for _, size := range upgradeSizes {
+		if size >= needed {
+			return size
+		}
+	}
+	return 0
+}
+
+// planCapacity works out how much extra capacity a drive needs to keep headroom
+// percent free for the next horizon, at its current growth rate
+func planCapacity(hist *history.History, drive string, cfg analysis.ForecastConfig, horizon time.Duration, headroom float64, now time.Time) (*CapacityPlan, error) {
+	f, err := analysis.ForecastDrive(hist, drive, cfg, now)
+	if err != nil {
+		return nil, err
+	}
+	points := hist.Series(drive, time.Time{})
+	last := points[len(points)-1]
+
+	p := &CapacityPlan{
+		Drive:    drive,
+		Used:     last.Total - last.Free,
+		Total:    last.Total,
+		Forecast: f,
+		Growth:   -f.Rate,
+	}
+	if p.Growth <= 0 {
+		return p, nil
+	}
+
+	days := horizon.Hours() / 24
+	projectedUsed := float64(p.Used) + p.Growth*days
+	required := projectedUsed / (1 - headroom/100)
+	if required > float64(p.Total) {
+		p.Needed = uint64(required - float64(p.Total))
+		p.Upgrade = smallestUpgrade(p.Needed)
+	}
+
+	// When does free space cross the headroom line?
+	limit := float64(p.Total) * (1 - headroom/100)
+	if untilLimit := (limit - float64(p.Used)) / p.Growth; untilLimit <= days {
+		p.NeededBy = last.Time.Add(time.Duration(math.Max(untilLimit, 0) * 24 * float64(time.Hour)))
+	}
+
+	return p, nil
+}
+
+// formatUpgrade describes the upgrade a plan calls for
+func formatUpgrade(p *CapacityPlan, horizon time.Duration, now time.Time) string {
+	months := horizon.Hours() / 24 / 30
+	if p.Needed == 0 {
+		return fmt.Sprintf("no upgrade needed within %s months", locale.Float(months, 0))
+	}
+
+	size := "more than " + diskinfo.FormatBytes(upgradeSizes[len(upgradeSizes)-1])
+	if p.Upgrade > 0 {
+		size = diskinfo.FormatBytes(p.Upgrade)
+	}
+	when := "now"
+	if d := p.NeededBy.Sub(now); d > 0 {
+		when = fmt.Sprintf("within %s months (by %s)", locale.Float(math.Ceil(d.Hours()/24/30), 0), locale.Date(p.NeededBy))
+	}
+	return fmt.Sprintf("needs +%s %s (short by %s)", size, when, diskinfo.FormatBytes(p.Needed))
+}
+
+// runPlan prints a capacity-planning summary per drive
+func runPlan(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	months := fs.Int("months", 12, "Planning horizon in months")
+	headroom := fs.Float64("headroom", 10, "Percentage of each drive to keep free")
+	fs.StringVar(&cfg.Forecast.Window, "window", cfg.Forecast.Window, "History window the growth rate is fitted over, e.g. 90d")
+	drives, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *headroom < 0 || *headroom >= 100 {
+		return fmt.Errorf("-headroom must be between 0 and 100")
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().In(zone)
+	horizon := time.Duration(*months) * 30 * 24 * time.Hour
+	for _, drive := range selectDrives(hist, drives) {
+		p, err := planCapacity(hist, drive, cfg.Forecast, horizon, *headroom, now)
+		if err != nil {
+			fmt.Printf("Drive %s: %v\n\n", drive, err)
+			continue
+		}
+
+		fmt.Printf("Drive %s:\n", drive)
+		fmt.Printf("  Used:      %s of %s (%s)\n", diskinfo.FormatBytes(p.Used), diskinfo.FormatBytes(p.Total), locale.Percent(percentOf(p.Used, p.Total), 1))
+		fmt.Printf("  Growth:    %s (last %s)\n", analysis.FormatRate(p.Growth), cfg.Forecast.Window)
+		fmt.Printf("  Full:      %s\n", analysis.FormatForecast(p.Forecast, now))
+		fmt.Printf("  Upgrade:   %s\n", formatUpgrade(p, horizon, now))
+		fmt.Println()
+	}
+
+	return nil
+}
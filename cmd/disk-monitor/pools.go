@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// printPool prints a pool's allocation and its virtual disks
+func printPool(p diskinfo.StoragePool) {
+	fmt.Printf("Pool %s:\n", p.Name)
+	fmt.Printf("  Size:      %s\n", diskinfo.FormatBytes(p.Size))
+	fmt.Printf("  Allocated: %s (%s)\n", diskinfo.FormatBytes(p.Allocated), locale.Percent(p.UsedPercent(), 1))
+	fmt.Printf("  Free:      %s\n", diskinfo.FormatBytes(p.Free()))
+	for _, vd := range p.VirtualDisks {
+		kind := "fixed"
+		if vd.Thin {
+			kind = "thin"
+		}
+		fmt.Printf("  Virtual:   %s, %s %s, %s allocated, %s of the pool\n", vd.Name, diskinfo.FormatBytes(vd.Size), kind,
+			diskinfo.FormatBytes(vd.Allocated), diskinfo.FormatBytes(vd.Footprint))
+	}
+	if p.Overcommitted() {
+		fmt.Printf("  Warning:   thin disks can grow by %s, more than the pool has free\n", diskinfo.FormatBytes(p.Growth()))
+	}
+}
+
+// poolPoints turns pool readings into the points growth rates are fitted to
+func poolPoints(points []history.PoolPoint) []history.Point {
+	result := make([]history.Point, len(points))
+	for i, p := range points {
+		result[i] = history.Point{Time: p.Time, Free: p.Free(), Total: p.Size}
+	}
+	return result
+}
+
+// runPools prints the latest reading of each Storage Spaces pool and how fast
+// its allocation grows
+func runPools(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("pools", flag.ExitOnError)
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	pools := hist.Pools()
+	if len(pools) == 0 {
+		fmt.Println("No Storage Spaces pools recorded.")
+		return nil
+	}
+	now := time.Now()
+	for _, name := range pools {
+		if len(names) > 0 && !containsFold(names, name) {
+			continue
+		}
+		points := hist.PoolSeries(name, time.Time{})
+		last := points[len(points)-1]
+		printPool(last.StoragePool)
+
+		var rates []string
+		for _, w := range analysis.GrowthWindows {
+			d, _ := analysis.ParseDuration(w)
+			if rate, ok := analysis.FitRate(poolPoints(hist.PoolSeries(name, now.Add(-d)))); ok {
+				rates = append(rates, fmt.Sprintf("%s %s", w, analysis.FormatRate(rate)))
+			}
+		}
+		if len(rates) > 0 {
+			fmt.Printf("  Growth:    %s\n", strings.Join(rates, " "))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
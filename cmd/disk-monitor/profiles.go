@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// ProfileSize is the size of one user profile folder
+type ProfileSize struct {
+	Name   string
+	Path   string
+	Size   uint64
+	Errors int
+}
+
+// defaultProfilesRoot returns the folder holding the user profiles, usually C:\Users
+func defaultProfilesRoot() string {
+	return filepath.Join(os.Getenv("SystemDrive")+"\\", "Users")
+}
+
+// measureProfiles sizes each profile folder under root, biggest first
+func measureProfiles(ctx context.Context, root string, opts scanOptions) ([]ProfileSize, error) {
+	opts.Depth = 0
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []ProfileSize
+	for _, entry := range entries {
+		// Junctions like "All Users" or "Default User" aren't directories here
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		result, err := scanDirectory(ctx, path, opts, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		profiles = append(profiles, ProfileSize{
+			Name:   entry.Name(),
+			Path:   path,
+			Size:   result.Tree.Size,
+			Errors: result.Errors,
+		})
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Size > profiles[j].Size
+	})
+
+	return profiles, nil
+}
+
+// printProfiles prints a profile size table
+func printProfiles(profiles []ProfileSize) {
+	var total uint64
+	for _, p := range profiles {
+		total += p.Size
+	}
+	for _, p := range profiles {
+		line := fmt.Sprintf("  %-24s %10s  %7s", p.Name, diskinfo.FormatBytes(p.Size), locale.Percent(percentOf(p.Size, total), 1))
+		if p.Errors > 0 {
+			line += fmt.Sprintf("  (%d entries could not be read)", p.Errors)
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("  %-24s %10s\n", "Total", diskinfo.FormatBytes(total))
+}
+
+// runProfiles reports the size of each user profile
+func runProfiles(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("profiles", flag.ExitOnError)
+	root := fs.String("root", defaultProfilesRoot(), "Folder containing the user profiles")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := newScanOptions(cfg.Scan, nil)
+	if err != nil {
+		return err
+	}
+
+	profiles, err := measureProfiles(ctx, *root, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User profiles in %s:\n", *root)
+	printProfiles(profiles)
+	if len(profiles) > 0 && profiles[0].Errors > 0 {
+		fmt.Println("\nRun as administrator to size other users' profiles completely.")
+	}
+
+	return nil
+}
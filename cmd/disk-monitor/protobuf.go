@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	pb "github.com/valsaven/disk-monitor/proto/diskmonitor/v1"
+)
+
+// errProtobuf is returned for messages that don't decode
+var errProtobuf = errors.New("invalid protobuf message")
+
+// timestampToProto converts a time, nil for the zero time
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timeFromProto converts a timestamp, the zero time when it is unset
+func timeFromProto(ts *timestamppb.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return time.Time{}, err
+	}
+	return ts.AsTime(), nil
+}
+
+// driveToProto converts a drive to a diskmonitor.v1.Drive
+func driveToProto(d diskinfo.DiskInfo) *pb.Drive {
+	return &pb.Drive{
+		Drive:            d.Drive,
+		TotalSpace:       d.TotalSpace,
+		FreeSpace:        d.FreeSpace,
+		UsedSpace:        d.UsedSpace,
+		VolumeFree:       d.VolumeFree,
+		FileSystem:       d.FileSystem,
+		BlockClone:       d.BlockClone,
+		IntegrityStreams: d.IntegrityStreams,
+		DevDrive:         d.DevDrive,
+		Savings:          d.Savings,
+		Files:            d.Files,
+		MaxFiles:         d.MaxFiles,
+		Image:            d.Image,
+	}
+}
+
+// driveFromProto converts a diskmonitor.v1.Drive
+func driveFromProto(m *pb.Drive) diskinfo.DiskInfo {
+	return diskinfo.DiskInfo{
+		Drive:            m.GetDrive(),
+		TotalSpace:       m.GetTotalSpace(),
+		FreeSpace:        m.GetFreeSpace(),
+		UsedSpace:        m.GetUsedSpace(),
+		VolumeFree:       m.GetVolumeFree(),
+		FileSystem:       m.GetFileSystem(),
+		BlockClone:       m.GetBlockClone(),
+		IntegrityStreams: m.GetIntegrityStreams(),
+		DevDrive:         m.GetDevDrive(),
+		Savings:          m.GetSavings(),
+		Files:            m.GetFiles(),
+		MaxFiles:         m.GetMaxFiles(),
+		Image:            m.GetImage(),
+	}
+}
+
+// snapshotToProto converts a snapshot to a diskmonitor.v1.Snapshot
+func snapshotToProto(s history.Snapshot) *pb.Snapshot {
+	m := &pb.Snapshot{
+		Timestamp: timestampToProto(s.Timestamp),
+		Host:      s.Host,
+		Note:      s.Note,
+		Details:   snapshotDetails(s),
+	}
+	for _, d := range s.Disks {
+		m.Drives = append(m.Drives, driveToProto(d))
+	}
+	return m
+}
+
+// snapshotDetails returns the readings of a snapshot the Snapshot message has
+// no fields for as JSON, nil when there are none
+func snapshotDetails(s history.Snapshot) []byte {
+	details := history.Snapshot{
+		Health:        s.Health,
+		Pools:         s.Pools,
+		Benchmarks:    s.Benchmarks,
+		Fragmentation: s.Fragmentation,
+		Trim:          s.Trim,
+		OneDrive:      s.OneDrive,
+		Updates:       s.Updates,
+	}
+	if len(details.Health)+len(details.Pools)+len(details.Benchmarks)+len(details.Fragmentation)+
+		len(details.Trim)+len(details.OneDrive)+len(details.Updates) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(details)
+	return data
+}
+
+// snapshotFromProto converts a diskmonitor.v1.Snapshot
+func snapshotFromProto(m *pb.Snapshot) (history.Snapshot, error) {
+	var s history.Snapshot
+	var err error
+	if s.Timestamp, err = timeFromProto(m.GetTimestamp()); err != nil {
+		return s, fmt.Errorf("%w: timestamp: %v", errProtobuf, err)
+	}
+	s.Timestamp = s.Timestamp.UTC()
+	s.Host, s.Note = m.GetHost(), m.GetNote()
+	for _, d := range m.GetDrives() {
+		s.Disks = append(s.Disks, driveFromProto(d))
+	}
+	if details := m.GetDetails(); len(details) > 0 {
+		var d history.Snapshot
+		if err := json.Unmarshal(details, &d); err != nil {
+			return s, fmt.Errorf("%w: details: %v", errProtobuf, err)
+		}
+		s.Health, s.Pools, s.Benchmarks = d.Health, d.Pools, d.Benchmarks
+		s.Fragmentation, s.Trim = d.Fragmentation, d.Trim
+		s.OneDrive, s.Updates = d.OneDrive, d.Updates
+	}
+	return s, nil
+}
+
+// alertToProto converts an alert to a diskmonitor.v1.Alert
+func alertToProto(a Alert) *pb.Alert {
+	return &pb.Alert{
+		Kind:     a.Kind,
+		Severity: a.Severity,
+		Drive:    a.Drive,
+		Time:     timestampToProto(a.Time),
+		Message:  a.Message,
+	}
+}
+
+// forecastToProto converts a forecast to a diskmonitor.v1.Forecast
+func forecastToProto(f driveForecast) *pb.Forecast {
+	return &pb.Forecast{
+		Drive:             f.Drive,
+		Rate:              f.Rate,
+		Filling:           f.Filling,
+		DaysUntilFull:     f.DaysUntilFull,
+		EstimatedFullDate: timestampToProto(f.EstimatedFullDate),
+		EarliestFullDate:  timestampToProto(f.EarliestFullDate),
+		LatestFullDate:    timestampToProto(f.LatestFullDate),
+	}
+}
+
+// statusToProto converts a status to a diskmonitor.v1.Status
+func statusToProto(st *daemonStatus) *pb.Status {
+	m := &pb.Status{Version: st.Version, Host: st.Host}
+	if st.Latest != nil {
+		m.Latest = snapshotToProto(*st.Latest)
+	}
+	for _, a := range st.Alerts {
+		m.Alerts = append(m.Alerts, alertToProto(a))
+	}
+	for _, f := range st.Forecasts {
+		m.Forecasts = append(m.Forecasts, forecastToProto(f))
+	}
+	return m
+}
+
+// uploadFromProto returns the snapshots of a diskmonitor.v1.UploadRequest,
+// which all need a host
+func uploadFromProto(m *pb.UploadRequest) ([]history.Snapshot, error) {
+	var snapshots []history.Snapshot
+	for _, ms := range m.GetSnapshots() {
+		s, err := snapshotFromProto(ms)
+		if err != nil {
+			return nil, err
+		}
+		if s.Host == "" {
+			return nil, fmt.Errorf("%w: snapshot of %s without a host", errProtobuf, s.Timestamp.Format(time.RFC3339))
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// encodeUpload encodes a diskmonitor.v1.UploadRequest
+func encodeUpload(snapshots []history.Snapshot) ([]byte, error) {
+	req := &pb.UploadRequest{}
+	for _, s := range snapshots {
+		req.Snapshots = append(req.Snapshots, snapshotToProto(s))
+	}
+	return proto.Marshal(req)
+}
+
+// decodeUpload decodes a diskmonitor.v1.UploadRequest
+func decodeUpload(data []byte) ([]history.Snapshot, error) {
+	var req pb.UploadRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", errProtobuf, err)
+	}
+	return uploadFromProto(&req)
+}
+
+// encodeUploadResult encodes a diskmonitor.v1.UploadResponse
+func encodeUploadResult(res uploadResult) []byte {
+	data, _ := proto.Marshal(&pb.UploadResponse{Stored: uint32(res.stored), Duplicates: uint32(res.duplicates)})
+	return data
+}
+
+// decodeUploadResult decodes a diskmonitor.v1.UploadResponse
+func decodeUploadResult(data []byte) (uploadResult, error) {
+	var resp pb.UploadResponse
+	if err := proto.Unmarshal(data, &resp); err != nil {
+		return uploadResult{}, fmt.Errorf("%w: %v", errProtobuf, err)
+	}
+	return uploadResult{stored: int(resp.GetStored()), duplicates: int(resp.GetDuplicates())}, nil
+}
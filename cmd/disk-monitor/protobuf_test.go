@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	pb "github.com/valsaven/disk-monitor/proto/diskmonitor/v1"
+)
+
+// fullSnapshot has every field the Snapshot and Drive messages carry set,
+// and readings that go in details
+func fullSnapshot() history.Snapshot {
+	return history.Snapshot{
+		Timestamp: time.Date(2026, 9, 7, 12, 30, 15, 123456789, time.UTC),
+		Host:      "nas",
+		Note:      "after deploy",
+		Disks: []diskinfo.DiskInfo{
+			{Drive: `C:\`, TotalSpace: 500 << 30, FreeSpace: 100 << 30, UsedSpace: 400 << 30, VolumeFree: 120 << 30,
+				FileSystem: "ReFS", BlockClone: true, IntegrityStreams: true, DevDrive: true, Savings: 3 << 30,
+				Files: 900, MaxFiles: 1000, Image: "vhdx"},
+			{Drive: `\\nas\backups\`, TotalSpace: 1 << 40, FreeSpace: 1 << 39, UsedSpace: 1 << 39},
+		},
+		Trim:    []diskinfo.TrimStatus{{Drive: `C:\`, Supported: true, Enabled: true}},
+		Updates: []diskinfo.UpdateEvent{{Time: time.Date(2026, 9, 6, 0, 0, 0, 0, time.UTC), Title: "KB5043076"}},
+	}
+}
+
+func TestDriveToProto(t *testing.T) {
+	// A field added to Drive needs converting both ways
+	m := driveToProto(fullSnapshot().Disks[0])
+	fields := m.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if f := fields.Get(i); !m.ProtoReflect().Has(f) {
+			t.Errorf("field %s = %d isn't set", f.Name(), f.Number())
+		}
+	}
+}
+
+func TestUploadRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []history.Snapshot
+	}{
+		{name: "every field", snapshots: []history.Snapshot{fullSnapshot()}},
+		{name: "bare", snapshots: []history.Snapshot{{Timestamp: time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC), Host: "nas"}}},
+		{name: "batch", snapshots: []history.Snapshot{fullSnapshot(), {Timestamp: time.Unix(0, 1).UTC(), Host: "desktop",
+			Disks: []diskinfo.DiskInfo{{Drive: `D:\`, TotalSpace: 1}}}}},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encodeUpload(tt.snapshots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeUpload(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.snapshots) {
+				t.Errorf("decodeUpload = %+v, want %+v", got, tt.snapshots)
+			}
+		})
+	}
+}
+
+func TestDecodeUpload(t *testing.T) {
+	valid, err := encodeUpload([]history.Snapshot{fullSnapshot()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unknown adds field 99 to a message, like a newer agent would
+	unknown := func(data []byte) []byte {
+		data = protowire.AppendTag(data, 99, protowire.BytesType)
+		data = protowire.AppendString(data, "from the future")
+		data = protowire.AppendTag(data, 100, protowire.VarintType)
+		return protowire.AppendVarint(data, 7)
+	}
+	// snapshot encodes an UploadRequest of one Snapshot message
+	snapshot := func(m *pb.Snapshot, extra func([]byte) []byte) []byte {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extra != nil {
+			data = extra(data)
+		}
+		return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), data)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want []history.Snapshot
+		err  string
+	}{
+		{name: "unknown fields of the request", data: unknown(bytes.Clone(valid)), want: []history.Snapshot{fullSnapshot()}},
+		{name: "unknown fields of a snapshot", data: snapshot(snapshotToProto(fullSnapshot()), unknown),
+			want: []history.Snapshot{fullSnapshot()}},
+		{name: "truncated", data: valid[:len(valid)-3], err: "invalid protobuf message"},
+		{name: "corrupt", data: []byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f}, err: "invalid protobuf message"},
+		{name: "no host", data: snapshot(&pb.Snapshot{Note: "x"}, nil), err: "without a host"},
+		{name: "invalid details", data: snapshot(&pb.Snapshot{Host: "nas", Details: []byte("{")}, nil), err: "details"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeUpload(tt.data)
+			if tt.err != "" {
+				if !errors.Is(err, errProtobuf) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("decodeUpload error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeUpload = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadUpload(t *testing.T) {
+	data, err := encodeUpload([]history.Snapshot{fullSnapshot()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write(data)
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		err      bool
+	}{
+		{name: "gzip", body: zipped.Bytes(), encoding: "gzip"},
+		{name: "plain", body: data},
+		{name: "not gzip", body: data, encoding: "gzip", err: true},
+		{name: "truncated gzip", body: zipped.Bytes()[:zipped.Len()/2], encoding: "gzip", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/upload", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			got, err := readUpload(r)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("readUpload = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, []history.Snapshot{fullSnapshot()}) {
+				t.Errorf("readUpload = %+v, want the uploaded snapshot", got)
+			}
+		})
+	}
+}
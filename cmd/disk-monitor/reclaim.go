@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// reclaimLocation is a well-known location whose contents can usually be deleted
+type reclaimLocation struct {
+	Name string
+	// Patterns are paths with environment variables and globs
+	Patterns []string
+	// MinAge only counts files older than this as reclaimable, for locations
+	// where recent files are likely still in use
+	MinAge time.Duration
+}
+
+// reclaimLocations lists the locations sized by the reclaim report
+var reclaimLocations = []reclaimLocation{
+	{"User TEMP", []string{"$TEMP"}, 24 * time.Hour},
+	{"Windows TEMP", []string{"$SystemRoot\\Temp"}, 24 * time.Hour},
+	{"Windows Update cache", []string{"$SystemRoot\\SoftwareDistribution\\Download"}, 0},
+	{"Chrome cache", []string{
+		"$LOCALAPPDATA\\Google\\Chrome\\User Data\\*\\Cache",
+		"$LOCALAPPDATA\\Google\\Chrome\\User Data\\*\\Code Cache",
+	}, 0},
+	{"Edge cache", []string{
+		"$LOCALAPPDATA\\Microsoft\\Edge\\User Data\\*\\Cache",
+		"$LOCALAPPDATA\\Microsoft\\Edge\\User Data\\*\\Code Cache",
+	}, 0},
+	{"Firefox cache", []string{"$LOCALAPPDATA\\Mozilla\\Firefox\\Profiles\\*\\cache2"}, 0},
+	{"npm cache", []string{"$LOCALAPPDATA\\npm-cache", "$APPDATA\\npm-cache"}, 0},
+	{"pip cache", []string{"$LOCALAPPDATA\\pip\\Cache"}, 0},
+	{"NuGet cache", []string{"$USERPROFILE\\.nuget\\packages", "$LOCALAPPDATA\\NuGet\\v3-cache"}, 0},
+	{"Thumbnail cache", []string{"$LOCALAPPDATA\\Microsoft\\Windows\\Explorer\\thumbcache_*.db"}, 0},
+}
+
+// ReclaimItem is the measured size of one reclaimable location
+type ReclaimItem struct {
+	Name        string
+	Path        string
+	Size        uint64
+	Reclaimable uint64
+	Errors      int
+	// MinAge is the age from which files count as reclaimable
+	MinAge time.Duration
+}
+
+// expandLocation resolves the patterns of a location to existing paths
+func expandLocation(loc reclaimLocation) []string {
+	var paths []string
+	for _, pattern := range loc.Patterns {
+		// Skip patterns whose variables aren't set, they'd expand to a relative path
+		missing := false
+		expanded := os.Expand(pattern, func(name string) string {
+			v := os.Getenv(name)
+			if v == "" {
+				missing = true
+			}
+			return v
+		})
+		if missing {
+			continue
+		}
+
+		matches, _ := filepath.Glob(expanded)
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// measurePath sizes a file or directory, counting files older than minAge as reclaimable
+func measurePath(path string, minAge time.Duration) (size, reclaimable uint64, errors int) {
+	cutoff := time.Now().Add(-minAge)
+
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errors++
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			errors++
+			return nil
+		}
+		size += uint64(info.Size())
+		if minAge == 0 || info.ModTime().Before(cutoff) {
+			reclaimable += uint64(info.Size())
+		}
+		return nil
+	})
+
+	return size, reclaimable, errors
+}
+
+// measureReclaimable sizes all known reclaimable locations, including each drive's Recycle Bin
+func measureReclaimable(drives []string) []ReclaimItem {
+	var items []ReclaimItem
+	seen := make(map[string]bool)
+
+	add := func(name, path string, minAge time.Duration) {
+		key := strings.ToLower(filepath.Clean(path))
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		size, reclaimable, errors := measurePath(path, minAge)
+		if size == 0 && errors == 0 {
+			return
+		}
+		items = append(items, ReclaimItem{
+			Name:        name,
+			Path:        path,
+			Size:        size,
+			Reclaimable: reclaimable,
+			Errors:      errors,
+			MinAge:      minAge,
+		})
+	}
+
+	for _, loc := range reclaimLocations {
+		for _, path := range expandLocation(loc) {
+			add(loc.Name, path, loc.MinAge)
+		}
+	}
+	for _, drive := range drives {
+		path := filepath.Join(drive, "$Recycle.Bin")
+		if _, err := os.Stat(path); err == nil {
+			add("Recycle Bin", path, 0)
+		}
+	}
+
+	return items
+}
+
+// runReclaim reports how much space well-known caches and temp folders use per drive
+func runReclaim(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("reclaim", flag.ExitOnError)
+	withComponentStore := fs.Bool("winsxs", true, "Include the Windows component store in the system drive breakdown")
+	clean := fs.Bool("clean", false, "Offer to empty Recycle Bins and clear TEMP folders afterwards")
+	dryRun := fs.Bool("dry-run", false, "With -clean, only show what would be deleted")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	drives := diskinfo.AvailableDrives()
+	items := measureReclaimable(drives)
+
+	systemDrive := normalizeDrive(filepath.VolumeName(os.Getenv("SystemRoot")))
+	var componentStore *ComponentStoreInfo
+	if *withComponentStore {
+		componentStore, _ = getComponentStoreInfo(ctx)
+	}
+
+	// Group by drive
+	byDrive := make(map[string][]ReclaimItem)
+	for _, item := range items {
+		drive := normalizeDrive(filepath.VolumeName(item.Path))
+		byDrive[drive] = append(byDrive[drive], item)
+	}
+
+	if componentStore != nil && byDrive[systemDrive] == nil {
+		byDrive[systemDrive] = []ReclaimItem{}
+	}
+
+	var driveNames []string
+	for drive := range byDrive {
+		driveNames = append(driveNames, drive)
+	}
+	sort.Strings(driveNames)
+
+	if len(driveNames) == 0 {
+		fmt.Println("Nothing to reclaim found")
+	}
+
+	for _, drive := range driveNames {
+		driveItems := byDrive[drive]
+		sort.Slice(driveItems, func(i, j int) bool {
+			return driveItems[i].Reclaimable > driveItems[j].Reclaimable
+		})
+
+		fmt.Printf("Drive %s:\n", drive)
+		var total uint64
+		for _, item := range driveItems {
+			fmt.Printf("  %-22s %10s  %10s reclaimable  %s\n",
+				item.Name, diskinfo.FormatBytes(item.Size), diskinfo.FormatBytes(item.Reclaimable), item.Path)
+			total += item.Reclaimable
+		}
+
+		// The component store is a hidden consumer, only part of it can be cleaned up
+		if componentStore != nil && drive == systemDrive {
+			cs := componentStore
+			fmt.Printf("  %-22s %10s  %10s reclaimable  %s\n",
+				"Component store", diskinfo.FormatBytes(cs.ActualSize), diskinfo.FormatBytes(cs.Reclaimable), cs.Path)
+			fmt.Printf("  %-22s Explorer reports %s, %s shared with Windows (%s)\n",
+				"", diskinfo.FormatBytes(cs.ReportedSize), diskinfo.FormatBytes(cs.SharedWithWindows), cs.Source)
+			if cs.CleanupRecommended {
+				fmt.Printf("  %-22s Cleanup recommended: Dism /Online /Cleanup-Image /StartComponentCleanup\n", "")
+			}
+			total += cs.Reclaimable
+		}
+		fmt.Printf("  Total reclaimable: %s", diskinfo.FormatBytes(total))
+		if info, err := diskinfo.QueryDrive(ctx, drive); err == nil {
+			fmt.Printf(" (free space would grow from %s to %s)",
+				diskinfo.FormatBytes(info.FreeSpace), diskinfo.FormatBytes(info.FreeSpace+total))
+		}
+		fmt.Print("\n\n")
+	}
+
+	if *clean {
+		runGuidedCleanup(items, *dryRun)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// recoveringStore repairs a corrupt history with history.Recover the first time
+// a call reports it, then retries that call, so one bad write doesn't stop
+// every command. It also converts the UTC timestamps read to the display zone.
+type recoveringStore struct {
+	history.Store
+	backend string
+	path    string
+	shard   string
+	// zone is the display zone the times read are converted to
+	zone *time.Location
+	// recovery is set once the history has been repaired
+	recovery *history.Recovery
+}
+
+// openStore opens the configured history store, converting the times read to zone
+func openStore(cfg *Config, zone *time.Location) (*recoveringStore, error) {
+	s := &recoveringStore{backend: cfg.Storage.Backend, path: cfg.Storage.Path, shard: cfg.Storage.Shard, zone: zone}
+	store, err := history.OpenSharded(s.backend, s.path, s.shard)
+	if err != nil {
+		if !s.recover(context.Background(), err) {
+			return nil, err
+		}
+		return s, nil
+	}
+	s.Store = store
+	return s, nil
+}
+
+// recover repairs the history if err says it is corrupt, reporting whether the call should be retried
+func (s *recoveringStore) recover(ctx context.Context, err error) bool {
+	if s.recovery != nil || !errors.Is(err, history.ErrHistoryCorrupt) {
+		return false
+	}
+	if s.Store != nil {
+		s.Store.Close()
+	}
+
+	rec, rerr := history.RecoverSharded(ctx, s.backend, s.path, s.shard)
+	if rerr != nil {
+		slog.Error("history recovery failed", "err", rerr)
+	}
+	store, oerr := history.OpenSharded(s.backend, s.path, s.shard)
+	if oerr != nil {
+		return false
+	}
+	s.Store = store
+	if rerr != nil {
+		return false
+	}
+
+	s.recovery = rec
+	slog.Warn("history recovered", "source", rec.Source, "snapshots", rec.Snapshots, "moved_to", rec.MovedTo)
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", rec)
+	return true
+}
+
+// Close closes the current store, which recovery may have replaced
+func (s *recoveringStore) Close() error {
+	return s.Store.Close()
+}
+
+// Load reads the full history
+func (s *recoveringStore) Load(ctx context.Context) (*history.History, error) {
+	h, err := s.Store.Load(ctx)
+	if s.recover(ctx, err) {
+		h, err = s.Store.Load(ctx)
+	}
+	if h != nil {
+		localSnapshots(h.Snapshots, s.zone)
+		localBaselines(h.Baselines, s.zone)
+		localAlertStates(h.AlertStates, s.zone)
+	}
+	return h, err
+}
+
+// Baselines reads the named baselines
+func (s *recoveringStore) Baselines(ctx context.Context) ([]history.Baseline, error) {
+	b, err := s.Store.Baselines(ctx)
+	if s.recover(ctx, err) {
+		b, err = s.Store.Baselines(ctx)
+	}
+	localBaselines(b, s.zone)
+	return b, err
+}
+
+// Append adds snapshots
+func (s *recoveringStore) Append(ctx context.Context, snapshots ...history.Snapshot) error {
+	err := s.Store.Append(ctx, snapshots...)
+	if s.recover(ctx, err) {
+		err = s.Store.Append(ctx, snapshots...)
+	}
+	return err
+}
+
+// Query returns the snapshots in [from, to)
+func (s *recoveringStore) Query(ctx context.Context, from, to time.Time, drive string) ([]history.Snapshot, error) {
+	snapshots, err := s.Store.Query(ctx, from, to, drive)
+	if s.recover(ctx, err) {
+		snapshots, err = s.Store.Query(ctx, from, to, drive)
+	}
+	localSnapshots(snapshots, s.zone)
+	return snapshots, err
+}
+
+// Prune deletes the snapshots taken before t
+func (s *recoveringStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	n, err := s.Store.Prune(ctx, before)
+	if s.recover(ctx, err) {
+		n, err = s.Store.Prune(ctx, before)
+	}
+	return n, err
+}
+
+// RemoveDrive deletes the measurements of a drive
+func (s *recoveringStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	n, err := s.Store.RemoveDrive(ctx, drive)
+	if s.recover(ctx, err) {
+		n, err = s.Store.RemoveDrive(ctx, drive)
+	}
+	return n, err
+}
+
+// Thin keeps fewer of the old snapshots
+func (s *recoveringStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	n, err := s.Store.Thin(ctx, before, every)
+	if s.recover(ctx, err) {
+		n, err = s.Store.Thin(ctx, before, every)
+	}
+	return n, err
+}
+
+// Compact reclaims the space left by removed data
+func (s *recoveringStore) Compact(ctx context.Context) error {
+	err := s.Store.Compact(ctx)
+	if s.recover(ctx, err) {
+		err = s.Store.Compact(ctx)
+	}
+	return err
+}
+
+// SaveBaselines replaces all named baselines
+func (s *recoveringStore) SaveBaselines(ctx context.Context, baselines []history.Baseline) error {
+	err := s.Store.SaveBaselines(ctx, baselines)
+	if s.recover(ctx, err) {
+		err = s.Store.SaveBaselines(ctx, baselines)
+	}
+	return err
+}
+
+// AlertStates reads the state of the raised alerts
+func (s *recoveringStore) AlertStates(ctx context.Context) ([]history.AlertState, error) {
+	states, err := s.Store.AlertStates(ctx)
+	if s.recover(ctx, err) {
+		states, err = s.Store.AlertStates(ctx)
+	}
+	localAlertStates(states, s.zone)
+	return states, err
+}
+
+// SaveAlertStates replaces all alert states
+func (s *recoveringStore) SaveAlertStates(ctx context.Context, states []history.AlertState) error {
+	err := s.Store.SaveAlertStates(ctx, states)
+	if s.recover(ctx, err) {
+		err = s.Store.SaveAlertStates(ctx, states)
+	}
+	return err
+}
+
+// localSnapshots converts timestamps to the display zone
+func localSnapshots(snapshots []history.Snapshot, zone *time.Location) {
+	for i := range snapshots {
+		snapshots[i].Timestamp = snapshots[i].Timestamp.In(zone)
+		for j := range snapshots[i].Trim {
+			snapshots[i].Trim[j].LastOptimized = snapshots[i].Trim[j].LastOptimized.In(zone)
+		}
+	}
+}
+
+// localBaselines is localSnapshots for baselines
+func localBaselines(baselines []history.Baseline, zone *time.Location) {
+	for i := range baselines {
+		baselines[i].Timestamp = baselines[i].Timestamp.In(zone)
+	}
+}
+
+// localAlertStates is localSnapshots for alert states
+func localAlertStates(states []history.AlertState, zone *time.Location) {
+	for i := range states {
+		states[i].Since = states[i].Since.In(zone)
+		if !states[i].LastNotified.IsZero() {
+			states[i].LastNotified = states[i].LastNotified.In(zone)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// errNoRecycleBin is returned outside Windows, nothing is deleted
+var errNoRecycleBin = fmt.Errorf("the Recycle Bin is only available on Windows")
+
+// recycle can't move anything to a Recycle Bin
+func recycle(path string) error {
+	return errNoRecycleBin
+}
+
+// emptyBin has no Recycle Bin to empty
+func emptyBin(drive string) error {
+	return errNoRecycleBin
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	shell32            = syscall.NewLazyDLL("shell32.dll")
+	shEmptyRecycleBinW = shell32.NewProc("SHEmptyRecycleBinW")
+	shFileOperationW   = shell32.NewProc("SHFileOperationW")
+)
+
+// Shell file operation constants
+const (
+	FO_DELETE          = 0x0003
+	FOF_SILENT         = 0x0004
+	FOF_NOCONFIRMATION = 0x0010
+	FOF_ALLOWUNDO      = 0x0040
+	FOF_NOERRORUI      = 0x0400
+
+	SHERB_NOCONFIRMATION = 0x0001
+	SHERB_NOPROGRESSUI   = 0x0002
+	SHERB_NOSOUND        = 0x0004
+)
+
+// shFileOpStruct mirrors SHFILEOPSTRUCTW
+type shFileOpStruct struct {
+	Hwnd                 uintptr
+	Func                 uint32
+	From                 *uint16
+	To                   *uint16
+	Flags                uint16
+	AnyOperationsAborted int32
+	NameMappings         uintptr
+	ProgressTitle        *uint16
+}
+
+// recycle moves a file or directory to the Recycle Bin through the shell
+func recycle(path string) error {
+	// The source list is terminated by two NULs
+	from, err := syscall.UTF16FromString(path)
+	if err != nil {
+		return err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		Func:  FO_DELETE,
+		From:  &from[0],
+		Flags: FOF_ALLOWUNDO | FOF_NOCONFIRMATION | FOF_SILENT | FOF_NOERRORUI,
+	}
+	ret, _, _ := shFileOperationW.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 {
+		return fmt.Errorf("failed to move %s to the Recycle Bin: error 0x%x", path, ret)
+	}
+	if op.AnyOperationsAborted != 0 {
+		return fmt.Errorf("moving %s to the Recycle Bin was aborted", path)
+	}
+	return nil
+}
+
+// emptyBin empties the Recycle Bin of a drive without asking
+func emptyBin(drive string) error {
+	root, err := syscall.UTF16PtrFromString(drive)
+	if err != nil {
+		return err
+	}
+	ret, _, _ := shEmptyRecycleBinW.Call(0, uintptr(unsafe.Pointer(root)),
+		SHERB_NOCONFIRMATION|SHERB_NOPROGRESSUI|SHERB_NOSOUND)
+	if ret != 0 {
+		return fmt.Errorf("failed to empty the Recycle Bin on %s: error 0x%x", drive, ret)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// What happens to the series of drives that are no longer attached
+const (
+	removedKeep    = "keep"
+	removedArchive = "archive"
+	removedPurge   = "purge"
+)
+
+// archivePath returns the file a removed drive's series is archived to
+func archivePath(drive string) string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, fmt.Sprintf("disk_monitor_archive_%s.json", driveKey(drive)))
+}
+
+// retireRemovedDrives archives or purges the series of drives in the history
+// load returns that are not in present and haven't been seen for
+// storage.removed_after. A dry run only says what would happen.
+func retireRemovedDrives(ctx context.Context, cfg *Config, store history.Store, load func() (*history.History, error), present map[string]bool, now time.Time, dryRun bool) error {
+	policy := strings.ToLower(cfg.Storage.RemovedDrives)
+	switch policy {
+	case "", removedKeep:
+		return nil
+	case removedArchive, removedPurge:
+	default:
+		return fmt.Errorf("invalid removed_drives %q, use keep, archive or purge", cfg.Storage.RemovedDrives)
+	}
+	after, err := analysis.ParseDuration(cfg.Storage.RemovedAfter)
+	if err != nil {
+		return fmt.Errorf("invalid removed_after: %v", err)
+	}
+	hist, err := load()
+	if err != nil {
+		return err
+	}
+
+	lastSeen := make(map[string]time.Time)
+	for _, snapshot := range hist.Snapshots {
+		for _, disk := range snapshot.Disks {
+			lastSeen[disk.Drive] = snapshot.Timestamp
+		}
+	}
+
+	for _, drive := range hist.Drives() {
+		if present[drive] || now.Sub(lastSeen[drive]) < after {
+			continue
+		}
+
+		switch {
+		case dryRun && policy == removedArchive:
+			fmt.Printf("Drive %s not seen since %s, would be archived to %s\n",
+				drive, locale.Date(lastSeen[drive]), archivePath(drive))
+		case dryRun:
+			fmt.Printf("Drive %s not seen since %s, would be purged from history\n",
+				drive, locale.Date(lastSeen[drive]))
+		case policy == removedArchive:
+			path, err := archiveDrive(ctx, store, drive)
+			if err != nil {
+				return fmt.Errorf("failed to archive drive %s: %v", drive, err)
+			}
+			fmt.Printf("Drive %s not seen since %s, archived to %s\n",
+				drive, locale.Date(lastSeen[drive]), path)
+		default:
+			fmt.Printf("Drive %s not seen since %s, purged from history\n",
+				drive, locale.Date(lastSeen[drive]))
+		}
+		if _, err := store.RemoveDrive(ctx, drive); err != nil {
+			return fmt.Errorf("failed to remove drive %s: %v", drive, err)
+		}
+	}
+	return nil
+}
+
+// archiveDrive appends a drive's series to its archive file, in the JSON history format
+func archiveDrive(ctx context.Context, store history.Store, drive string) (string, error) {
+	snapshots, err := store.Query(ctx, time.Time{}, time.Time{}, drive)
+	if err != nil {
+		return "", err
+	}
+
+	path := archivePath(drive)
+	archive, err := history.Load(path)
+	if err != nil {
+		return "", err
+	}
+	archive.Snapshots = append(archive.Snapshots, snapshots...)
+	return path, history.Save(path, archive)
+}
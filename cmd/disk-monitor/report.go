@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/internal/clipboard"
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Report periods
+const (
+	periodWeekly  = "weekly"
+	periodMonthly = "monthly"
+)
+
+// PeriodSummary aggregates one drive over one week or month
+type PeriodSummary struct {
+	Label     string
+	Start     time.Time
+	End       time.Time
+	Samples   int
+	StartFree uint64
+	EndFree   uint64
+	// NetChange is the change of free space, negative when space was consumed
+	NetChange float64
+	// MaxDrawdown is the largest drop of free space from a previous high
+	MaxDrawdown uint64
+}
+
+// DriveReport holds the period summaries of one drive
+type DriveReport struct {
+	Drive string
+	// DevDrive is set when the drive was a Dev Drive at its latest reading
+	DevDrive bool
+	// Warnings are problems with the drive worth a look, such as TRIM being off
+	Warnings []string
+	Periods  []PeriodSummary
+	// Stats and Forecast are nil when there isn't enough data
+	Stats    *analysis.DriveStats
+	Forecast *analysis.Forecast
+	// Series is the full history, for formats that draw charts
+	Series []history.Point
+	// Comparison summarizes each of the report's Ranges, if comparing
+	Comparison []RangeSummary
+}
+
+// Report is the data model shared by all report formats
+type Report struct {
+	Generated time.Time
+	Period    string
+	Drives    []DriveReport
+	// Profiles is the user profile breakdown, if requested
+	Profiles []ProfileSize
+	// Ranges are the compared time ranges, replacing the period tables
+	Ranges []TimeRange
+}
+
+// periodBounds returns the start and label of the period containing t
+func periodBounds(t time.Time, period string) (time.Time, time.Time, string) {
+	y, m, d := t.Date()
+	if period == periodMonthly {
+		start := time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0), start.Format("2006-01")
+	}
+
+	// Weeks start on Monday
+	offset := (int(t.Weekday()) + 6) % 7
+	start := time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 7), locale.Date(start)
+}
+
+// summarizePeriods splits a drive's series into periods and summarizes each
+func summarizePeriods(points []history.Point, period string) []PeriodSummary {
+	var summaries []PeriodSummary
+	var peak uint64
+
+	for _, p := range points {
+		start, end, label := periodBounds(p.Time, period)
+		if len(summaries) == 0 || !summaries[len(summaries)-1].Start.Equal(start) {
+			summaries = append(summaries, PeriodSummary{
+				Label:     label,
+				Start:     start,
+				End:       end,
+				StartFree: p.Free,
+			})
+			peak = p.Free
+		}
+
+		cur := &summaries[len(summaries)-1]
+		cur.Samples++
+		cur.EndFree = p.Free
+		cur.NetChange = float64(p.Free) - float64(cur.StartFree)
+
+		if p.Free > peak {
+			peak = p.Free
+		}
+		if drawdown := peak - p.Free; drawdown > cur.MaxDrawdown {
+			cur.MaxDrawdown = drawdown
+		}
+	}
+
+	return summaries
+}
+
+// Title names the drive in report headings, marking Dev Drives
+func (dr DriveReport) Title() string {
+	if dr.DevDrive {
+		return "Drive " + dr.Drive + " (Dev Drive)"
+	}
+	return "Drive " + dr.Drive
+}
+
+// buildReport aggregates the history into per-period summaries, stats and
+// forecasts as of now, which is in the display zone
+func buildReport(hist *history.History, drives []string, period string, last int, forecast analysis.ForecastConfig, now time.Time) *Report {
+	report := &Report{
+		Generated: now,
+		Period:    period,
+	}
+
+	for _, drive := range drives {
+		series := hist.Series(drive, time.Time{})
+		periods := summarizePeriods(series, period)
+		if last > 0 && len(periods) > last {
+			periods = periods[len(periods)-last:]
+		}
+
+		dr := DriveReport{Drive: drive, DevDrive: hist.DevDrive(drive), Periods: periods, Series: series}
+		if t := hist.Trim(drive); t != nil && t.Disabled() {
+			dr.Warnings = append(dr.Warnings, "TRIM is disabled, the SSD isn't told which blocks are free and slows down as it fills")
+		}
+		dr.Stats, _ = analysis.ComputeStats(hist, drive, report.Generated)
+		dr.Forecast, _ = analysis.ForecastDrive(hist, drive, forecast, report.Generated)
+		report.Drives = append(report.Drives, dr)
+	}
+
+	return report
+}
+
+// generateReport builds a report and adds the profile breakdown if requested
+func generateReport(ctx context.Context, zone *time.Location, hist *history.History, drives []string, period string, last int, cfg *Config, profiles bool) (*Report, error) {
+	report := buildReport(hist, drives, period, last, cfg.Forecast, time.Now().In(zone))
+	if profiles {
+		opts, err := newScanOptions(cfg.Scan, nil)
+		if err != nil {
+			return nil, err
+		}
+		report.Profiles, err = measureProfiles(ctx, defaultProfilesRoot(), opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Report formats
+const (
+	formatText     = "text"
+	formatHTML     = "html"
+	formatMarkdown = "markdown"
+	formatPDF      = "pdf"
+	formatXLSX     = "xlsx"
+)
+
+// reportWriters maps each report format to its renderer
+var reportWriters = map[string]func(io.Writer, *Report) error{
+	formatText:     writeTextReport,
+	formatHTML:     writeHTMLReport,
+	formatMarkdown: writeMarkdownReport,
+	formatPDF:      writePDFReport,
+	formatXLSX:     writeXLSXReport,
+}
+
+// writeTextReport renders the report as plain text
+func writeTextReport(w io.Writer, report *Report) error {
+	fmt.Fprintf(w, "Disk space report (%s), generated %s\n\n",
+		report.Period, locale.DateTime(report.Generated))
+
+	for _, dr := range report.Drives {
+		fmt.Fprintf(w, "%s:\n", dr.Title())
+		for _, warning := range dr.Warnings {
+			fmt.Fprintf(w, "  Warning: %s\n", warning)
+		}
+		if len(dr.Comparison) > 0 {
+			fmt.Fprintf(w, "  %-24s %8s %12s %16s\n", "Range", "Samples", "Net change", "Growth")
+			for _, c := range dr.Comparison {
+				rate := "n/a"
+				if c.HasRate {
+					rate = analysis.FormatRate(c.Rate)
+				}
+				fmt.Fprintf(w, "  %-24s %8d %12s %16s\n", c.Range.Label, c.Samples, diskinfo.FormatChange(c.NetChange), rate)
+			}
+			if acc := formatAcceleration(dr.Comparison); acc != "" {
+				fmt.Fprintf(w, "  %s\n", acc)
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+		if len(dr.Periods) == 0 {
+			fmt.Fprintf(w, "  No data\n\n")
+			continue
+		}
+
+		fmt.Fprintf(w, "  %-12s %12s %12s %12s %12s\n",
+			"Period", "Start free", "End free", "Net change", "Max drawdown")
+		for _, p := range dr.Periods {
+			fmt.Fprintf(w, "  %-12s %12s %12s %12s %12s\n",
+				p.Label,
+				diskinfo.FormatBytes(p.StartFree),
+				diskinfo.FormatBytes(p.EndFree),
+				diskinfo.FormatChange(p.NetChange),
+				diskinfo.FormatBytes(p.MaxDrawdown))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Profiles) > 0 {
+		fmt.Fprintf(w, "User profiles:\n")
+		for _, p := range report.Profiles {
+			fmt.Fprintf(w, "  %-24s %12s\n", p.Name, diskinfo.FormatBytes(p.Size))
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// runReport prints weekly or monthly summaries
+func runReport(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	period := fs.String("period", periodWeekly, "Summary period: weekly or monthly")
+	last := fs.Int("last", 0, "Only show the last N periods (0 = all)")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := fs.Bool("profiles", cfg.Reports.IncludeProfiles, "Include the user profile size breakdown")
+	format := fs.String("format", formatText, "Output format: text, html, markdown, pdf or xlsx")
+	out := fs.String("out", "", "Write the report to this file instead of stdout")
+	email := fs.Bool("email", false, "Email the report to the recipients in the config instead of printing it")
+	templatePath := fs.String("template", "", "Render the report with this Go text/template instead of a built-in format")
+	copyOut := fs.Bool("clipboard", false, "Also copy the report to the clipboard")
+	compare := fs.Bool("compare", false, "Compare the time ranges given as arguments, e.g. \"last 30d\" \"previous 30d\"")
+	args, err = parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// With -compare, arguments are time ranges and drives
+	var drives, rangeSpecs []string
+	for _, arg := range args {
+		if *compare && isTimeRange(arg) {
+			rangeSpecs = append(rangeSpecs, arg)
+		} else {
+			drives = append(drives, arg)
+		}
+	}
+	if *compare && len(rangeSpecs) < 2 {
+		return fmt.Errorf("usage: report -compare \"last 30d\" \"previous 30d\" [drive...]")
+	}
+	if *compare && (*format == formatPDF || *format == formatXLSX) {
+		return fmt.Errorf("-compare supports the text, markdown and html formats")
+	}
+
+	if *period != periodWeekly && *period != periodMonthly {
+		return fmt.Errorf("unknown period %q", *period)
+	}
+	write, ok := reportWriters[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *templatePath != "" {
+		tmpl, err := loadReportTemplate(*templatePath)
+		if err != nil {
+			return err
+		}
+		write = templateReportWriter(tmpl)
+	}
+
+	hist, err := loadHistory(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	report, err := generateReport(ctx, zone, hist, selectDrives(hist, drives), *period, *last, cfg, *profiles)
+	if err != nil {
+		return err
+	}
+	if *compare {
+		var ranges []TimeRange
+		for _, spec := range rangeSpecs {
+			var prev *TimeRange
+			if len(ranges) > 0 {
+				prev = &ranges[len(ranges)-1]
+			}
+			r, err := parseTimeRange(spec, prev, report.Generated)
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, r)
+		}
+		addComparison(report, hist, ranges)
+	}
+	if *email {
+		return emailReport(ctx, report, *format, cfg)
+	}
+	if *copyOut {
+		if *format == formatPDF || *format == formatXLSX {
+			return fmt.Errorf("%s reports can't be copied to the clipboard", *format)
+		}
+		var text strings.Builder
+		if err := write(&text, report); err != nil {
+			return err
+		}
+		if err := clipboard.Copy(text.String()); err != nil {
+			return err
+		}
+	}
+
+	if *out == "" {
+		return write(os.Stdout, report)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %v", err)
+	}
+	if err := write(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
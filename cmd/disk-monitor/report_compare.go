@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// TimeRange is a named span of history compared in a report
+type TimeRange struct {
+	Label string
+	Start time.Time
+	End   time.Time
+}
+
+// RangeSummary is the change of one drive over one time range
+type RangeSummary struct {
+	Range   TimeRange
+	Samples int
+	// NetChange is the change of free space, negative when space was consumed
+	NetChange float64
+	// Rate is the fitted growth of used space in bytes per day, valid if HasRate
+	Rate    float64
+	HasRate bool
+}
+
+// isTimeRange reports whether an argument looks like a time range rather than a drive
+func isTimeRange(arg string) bool {
+	return strings.HasPrefix(arg, "last ") || strings.HasPrefix(arg, "previous ") || strings.Contains(arg, "..")
+}
+
+// parseTimeRange parses "last 30d", "previous 30d" or "2024-01-01..2024-02-01".
+// A "previous" range ends where the preceding range starts, or one length before now.
+func parseTimeRange(spec string, prev *TimeRange, now time.Time) (TimeRange, error) {
+	r := TimeRange{Label: spec}
+
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		var err error
+		if r.Start, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(from), now.Location()); err != nil {
+			return r, fmt.Errorf("invalid range start %q", from)
+		}
+		if r.End, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(to), now.Location()); err != nil {
+			return r, fmt.Errorf("invalid range end %q", to)
+		}
+		if !r.End.After(r.Start) {
+			return r, fmt.Errorf("range %q ends before it starts", spec)
+		}
+		return r, nil
+	}
+
+	kind, length, _ := strings.Cut(spec, " ")
+	d, err := analysis.ParseDuration(length)
+	if err != nil || d <= 0 {
+		return r, fmt.Errorf("invalid range %q", spec)
+	}
+
+	switch kind {
+	case "last":
+		r.End = now
+	case "previous":
+		r.End = now.Add(-d)
+		if prev != nil {
+			r.End = prev.Start
+		}
+	default:
+		return r, fmt.Errorf("invalid range %q, use \"last 30d\" or \"previous 30d\"", spec)
+	}
+	r.Start = r.End.Add(-d)
+
+	return r, nil
+}
+
+// summarizeRange computes the net change and growth rate of a drive over a range
+func summarizeRange(hist *history.History, drive string, r TimeRange) RangeSummary {
+	var points []history.Point
+	for _, p := range hist.Series(drive, r.Start) {
+		if p.Time.After(r.End) {
+			break
+		}
+		points = append(points, p)
+	}
+
+	s := RangeSummary{Range: r, Samples: len(points)}
+	if len(points) > 0 {
+		s.NetChange = float64(points[len(points)-1].Free) - float64(points[0].Free)
+	}
+	s.Rate, s.HasRate = analysis.FitRate(points)
+	return s
+}
+
+// formatAcceleration describes how the growth rate of the first range compares to the second
+func formatAcceleration(ranges []RangeSummary) string {
+	if len(ranges) < 2 || !ranges[0].HasRate || !ranges[1].HasRate {
+		return ""
+	}
+
+	cur, prev := ranges[0].Rate, ranges[1].Rate
+	diff := cur - prev
+	trend := "steady"
+	if math.Abs(diff) > math.Abs(prev)*0.1 {
+		trend = "accelerating"
+		if diff < 0 {
+			trend = "slowing down"
+		}
+	}
+
+	if prev == 0 {
+		return fmt.Sprintf("%s vs %s: %s (%s)", ranges[0].Range.Label, ranges[1].Range.Label, analysis.FormatRate(diff), trend)
+	}
+	return fmt.Sprintf("%s vs %s: %s (%s%%, %s)",
+		ranges[0].Range.Label, ranges[1].Range.Label, analysis.FormatRate(diff), locale.Signed(diff/math.Abs(prev)*100, 0), trend)
+}
+
+// addComparison adds side-by-side range summaries to each drive of a report
+func addComparison(report *Report, hist *history.History, ranges []TimeRange) {
+	report.Ranges = ranges
+	for i := range report.Drives {
+		dr := &report.Drives[i]
+		for _, r := range ranges {
+			dr.Comparison = append(dr.Comparison, summarizeRange(hist, dr.Drive, r))
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// htmlFuncs are the helpers available to the HTML report template
+var htmlFuncs = template.FuncMap{
+	"bytes":  diskinfo.FormatBytes,
+	"change": diskinfo.FormatChange,
+	"rate":   analysis.FormatRate,
+	"bytesf": func(v float64) string {
+		return diskinfo.FormatBytes(uint64(v))
+	},
+	"forecast":     analysis.FormatForecast,
+	"acceleration": formatAcceleration,
+	// unitBase and unitSuffix let the chart script format sizes like FormatBytes
+	"unitBase": diskinfo.UnitBase,
+	"unitSuffix": func() string {
+		return strings.TrimPrefix(diskinfo.UnitName('K'), "K")
+	},
+	// series encodes a drive's history as [unix ms, free, total] triples for the chart script
+	"series": func(points []history.Point) (template.JS, error) {
+		data := make([][3]int64, len(points))
+		for i, p := range points {
+			data[i] = [3]int64{p.Time.UnixMilli(), int64(p.Free), int64(p.Total)}
+		}
+		b, err := json.Marshal(data)
+		return template.JS(b), err
+	},
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Disk space report</title>
+<style>
+body { font-family: "Segoe UI", sans-serif; margin: 2em auto; color: #222; max-width: 960px; }
+h1 { color: #7D56F4; }
+h2 { border-bottom: 2px solid #7D56F4; padding-bottom: 4px; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 4px 12px; text-align: right; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+thead th { background: #f3f0fe; }
+.muted { color: #888; }
+.warning { color: #b00020; font-weight: bold; }
+.chart { position: relative; }
+.chart svg { width: 100%; height: auto; background: #fafafa; border: 1px solid #eee; }
+.tip { position: absolute; top: 8px; pointer-events: none; background: #333; color: #fff;
+  padding: 4px 8px; border-radius: 4px; font-size: 12px; display: none; white-space: nowrap; }
+</style>
+<script>
+var unitBase = {{unitBase}}, unitSuffix = {{unitSuffix}};
+function formatBytes(b) {
+  if (b < unitBase) return b + " B";
+  var units = "KMGTPE", i = -1;
+  do { b /= unitBase; i++; } while (b >= unitBase && i < units.length - 1);
+  return b.toFixed(1) + " " + units[i] + unitSuffix;
+}
+
+// drawChart plots free space over time as an SVG with a hover readout
+function drawChart(id, data) {
+  var el = document.getElementById(id);
+  var W = 900, H = 240, L = 70, R = 10, T = 10, B = 30;
+  var t0 = data[0][0], t1 = data[data.length - 1][0];
+  if (t1 === t0) t1 = t0 + 1;
+  var max = 0;
+  data.forEach(function (d) { max = Math.max(max, d[2]); });
+  if (max === 0) max = 1;
+
+  var x = function (t) { return L + (t - t0) / (t1 - t0) * (W - L - R); };
+  var y = function (v) { return T + (1 - v / max) * (H - T - B); };
+  var ns = "http://www.w3.org/2000/svg";
+  var svg = document.createElementNS(ns, "svg");
+  svg.setAttribute("viewBox", "0 0 " + W + " " + H);
+  var add = function (tag, attrs, text) {
+    var e = document.createElementNS(ns, tag);
+    for (var k in attrs) e.setAttribute(k, attrs[k]);
+    if (text) e.textContent = text;
+    svg.appendChild(e);
+    return e;
+  };
+
+  for (var i = 0; i <= 4; i++) {
+    var v = max * i / 4;
+    add("line", {x1: L, x2: W - R, y1: y(v), y2: y(v), stroke: "#e5e5e5"});
+    add("text", {x: L - 6, y: y(v) + 4, "text-anchor": "end", "font-size": 11, fill: "#888"}, formatBytes(v));
+  }
+  [t0, (t0 + t1) / 2, t1].forEach(function (t, i) {
+    add("text", {x: x(t), y: H - 10, "text-anchor": ["start", "middle", "end"][i], "font-size": 11, fill: "#888"},
+      new Date(t).toISOString().slice(0, 10));
+  });
+
+  var path = data.map(function (d, i) { return (i ? "L" : "M") + x(d[0]).toFixed(1) + "," + y(d[1]).toFixed(1); });
+  add("path", {d: path.join(""), fill: "none", stroke: "#7D56F4", "stroke-width": 2});
+  var cursor = add("line", {y1: T, y2: H - B, stroke: "#aaa", visibility: "hidden"});
+  var dot = add("circle", {r: 4, fill: "#7D56F4", visibility: "hidden"});
+
+  var tip = document.createElement("div");
+  tip.className = "tip";
+  el.appendChild(svg);
+  el.appendChild(tip);
+
+  svg.addEventListener("mousemove", function (ev) {
+    var box = svg.getBoundingClientRect();
+    var t = t0 + ((ev.clientX - box.left) * W / box.width - L) / (W - L - R) * (t1 - t0);
+    var lo = 0, hi = data.length - 1;
+    while (lo < hi) {
+      var mid = (lo + hi) >> 1;
+      if (data[mid][0] < t) lo = mid + 1; else hi = mid;
+    }
+    if (lo > 0 && t - data[lo - 1][0] < data[lo][0] - t) lo--;
+    var d = data[lo], px = x(d[0]);
+    cursor.setAttribute("x1", px);
+    cursor.setAttribute("x2", px);
+    dot.setAttribute("cx", px);
+    dot.setAttribute("cy", y(d[1]));
+    cursor.setAttribute("visibility", "visible");
+    dot.setAttribute("visibility", "visible");
+    tip.textContent = new Date(d[0]).toLocaleString() + ": " + formatBytes(d[1]) + " free of " + formatBytes(d[2]);
+    tip.style.display = "block";
+    tip.style.left = Math.min(px * box.width / W + 10, box.width - tip.offsetWidth) + "px";
+  });
+  svg.addEventListener("mouseleave", function () {
+    cursor.setAttribute("visibility", "hidden");
+    dot.setAttribute("visibility", "hidden");
+    tip.style.display = "none";
+  });
+}
+</script>
+</head>
+<body>
+<h1>Disk space report</h1>
+<p class="muted">{{.Period}} summary, generated {{.Generated.Format "2006-01-02 15:04"}}</p>
+{{range $i, $d := .Drives}}
+<h2>{{$d.Title}}</h2>
+{{range $d.Warnings}}<p class="warning">Warning: {{.}}</p>
+{{end}}{{if $d.Series}}
+<div class="chart" id="chart-{{$i}}"></div>
+<script>drawChart("chart-{{$i}}", {{series $d.Series}});</script>
+{{else}}
+<p class="muted">No data</p>
+{{end}}
+{{with $d.Stats}}
+<table>
+<thead><tr><th>Statistics</th><th></th></tr></thead>
+<tr><td>Free</td><td>{{bytes .Current.Free}} of {{bytes .Current.Total}}</td></tr>
+<tr><td>Min / avg / max free</td><td>{{bytes .MinFree}} / {{bytesf .AvgFree}} / {{bytes .MaxFree}}</td></tr>
+{{range .Growth}}<tr><td>Growth {{.Window}}</td><td>{{rate .BytesPerDay}}</td></tr>
+{{end}}<tr><td>Free p5 / p50 / p95</td><td>{{bytesf .FreePercentiles.P5}} / {{bytesf .FreePercentiles.P50}} / {{bytesf .FreePercentiles.P95}}</td></tr>
+{{if .Days}}<tr><td>Daily change p5 / p50 / p95</td><td>{{change .DailyChangePercentiles.P5}} / {{change .DailyChangePercentiles.P50}} / {{change .DailyChangePercentiles.P95}}</td></tr>
+{{end}}{{with $d.Forecast}}<tr><td>Full</td><td>{{forecast . $.Generated}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if $d.Comparison}}
+<table>
+<thead><tr><th>Range</th><th>Samples</th><th>Net change</th><th>Growth</th></tr></thead>
+{{range $d.Comparison}}<tr><td>{{.Range.Label}}</td><td>{{.Samples}}</td><td>{{change .NetChange}}</td><td>{{if .HasRate}}{{rate .Rate}}{{else}}n/a{{end}}</td></tr>
+{{end}}</table>
+{{with acceleration $d.Comparison}}<p>{{.}}</p>{{end}}
+{{else if $d.Periods}}
+<table>
+<thead><tr><th>Period</th><th>Start free</th><th>End free</th><th>Net change</th><th>Max drawdown</th></tr></thead>
+{{range $d.Periods}}<tr><td>{{.Label}}</td><td>{{bytes .StartFree}}</td><td>{{bytes .EndFree}}</td><td>{{change .NetChange}}</td><td>{{bytes .MaxDrawdown}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+{{if .Profiles}}
+<h2>User profiles</h2>
+<table>
+<thead><tr><th>Profile</th><th>Size</th></tr></thead>
+{{range .Profiles}}<tr><td>{{.Name}}</td><td>{{bytes .Size}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// writeHTMLReport renders the report as a standalone HTML page with interactive charts
+func writeHTMLReport(w io.Writer, report *Report) error {
+	if err := htmlReportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("failed to render report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// sparklineWidth is the number of characters in a Markdown report sparkline
+const sparklineWidth = 60
+
+// sparkline renders values as a row of block characters, averaging them into at most width buckets
+func sparkline(values []float64, width int) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	if len(values) > width {
+		buckets := make([]float64, width)
+		for i := range buckets {
+			start := i * len(values) / width
+			end := (i + 1) * len(values) / width
+			sum := 0.0
+			for _, v := range values[start:end] {
+				sum += v
+			}
+			buckets[i] = sum / float64(end-start)
+		}
+		values = buckets
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	var s strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(blocks)-1))
+		}
+		s.WriteRune(blocks[i])
+	}
+	return s.String()
+}
+
+// writeMarkdownReport renders the report as Markdown tables with sparklines
+func writeMarkdownReport(w io.Writer, report *Report) error {
+	fmt.Fprintf(w, "# Disk space report\n\n")
+	fmt.Fprintf(w, "%s summary, generated %s\n\n",
+		report.Period, locale.DateTime(report.Generated))
+
+	for _, dr := range report.Drives {
+		fmt.Fprintf(w, "## %s\n\n", strings.ReplaceAll(dr.Title(), `\`, `\\`))
+		for _, warning := range dr.Warnings {
+			fmt.Fprintf(w, "> **Warning:** %s\n\n", warning)
+		}
+		if len(dr.Series) == 0 {
+			fmt.Fprintf(w, "No data\n\n")
+			continue
+		}
+
+		free := make([]float64, len(dr.Series))
+		for i, p := range dr.Series {
+			free[i] = float64(p.Free)
+		}
+		first, last := dr.Series[0], dr.Series[len(dr.Series)-1]
+		fmt.Fprintf(w, "Free space %s – %s:\n\n```\n%s\n```\n\n",
+			locale.Date(first.Time), locale.Date(last.Time), sparkline(free, sparklineWidth))
+
+		if st := dr.Stats; st != nil {
+			fmt.Fprintf(w, "| Statistic | Value |\n|---|---:|\n")
+			fmt.Fprintf(w, "| Free | %s of %s |\n", diskinfo.FormatBytes(st.Current.Free), diskinfo.FormatBytes(st.Current.Total))
+			fmt.Fprintf(w, "| Min / avg / max free | %s / %s / %s |\n",
+				diskinfo.FormatBytes(st.MinFree), diskinfo.FormatBytes(uint64(st.AvgFree)), diskinfo.FormatBytes(st.MaxFree))
+			for _, g := range st.Growth {
+				fmt.Fprintf(w, "| Growth %s | %s |\n", g.Window, analysis.FormatRate(g.BytesPerDay))
+			}
+			if st.Days > 0 {
+				fmt.Fprintf(w, "| Daily change p5 / p50 / p95 | %s / %s / %s |\n",
+					diskinfo.FormatChange(st.DailyChangePercentiles.P5),
+					diskinfo.FormatChange(st.DailyChangePercentiles.P50),
+					diskinfo.FormatChange(st.DailyChangePercentiles.P95))
+			}
+			if dr.Forecast != nil {
+				fmt.Fprintf(w, "| Full | %s |\n", analysis.FormatForecast(dr.Forecast, report.Generated))
+			}
+			fmt.Fprintln(w)
+		}
+
+		if len(dr.Comparison) > 0 {
+			fmt.Fprintf(w, "| Range | Samples | Net change | Growth |\n|---|---:|---:|---:|\n")
+			for _, c := range dr.Comparison {
+				rate := "n/a"
+				if c.HasRate {
+					rate = analysis.FormatRate(c.Rate)
+				}
+				fmt.Fprintf(w, "| %s | %d | %s | %s |\n", c.Range.Label, c.Samples, diskinfo.FormatChange(c.NetChange), rate)
+			}
+			fmt.Fprintln(w)
+			if acc := formatAcceleration(dr.Comparison); acc != "" {
+				fmt.Fprintf(w, "%s\n\n", acc)
+			}
+		} else if len(dr.Periods) > 0 {
+			fmt.Fprintf(w, "| Period | Start free | End free | Net change | Max drawdown |\n")
+			fmt.Fprintf(w, "|---|---:|---:|---:|---:|\n")
+			for _, p := range dr.Periods {
+				fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+					p.Label,
+					diskinfo.FormatBytes(p.StartFree),
+					diskinfo.FormatBytes(p.EndFree),
+					diskinfo.FormatChange(p.NetChange),
+					diskinfo.FormatBytes(p.MaxDrawdown))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	if len(report.Profiles) > 0 {
+		fmt.Fprintf(w, "## User profiles\n\n| Profile | Size |\n|---|---:|\n")
+		for _, p := range report.Profiles {
+			fmt.Fprintf(w, "| %s | %s |\n", p.Name, diskinfo.FormatBytes(p.Size))
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// pdfTable draws a table with a shaded header row; the first column is left aligned
+func pdfTable(pdf *fpdf.Fpdf, tr func(string) string, widths []float64, header []string, rows [][]string) {
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(243, 240, 254)
+	for i, h := range header {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, tr(h), "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, row := range rows {
+		for i, cell := range row {
+			align := "R"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, tr(cell), "B", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+}
+
+// writePDFReport renders the report as a PDF with a summary cover page,
+// one chart page per drive and a forecast table
+func writePDFReport(w io.Writer, report *Report) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(136, 136, 136)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	heading := func(size float64, text string) {
+		pdf.SetFont("Helvetica", "B", size)
+		pdf.SetTextColor(125, 86, 244)
+		pdf.CellFormat(0, size/2, tr(text), "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(3)
+	}
+
+	// Cover page with the current state of every drive
+	pdf.AddPage()
+	heading(24, "Disk space report")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("%s summary, generated %s",
+		report.Period, locale.DateTime(report.Generated))), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	var summary [][]string
+	for _, dr := range report.Drives {
+		if dr.Stats == nil {
+			summary = append(summary, []string{dr.Drive, "No data", "", "", ""})
+			continue
+		}
+		cur := dr.Stats.Current
+		full := ""
+		if dr.Forecast != nil {
+			full = analysis.FormatForecast(dr.Forecast, report.Generated)
+		}
+		summary = append(summary, []string{
+			dr.Drive,
+			diskinfo.FormatBytes(cur.Free),
+			diskinfo.FormatBytes(cur.Total),
+			locale.Percent(percentOf(cur.Total-cur.Free, cur.Total), 1),
+			full,
+		})
+	}
+	pdfTable(pdf, tr, []float64{20, 28, 28, 20, 84},
+		[]string{"Drive", "Free", "Total", "Used", "Full"}, summary)
+
+	if len(report.Profiles) > 0 {
+		heading(14, "User profiles")
+		var rows [][]string
+		for _, p := range report.Profiles {
+			rows = append(rows, []string{p.Name, diskinfo.FormatBytes(p.Size)})
+		}
+		pdfTable(pdf, tr, []float64{80, 30}, []string{"Profile", "Size"}, rows)
+	}
+
+	// One page per drive with its chart and period table
+	for i, dr := range report.Drives {
+		if len(dr.Series) < 2 {
+			continue
+		}
+		pdf.AddPage()
+		heading(18, dr.Title())
+		for _, warning := range dr.Warnings {
+			pdf.SetTextColor(176, 0, 32)
+			pdf.MultiCell(0, 6, tr("Warning: "+warning), "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+		}
+
+		var img bytes.Buffer
+		if err := renderChart(&img, []chartSeries{{Drive: dr.Drive, Points: dr.Series}}, chartPNG, 1200, 600); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("chart-%d", i)
+		opts := fpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, opts, &img)
+		pdf.ImageOptions(name, 15, pdf.GetY(), 180, 90, false, opts, 0, "")
+		pdf.SetY(pdf.GetY() + 95)
+
+		if st := dr.Stats; st != nil {
+			rows := [][]string{
+				{"Min / avg / max free", fmt.Sprintf("%s / %s / %s",
+					diskinfo.FormatBytes(st.MinFree), diskinfo.FormatBytes(uint64(st.AvgFree)), diskinfo.FormatBytes(st.MaxFree))},
+			}
+			for _, g := range st.Growth {
+				rows = append(rows, []string{"Growth " + g.Window, analysis.FormatRate(g.BytesPerDay)})
+			}
+			pdfTable(pdf, tr, []float64{60, 60}, []string{"Statistic", "Value"}, rows)
+		}
+
+		var rows [][]string
+		for _, p := range dr.Periods {
+			rows = append(rows, []string{p.Label, diskinfo.FormatBytes(p.StartFree), diskinfo.FormatBytes(p.EndFree),
+				diskinfo.FormatChange(p.NetChange), diskinfo.FormatBytes(p.MaxDrawdown)})
+		}
+		pdfTable(pdf, tr, []float64{36, 36, 36, 36, 36},
+			[]string{"Period", "Start free", "End free", "Net change", "Max drawdown"}, rows)
+	}
+
+	// Forecast table for all drives
+	pdf.AddPage()
+	heading(18, "Forecast")
+	var rows [][]string
+	for _, dr := range report.Drives {
+		f := dr.Forecast
+		if f == nil {
+			rows = append(rows, []string{dr.Drive, "", "", "Not enough data", "", ""})
+			continue
+		}
+		estimated, earliest, latest := "never", "", ""
+		if f.Filling {
+			estimated = locale.Date(f.EstimatedFullDate)
+			earliest = locale.Date(f.EarliestFullDate)
+			latest = "never"
+			if !f.LatestFullDate.IsZero() {
+				latest = locale.Date(f.LatestFullDate)
+			}
+		}
+		rows = append(rows, []string{dr.Drive, f.Model, analysis.FormatRate(f.Rate), estimated, earliest, latest})
+	}
+	pdfTable(pdf, tr, []float64{20, 20, 35, 35, 35, 35},
+		[]string{"Drive", "Model", "Trend", "Full", "Earliest", "Latest"}, rows)
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write PDF: %v", err)
+	}
+	return nil
+}
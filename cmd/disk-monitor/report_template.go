@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// templateFuncs are the helpers available to user report templates
+var templateFuncs = template.FuncMap{
+	"bytes":  diskinfo.FormatBytes,
+	"change": diskinfo.FormatChange,
+	"rate":   analysis.FormatRate,
+	"bytesf": func(v float64) string {
+		return diskinfo.FormatBytes(uint64(v))
+	},
+	"gb": func(v uint64) float64 {
+		return float64(v) / diskinfo.Gigabyte()
+	},
+	"percent": percentOf,
+	"number":  locale.Float,
+	"date": func(t time.Time) string {
+		return locale.Date(t)
+	},
+	"forecast": analysis.FormatForecast,
+	"sparkline": func(points []history.Point) string {
+		free := make([]float64, len(points))
+		for i, p := range points {
+			free[i] = float64(p.Free)
+		}
+		return sparkline(free, sparklineWidth)
+	},
+}
+
+// loadReportTemplate parses a user-supplied text/template for reports
+func loadReportTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %v", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// templateReportWriter returns a report writer that executes the template
+func templateReportWriter(tmpl *template.Template) func(io.Writer, *Report) error {
+	return func(w io.Writer, report *Report) error {
+		if err := tmpl.Execute(w, report); err != nil {
+			return fmt.Errorf("failed to render template: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// gigabyte converts bytes to GB or GiB for spreadsheet cells
+func gigabyte(b uint64) float64 {
+	return float64(b) / diskinfo.Gigabyte()
+}
+
+// gigabyteHeader names a column in the units of gigabyte, e.g. "Free (GiB)"
+func gigabyteHeader(name string) string {
+	return name + " (" + diskinfo.GigabyteUnit() + ")"
+}
+
+// sheetName turns a drive into a valid worksheet name, e.g. "C:\" into "Drive C"
+func sheetName(drive string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, drive)
+	return "Drive " + name
+}
+
+// writeXLSXReport renders the report as an Excel workbook with a summary sheet
+// and one sheet per drive holding the full history and a chart
+func writeXLSXReport(w io.Writer, report *Report) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	header, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"F3F0FE"}},
+	})
+	dateFormat := "yyyy-mm-dd hh:mm"
+	dateStyle, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	decimals := 2
+	numberStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 2, DecimalPlaces: &decimals})
+	freeze := &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}
+
+	// Summary sheet with the current state and forecast of each drive
+	const summary = "Summary"
+	f.SetSheetName("Sheet1", summary)
+	f.SetSheetRow(summary, "A1", &[]any{"Drive", gigabyteHeader("Free"), gigabyteHeader("Total"), "Used %",
+		"Trend (" + diskinfo.GigabyteUnit() + "/day)", "Full"})
+	f.SetCellStyle(summary, "A1", "F1", header)
+	for i, dr := range report.Drives {
+		row := []any{dr.Drive}
+		if st := dr.Stats; st != nil {
+			cur := st.Current
+			row = append(row, gigabyte(cur.Free), gigabyte(cur.Total), percentOf(cur.Total-cur.Free, cur.Total))
+		} else {
+			row = append(row, nil, nil, nil)
+		}
+		if fc := dr.Forecast; fc != nil {
+			row = append(row, fc.Rate/diskinfo.Gigabyte(), analysis.FormatForecast(fc, report.Generated))
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(summary, cell, &row)
+	}
+	f.SetCellStyle(summary, "B2", fmt.Sprintf("E%d", len(report.Drives)+1), numberStyle)
+	f.SetColWidth(summary, "A", "E", 14)
+	f.SetColWidth(summary, "F", "F", 50)
+	f.SetPanes(summary, freeze)
+
+	for _, dr := range report.Drives {
+		if len(dr.Series) == 0 {
+			continue
+		}
+
+		sheet := sheetName(dr.Drive)
+		if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("failed to create sheet for %s: %v", dr.Drive, err)
+		}
+		f.SetSheetRow(sheet, "A1", &[]any{"Timestamp", gigabyteHeader("Free"), gigabyteHeader("Used"), gigabyteHeader("Total"), "Used %"})
+		f.SetCellStyle(sheet, "A1", "E1", header)
+
+		for i, p := range dr.Series {
+			cell, _ := excelize.CoordinatesToCellName(1, i+2)
+			f.SetSheetRow(sheet, cell, &[]any{
+				p.Time,
+				gigabyte(p.Free),
+				gigabyte(p.Total - p.Free),
+				gigabyte(p.Total),
+				percentOf(p.Total-p.Free, p.Total),
+			})
+		}
+		last := len(dr.Series) + 1
+		f.SetCellStyle(sheet, "A2", fmt.Sprintf("A%d", last), dateStyle)
+		f.SetCellStyle(sheet, "B2", fmt.Sprintf("E%d", last), numberStyle)
+		f.SetColWidth(sheet, "A", "A", 18)
+		f.SetColWidth(sheet, "B", "E", 12)
+		f.SetPanes(sheet, freeze)
+
+		ref := func(col string) string {
+			return fmt.Sprintf("'%s'!$%s$2:$%s$%d", sheet, col, col, last)
+		}
+		err := f.AddChart(sheet, "G2", &excelize.Chart{
+			Type: excelize.Line,
+			Series: []excelize.ChartSeries{
+				{Name: fmt.Sprintf("'%s'!$B$1", sheet), Categories: ref("A"), Values: ref("B")},
+				{Name: fmt.Sprintf("'%s'!$C$1", sheet), Categories: ref("A"), Values: ref("C")},
+			},
+			Title:     excelize.ChartTitle{Paragraph: []excelize.RichTextRun{{Text: "Drive " + dr.Drive}}},
+			Legend:    excelize.ChartLegend{Position: "bottom"},
+			Dimension: excelize.ChartDimension{Width: 720, Height: 360},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add chart for %s: %v", dr.Drive, err)
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("failed to write workbook: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// DirNode is the aggregated size of a directory and its subdirectories
+type DirNode struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+	// Files is the size of the files directly in this directory
+	Files    uint64     `json:"files,omitempty"`
+	Children []*DirNode `json:"children,omitempty"`
+}
+
+// ScanResult is a stored directory scan of one root
+type ScanResult struct {
+	Root      string    `json:"root"`
+	Timestamp time.Time `json:"timestamp"`
+	Depth     int       `json:"depth"`
+	Errors    int       `json:"errors"`
+	Tree      *DirNode  `json:"tree"`
+	// Journal is the USN journal position at scan time, used for incremental rescans
+	Journal *usnCheckpoint `json:"journal,omitempty"`
+}
+
+// usnCheckpoint is a position in an NTFS change journal
+type usnCheckpoint struct {
+	JournalID uint64 `json:"journal_id"`
+	NextUsn   int64  `json:"next_usn"`
+}
+
+// ScanStore holds all stored scans
+type ScanStore struct {
+	Scans []ScanResult `json:"scans"`
+}
+
+// Default scan settings
+const (
+	// defaultScanDepth limits how deep the stored tree goes, deeper sizes are folded into parents
+	defaultScanDepth = 4
+	// defaultScansKept is how many scans per root are kept
+	defaultScansKept = 10
+)
+
+// getScansFilePath returns path to scan results file
+func getScansFilePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_scans.json")
+}
+
+// loadScans loads stored scans from file
+func loadScans() (*ScanStore, error) {
+	data, err := os.ReadFile(getScansFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ScanStore{}, nil
+		}
+		return nil, err
+	}
+
+	var store ScanStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// saveScans saves stored scans to file
+func saveScans(store *ScanStore) error {
+	data, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(getScansFilePath(), data, 0644)
+}
+
+// addScan stores a scan, dropping the oldest scans of the same root beyond keep
+func (s *ScanStore) addScan(result ScanResult, keep int) {
+	s.Scans = append(s.Scans, result)
+
+	count := 0
+	for i := len(s.Scans) - 1; i >= 0; i-- {
+		if s.Scans[i].Root != result.Root {
+			continue
+		}
+		count++
+		if count > keep {
+			s.Scans = append(s.Scans[:i], s.Scans[i+1:]...)
+		}
+	}
+}
+
+// findScanBefore returns the newest scan of root taken at or before t
+func (s *ScanStore) findScanBefore(root string, t time.Time) *ScanResult {
+	var found *ScanResult
+	for i := range s.Scans {
+		scan := &s.Scans[i]
+		if scan.Root != root || scan.Timestamp.After(t) {
+			continue
+		}
+		if found == nil || scan.Timestamp.After(found.Timestamp) {
+			found = scan
+		}
+	}
+	return found
+}
+
+// scanProgress reports how far a running scan has got
+type scanProgress struct {
+	Files int64
+	Dirs  int64
+	Bytes int64
+}
+
+// scanner walks a directory tree with a bounded number of parallel walkers
+type scanner struct {
+	ctx      context.Context
+	maxDepth int
+	opts     scanOptions
+	sem      chan struct{}
+	files    atomic.Int64
+	dirs     atomic.Int64
+	bytes    atomic.Int64
+	errors   atomic.Int64
+}
+
+// newScanner creates a scanner for the given options, it stops walking when ctx is done
+func newScanner(ctx context.Context, opts scanOptions) *scanner {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU() * 2
+	}
+	return &scanner{
+		ctx:      ctx,
+		maxDepth: opts.Depth,
+		opts:     opts,
+		sem:      make(chan struct{}, workers),
+	}
+}
+
+// scanDirectory walks root and aggregates sizes per directory down to opts.Depth.
+// If progress is not nil it is called periodically while the scan runs.
+func scanDirectory(ctx context.Context, root string, opts scanOptions, progress func(scanProgress)) (*ScanResult, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	sc := newScanner(ctx, opts)
+
+	done := make(chan struct{})
+	if progress != nil {
+		go func() {
+			ticker := time.NewTicker(250 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					progress(sc.progress())
+				}
+			}
+		}()
+	}
+
+	result := &ScanResult{
+		Root:      root,
+		Timestamp: time.Now(),
+		Depth:     opts.Depth,
+	}
+	// Remember where the change journal was before walking, so nothing is missed
+	result.Journal, _ = queryUsnCheckpoint(root)
+	result.Tree = sc.scanNode(root, root, 0)
+	close(done)
+
+	if progress != nil {
+		progress(sc.progress())
+	}
+	// A cancelled scan is incomplete and must not be saved
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result.Errors = int(sc.errors.Load())
+
+	return result, nil
+}
+
+// progress returns the current counters
+func (sc *scanner) progress() scanProgress {
+	return scanProgress{
+		Files: sc.files.Load(),
+		Dirs:  sc.dirs.Load(),
+		Bytes: sc.bytes.Load(),
+	}
+}
+
+// scanNode sizes one directory; children below maxDepth are counted but not kept.
+// Subdirectories are handed to another walker when one is free.
+func (sc *scanner) scanNode(path, name string, depth int) *DirNode {
+	node := &DirNode{Name: name}
+	if sc.ctx.Err() != nil {
+		return node
+	}
+	sc.dirs.Add(1)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		sc.errors.Add(1)
+		return node
+	}
+
+	var subdirs []os.DirEntry
+	for _, entry := range entries {
+		kind, info, err := sc.opts.resolveEntry(filepath.Join(path, entry.Name()), entry)
+		if err != nil {
+			sc.errors.Add(1)
+			continue
+		}
+		if kind == entryDir {
+			// Followed junctions could loop forever
+			if depth < maxReparseDepth {
+				subdirs = append(subdirs, entry)
+			}
+			continue
+		}
+		if kind != entryFile {
+			continue
+		}
+		node.Files += uint64(info.Size())
+		sc.files.Add(1)
+		sc.bytes.Add(info.Size())
+	}
+	node.Size = node.Files
+
+	children := make([]*DirNode, len(subdirs))
+	var wg sync.WaitGroup
+	for i, entry := range subdirs {
+		childPath := filepath.Join(path, entry.Name())
+		select {
+		case sc.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				children[i] = sc.scanNode(childPath, entry.Name(), depth+1)
+				<-sc.sem
+			}(i)
+		default:
+			children[i] = sc.scanNode(childPath, entry.Name(), depth+1)
+		}
+	}
+	wg.Wait()
+
+	for _, child := range children {
+		node.Size += child.Size
+	}
+	if depth < sc.maxDepth {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Size > children[j].Size
+		})
+		node.Children = children
+	}
+
+	return node
+}
+
+// refreshScan returns an up-to-date scan of root. When the previous scan has a
+// change journal checkpoint only the directories that changed since are rescanned,
+// otherwise root is scanned from scratch.
+func refreshScan(ctx context.Context, store *ScanStore, root string, opts scanOptions, full bool) (*ScanResult, bool, error) {
+	if prev := store.findScanBefore(root, time.Now()); prev != nil && !full &&
+		prev.Journal != nil && prev.Depth == opts.Depth {
+		result, err := rescanChanged(ctx, prev, opts)
+		if err == nil {
+			return result, true, nil
+		}
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+	}
+
+	result, err := scanDirectory(ctx, root, opts, printScanProgress)
+	fmt.Fprintln(os.Stderr)
+	return result, false, err
+}
+
+// rescanChanged updates a copy of prev using the directories the change journal reports as modified
+func rescanChanged(ctx context.Context, prev *ScanResult, opts scanOptions) (*ScanResult, error) {
+	checkpoint, changed, err := readUsnChanges(prev.Root, prev.Journal)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{
+		Root:      prev.Root,
+		Timestamp: time.Now(),
+		Depth:     prev.Depth,
+		Errors:    prev.Errors,
+		Tree:      prev.Tree.clone(),
+		Journal:   checkpoint,
+	}
+	sc := newScanner(ctx, opts)
+
+	// Shallow directories first, so a full rescan of a parent makes its children's redundant
+	type target struct {
+		stack []*DirNode
+		path  string
+	}
+	var targets []target
+	for _, dir := range changed {
+		if stack, path, ok := result.Tree.locate(prev.Root, dir); ok {
+			targets = append(targets, target{stack, path})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return len(targets[i].stack) < len(targets[j].stack)
+	})
+
+	rescanned := make(map[*DirNode]bool)
+	for _, t := range targets {
+		skip := false
+		for _, n := range t.stack[:len(t.stack)-1] {
+			if rescanned[n] {
+				skip = true
+				break
+			}
+		}
+		node := t.stack[len(t.stack)-1]
+		if skip || rescanned[node] {
+			continue
+		}
+
+		depth := len(t.stack) - 1
+		oldSize := node.Size
+		if depth < sc.maxDepth {
+			sc.rescanShallow(node, t.path, depth)
+		} else {
+			// Children aren't stored below maxDepth, so the whole subtree is rescanned
+			*node = *sc.scanNode(t.path, node.Name, depth)
+			rescanned[node] = true
+		}
+
+		// Apply the change to all ancestors
+		for _, ancestor := range t.stack[:len(t.stack)-1] {
+			ancestor.Size = ancestor.Size - oldSize + node.Size
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result.Errors += int(sc.errors.Load())
+
+	return result, nil
+}
+
+// rescanShallow re-reads the files directly in a directory and its list of
+// subdirectories. Known subdirectories keep their sizes, new ones are scanned.
+func (sc *scanner) rescanShallow(node *DirNode, path string, depth int) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// The directory is gone
+		sc.errors.Add(1)
+		node.Files = 0
+		node.Children = nil
+		node.Size = 0
+		return
+	}
+
+	existing := make(map[string]*DirNode)
+	for _, child := range node.Children {
+		existing[strings.ToLower(child.Name)] = child
+	}
+
+	node.Files = 0
+	var children []*DirNode
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		kind, info, err := sc.opts.resolveEntry(childPath, entry)
+		if err != nil {
+			sc.errors.Add(1)
+			continue
+		}
+		switch kind {
+		case entryDir:
+			if child, ok := existing[strings.ToLower(entry.Name())]; ok {
+				children = append(children, child)
+			} else {
+				children = append(children, sc.scanNode(childPath, entry.Name(), depth+1))
+			}
+		case entryFile:
+			node.Files += uint64(info.Size())
+		}
+	}
+
+	node.Size = node.Files
+	for _, child := range children {
+		node.Size += child.Size
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Size > children[j].Size
+	})
+	node.Children = children
+}
+
+// clone returns a deep copy of the tree
+func (n *DirNode) clone() *DirNode {
+	c := *n
+	c.Children = make([]*DirNode, len(n.Children))
+	for i, child := range n.Children {
+		c.Children[i] = child.clone()
+	}
+	return &c
+}
+
+// locate finds the deepest stored node on the way to dir. It returns the nodes
+// from the root down to it and its full path, or false if dir is outside root.
+func (n *DirNode) locate(root, dir string) ([]*DirNode, string, bool) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", false
+	}
+
+	stack := []*DirNode{n}
+	path := root
+	if rel == "." {
+		return stack, path, true
+	}
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		node := stack[len(stack)-1]
+		var next *DirNode
+		for _, child := range node.Children {
+			if strings.EqualFold(child.Name, part) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		stack = append(stack, next)
+		path = filepath.Join(path, next.Name)
+	}
+
+	return stack, path, true
+}
+
+// printScanProgress prints a single updating progress line to stderr
+func printScanProgress(p scanProgress) {
+	fmt.Fprintf(os.Stderr, "\rScanned %d files in %d directories, %s   ", p.Files, p.Dirs, diskinfo.FormatBytes(uint64(p.Bytes)))
+}
+
+// runScan scans a path, stores the result and shows or browses the biggest directories
+func runScan(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	depth := fs.Int("depth", defaultScanDepth, "Directory depth to keep in the stored result")
+	workers := fs.Int("workers", 0, "Number of parallel walkers (0 = 2 per CPU)")
+	var excludes listFlag
+	fs.Var(&excludes, "exclude", "Glob or re:regexp to skip, can be repeated")
+	browse := fs.Bool("browse", false, "Browse the result interactively")
+	last := fs.Bool("last", false, "Use the last stored scan instead of scanning again")
+	full := fs.Bool("full", false, "Always rescan everything instead of using the change journal")
+	limit := fs.Int("limit", 20, "Number of directories to list")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: scan [-browse] [-last] <drive or path>")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := newScanOptions(cfg.Scan, excludes)
+	if err != nil {
+		return err
+	}
+	opts.Depth = *depth
+	opts.Workers = *workers
+
+	root := scanRoot(positional[0])
+	store, err := loadScans()
+	if err != nil {
+		return err
+	}
+
+	var result *ScanResult
+	if *last {
+		result = store.findScanBefore(root, time.Now())
+		if result == nil {
+			return fmt.Errorf("no stored scan of %s", root)
+		}
+	} else {
+		var incremental bool
+		result, incremental, err = refreshScan(ctx, store, root, opts, *full)
+		if err != nil {
+			return err
+		}
+		if incremental {
+			slog.Info("updated the previous scan from the change journal", "root", root)
+		}
+		store.addScan(*result, defaultScansKept)
+		if err := saveScans(store); err != nil {
+			return err
+		}
+	}
+
+	if *browse {
+		_, err := tea.NewProgram(newScanBrowser(result), tea.WithAltScreen()).Run()
+		return err
+	}
+
+	fmt.Printf("%s: %s", root, diskinfo.FormatBytes(result.Tree.Size))
+	if result.Errors > 0 {
+		fmt.Printf(" (%d entries could not be read)", result.Errors)
+	}
+	fmt.Println()
+	for i, child := range result.Tree.Children {
+		if i >= *limit {
+			break
+		}
+		fmt.Printf("  %10s  %7s  %s\n", diskinfo.FormatBytes(child.Size), locale.Percent(percentOf(child.Size, result.Tree.Size), 1), child.Name)
+	}
+
+	return nil
+}
+
+// percentOf returns part as a percentage of whole
+func percentOf(part, whole uint64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
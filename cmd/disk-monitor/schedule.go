@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// reportAttachments maps report formats sent as attachments to their file extension and MIME type
+var reportAttachments = map[string][2]string{
+	formatHTML:     {"html", "text/html; charset=utf-8"},
+	formatMarkdown: {"md", "text/markdown; charset=utf-8"},
+	formatPDF:      {"pdf", "application/pdf"},
+	formatXLSX:     {"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+// ScheduleState remembers which scheduled report was sent last and when the
+// history was last backed up
+type ScheduleState struct {
+	// LastReport is the start of the period in which the last report was sent
+	LastReport time.Time `json:"last_report"`
+	// LastBackup is when the last scheduled backup was uploaded
+	LastBackup time.Time `json:"last_backup,omitzero"`
+}
+
+// getScheduleFilePath returns path to the schedule state file
+func getScheduleFilePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_schedule.json")
+}
+
+// loadScheduleState loads the schedule state, empty if it doesn't exist yet
+func loadScheduleState() (*ScheduleState, error) {
+	data, err := os.ReadFile(getScheduleFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ScheduleState{}, nil
+		}
+		return nil, err
+	}
+
+	var state ScheduleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// saveScheduleState saves the schedule state
+func saveScheduleState(state *ScheduleState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getScheduleFilePath(), data, 0644)
+}
+
+// emailReport mails a report to the configured recipients. The text report is
+// the message body, other formats are attached.
+func emailReport(ctx context.Context, report *Report, format string, cfg *Config) error {
+	var body bytes.Buffer
+	if err := writeTextReport(&body, report); err != nil {
+		return err
+	}
+
+	var attachments []mailAttachment
+	if format != formatText {
+		write, ok := reportWriters[format]
+		if !ok {
+			return fmt.Errorf("unknown format %q", format)
+		}
+		var data bytes.Buffer
+		if err := write(&data, report); err != nil {
+			return err
+		}
+		ext := reportAttachments[format]
+		attachments = append(attachments, mailAttachment{
+			Name:        fmt.Sprintf("disk-report-%s.%s", report.Generated.Format("2006-01-02"), ext[0]),
+			ContentType: ext[1],
+			Data:        data.Bytes(),
+		})
+	}
+
+	subject := fmt.Sprintf("Disk space report (%s), %s", report.Period, locale.Date(report.Generated))
+	return sendMail(ctx, cfg.SMTP, parseRecipients(cfg.Reports.To), subject, body.String(), attachments)
+}
+
+// sendScheduledReport emails the report once per configured period, after the
+// first collection of a new week or month, from the history load returns.
+// Returns whether a report was sent.
+func sendScheduledReport(ctx context.Context, zone *time.Location, load func() (*history.History, error), cfg *Config, now time.Time) (bool, error) {
+	schedule := cfg.Reports.Schedule
+	if schedule == "" {
+		return false, nil
+	}
+	if schedule != periodWeekly && schedule != periodMonthly {
+		return false, fmt.Errorf("unknown report schedule %q", schedule)
+	}
+
+	state, err := loadScheduleState()
+	if err != nil {
+		return false, err
+	}
+
+	start, _, _ := periodBounds(now, schedule)
+	if !state.LastReport.IsZero() && !state.LastReport.Before(start) {
+		return false, nil
+	}
+
+	// The first run only starts the schedule, so enabling it doesn't send a report right away
+	sent := !state.LastReport.IsZero()
+	if sent {
+		hist, err := load()
+		if err != nil {
+			return false, err
+		}
+		report, err := generateReport(ctx, zone, hist, hist.Drives(), schedule, cfg.Reports.Last, cfg, cfg.Reports.IncludeProfiles)
+		if err != nil {
+			return false, err
+		}
+		if err := emailReport(ctx, report, cfg.Reports.Format, cfg); err != nil {
+			return false, err
+		}
+	}
+
+	state.LastReport = start
+	return sent, saveScheduleState(state)
+}
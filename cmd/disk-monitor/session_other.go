@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// watchSessionEvents has no session notifications to watch
+func watchSessionEvents(ctx context.Context) (<-chan string, error) {
+	return nil, fmt.Errorf("session events are only available on Windows")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Window messages and notification codes of session and power changes
+const (
+	WM_DESTROY                  = 0x0002
+	WM_CLOSE                    = 0x0010
+	WM_POWERBROADCAST           = 0x0218
+	WM_WTSSESSION_CHANGE        = 0x02b1
+	WTS_SESSION_LOGON           = 0x5
+	WTS_SESSION_UNLOCK          = 0x8
+	PBT_APMRESUMEAUTOMATIC      = 0x12
+	NOTIFY_FOR_ALL_SESSIONS     = 1
+	DEVICE_NOTIFY_WINDOW_HANDLE = 0
+	// HWND_MESSAGE parents a message-only window, which is never shown
+	HWND_MESSAGE = ^uintptr(2)
+)
+
+var (
+	user32                              = syscall.NewLazyDLL("user32.dll")
+	wtsapi32                            = syscall.NewLazyDLL("wtsapi32.dll")
+	getModuleHandleW                    = kernel32.NewProc("GetModuleHandleW")
+	registerClassExW                    = user32.NewProc("RegisterClassExW")
+	createWindowExW                     = user32.NewProc("CreateWindowExW")
+	defWindowProcW                      = user32.NewProc("DefWindowProcW")
+	getMessageW                         = user32.NewProc("GetMessageW")
+	dispatchMessageW                    = user32.NewProc("DispatchMessageW")
+	postMessageW                        = user32.NewProc("PostMessageW")
+	postQuitMessage                     = user32.NewProc("PostQuitMessage")
+	registerSuspendResumeNotification   = user32.NewProc("RegisterSuspendResumeNotification")
+	wtsRegisterSessionNotification      = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	wtsUnRegisterSessionNotification    = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+	unregisterSuspendResumeNotification = user32.NewProc("UnregisterSuspendResumeNotification")
+)
+
+// wndClassEx mirrors WNDCLASSEXW
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     uintptr
+}
+
+// msg mirrors MSG
+type msg struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	X, Y    int32
+	Private uint32
+}
+
+// sessionEvents receives the events of the window procedure, which can't
+// carry state of its own. There is only ever one watcher.
+var sessionEvents chan string
+
+// sessionWndProc turns the notifications into events, dropping them while an
+// earlier one is still waiting
+func sessionWndProc(hwnd, message, wParam, lParam uintptr) uintptr {
+	event := ""
+	switch message {
+	case WM_WTSSESSION_CHANGE:
+		switch wParam {
+		case WTS_SESSION_LOGON:
+			event = sessionLogon
+		case WTS_SESSION_UNLOCK:
+			event = sessionUnlock
+		}
+	case WM_POWERBROADCAST:
+		if wParam == PBT_APMRESUMEAUTOMATIC {
+			event = sessionResume
+		}
+	case WM_DESTROY:
+		postQuitMessage.Call(0)
+		return 0
+	}
+	if event != "" {
+		select {
+		case sessionEvents <- event:
+		default:
+		}
+	}
+	ret, _, _ := defWindowProcW.Call(hwnd, message, wParam, lParam)
+	return ret
+}
+
+// watchSessionEvents registers a message-only window for logon and unlock
+// notifications of all sessions and for resume from sleep, and sends them on
+// the returned channel until ctx is done
+func watchSessionEvents(ctx context.Context) (<-chan string, error) {
+	sessionEvents = make(chan string, 1)
+	ready := make(chan error, 1)
+
+	go func() {
+		// The window belongs to this thread and only its message loop sees its messages
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		instance, _, _ := getModuleHandleW.Call(0)
+		className, _ := syscall.UTF16PtrFromString("DiskMonitorSessionEvents")
+		class := wndClassEx{
+			WndProc:   syscall.NewCallback(sessionWndProc),
+			Instance:  instance,
+			ClassName: className,
+		}
+		class.Size = uint32(unsafe.Sizeof(class))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&class))); ret == 0 {
+			ready <- fmt.Errorf("failed to register window class: %v", err)
+			return
+		}
+		hwnd, _, err := createWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0,
+			HWND_MESSAGE, 0, instance, 0)
+		if hwnd == 0 {
+			ready <- fmt.Errorf("failed to create window: %v", err)
+			return
+		}
+		if ret, _, err := wtsRegisterSessionNotification.Call(hwnd, NOTIFY_FOR_ALL_SESSIONS); ret == 0 {
+			ready <- fmt.Errorf("failed to register for session notifications: %v", err)
+			return
+		}
+		defer wtsUnRegisterSessionNotification.Call(hwnd)
+		power, _, err := registerSuspendResumeNotification.Call(hwnd, DEVICE_NOTIFY_WINDOW_HANDLE)
+		if power == 0 {
+			ready <- fmt.Errorf("failed to register for resume notifications: %v", err)
+			return
+		}
+		defer unregisterSuspendResumeNotification.Call(power)
+		ready <- nil
+
+		go func() {
+			<-ctx.Done()
+			postMessageW.Call(hwnd, WM_CLOSE, 0, 0)
+		}()
+		var m msg
+		for {
+			ret, _, _ := getMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			// 0 is WM_QUIT, -1 an error
+			if ret == 0 || int32(ret) == -1 {
+				return
+			}
+			dispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+		}
+	}()
+
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return sessionEvents, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Silence keeps the alerts of a drive, or of all drives, from the sinks until it ends
+type Silence struct {
+	// Drive is the silenced drive, pool or folder, empty for all of them
+	Drive   string    `json:"drive,omitempty"`
+	Created time.Time `json:"created"`
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// getSilencesFilePath returns path to the silences file
+func getSilencesFilePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_silences.json")
+}
+
+// loadSilences loads the silences that haven't ended by now
+func loadSilences(now time.Time) ([]Silence, error) {
+	data, err := os.ReadFile(getSilencesFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var all []Silence
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	var active []Silence
+	for _, s := range all {
+		if s.Until.After(now) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// saveSilences saves the silences
+func saveSilences(silences []Silence) error {
+	data, err := json.MarshalIndent(silences, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getSilencesFilePath(), data, 0644)
+}
+
+// matchesDrive reports whether drive is one of drives, which may be written
+// without the trailing backslash or in another case; empty drives match all
+func matchesDrive(drives []string, drive string) bool {
+	if len(drives) == 0 {
+		return true
+	}
+	for _, d := range drives {
+		if strings.EqualFold(d, drive) || strings.EqualFold(normalizeDrive(d), drive) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdays maps the day names of maintenance windows
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// maintenanceWindow is a parsed MaintenanceWindow
+type maintenanceWindow struct {
+	drives []string
+	// days are the weekdays the window starts on, nil for every day
+	days map[time.Weekday]bool
+	// start and end are minutes after midnight
+	start, end int
+	reason     string
+}
+
+// active reports whether t, in local time, falls into the window
+func (w maintenanceWindow) active(t time.Time) bool {
+	t = t.Local()
+	minute := t.Hour()*60 + t.Minute()
+	startsOn := func(day time.Weekday) bool { return w.days == nil || w.days[day] }
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end && startsOn(t.Weekday())
+	}
+	// The window runs past midnight into the next day
+	if minute >= w.start {
+		return startsOn(t.Weekday())
+	}
+	return minute < w.end && startsOn((t.Weekday()+6)%7)
+}
+
+// parseClock parses a "15:04" time into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// maintenance parses the maintenance windows
+func (c AlertConfig) maintenance() ([]maintenanceWindow, error) {
+	var windows []maintenanceWindow
+	for i, mw := range c.Maintenance {
+		w := maintenanceWindow{drives: mw.Drives, reason: mw.Reason}
+		var err error
+		if w.start, err = parseClock(mw.Start); err != nil {
+			return nil, fmt.Errorf("maintenance window #%d: %v", i+1, err)
+		}
+		if w.end, err = parseClock(mw.End); err != nil {
+			return nil, fmt.Errorf("maintenance window #%d: %v", i+1, err)
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("maintenance window #%d: start and end are the same", i+1)
+		}
+		for _, name := range mw.Days {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("maintenance window #%d: invalid day %q, use mon to sun", i+1, name)
+			}
+			if w.days == nil {
+				w.days = make(map[time.Weekday]bool)
+			}
+			w.days[day] = true
+		}
+		if w.reason == "" {
+			w.reason = "maintenance window"
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// silenceAlerts splits the alerts into those still sent to the sinks and those
+// a silence or maintenance window holds back, which are logged instead
+func silenceAlerts(alerts []Alert, silences []Silence, windows []maintenanceWindow, now time.Time) (sent []Alert, silenced map[string][]Alert) {
+	silenced = make(map[string][]Alert)
+	for _, a := range alerts {
+		reason := ""
+		for _, s := range silences {
+			if s.Drive == "" || matchesDrive([]string{s.Drive}, a.Drive) {
+				reason = s.Reason
+				if reason == "" {
+					reason = "silenced"
+				}
+				break
+			}
+		}
+		if reason == "" {
+			for _, w := range windows {
+				if matchesDrive(w.drives, a.Drive) && w.active(now) {
+					reason = w.reason
+					break
+				}
+			}
+		}
+		if reason == "" {
+			sent = append(sent, a)
+			continue
+		}
+		silenced[reason] = append(silenced[reason], a)
+	}
+	return sent, silenced
+}
+
+// runSilence keeps the alerts of a drive from the sinks for a while, lists the
+// active silences and maintenance windows, or clears silences
+func runSilence(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("silence", flag.ExitOnError)
+	drive := fs.String("drive", "", "Drive, pool or folder to silence, all of them if empty")
+	forFlag := fs.String("for", "", "How long to silence alerts, e.g. 4h or 2d")
+	reason := fs.String("reason", "", "Why the alerts are silenced, logged with them")
+	clearFlag := fs.Bool("clear", false, "End the silences of -drive, or all silences")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	silences, err := loadSilences(now)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *clearFlag:
+		kept := silences[:0]
+		for _, s := range silences {
+			if *drive != "" && !strings.EqualFold(normalizeDrive(s.Drive), normalizeDrive(*drive)) {
+				kept = append(kept, s)
+			}
+		}
+		if err := saveSilences(kept); err != nil {
+			return err
+		}
+		fmt.Printf("%d silences cleared\n", len(silences)-len(kept))
+
+	case *forFlag != "":
+		d, err := analysis.ParseDuration(*forFlag)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid -for %q", *forFlag)
+		}
+		s := Silence{Drive: *drive, Created: now, Until: now.Add(d), Reason: *reason}
+		if err := saveSilences(append(silences, s)); err != nil {
+			return err
+		}
+		what := "All alerts"
+		if *drive != "" {
+			what = "Alerts of " + *drive
+		}
+		fmt.Printf("%s silenced until %s\n", what, locale.DateTime(s.Until.In(zone)))
+
+	default:
+		if *drive != "" || *reason != "" {
+			return fmt.Errorf("-drive and -reason need -for or -clear")
+		}
+		if len(silences) == 0 {
+			fmt.Println("No active silences")
+		}
+		for _, s := range silences {
+			drive := s.Drive
+			if drive == "" {
+				drive = "all drives"
+			}
+			fmt.Printf("%-20s until %-16s %s\n", drive, locale.DateTime(s.Until.In(zone)), s.Reason)
+		}
+		for _, mw := range cfg.Alerts.Maintenance {
+			days, drives := "every day", "all drives"
+			if len(mw.Days) > 0 {
+				days = strings.Join(mw.Days, ", ")
+			}
+			if len(mw.Drives) > 0 {
+				drives = strings.Join(mw.Drives, ", ")
+			}
+			fmt.Printf("Maintenance window: %s-%s %s, %s %s\n", mw.Start, mw.End, days, drives, mw.Reason)
+		}
+	}
+	return nil
+}
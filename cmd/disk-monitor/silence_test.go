@@ -0,0 +1,162 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local is a time on the machine's clock, which maintenance windows follow.
+// 2026-09-07 is a Monday.
+func local(day, hour, minute int) time.Time {
+	return time.Date(2026, 9, day, hour, minute, 0, 0, time.Local)
+}
+
+func TestMaintenance(t *testing.T) {
+	tests := []struct {
+		name   string
+		window MaintenanceWindow
+		err    string
+	}{
+		{name: "every day", window: MaintenanceWindow{Start: "02:00", End: "04:00"}},
+		{name: "past midnight", window: MaintenanceWindow{Start: "22:00", End: "01:30", Days: []string{"Sat", "sun"}}},
+		{name: "bad start", window: MaintenanceWindow{Start: "2am", End: "04:00"}, err: `invalid time "2am"`},
+		{name: "bad end", window: MaintenanceWindow{Start: "02:00", End: "25:00"}, err: `invalid time "25:00"`},
+		{name: "empty", window: MaintenanceWindow{Start: "02:00", End: "02:00"}, err: "start and end are the same"},
+		{name: "bad day", window: MaintenanceWindow{Start: "02:00", End: "04:00", Days: []string{"monday"}}, err: `invalid day "monday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := AlertConfig{Maintenance: []MaintenanceWindow{{Start: "01:00", End: "02:00"}, tt.window}}
+			windows, err := cfg.maintenance()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), "#2") {
+					t.Fatalf("maintenance() error = %v, want %q of window #2", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(windows) != 2 || windows[1].reason != "maintenance window" {
+				t.Errorf("maintenance() = %+v, want two windows with the default reason", windows)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	parse := func(w MaintenanceWindow) maintenanceWindow {
+		t.Helper()
+		windows, err := AlertConfig{Maintenance: []MaintenanceWindow{w}}.maintenance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return windows[0]
+	}
+	nightly := parse(MaintenanceWindow{Start: "02:00", End: "04:00"})
+	weekend := parse(MaintenanceWindow{Start: "22:00", End: "01:30", Days: []string{"sat"}})
+
+	tests := []struct {
+		name   string
+		window maintenanceWindow
+		at     time.Time
+		want   bool
+	}{
+		{"before", nightly, local(7, 1, 59), false},
+		{"start", nightly, local(7, 2, 0), true},
+		{"inside", nightly, local(8, 3, 30), true},
+		{"end", nightly, local(7, 4, 0), false},
+		{"saturday night", weekend, local(12, 23, 0), true},
+		{"after midnight", weekend, local(13, 1, 0), true},
+		{"ended on sunday", weekend, local(13, 1, 30), false},
+		{"sunday night", weekend, local(13, 23, 0), false},
+		{"friday night", weekend, local(11, 23, 0), false},
+		{"after friday midnight", weekend, local(12, 1, 0), false},
+		{"in UTC", nightly, local(7, 3, 0).UTC(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.active(tt.at); got != tt.want {
+				t.Errorf("active(%s) = %v, want %v", tt.at.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilenceAlerts(t *testing.T) {
+	alerts := []Alert{
+		{Kind: alertThreshold, Drive: `C:\`},
+		{Kind: alertThreshold, Drive: `D:\`},
+		{Kind: alertThreshold, Drive: `\\nas\backups\`},
+	}
+	backups := maintenanceWindow{drives: []string{`\\NAS\backups`}, start: 2 * 60, end: 4 * 60, reason: "backup"}
+	everything := maintenanceWindow{start: 2 * 60, end: 4 * 60, reason: "maintenance window"}
+
+	tests := []struct {
+		name     string
+		silences []Silence
+		windows  []maintenanceWindow
+		now      time.Time
+		sent     []string
+		silenced map[string][]string
+	}{
+		{name: "nothing silenced", now: local(7, 12, 0),
+			sent: []string{`C:\`, `D:\`, `\\nas\backups\`}},
+		{name: "one drive", silences: []Silence{{Drive: "d"}}, now: local(7, 12, 0),
+			sent: []string{`C:\`, `\\nas\backups\`}, silenced: map[string][]string{"silenced": {`D:\`}}},
+		{name: "all drives", silences: []Silence{{Reason: "moving house"}}, now: local(7, 12, 0),
+			silenced: map[string][]string{"moving house": {`C:\`, `D:\`, `\\nas\backups\`}}},
+		{name: "window of a share", windows: []maintenanceWindow{backups}, now: local(7, 3, 0),
+			sent: []string{`C:\`, `D:\`}, silenced: map[string][]string{"backup": {`\\nas\backups\`}}},
+		{name: "window closed", windows: []maintenanceWindow{backups}, now: local(7, 5, 0),
+			sent: []string{`C:\`, `D:\`, `\\nas\backups\`}},
+		{name: "silence before window", silences: []Silence{{Drive: `C:`, Reason: "disk swap"}},
+			windows: []maintenanceWindow{everything}, now: local(7, 3, 0),
+			silenced: map[string][]string{"disk swap": {`C:\`}, "maintenance window": {`D:\`, `\\nas\backups\`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, silenced := silenceAlerts(alerts, tt.silences, tt.windows, tt.now)
+			if got := alertDrives(sent); !slices.Equal(got, tt.sent) {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+			if len(silenced) != len(tt.silenced) {
+				t.Fatalf("silenced %v, want %q", silenced, tt.silenced)
+			}
+			for reason, want := range tt.silenced {
+				if got := alertDrives(silenced[reason]); !slices.Equal(got, want) {
+					t.Errorf("silenced for %q: %q, want %q", reason, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSilences(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	now := local(7, 12, 0)
+	if silences, err := loadSilences(now); err != nil || silences != nil {
+		t.Fatalf("loadSilences without a file = %v, %v, want none", silences, err)
+	}
+
+	all := []Silence{
+		{Drive: `C:\`, Created: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour), Reason: "over"},
+		{Drive: `D:\`, Created: now.Add(-time.Hour), Until: now, Reason: "ends now"},
+		{Drive: `E:\`, Created: now.Add(-time.Hour), Until: now.Add(time.Hour), Reason: "running"},
+	}
+	if err := saveSilences(all); err != nil {
+		t.Fatal(err)
+	}
+	silences, err := loadSilences(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(silences) != 1 || silences[0].Reason != "running" || !silences[0].Until.Equal(all[2].Until) {
+		t.Errorf("loadSilences = %+v, want only the running silence", silences)
+	}
+}
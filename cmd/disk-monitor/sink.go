@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// Event is what sinks receive after each collection
+type Event struct {
+	Host     string           `json:"host"`
+	Snapshot history.Snapshot `json:"snapshot"`
+	Alerts   []Alert          `json:"alerts,omitempty"`
+}
+
+// Sink is an alert notifier or metric output. Notifiers ignore events
+// without alerts, metric outputs send every snapshot.
+type Sink interface {
+	Send(ctx context.Context, ev *Event) error
+}
+
+// SinkConfig is one entry of the "sinks" config list. Type selects the sink,
+// the remaining keys of the block are decoded by the sink itself.
+type SinkConfig struct {
+	Type string `json:"type"`
+	// Name identifies the sink in error messages and alert policies, defaults to Type
+	Name string `json:"name"`
+	// RateLimit caps the sends, e.g. "10/h", events over it wait in the sink's queue
+	RateLimit string `json:"rate_limit"`
+	// Batch holds events until this many are queued, or the oldest is BatchWait
+	// ("1h") old, and sends them together
+	Batch     int    `json:"batch"`
+	BatchWait string `json:"batch_wait"`
+	// Retry keeps events that failed to send queued and tries again with backoff
+	Retry bool `json:"retry"`
+	// QueueSize is the most events queued, 100 when 0
+	QueueSize int `json:"queue_size"`
+	// DropPolicy is what a full queue does, drop-oldest (default) or block
+	DropPolicy string `json:"drop_policy"`
+
+	raw json.RawMessage
+}
+
+// UnmarshalJSON keeps the whole block for the sink to decode its own settings
+func (c *SinkConfig) UnmarshalJSON(data []byte) error {
+	type plain SinkConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON writes the block back with the sink specific settings
+func (c SinkConfig) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	type plain SinkConfig
+	return json.Marshal(plain(c))
+}
+
+// decode unmarshals the block into the settings struct of a sink
+func (c SinkConfig) decode(v interface{}) error {
+	if c.raw == nil {
+		return nil
+	}
+	if err := json.Unmarshal(c.raw, v); err != nil {
+		return fmt.Errorf("invalid %s sink config: %v", c.Type, err)
+	}
+	return nil
+}
+
+// sinkTypes creates a sink from its config block
+var sinkTypes = map[string]func(sc SinkConfig, cfg *Config) (Sink, error){
+	"console":       newConsoleSink,
+	"email":         newEmailSink,
+	"webhook":       newWebhookSink,
+	"toast":         newToastSink,
+	"mqtt":          newMQTTSink,
+	"prometheus":    newPrometheusSink,
+	"syslog":        newSyslogSink,
+	"kafka":         newKafkaSink,
+	"elasticsearch": newElasticSink,
+	"azure":         newAzureSink,
+	"cloudwatch":    newCloudWatchSink,
+	"datadog":       newDatadogSink,
+}
+
+// sinkTypeNames returns the registered sink types, sorted
+func sinkTypeNames() []string {
+	var names []string
+	for name := range sinkTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedSink is a configured sink with the name used in error messages
+type namedSink struct {
+	name string
+	// policy queues the events, nil sends each right away
+	policy *sinkPolicy
+	Sink
+}
+
+// loadSinks creates the sinks listed in the config
+func loadSinks(cfg *Config) ([]namedSink, error) {
+	var sinks []namedSink
+	for _, sc := range cfg.Sinks {
+		create, ok := sinkTypes[sc.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown sink type %q, use one of: %s", sc.Type, strings.Join(sinkTypeNames(), ", "))
+		}
+		s, err := create(sc, cfg)
+		if err != nil {
+			return nil, err
+		}
+		name := sc.Name
+		if name == "" {
+			name = sc.Type
+		}
+		policy, err := sc.policy()
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %v", name, err)
+		}
+		sinks = append(sinks, namedSink{name: name, policy: policy, Sink: s})
+	}
+	return sinks, nil
+}
+
+// newEvent builds the event for the latest snapshot of history
+func newEvent(snapshot history.Snapshot, alerts []Alert) *Event {
+	host, _ := os.Hostname()
+	return &Event{Host: host, Snapshot: snapshot, Alerts: alerts}
+}
+
+// dispatch sends an event to every sink, each with the alerts routing sends
+// to it that the tracker finds due; nil routing and tracker send all of them
+// everywhere. Sinks with a queue policy get the event through their queue.
+// A failing sink doesn't stop the others, the errors are returned together.
+func dispatch(ctx context.Context, sinks []namedSink, ev *Event, routing *alertRouting, tracker *alertTracker) []error {
+	var errs []error
+	var queues map[string]*sinkQueue
+	for _, s := range sinks {
+		if s.policy != nil && queues == nil {
+			var err error
+			if queues, err = loadSinkQueues(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, s := range sinks {
+		sinkEv := *ev
+		sinkEv.Alerts = tracker.due(s.name, routing.alertsFor(s.name, ev.Alerts))
+		if s.policy == nil {
+			if err := s.Send(ctx, &sinkEv); err != nil {
+				errs = append(errs, fmt.Errorf("sink %s: %v", s.name, err))
+				continue
+			}
+			tracker.sent(s.name, sinkEv.Alerts)
+			slog.Debug("event sent", "sink", s.name, "alerts", len(sinkEv.Alerts))
+			continue
+		}
+
+		q := queues[s.name]
+		if q == nil {
+			q = &sinkQueue{}
+			queues[s.name] = q
+		}
+		if err := q.makeRoom(ctx, s, s.policy); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %v", s.name, err))
+		}
+		if dropped := q.push(&sinkEv, s.policy); dropped > 0 {
+			slog.Warn("sink queue full, events dropped", "sink", s.name, "dropped", dropped)
+		}
+		// Queued alerts count as notified, the queue delivers them
+		tracker.sent(s.name, sinkEv.Alerts)
+		if err := q.flush(ctx, s, s.policy, now, false); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %v", s.name, err))
+		}
+		slog.Debug("event queued", "sink", s.name, "alerts", len(sinkEv.Alerts), "queued", len(q.Events))
+	}
+	if queues != nil {
+		if err := saveSinkQueues(queues); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save sink queues: %v", err))
+		}
+	}
+	return errs
+}
+
+// consoleSink prints alerts to stderr
+type consoleSink struct{}
+
+// newConsoleSink creates the console sink, it has no settings
+func newConsoleSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	return consoleSink{}, nil
+}
+
+// Send prints each alert on its own line
+func (consoleSink) Send(ctx context.Context, ev *Event) error {
+	for _, alert := range ev.Alerts {
+		fmt.Fprintf(os.Stderr, "ALERT [%s] %s\n", alert.Kind, alert.Message)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Azure endpoints. The instance metadata service hands out managed identity
+// tokens on VMs, App Service and Functions set IDENTITY_ENDPOINT instead.
+const (
+	azureIMDS          = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureMonitorScope  = "https://monitor.azure.com"
+	azureCollectorHost = "ods.opinsights.azure.com"
+)
+
+// azureSink sends each drive and alert as a log record to Log Analytics,
+// through the Logs Ingestion API with a managed identity or the older Data
+// Collector API with the workspace key
+type azureSink struct {
+	client *http.Client
+
+	// Data Collector API
+	workspace string
+	key       []byte
+	logType   string
+	alertType string
+	collector string
+
+	// Logs Ingestion API
+	ingest      string
+	stream      string
+	alertStream string
+	clientID    string
+	token       string
+	expires     time.Time
+}
+
+// newAzureSink creates an Azure Monitor sink
+func newAzureSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// WorkspaceID and SharedKey select the Data Collector API, records go
+		// to the <log_type>_CL tables
+		WorkspaceID   string `json:"workspace_id"`
+		SharedKey     string `json:"shared_key"`
+		LogType       string `json:"log_type"`
+		AlertsLogType string `json:"alerts_log_type"`
+		// Endpoint, RuleID and Stream select the Logs Ingestion API: the data
+		// collection endpoint, the immutable id of the rule and its streams
+		Endpoint     string `json:"endpoint"`
+		RuleID       string `json:"rule_id"`
+		Stream       string `json:"stream"`
+		AlertsStream string `json:"alerts_stream"`
+		// ClientID selects a user-assigned managed identity
+		ClientID string `json:"client_id"`
+	}
+	c.LogType = "DiskMonitor"
+	c.AlertsLogType = "DiskMonitorAlert"
+	c.Stream = "Custom-DiskMonitor"
+	c.AlertsStream = "Custom-DiskMonitorAlert"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+
+	s := &azureSink{client: &http.Client{Timeout: 30 * time.Second}}
+	switch {
+	case c.WorkspaceID != "" && c.Endpoint != "":
+		return nil, fmt.Errorf("azure sink takes either \"workspace_id\" or \"endpoint\", not both")
+	case c.WorkspaceID != "":
+		key, err := base64.StdEncoding.DecodeString(c.SharedKey)
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("azure sink needs the base64 \"shared_key\" of the workspace")
+		}
+		s.workspace, s.key = c.WorkspaceID, key
+		s.logType, s.alertType = c.LogType, c.AlertsLogType
+		s.collector = fmt.Sprintf("https://%s.%s/api/logs?api-version=2016-04-01", c.WorkspaceID, azureCollectorHost)
+	case c.Endpoint != "":
+		if c.RuleID == "" {
+			return nil, fmt.Errorf("azure sink needs the \"rule_id\" of the data collection rule")
+		}
+		s.ingest = strings.TrimSuffix(c.Endpoint, "/") + "/dataCollectionRules/" + url.PathEscape(c.RuleID) + "/streams/"
+		s.stream, s.alertStream, s.clientID = c.Stream, c.AlertsStream, c.ClientID
+	default:
+		return nil, fmt.Errorf("azure sink needs \"workspace_id\" and \"shared_key\", or \"endpoint\" and \"rule_id\"")
+	}
+	return s, nil
+}
+
+// azureRecords turns an event into drive and alert records, with the column
+// names Log Analytics tables use
+func azureRecords(ev *Event) (drives, alerts []map[string]any) {
+	for _, disk := range ev.Snapshot.Disks {
+		var used float64
+		if disk.TotalSpace > 0 {
+			used = float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+		}
+		drives = append(drives, map[string]any{
+			"TimeGenerated": ev.Snapshot.Timestamp,
+			"Computer":      ev.Host,
+			"Drive":         disk.Drive,
+			"FileSystem":    disk.FileSystem,
+			"TotalBytes":    disk.TotalSpace,
+			"FreeBytes":     disk.FreeSpace,
+			"UsedBytes":     disk.UsedSpace,
+			"UsedPercent":   used,
+		})
+	}
+	for _, alert := range ev.Alerts {
+		alerts = append(alerts, map[string]any{
+			"TimeGenerated": alert.Time,
+			"Computer":      ev.Host,
+			"Kind":          alert.Kind,
+			"Drive":         alert.Drive,
+			"Message":       alert.Message,
+		})
+	}
+	return drives, alerts
+}
+
+// Send posts the drive records and, when there are any, the alert records
+func (s *azureSink) Send(ctx context.Context, ev *Event) error {
+	drives, alerts := azureRecords(ev)
+	send := s.sendIngestion
+	table, alertTable := s.stream, s.alertStream
+	if s.workspace != "" {
+		send = s.sendCollector
+		table, alertTable = s.logType, s.alertType
+	}
+	if len(drives) > 0 {
+		if err := send(ctx, table, drives); err != nil {
+			return err
+		}
+	}
+	if len(alerts) > 0 {
+		return send(ctx, alertTable, alerts)
+	}
+	return nil
+}
+
+// sendCollector posts records to the Data Collector API, signed with the workspace key
+func (s *azureSink) sendCollector(ctx context.Context, logType string, records []map[string]any) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "POST\n%d\napplication/json\nx-ms-date:%s\n/api/logs", len(body), date)
+	headers := map[string]string{
+		"Authorization":        "SharedKey " + s.workspace + ":" + base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		"Log-Type":             logType,
+		"x-ms-date":            date,
+		"time-generated-field": "TimeGenerated",
+	}
+	return httpSend(ctx, s.client, http.MethodPost, s.collector, "application/json", headers, body)
+}
+
+// sendIngestion posts records to a stream of the data collection rule
+func (s *azureSink) sendIngestion(ctx context.Context, stream string, records []map[string]any) error {
+	token, err := s.managedToken(ctx)
+	if err != nil {
+		return fmt.Errorf("managed identity: %v", err)
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	target := s.ingest + url.PathEscape(stream) + "?api-version=2023-01-01"
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	return httpSend(ctx, s.client, http.MethodPost, target, "application/json", headers, body)
+}
+
+// managedToken returns a token for Azure Monitor, cached until shortly before it expires
+func (s *azureSink) managedToken(ctx context.Context) (string, error) {
+	if s.token != "" && time.Until(s.expires) > 5*time.Minute {
+		return s.token, nil
+	}
+
+	q := url.Values{"resource": {azureMonitorScope}}
+	endpoint, api := azureIMDS, "2018-02-01"
+	if env := os.Getenv("IDENTITY_ENDPOINT"); env != "" {
+		endpoint, api = env, "2019-08-01"
+	}
+	q.Set("api-version", api)
+	if s.clientID != "" {
+		q.Set("client_id", s.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if header := os.Getenv("IDENTITY_HEADER"); header != "" {
+		req.Header.Set("X-IDENTITY-HEADER", header)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return "", fmt.Errorf("token request returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	// expires_on is a string of Unix seconds in both services
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.Unmarshal(data, &t); err != nil || t.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response")
+	}
+	s.token = t.AccessToken
+	s.expires = time.Now().Add(time.Hour)
+	if sec, err := strconv.ParseInt(t.ExpiresOn, 10, 64); err == nil {
+		s.expires = time.Unix(sec, 0)
+	}
+	return s.token, nil
+}
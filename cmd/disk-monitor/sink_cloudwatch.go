@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cloudwatchBatch is the most metrics one PutMetricData request takes
+const cloudwatchBatch = 1000
+
+// cloudwatchSink puts drive metrics to CloudWatch, dimensioned by host and drive
+type cloudwatchSink struct {
+	endpoint   string
+	region     string
+	namespace  string
+	dimensions map[string]string
+	accessKey  string
+	secretKey  string
+	profile    string
+	creds      *awsCredentials
+	client     *http.Client
+}
+
+// newCloudWatchSink creates a CloudWatch metrics sink
+func newCloudWatchSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// Region defaults to AWS_REGION or the region of the EC2 instance
+		Region    string `json:"region"`
+		Namespace string `json:"namespace"`
+		// Dimensions are added to every metric, e.g. {"InstanceId": "i-0abc"}
+		Dimensions map[string]string `json:"dimensions"`
+		// AccessKey, SecretKey and Profile pick the credentials, otherwise the
+		// environment, ~/.aws/credentials and the instance role are tried
+		AccessKey string `json:"access_key"`
+		SecretKey string `json:"secret_key"`
+		Profile   string `json:"profile"`
+		// Endpoint replaces https://monitoring.<region>.amazonaws.com, e.g. a VPC endpoint
+		Endpoint string `json:"endpoint"`
+	}
+	c.Namespace = "DiskMonitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Namespace == "" || strings.HasPrefix(c.Namespace, "AWS/") {
+		return nil, fmt.Errorf("cloudwatch namespace must be set and not start with AWS/")
+	}
+	return &cloudwatchSink{
+		endpoint:   strings.TrimSuffix(c.Endpoint, "/"),
+		region:     c.Region,
+		namespace:  c.Namespace,
+		dimensions: c.Dimensions,
+		accessKey:  c.AccessKey,
+		secretKey:  c.SecretKey,
+		profile:    c.Profile,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// cloudwatchDatum is one value of PutMetricData
+type cloudwatchDatum struct {
+	name       string
+	unit       string
+	value      float64
+	dimensions [][2]string
+}
+
+// Send puts the free, used and total bytes and the used percentage of each
+// drive, and the number of alerts of the host
+func (s *cloudwatchSink) Send(ctx context.Context, ev *Event) error {
+	if s.region == "" {
+		region, err := awsRegion(ctx)
+		if err != nil {
+			return err
+		}
+		s.region = region
+	}
+	if s.creds.expired() {
+		creds, err := loadAWSCredentials(ctx, s.accessKey, s.secretKey, s.profile)
+		if err != nil {
+			return err
+		}
+		s.creds = creds
+	}
+
+	var extra [][2]string
+	for _, k := range slices.Sorted(maps.Keys(s.dimensions)) {
+		extra = append(extra, [2]string{k, s.dimensions[k]})
+	}
+	host := append([][2]string{{"Host", ev.Host}}, extra...)
+	var data []cloudwatchDatum
+	for _, disk := range ev.Snapshot.Disks {
+		dims := append([][2]string{{"Host", ev.Host}, {"Drive", strings.TrimSuffix(disk.Drive, `\`)}}, extra...)
+		var used float64
+		if disk.TotalSpace > 0 {
+			used = float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+		}
+		data = append(data,
+			cloudwatchDatum{"FreeSpace", "Bytes", float64(disk.FreeSpace), dims},
+			cloudwatchDatum{"UsedSpace", "Bytes", float64(disk.UsedSpace), dims},
+			cloudwatchDatum{"TotalSpace", "Bytes", float64(disk.TotalSpace), dims},
+			cloudwatchDatum{"UsedPercent", "Percent", used, dims},
+		)
+	}
+	data = append(data, cloudwatchDatum{"Alerts", "Count", float64(len(ev.Alerts)), host})
+
+	for start := 0; start < len(data); start += cloudwatchBatch {
+		if err := s.put(ctx, data[start:min(start+cloudwatchBatch, len(data))], ev.Snapshot.Timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put sends one PutMetricData request of the query API
+func (s *cloudwatchSink) put(ctx context.Context, data []cloudwatchDatum, t time.Time) error {
+	form := url.Values{
+		"Action":    {"PutMetricData"},
+		"Version":   {"2010-08-01"},
+		"Namespace": {s.namespace},
+	}
+	timestamp := t.UTC().Format(time.RFC3339)
+	for i, d := range data {
+		prefix := "MetricData.member." + strconv.Itoa(i+1) + "."
+		form.Set(prefix+"MetricName", d.name)
+		form.Set(prefix+"Unit", d.unit)
+		form.Set(prefix+"Value", strconv.FormatFloat(d.value, 'f', -1, 64))
+		form.Set(prefix+"Timestamp", timestamp)
+		for j, dim := range d.dimensions {
+			dp := prefix + "Dimensions.member." + strconv.Itoa(j+1) + "."
+			form.Set(dp+"Name", dim[0])
+			form.Set(dp+"Value", dim[1])
+		}
+	}
+	body := []byte(s3Query(form))
+
+	endpoint := s.endpoint
+	if endpoint == "" {
+		endpoint = "https://monitoring." + s.region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	awsSign(req, body, *s.creds, s.region, "monitoring", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(msg, &e) == nil && e.Code != "" {
+			// Rotated role credentials are picked up again on the next send
+			if e.Code == "ExpiredToken" {
+				s.creds = nil
+			}
+			return fmt.Errorf("PutMetricData: %s: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("PutMetricData returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// datadogSink submits drive gauges to the Datadog series API and posts an
+// event for each alert
+type datadogSink struct {
+	url     string
+	prefix  string
+	tags    []string
+	headers map[string]string
+	client  *http.Client
+}
+
+// newDatadogSink creates a Datadog sink
+func newDatadogSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// APIKey defaults to DD_API_KEY
+		APIKey string `json:"api_key"`
+		// Site is the Datadog site of the account, e.g. datadoghq.eu, defaults
+		// to DD_SITE or datadoghq.com
+		Site string `json:"site"`
+		// Prefix goes before the metric names, <prefix>.free_bytes
+		Prefix string `json:"prefix"`
+		// Tags are added to every metric and event, e.g. "env:prod"
+		Tags []string `json:"tags"`
+	}
+	c.APIKey = os.Getenv("DD_API_KEY")
+	c.Site = os.Getenv("DD_SITE")
+	c.Prefix = "disk_monitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.APIKey == "" {
+		return nil, fmt.Errorf("datadog sink needs an \"api_key\" or DD_API_KEY")
+	}
+	if c.Site == "" {
+		c.Site = "datadoghq.com"
+	}
+	site := c.Site
+	if !strings.Contains(site, "://") {
+		site = "https://api." + site
+	}
+	return &datadogSink{
+		url:     strings.TrimSuffix(site, "/"),
+		prefix:  strings.TrimSuffix(c.Prefix, "."),
+		tags:    c.Tags,
+		headers: map[string]string{"DD-API-KEY": c.APIKey},
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// datadogTags returns the configured tags followed by extra ones
+func (s *datadogSink) datadogTags(extra ...string) []string {
+	return append(append([]string(nil), s.tags...), extra...)
+}
+
+// Send submits the gauges of each drive, then the alert events
+func (s *datadogSink) Send(ctx context.Context, ev *Event) error {
+	type point struct {
+		Timestamp int64   `json:"timestamp"`
+		Value     float64 `json:"value"`
+	}
+	type resource struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	type series struct {
+		Metric    string     `json:"metric"`
+		Type      int        `json:"type"`
+		Unit      string     `json:"unit,omitempty"`
+		Points    []point    `json:"points"`
+		Tags      []string   `json:"tags"`
+		Resources []resource `json:"resources"`
+	}
+	const gauge = 3
+
+	ts := ev.Snapshot.Timestamp.Unix()
+	host := []resource{{Name: ev.Host, Type: "host"}}
+	var all []series
+	for _, disk := range ev.Snapshot.Disks {
+		tags := s.datadogTags("drive:" + strings.ToLower(strings.TrimSuffix(disk.Drive, `\`)))
+		if disk.FileSystem != "" {
+			tags = append(tags, "file_system:"+strings.ToLower(disk.FileSystem))
+		}
+		var used float64
+		if disk.TotalSpace > 0 {
+			used = float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+		}
+		for _, m := range []struct {
+			name, unit string
+			value      float64
+		}{
+			{"free_bytes", "byte", float64(disk.FreeSpace)},
+			{"used_bytes", "byte", float64(disk.UsedSpace)},
+			{"total_bytes", "byte", float64(disk.TotalSpace)},
+			{"used_percent", "percent", used},
+		} {
+			all = append(all, series{
+				Metric:    s.prefix + "." + m.name,
+				Type:      gauge,
+				Unit:      m.unit,
+				Points:    []point{{ts, m.value}},
+				Tags:      tags,
+				Resources: host,
+			})
+		}
+	}
+	all = append(all, series{
+		Metric:    s.prefix + ".alerts",
+		Type:      gauge,
+		Points:    []point{{ts, float64(len(ev.Alerts))}},
+		Tags:      s.datadogTags(),
+		Resources: host,
+	})
+
+	body, err := json.Marshal(map[string]any{"series": all})
+	if err != nil {
+		return err
+	}
+	if err := httpSend(ctx, s.client, http.MethodPost, s.url+"/api/v2/series", "application/json", s.headers, body); err != nil {
+		return err
+	}
+
+	// Events of the same drive and kind are grouped in the event stream
+	for _, alert := range ev.Alerts {
+		body, err := json.Marshal(map[string]any{
+			"title":            fmt.Sprintf("disk-monitor %s alert on %s", alert.Kind, ev.Host),
+			"text":             alert.Message,
+			"date_happened":    alert.Time.Unix(),
+			"alert_type":       "warning",
+			"host":             ev.Host,
+			"aggregation_key":  ev.Host + ":" + alert.Kind + ":" + alert.Drive,
+			"source_type_name": "disk-monitor",
+			"tags":             s.datadogTags("alert_kind:"+alert.Kind, "drive:"+strings.ToLower(strings.TrimSuffix(alert.Drive, `\`))),
+		})
+		if err != nil {
+			return err
+		}
+		if err := httpSend(ctx, s.client, http.MethodPost, s.url+"/api/v1/events", "application/json", s.headers, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// elasticSink bulk-indexes one document per drive and per alert into monthly
+// Elasticsearch or OpenSearch indices
+type elasticSink struct {
+	url     string
+	index   string
+	headers map[string]string
+	client  *http.Client
+	// templated is set once the index template is installed
+	templated bool
+}
+
+// newElasticSink creates an Elasticsearch/OpenSearch sink
+func newElasticSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// URL of the cluster, e.g. https://localhost:9200
+		URL string `json:"url"`
+		// Index prefixes the index names, <index>-drives-2006.01 and <index>-alerts-2006.01
+		Index string `json:"index"`
+		// APIKey is a base64 encoded Elasticsearch API key, else Username and
+		// Password authenticate with basic auth
+		APIKey   string `json:"api_key"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Timeout  string `json:"timeout"`
+	}
+	c.Index = "disk-monitor"
+	c.Timeout = "10s"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, fmt.Errorf("elasticsearch sink needs a \"url\"")
+	}
+	if c.Index == "" || c.Index != strings.ToLower(c.Index) {
+		return nil, fmt.Errorf("elasticsearch index must be a lowercase name")
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch timeout: %v", err)
+	}
+
+	headers := make(map[string]string)
+	switch {
+	case c.APIKey != "":
+		headers["Authorization"] = "ApiKey " + c.APIKey
+	case c.Username != "":
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+	return &elasticSink{
+		url:     strings.TrimSuffix(c.URL, "/"),
+		index:   c.Index,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// elasticTemplate maps the fields of both document kinds, keywords for the
+// fields dashboards group by and one shard, since the indices stay small
+func elasticTemplate(index string) map[string]any {
+	keyword := map[string]any{"type": "keyword"}
+	long := map[string]any{"type": "long"}
+	return map[string]any{
+		"index_patterns": []string{index + "-drives-*", index + "-alerts-*"},
+		"priority":       100,
+		"template": map[string]any{
+			"settings": map[string]any{"number_of_shards": 1},
+			"mappings": map[string]any{
+				"dynamic": false,
+				"properties": map[string]any{
+					"@timestamp":   map[string]any{"type": "date"},
+					"host":         keyword,
+					"drive":        keyword,
+					"file_system":  keyword,
+					"total":        long,
+					"free":         long,
+					"used":         long,
+					"volume_free":  long,
+					"used_percent": map[string]any{"type": "float"},
+					"kind":         keyword,
+					"message":      map[string]any{"type": "text"},
+				},
+			},
+		},
+	}
+}
+
+// install puts the index template, replacing the one of an older version
+func (s *elasticSink) install(ctx context.Context) error {
+	body, err := json.Marshal(elasticTemplate(s.index))
+	if err != nil {
+		return err
+	}
+	target := s.url + "/_index_template/" + url.PathEscape(s.index)
+	if err := httpSend(ctx, s.client, http.MethodPut, target, "application/json", s.headers, body); err != nil {
+		return fmt.Errorf("index template: %v", err)
+	}
+	s.templated = true
+	return nil
+}
+
+// Send indexes the drives and alerts of the event in one bulk request
+func (s *elasticSink) Send(ctx context.Context, ev *Event) error {
+	return s.SendBatch(ctx, []*Event{ev})
+}
+
+// SendBatch indexes the drives and alerts of several events in one bulk request
+func (s *elasticSink) SendBatch(ctx context.Context, evs []*Event) error {
+	if !s.templated {
+		if err := s.install(ctx); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	add := func(index string, doc any) {
+		enc.Encode(map[string]any{"create": map[string]any{"_index": index}})
+		enc.Encode(doc)
+	}
+	for _, ev := range evs {
+		month := ev.Snapshot.Timestamp.UTC().Format("2006.01")
+		for _, disk := range ev.Snapshot.Disks {
+			var used float64
+			if disk.TotalSpace > 0 {
+				used = float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+			}
+			add(s.index+"-drives-"+month, map[string]any{
+				"@timestamp":   ev.Snapshot.Timestamp,
+				"host":         ev.Host,
+				"drive":        disk.Drive,
+				"file_system":  disk.FileSystem,
+				"total":        disk.TotalSpace,
+				"free":         disk.FreeSpace,
+				"used":         disk.UsedSpace,
+				"volume_free":  disk.VolumeFreeSpace(),
+				"used_percent": used,
+			})
+		}
+		for _, alert := range ev.Alerts {
+			add(s.index+"-alerts-"+month, map[string]any{
+				"@timestamp": alert.Time,
+				"host":       ev.Host,
+				"kind":       alert.Kind,
+				"drive":      alert.Drive,
+				"message":    alert.Message,
+			})
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/_bulk", &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return fmt.Errorf("bulk request returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	// A bulk request succeeds as a whole, failed documents are listed in the items
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Status/100 != 2 {
+				if failed == 0 {
+					first = fmt.Sprintf("%s: %s", r.Error.Type, r.Error.Reason)
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("%d of %d documents failed, %s", failed, len(result.Items), first)
+}
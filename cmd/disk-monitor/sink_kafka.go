@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kafka API keys. The versions used predate flexible encoding and are
+// supported by every broker since Kafka 1.0.
+const (
+	kafkaProduce          = 0  // v3, the first with record batches
+	kafkaMetadata         = 3  // v4
+	kafkaSaslHandshake    = 17 // v1
+	kafkaSaslAuthenticate = 36 // v0
+)
+
+// kafkaCastagnoli is the CRC-32C table record batches are checksummed with
+var kafkaCastagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaSink produces each snapshot and alert as a JSON record keyed by host,
+// so the records of one host stay in order on one partition
+type kafkaSink struct {
+	brokers     []string
+	topic       string
+	alertsTopic string
+	acks        int16
+	clientID    string
+	username    string
+	password    string
+	tls         *tls.Config
+}
+
+// newKafkaSink creates a Kafka sink
+func newKafkaSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// Brokers are host:port bootstrap addresses, the port defaults to 9092
+		Brokers []string `json:"brokers"`
+		// Topic receives the snapshots, AlertsTopic the alerts, empty skips them
+		Topic       string  `json:"topic"`
+		AlertsTopic *string `json:"alerts_topic"`
+		// Acks is 1 for the leader or -1 for all in-sync replicas
+		Acks     int16  `json:"acks"`
+		ClientID string `json:"client_id"`
+		// TLS connects with TLS, Username and Password authenticate with SASL/PLAIN
+		TLS      bool   `json:"tls"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	c.Topic = "disk-monitor.snapshots"
+	c.Acks = 1
+	c.ClientID = "disk-monitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if len(c.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka sink needs \"brokers\"")
+	}
+	if c.Acks != 1 && c.Acks != -1 {
+		return nil, fmt.Errorf("kafka acks must be 1 or -1")
+	}
+
+	s := &kafkaSink{
+		topic:       c.Topic,
+		alertsTopic: "disk-monitor.alerts",
+		acks:        c.Acks,
+		clientID:    c.ClientID,
+		username:    c.Username,
+		password:    c.Password,
+	}
+	if c.AlertsTopic != nil {
+		s.alertsTopic = *c.AlertsTopic
+	}
+	for _, b := range c.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			b = net.JoinHostPort(b, "9092")
+		}
+		s.brokers = append(s.brokers, b)
+	}
+	if c.TLS {
+		s.tls = &tls.Config{}
+	}
+	return s, nil
+}
+
+// kafkaConn is a connection to one broker
+type kafkaConn struct {
+	conn        net.Conn
+	clientID    string
+	correlation int32
+}
+
+// kafkaString encodes a string with its int16 length
+func kafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// request sends a request and returns the response after its correlation id
+func (c *kafkaConn) request(apiKey, version int16, body []byte) ([]byte, error) {
+	c.correlation++
+	msg := binary.BigEndian.AppendUint16(nil, uint16(apiKey))
+	msg = binary.BigEndian.AppendUint16(msg, uint16(version))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(c.correlation))
+	msg = kafkaString(msg, c.clientID)
+	msg = append(msg, body...)
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	if _, err := c.conn.Write(append(frame, msg...)); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > 16<<20 {
+		return nil, fmt.Errorf("invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	if int32(binary.BigEndian.Uint32(resp)) != c.correlation {
+		return nil, fmt.Errorf("response out of order")
+	}
+	return resp[4:], nil
+}
+
+// kafkaReader decodes a response, the first short read sticks as err
+type kafkaReader struct {
+	b   []byte
+	err error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.b) < n {
+		if r.err == nil {
+			r.err = errors.New("short response")
+		}
+		return make([]byte, max(n, 0))
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kafkaReader) int16() int16 { return int16(binary.BigEndian.Uint16(r.take(2))) }
+func (r *kafkaReader) int32() int32 { return int32(binary.BigEndian.Uint32(r.take(4))) }
+func (r *kafkaReader) int64() int64 { return int64(binary.BigEndian.Uint64(r.take(8))) }
+
+// string reads a string, a nullable one reads as ""
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+// kafkaError describes a Kafka error code
+func kafkaError(code int16) error {
+	names := map[int16]string{
+		3:  "unknown topic or partition",
+		5:  "leader not available",
+		6:  "not leader for partition",
+		7:  "request timed out",
+		10: "message too large",
+		29: "topic authorization failed",
+		31: "cluster authorization failed",
+		33: "unsupported SASL mechanism",
+		58: "SASL authentication failed",
+	}
+	if name, ok := names[code]; ok {
+		return fmt.Errorf("%s (error %d)", name, code)
+	}
+	return fmt.Errorf("error %d", code)
+}
+
+// dial connects to a broker and authenticates
+func (s *kafkaSink) dial(ctx context.Context, addr string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		td := tls.Dialer{NetDialer: dialer, Config: s.tls}
+		conn, err = td.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	c := &kafkaConn{conn: conn, clientID: s.clientID}
+	if s.username == "" {
+		return c, nil
+	}
+
+	resp, err := c.request(kafkaSaslHandshake, 1, kafkaString(nil, "PLAIN"))
+	if err == nil {
+		r := &kafkaReader{b: resp}
+		if code := r.int16(); r.err == nil && code != 0 {
+			err = kafkaError(code)
+		}
+	}
+	if err == nil {
+		auth := []byte("\x00" + s.username + "\x00" + s.password)
+		body := binary.BigEndian.AppendUint32(nil, uint32(len(auth)))
+		if resp, err = c.request(kafkaSaslAuthenticate, 0, append(body, auth...)); err == nil {
+			r := &kafkaReader{b: resp}
+			code, msg := r.int16(), r.string()
+			if r.err == nil && code != 0 {
+				err = fmt.Errorf("%v: %s", kafkaError(code), msg)
+			}
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SASL authentication: %v", err)
+	}
+	return c, nil
+}
+
+// kafkaPartition is a partition of a topic and the broker leading it
+type kafkaPartition struct {
+	index  int32
+	leader string
+}
+
+// metadata returns the partitions of the topics, sorted by index
+func (c *kafkaConn) metadata(topics []string) (map[string][]kafkaPartition, error) {
+	body := binary.BigEndian.AppendUint32(nil, uint32(len(topics)))
+	for _, t := range topics {
+		body = kafkaString(body, t)
+	}
+	// New topics are created with the broker defaults when it allows it
+	body = append(body, 1)
+	resp, err := c.request(kafkaMetadata, 4, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &kafkaReader{b: resp}
+	r.int32() // throttle time
+	brokers := make(map[int32]string)
+	for range r.int32() {
+		id, host, port := r.int32(), r.string(), r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.string() // cluster id
+	r.int32()  // controller id
+	result := make(map[string][]kafkaPartition)
+	for range r.int32() {
+		code, name := r.int16(), r.string()
+		r.take(1) // is internal
+		var partitions []kafkaPartition
+		for range r.int32() {
+			pcode, index, leader := r.int16(), r.int32(), r.int32()
+			for range 2 { // replicas and in-sync replicas
+				r.take(4 * int(r.int32()))
+			}
+			if pcode == 0 && brokers[leader] != "" {
+				partitions = append(partitions, kafkaPartition{index: index, leader: brokers[leader]})
+			}
+		}
+		if r.err != nil {
+			break
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("topic %s: %v", name, kafkaError(code))
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i].index < partitions[j].index })
+		result[name] = partitions
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return result, nil
+}
+
+// kafkaRecordBatch encodes values as an uncompressed v2 record batch with one key
+func kafkaRecordBatch(key []byte, values [][]byte, t time.Time) []byte {
+	ms := t.UnixMilli()
+	var records []byte
+	for i, v := range values {
+		var rec []byte
+		rec = append(rec, 0)                     // attributes
+		rec = binary.AppendVarint(rec, 0)        // timestamp delta
+		rec = binary.AppendVarint(rec, int64(i)) // offset delta
+		rec = binary.AppendVarint(rec, int64(len(key)))
+		rec = append(rec, key...)
+		rec = binary.AppendVarint(rec, int64(len(v)))
+		rec = append(rec, v...)
+		rec = binary.AppendVarint(rec, 0) // headers
+		records = binary.AppendVarint(records, int64(len(rec)))
+		records = append(records, rec...)
+	}
+
+	// The checksum covers everything from the attributes on
+	var tail []byte
+	tail = binary.BigEndian.AppendUint16(tail, 0) // attributes: no compression
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(values)-1))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(ms))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(ms))
+	tail = binary.BigEndian.AppendUint64(tail, ^uint64(0)) // no producer id
+	tail = binary.BigEndian.AppendUint16(tail, ^uint16(0)) // producer epoch
+	tail = binary.BigEndian.AppendUint32(tail, ^uint32(0)) // base sequence
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(values)))
+	tail = append(tail, records...)
+
+	batch := binary.BigEndian.AppendUint64(nil, 0) // base offset
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(tail)))
+	batch = binary.BigEndian.AppendUint32(batch, 0) // partition leader epoch
+	batch = append(batch, 2)                        // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(tail, kafkaCastagnoli))
+	return append(batch, tail...)
+}
+
+// produce writes a record batch to one partition and waits for the acks
+func (c *kafkaConn) produce(topic string, partition int32, acks int16, batch []byte) error {
+	body := binary.BigEndian.AppendUint16(nil, ^uint16(0)) // no transactional id
+	body = binary.BigEndian.AppendUint16(body, uint16(acks))
+	body = binary.BigEndian.AppendUint32(body, 10000)
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = kafkaString(body, topic)
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = binary.BigEndian.AppendUint32(body, uint32(partition))
+	body = binary.BigEndian.AppendUint32(body, uint32(len(batch)))
+	body = append(body, batch...)
+	resp, err := c.request(kafkaProduce, 3, body)
+	if err != nil {
+		return err
+	}
+
+	r := &kafkaReader{b: resp}
+	for range r.int32() {
+		r.string()
+		for range r.int32() {
+			r.int32()
+			code := r.int16()
+			r.int64() // base offset
+			r.int64() // log append time
+			if r.err == nil && code != 0 {
+				return kafkaError(code)
+			}
+		}
+	}
+	return r.err
+}
+
+// Send produces the snapshot to the topic and the alerts to the alerts topic
+func (s *kafkaSink) Send(ctx context.Context, ev *Event) error {
+	values := make(map[string][][]byte)
+	if s.topic != "" {
+		v, err := json.Marshal(struct {
+			Host     string `json:"host"`
+			Snapshot any    `json:"snapshot"`
+		}{ev.Host, ev.Snapshot})
+		if err != nil {
+			return err
+		}
+		values[s.topic] = append(values[s.topic], v)
+	}
+	if s.alertsTopic != "" {
+		for _, alert := range ev.Alerts {
+			v, err := json.Marshal(struct {
+				Host string `json:"host"`
+				Alert
+			}{ev.Host, alert})
+			if err != nil {
+				return err
+			}
+			values[s.alertsTopic] = append(values[s.alertsTopic], v)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	topics := make([]string, 0, len(values))
+	for t := range values {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+
+	// Any broker can tell where the partitions are
+	conns := make(map[string]*kafkaConn)
+	defer func() {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	})
+	defer stop()
+	var bootstrap *kafkaConn
+	var err error
+	for _, addr := range s.brokers {
+		if bootstrap, err = s.dial(ctx, addr); err == nil {
+			conns[addr] = bootstrap
+			break
+		}
+	}
+	if bootstrap == nil {
+		return fmt.Errorf("failed to connect to %s: %v", s.brokers[0], err)
+	}
+
+	// A topic created by the request has no leader for a moment
+	var partitions map[string][]kafkaPartition
+	for attempt := 0; ; attempt++ {
+		partitions, err = bootstrap.metadata(topics)
+		if err == nil || attempt == 4 {
+			break
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("metadata: %v", err)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(ev.Host))
+	for _, topic := range topics {
+		parts := partitions[topic]
+		if len(parts) == 0 {
+			return fmt.Errorf("topic %s has no partition with a leader", topic)
+		}
+		p := parts[h.Sum32()%uint32(len(parts))]
+		c, ok := conns[p.leader]
+		if !ok {
+			if c, err = s.dial(ctx, p.leader); err != nil {
+				return fmt.Errorf("failed to connect to %s: %v", p.leader, err)
+			}
+			conns[p.leader] = c
+		}
+		batch := kafkaRecordBatch([]byte(ev.Host), values[topic], ev.Snapshot.Timestamp)
+		if err := c.produce(topic, p.index, s.acks, batch); err != nil {
+			return fmt.Errorf("topic %s: %v", topic, err)
+		}
+	}
+	return nil
+}
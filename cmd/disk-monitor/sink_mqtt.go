@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// MQTT 3.1.1 packet types
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xe0
+)
+
+// mqttSink publishes drive state and alerts to an MQTT broker, QoS 0.
+// Each drive is retained at <topic>/<host>/<drive>, alerts go to <topic>/<host>/alerts.
+type mqttSink struct {
+	broker   string
+	topic    string
+	clientID string
+	username string
+	password string
+	retain   bool
+}
+
+// newMQTTSink creates an MQTT sink
+func newMQTTSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// Broker is host:port, the port defaults to 1883
+		Broker   string `json:"broker"`
+		Topic    string `json:"topic"`
+		ClientID string `json:"client_id"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		// Retain keeps the last drive state on the broker for new subscribers
+		Retain *bool `json:"retain"`
+	}
+	c.Topic = "disk-monitor"
+	c.ClientID = "disk-monitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Broker == "" {
+		return nil, fmt.Errorf("mqtt sink needs a \"broker\"")
+	}
+	if _, _, err := net.SplitHostPort(c.Broker); err != nil {
+		c.Broker = net.JoinHostPort(c.Broker, "1883")
+	}
+
+	return &mqttSink{
+		broker:   c.Broker,
+		topic:    strings.TrimSuffix(c.Topic, "/"),
+		clientID: c.ClientID,
+		username: c.Username,
+		password: c.Password,
+		retain:   c.Retain == nil || *c.Retain,
+	}, nil
+}
+
+// mqttString encodes a length-prefixed UTF-8 string
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// mqttPacket frames a packet with its variable-length remaining length
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// connect opens a session with the broker, cancelling ctx aborts the handshake
+func (s *mqttSink) connect(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", s.broker)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	flags := byte(0x02) // clean session
+	payload := mqttString(s.clientID)
+	if s.username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(s.username)...)
+		if s.password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(s.password)...)
+		}
+	}
+	body := append(mqttString("MQTT"), 4, flags, 0, 60)
+	body = append(body, payload...)
+	if _, err := conn.Write(mqttPacket(mqttConnect, body)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no reply from broker: %v", err)
+	}
+	if ack[0] != mqttConnAck || ack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection (code %d)", ack[3])
+	}
+	return conn, nil
+}
+
+// publish sends a QoS 0 message
+func (s *mqttSink) publish(conn net.Conn, topic string, payload []byte, retain bool) error {
+	header := byte(mqttPublish)
+	if retain {
+		header |= 0x01
+	}
+	_, err := conn.Write(mqttPacket(header, append(mqttString(topic), payload...)))
+	return err
+}
+
+// Send publishes each drive and the alerts of the event
+func (s *mqttSink) Send(ctx context.Context, ev *Event) error {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", s.broker, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	base := s.topic + "/" + ev.Host
+	for _, disk := range ev.Snapshot.Disks {
+		payload, err := json.Marshal(struct {
+			Timestamp time.Time `json:"timestamp"`
+			Total     uint64    `json:"total_space"`
+			Free      uint64    `json:"free_space"`
+			Used      uint64    `json:"used_space"`
+			// VolumeFree is more than Free when a quota limits the space disk-monitor may use
+			VolumeFree   uint64 `json:"volume_free"`
+			QuotaLimited bool   `json:"quota_limited"`
+		}{ev.Snapshot.Timestamp, disk.TotalSpace, disk.FreeSpace, disk.UsedSpace, disk.VolumeFreeSpace(), disk.QuotaLimited()})
+		if err != nil {
+			return err
+		}
+		drive := driveKey(disk.Drive)
+		if err := s.publish(conn, base+"/"+drive, payload, s.retain); err != nil {
+			return err
+		}
+	}
+	for _, alert := range ev.Alerts {
+		payload, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		if err := s.publish(conn, base+"/alerts", payload, false); err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.Write([]byte{mqttDisconnect, 0})
+	return err
+}
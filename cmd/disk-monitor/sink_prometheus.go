@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// prometheusSink pushes drive gauges to a Prometheus Pushgateway
+type prometheusSink struct {
+	url    string
+	client *http.Client
+}
+
+// newPrometheusSink creates a Pushgateway sink. Metrics are grouped by job
+// and by the host as instance.
+func newPrometheusSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// URL of the Pushgateway, e.g. http://localhost:9091
+		URL string `json:"url"`
+		Job string `json:"job"`
+	}
+	c.Job = "disk_monitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, fmt.Errorf("prometheus sink needs the Pushgateway \"url\"")
+	}
+
+	return &prometheusSink{
+		url:    strings.TrimSuffix(c.URL, "/") + "/metrics/job/" + url.PathEscape(c.Job),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// promLabel escapes a label value for the Prometheus text format
+func promLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Send replaces the metrics of this host on the Pushgateway
+func (s *prometheusSink) Send(ctx context.Context, ev *Event) error {
+	var b strings.Builder
+	gauges := []struct {
+		name, help string
+		value      func(d diskinfo.DiskInfo) uint64
+	}{
+		{"disk_monitor_total_bytes", "Total size of the drive", func(d diskinfo.DiskInfo) uint64 { return d.TotalSpace }},
+		{"disk_monitor_free_bytes", "Free space of the drive", func(d diskinfo.DiskInfo) uint64 { return d.FreeSpace }},
+		{"disk_monitor_used_bytes", "Used space of the drive", func(d diskinfo.DiskInfo) uint64 { return d.UsedSpace }},
+		{"disk_monitor_volume_free_bytes", "Free space of the whole volume, more than free when a quota applies", func(d diskinfo.DiskInfo) uint64 { return d.VolumeFreeSpace() }},
+		{"disk_monitor_files", "File records in use on the volume, 0 when not recorded", func(d diskinfo.DiskInfo) uint64 { return d.Files }},
+		{"disk_monitor_max_files", "File records the volume can have, 0 when not recorded", func(d diskinfo.DiskInfo) uint64 { return d.MaxFiles }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, disk := range ev.Snapshot.Disks {
+			fmt.Fprintf(&b, "%s{drive=\"%s\"} %d\n", g.name, promLabel(disk.Drive), g.value(disk))
+		}
+	}
+	fmt.Fprintf(&b, "# HELP disk_monitor_alerts Alerts raised by the last collection\n# TYPE disk_monitor_alerts gauge\n")
+	fmt.Fprintf(&b, "disk_monitor_alerts %d\n", len(ev.Alerts))
+	fmt.Fprintf(&b, "# HELP disk_monitor_last_collection_seconds Time of the last collection\n# TYPE disk_monitor_last_collection_seconds gauge\n")
+	fmt.Fprintf(&b, "disk_monitor_last_collection_seconds %d\n", ev.Snapshot.Timestamp.Unix())
+
+	target := s.url + "/instance/" + url.PathEscape(ev.Host)
+	return httpSend(ctx, s.client, http.MethodPut, target, "text/plain; version=0.0.4", nil, []byte(b.String()))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// Syslog severities of RFC 5424
+const (
+	syslogWarning = 4
+	syslogInfo    = 6
+)
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSDID is the structured data namespace, 32473 is the private enterprise
+// number set aside for documentation and examples
+const syslogSDID = "@32473"
+
+// syslogSink sends each drive and alert as an RFC 5424 message. UDP sends one
+// datagram per message, TCP and TLS frame them with octet counting (RFC 6587, 5425).
+type syslogSink struct {
+	network    string
+	address    string
+	facility   int
+	appName    string
+	alertsOnly bool
+	tls        *tls.Config
+}
+
+// newSyslogSink creates a syslog sink
+func newSyslogSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		// Network is udp, tcp or tls
+		Network string `json:"network"`
+		// Address is host:port, default localhost on port 514, 6514 for TLS
+		Address  string `json:"address"`
+		Facility string `json:"facility"`
+		AppName  string `json:"app_name"`
+		// AlertsOnly sends only the alerts, not the drives of every collection
+		AlertsOnly bool `json:"alerts_only"`
+		// CAFile verifies the collector's TLS certificate instead of the system roots
+		CAFile string `json:"ca_file"`
+	}
+	c.Network = "udp"
+	c.Facility = "daemon"
+	c.AppName = "disk-monitor"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+
+	s := &syslogSink{network: strings.ToLower(c.Network), address: c.Address, appName: c.AppName, alertsOnly: c.AlertsOnly}
+	var ok bool
+	if s.facility, ok = syslogFacilities[strings.ToLower(c.Facility)]; !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", c.Facility)
+	}
+	port := "514"
+	switch s.network {
+	case "udp", "tcp":
+	case "tls":
+		port = "6514"
+		s.tls = &tls.Config{}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read syslog ca_file: %v", err)
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in syslog ca_file %s", c.CAFile)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q, use udp, tcp or tls", c.Network)
+	}
+	if s.address == "" {
+		s.address = "localhost"
+	}
+	if _, _, err := net.SplitHostPort(s.address); err != nil {
+		s.address = net.JoinHostPort(s.address, port)
+	}
+	return s, nil
+}
+
+// syslogParam escapes a structured data parameter value
+func syslogParam(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// syslogHeaderField returns v as a header field, which may not be empty or hold spaces
+func syslogHeaderField(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\n") {
+		return "-"
+	}
+	return v
+}
+
+// message formats one RFC 5424 message
+func (s *syslogSink) message(severity int, t time.Time, host, msgID, sd, msg string) []byte {
+	return fmt.Appendf(nil, "<%d>1 %s %s %s %d %s %s %s", s.facility*8+severity,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), syslogHeaderField(host),
+		syslogHeaderField(s.appName), os.Getpid(), msgID, sd, msg)
+}
+
+// dial connects to the collector
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if s.tls != nil {
+		td := tls.Dialer{NetDialer: dialer, Config: s.tls}
+		return td.DialContext(ctx, "tcp", s.address)
+	}
+	return dialer.DialContext(ctx, s.network, s.address)
+}
+
+// Send writes a message per drive and per alert
+func (s *syslogSink) Send(ctx context.Context, ev *Event) error {
+	if s.alertsOnly && len(ev.Alerts) == 0 {
+		return nil
+	}
+
+	var messages [][]byte
+	if !s.alertsOnly {
+		for _, disk := range ev.Snapshot.Disks {
+			used := 0.0
+			if disk.TotalSpace > 0 {
+				used = float64(disk.UsedSpace) / float64(disk.TotalSpace) * 100
+			}
+			sd := fmt.Sprintf(`[drive%s drive="%s" total="%d" free="%d" used="%d" used_percent="%.1f"]`, syslogSDID,
+				syslogParam(disk.Drive), disk.TotalSpace, disk.FreeSpace, disk.UsedSpace, used)
+			msg := fmt.Sprintf("%s %.1f%% used, %s free", disk.Drive, used, diskinfo.FormatBytes(disk.FreeSpace))
+			messages = append(messages, s.message(syslogInfo, ev.Snapshot.Timestamp, ev.Host, "snapshot", sd, msg))
+		}
+	}
+	for _, alert := range ev.Alerts {
+		sd := fmt.Sprintf(`[alert%s kind="%s" drive="%s"]`, syslogSDID, syslogParam(alert.Kind), syslogParam(alert.Drive))
+		messages = append(messages, s.message(syslogWarning, alert.Time, ev.Host, "alert", sd, alert.Message))
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", s.address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for _, m := range messages {
+		if s.network != "udp" {
+			m = append([]byte(strconv.Itoa(len(m))+" "), m...)
+		}
+		if _, err := conn.Write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// toastAppID is PowerShell's registered app ID, toasts need one to be shown
+const toastAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows a toast with a title and a body line through the WinRT API
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('<toast><visual><binding template="ToastGeneric"><text>%s</text><text>%s</text></binding></visual></toast>')
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('%s').Show([Windows.UI.Notifications.ToastNotification]::new($xml))
+`
+
+// toastSink shows alerts as Windows notifications
+type toastSink struct{}
+
+// newToastSink creates the toast sink, it has no settings
+func newToastSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	return toastSink{}, nil
+}
+
+// toastText escapes text for the toast XML inside a single-quoted PowerShell string
+func toastText(s string) string {
+	s = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// Send shows one toast per alert
+func (toastSink) Send(ctx context.Context, ev *Event) error {
+	for _, alert := range ev.Alerts {
+		title := fmt.Sprintf("Disk %s alert: %s", alert.Kind, alert.Drive)
+		script := fmt.Sprintf(toastScript, toastText(title), toastText(alert.Message), toastAppID)
+		out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to show notification: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
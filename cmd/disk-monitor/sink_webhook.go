@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookSink posts events as JSON to a URL
+type webhookSink struct {
+	url        string
+	headers    map[string]string
+	alertsOnly bool
+	client     *http.Client
+}
+
+// newWebhookSink creates a webhook sink
+func newWebhookSink(sc SinkConfig, cfg *Config) (Sink, error) {
+	var c struct {
+		URL string `json:"url"`
+		// Headers are added to each request, e.g. an Authorization token
+		Headers map[string]string `json:"headers"`
+		// AlertsOnly posts only events that raised alerts
+		AlertsOnly bool   `json:"alerts_only"`
+		Timeout    string `json:"timeout"`
+	}
+	c.Timeout = "10s"
+	if err := sc.decode(&c); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, fmt.Errorf("webhook sink needs a \"url\"")
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook timeout: %v", err)
+	}
+
+	return &webhookSink{
+		url:        c.URL,
+		headers:    c.Headers,
+		alertsOnly: c.AlertsOnly,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Send posts the event
+func (s *webhookSink) Send(ctx context.Context, ev *Event) error {
+	if s.alertsOnly && len(ev.Alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return httpSend(ctx, s.client, http.MethodPost, s.url, "application/json", s.headers, body)
+}
+
+// SendBatch posts the events as one JSON array
+func (s *webhookSink) SendBatch(ctx context.Context, evs []*Event) error {
+	var batch []*Event
+	for _, ev := range evs {
+		if !s.alertsOnly || len(ev.Alerts) > 0 {
+			batch = append(batch, ev)
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return httpSend(ctx, s.client, http.MethodPost, s.url, "application/json", s.headers, body)
+}
+
+// httpSend sends a request body and treats any non-2xx response as an error
+func httpSend(ctx context.Context, client *http.Client, method, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+)
+
+// Drop policies of a full sink queue
+const (
+	dropOldest = "drop-oldest"
+	dropBlock  = "block"
+)
+
+// Backoff of a sink whose sends fail, doubled after each failure
+const (
+	retryBackoff    = time.Minute
+	maxRetryBackoff = time.Hour
+)
+
+// batchSink is a Sink that sends several events in one request
+type batchSink interface {
+	SendBatch(ctx context.Context, evs []*Event) error
+}
+
+// sinkPolicy is how events reach a sink that is rate limited, batched or retried
+type sinkPolicy struct {
+	// rate is the most sends per window, 0 for no limit
+	rate   int
+	window time.Duration
+	// batch events are sent together, once that many are queued or the oldest is batchWait old
+	batch     int
+	batchWait time.Duration
+	retry     bool
+	size      int
+	block     bool
+}
+
+// policy parses the queue settings of a sink, nil when it has none and each
+// event is sent right away
+func (c SinkConfig) policy() (*sinkPolicy, error) {
+	if c.RateLimit == "" && c.Batch <= 1 && !c.Retry {
+		return nil, nil
+	}
+	p := &sinkPolicy{batch: c.Batch, retry: c.Retry, size: c.QueueSize}
+	if p.size <= 0 {
+		p.size = 100
+	}
+	if c.RateLimit != "" {
+		count, per, ok := strings.Cut(c.RateLimit, "/")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid rate_limit %q, use e.g. \"10/h\"", c.RateLimit)
+		}
+		// "10/h" reads as "10/1h"
+		if per != "" && (per[0] < '0' || per[0] > '9') {
+			per = "1" + per
+		}
+		if p.window, err = analysis.ParseDuration(per); err != nil || p.window <= 0 {
+			return nil, fmt.Errorf("invalid rate_limit %q, use e.g. \"10/h\"", c.RateLimit)
+		}
+		p.rate = n
+	}
+	if p.batch > 1 {
+		p.batchWait = time.Hour
+		if c.BatchWait != "" {
+			d, err := analysis.ParseDuration(c.BatchWait)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid batch_wait %q", c.BatchWait)
+			}
+			p.batchWait = d
+		}
+		if p.batch > p.size {
+			return nil, fmt.Errorf("batch %d is larger than queue_size %d", p.batch, p.size)
+		}
+	}
+	switch c.DropPolicy {
+	case "", dropOldest:
+	case dropBlock:
+		p.block = true
+	default:
+		return nil, fmt.Errorf("invalid drop_policy %q, use %s or %s", c.DropPolicy, dropOldest, dropBlock)
+	}
+	return p, nil
+}
+
+// sinkQueue holds the events of one sink that weren't sent yet, kept across
+// collections
+type sinkQueue struct {
+	Events []*Event `json:"events,omitempty"`
+	// Sent are the times of the sends within the rate limit window
+	Sent []time.Time `json:"sent,omitempty"`
+	// Failures is how many sends failed in a row, RetryAt when to try again
+	Failures int       `json:"failures,omitempty"`
+	RetryAt  time.Time `json:"retry_at,omitzero"`
+}
+
+// getSinkQueuePath returns the file of the sink queues
+func getSinkQueuePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_sink_queue.json")
+}
+
+// loadSinkQueues loads the queues by sink name, a missing file has none
+func loadSinkQueues() (map[string]*sinkQueue, error) {
+	queues := make(map[string]*sinkQueue)
+	data, err := os.ReadFile(getSinkQueuePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queues, nil
+		}
+		return queues, err
+	}
+	if err := json.Unmarshal(data, &queues); err != nil {
+		return make(map[string]*sinkQueue), fmt.Errorf("invalid sink queue file: %v", err)
+	}
+	return queues, nil
+}
+
+// saveSinkQueues writes the queues, removing the file when there is nothing to keep
+func saveSinkQueues(queues map[string]*sinkQueue) error {
+	for name, q := range queues {
+		if len(q.Events) == 0 && len(q.Sent) == 0 && q.Failures == 0 {
+			delete(queues, name)
+		}
+	}
+	if len(queues) == 0 {
+		if err := os.Remove(getSinkQueuePath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(queues, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getSinkQueuePath(), data, 0644)
+}
+
+// push queues an event. When the queue is full the oldest event without
+// alerts is dropped, or the oldest one when all have alerts, and the number
+// dropped is returned.
+func (q *sinkQueue) push(ev *Event, p *sinkPolicy) int {
+	q.Events = append(q.Events, ev)
+	dropped := 0
+	for len(q.Events) > p.size {
+		i := 0
+		for j, queued := range q.Events {
+			if len(queued.Alerts) == 0 {
+				i = j
+				break
+			}
+		}
+		q.Events = append(q.Events[:i], q.Events[i+1:]...)
+		dropped++
+	}
+	return dropped
+}
+
+// allowance returns how many sends the rate limit allows now, and when the
+// next one is allowed if none is
+func (q *sinkQueue) allowance(p *sinkPolicy, now time.Time) (int, time.Time) {
+	if p.rate == 0 {
+		return len(q.Events), now
+	}
+	recent := q.Sent[:0]
+	for _, t := range q.Sent {
+		if now.Sub(t) < p.window {
+			recent = append(recent, t)
+		}
+	}
+	q.Sent = recent
+	if n := p.rate - len(q.Sent); n > 0 {
+		return n, now
+	}
+	return 0, q.Sent[len(q.Sent)-p.rate].Add(p.window)
+}
+
+// nextSend returns when the queue may be sent next, now if it may be right away
+func (q *sinkQueue) nextSend(p *sinkPolicy, now time.Time) time.Time {
+	_, next := q.allowance(p, now)
+	if q.RetryAt.After(next) {
+		next = q.RetryAt
+	}
+	return next
+}
+
+// flush sends what the rate limit and backoff allow of the queued events.
+// A batched sink waits until a batch is full or old enough, unless force is
+// set. Failed events stay queued for a retry, or are dropped without one.
+func (q *sinkQueue) flush(ctx context.Context, s namedSink, p *sinkPolicy, now time.Time, force bool) error {
+	if len(q.Events) == 0 || q.nextSend(p, now).After(now) {
+		return nil
+	}
+	batcher, batched := s.Sink.(batchSink)
+	if p.batch > 1 && !force && len(q.Events) < p.batch && now.Sub(q.Events[0].Snapshot.Timestamp) < p.batchWait {
+		return nil
+	}
+
+	allowed, _ := q.allowance(p, now)
+	// failed is how many events the failed send carried
+	var sent, failed int
+	var err error
+	if batched && p.batch > 1 {
+		// One request per batch, however many events it carries
+		for allowed > 0 && len(q.Events) > sent && err == nil {
+			n := min(p.batch, len(q.Events)-sent)
+			if err = batcher.SendBatch(ctx, q.Events[sent:sent+n]); err == nil {
+				sent += n
+			} else {
+				failed = n
+			}
+			q.Sent = append(q.Sent, now)
+			allowed--
+		}
+	} else {
+		for sent < len(q.Events) && sent < allowed {
+			err = s.Send(ctx, q.Events[sent])
+			q.Sent = append(q.Sent, now)
+			if err != nil {
+				failed = 1
+				break
+			}
+			sent++
+		}
+	}
+	if p.rate == 0 {
+		q.Sent = nil
+	}
+
+	q.Events = q.Events[sent:]
+	if err == nil {
+		q.Failures, q.RetryAt = 0, time.Time{}
+		return nil
+	}
+	if !p.retry {
+		// Without retries failed events are lost, like with an unqueued sink
+		q.Events = q.Events[failed:]
+		return err
+	}
+	q.Failures++
+	backoff := retryBackoff << min(q.Failures-1, 10)
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	q.RetryAt = now.Add(backoff)
+	return fmt.Errorf("%v, %d events queued, retrying at %s", err, len(q.Events), q.RetryAt.Format(time.TimeOnly))
+}
+
+// makeRoom waits until a full queue of a blocking sink has room for another
+// event, sending the queued ones as the rate limit and backoff allow
+func (q *sinkQueue) makeRoom(ctx context.Context, s namedSink, p *sinkPolicy) error {
+	var errs []error
+	for p.block && len(q.Events) >= p.size {
+		now := time.Now()
+		if next := q.nextSend(p, now); next.After(now) {
+			select {
+			case <-time.After(next.Sub(now)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := q.flush(ctx, s, p, now, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[len(errs)-1]
+	}
+	return nil
+}
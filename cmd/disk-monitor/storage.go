@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// historyPath returns the file of the configured store
+func historyPath(cfg *Config) string {
+	if cfg.Storage.Path != "" {
+		return cfg.Storage.Path
+	}
+	return history.BackendPath(cfg.Storage.Backend)
+}
+
+// runConvert copies the history into another storage backend
+func runConvert(ctx context.Context, zone *time.Location, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", cfg.Storage.Backend, "Backend to read: "+strings.Join(history.Backends(), ", "))
+	in := fs.String("in", cfg.Storage.Path, "History file to read (default the backend's file in the home directory)")
+	to := fs.String("to", "", "Backend to write: "+strings.Join(history.Backends(), ", "))
+	out := fs.String("out", "", "History file to write (default the backend's file in the home directory)")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be copied")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return fmt.Errorf("-to is required")
+	}
+	// The configured path belongs to the configured backend, and so do its shards
+	shard := history.ShardNone
+	if *from != cfg.Storage.Backend && *in == cfg.Storage.Path {
+		*in = ""
+	} else if *in == cfg.Storage.Path {
+		shard = cfg.Storage.Shard
+	}
+	if *in == "" {
+		*in = history.BackendPath(*from)
+	}
+	if *out == "" {
+		*out = history.BackendPath(*to)
+	}
+	if *in == *out {
+		return fmt.Errorf("-in and -out are the same file")
+	}
+
+	src, err := history.OpenSharded(*from, *in, shard)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Opening the destination creates it, so a dry run only reads an existing one
+	if *dryRun {
+		return dryRunConvert(ctx, src, *to, *in, *out)
+	}
+	dst, err := history.Open(*to, *out)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	// Appending to existing history would leave duplicate snapshots
+	existing, err := dst.Query(ctx, time.Time{}, time.Time{}, "")
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s already contains %d snapshots", *out, len(existing))
+	}
+
+	n, err := history.Copy(ctx, dst, src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Copied %d snapshots from %s to %s\n", n, *in, *out)
+	if *to != cfg.Storage.Backend {
+		fmt.Printf("Set \"storage\": {\"backend\": %q} in the config file to use it\n", *to)
+	}
+	return nil
+}
+
+// dryRunConvert prints what convert would copy from src into the file out
+func dryRunConvert(ctx context.Context, src history.Store, to, in, out string) error {
+	if _, err := os.Stat(out); err == nil {
+		dst, err := history.Open(to, out)
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+		existing, err := dst.Query(ctx, time.Time{}, time.Time{}, "")
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("%s already contains %d snapshots", out, len(existing))
+		}
+	}
+
+	h, err := src.Load(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Would copy %d snapshots and %d baselines from %s to %s\n", len(h.Snapshots), len(h.Baselines), in, out)
+	return nil
+}
+
+// runCompact prunes old snapshots and reclaims the space in the history store
+func runCompact(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	olderThan := fs.String("older-than", "", "Delete snapshots older than this first, e.g. 365d")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be deleted")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var store history.Store
+	if *dryRun {
+		store, err = openDryRunStore(ctx, cfg)
+	} else {
+		store, err = openStore(cfg, zone)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *olderThan != "" {
+		d, err := analysis.ParseDuration(*olderThan)
+		if err != nil {
+			return fmt.Errorf("invalid -older-than: %v", err)
+		}
+		n, err := store.Prune(ctx, time.Now().Add(-d))
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Printf("Would delete %d snapshots older than %s\n", n, *olderThan)
+		} else {
+			fmt.Printf("Deleted %d snapshots older than %s\n", n, *olderThan)
+		}
+	}
+	if *dryRun {
+		fmt.Printf("Would compact %s history %s\n", cfg.Storage.Backend, historyPath(cfg))
+		return nil
+	}
+
+	if err := store.Compact(ctx); err != nil {
+		return fmt.Errorf("failed to compact history: %v", err)
+	}
+	fmt.Printf("Compacted %s history\n", cfg.Storage.Backend)
+	return nil
+}
+
+// openDryRunStore loads the configured history into a store that only lives in
+// memory, so a dry run shows what the real one would do without writing
+// anything. A corrupt history is reported, not repaired.
+func openDryRunStore(ctx context.Context, cfg *Config) (history.Store, error) {
+	store, err := history.OpenSharded(cfg.Storage.Backend, cfg.Storage.Path, cfg.Storage.Shard)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	hist, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return history.NewMemoryStore(hist), nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// tailReading is the last reading of a drive tail printed
+type tailReading struct {
+	free uint64
+	time time.Time
+}
+
+// runTail samples the drives until interrupted and prints a line whenever a
+// drive's free space moved by at least the threshold since its last line, with
+// the rate it moved at. Nothing is saved to the history.
+func runTail(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	every := fs.String("interval", "5s", "How often to sample the drives")
+	threshold := fs.String("threshold", "10MB", "Smallest change that is printed")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	interval, err := analysis.ParseDuration(*every)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid -interval %q", *every)
+	}
+	minChange, err := diskinfo.ParseSize(*threshold)
+	if err != nil {
+		return fmt.Errorf("invalid -threshold: %v", err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[normalizeDrive(name)] = true
+	}
+
+	last := make(map[string]tailReading)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		now := time.Now().In(zone)
+		disks, errs := diskinfo.CollectAll(ctx, cfg.Collection.Workers, cfg.Paths)
+		if ctx.Err() != nil {
+			return nil
+		}
+		seen := make(map[string]bool)
+		for _, disk := range disks {
+			if len(wanted) > 0 && !wanted[disk.Drive] {
+				continue
+			}
+			seen[disk.Drive] = true
+			prev, ok := last[disk.Drive]
+			switch {
+			case first:
+				fmt.Printf("%s  %-4s %s free\n", locale.Timestamp(now), disk.Drive, diskinfo.FormatBytes(disk.FreeSpace))
+			case !ok:
+				fmt.Printf("%s  %-4s attached, %s free\n", locale.Timestamp(now), disk.Drive, diskinfo.FormatBytes(disk.FreeSpace))
+			default:
+				change := float64(disk.FreeSpace) - float64(prev.free)
+				if change == 0 || max(change, -change) < float64(minChange) {
+					// The rate of the next line covers the time since the last one
+					continue
+				}
+				rate := change / now.Sub(prev.time).Seconds()
+				fmt.Printf("%s  %-4s %s at %s/s, %s free\n", locale.Timestamp(now), disk.Drive,
+					diskinfo.FormatChange(change), diskinfo.FormatChange(rate), diskinfo.FormatBytes(disk.FreeSpace))
+			}
+			last[disk.Drive] = tailReading{free: disk.FreeSpace, time: now}
+		}
+		for drive := range last {
+			if seen[drive] {
+				continue
+			}
+			// A drive that doesn't answer this once isn't gone
+			var de *diskinfo.DriveError
+			answered := true
+			for _, err := range errs {
+				if errors.As(err, &de) && de.Drive == drive {
+					answered = false
+				}
+			}
+			if answered {
+				fmt.Printf("%s  %-4s removed\n", locale.Timestamp(now), drive)
+				delete(last, drive)
+			}
+		}
+		if first && len(last) == 0 {
+			return fmt.Errorf("no drives to watch")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/valsaven/disk-monitor/internal/tui"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// FileEntry is a single file found on disk
+type FileEntry struct {
+	Path       string
+	Size       uint64
+	ModTime    time.Time
+	AccessTime time.Time
+	score      float64
+}
+
+// LastUsed returns the later of the modification and access time
+func (f FileEntry) LastUsed() time.Time {
+	if f.AccessTime.After(f.ModTime) {
+		return f.AccessTime
+	}
+	return f.ModTime
+}
+
+// fileHeap is a min-heap of files by score, used to keep the N best
+type fileHeap []FileEntry
+
+func (h fileHeap) Len() int           { return len(h) }
+func (h fileHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h fileHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x any)        { *h = append(*h, x.(FileEntry)) }
+func (h *fileHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// findFiles walks root and returns the limit files with the highest score, best first.
+// Files for which score returns a negative value are left out.
+// Entries that can't be read are skipped and counted. The walk stops when ctx is done.
+func findFiles(ctx context.Context, root string, opts scanOptions, limit int, score func(FileEntry) float64, progress func(scanProgress)) ([]FileEntry, int, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, 0, err
+	}
+
+	h := &fileHeap{}
+	var p scanProgress
+	errors := 0
+	lastReport := time.Now()
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		if ctx.Err() != nil {
+			return
+		}
+		p.Dirs++
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errors++
+			return
+		}
+
+		for _, d := range entries {
+			path := filepath.Join(dir, d.Name())
+			kind, info, err := opts.resolveEntry(path, d)
+			if err != nil {
+				errors++
+				continue
+			}
+			if kind == entryDir {
+				if depth < maxReparseDepth {
+					walk(path, depth+1)
+				}
+				continue
+			}
+			if kind != entryFile {
+				continue
+			}
+
+			p.Files++
+			p.Bytes += info.Size()
+			if progress != nil && time.Since(lastReport) > 250*time.Millisecond {
+				progress(p)
+				lastReport = time.Now()
+			}
+
+			entry := FileEntry{Path: path, Size: uint64(info.Size()), ModTime: info.ModTime(), AccessTime: accessTime(info)}
+			entry.score = score(entry)
+			if entry.score < 0 {
+				continue
+			}
+
+			if h.Len() < limit {
+				heap.Push(h, entry)
+			} else if limit > 0 && entry.score > (*h)[0].score {
+				(*h)[0] = entry
+				heap.Fix(h, 0)
+			}
+		}
+	}
+	walk(root, 0)
+
+	if progress != nil {
+		progress(p)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors, err
+	}
+
+	files := []FileEntry(*h)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].score > files[j].score
+	})
+
+	return files, errors, nil
+}
+
+// findLargestFiles returns the largest files of at least minSize, biggest first
+func findLargestFiles(ctx context.Context, root string, opts scanOptions, minSize uint64, limit int, progress func(scanProgress)) ([]FileEntry, int, error) {
+	return findFiles(ctx, root, opts, limit, func(f FileEntry) float64 {
+		if f.Size < minSize {
+			return -1
+		}
+		return float64(f.Size)
+	}, progress)
+}
+
+// openContainingFolder opens Explorer with the file selected
+func openContainingFolder(path string) error {
+	return exec.Command("explorer.exe", "/select,"+path).Start()
+}
+
+// runTopFiles lists the biggest files on a volume
+func runTopFiles(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("top-files", flag.ExitOnError)
+	minFlag := fs.String("min", "0", "Only list files at least this big, e.g. 500MB")
+	limit := fs.Int("limit", 50, "Number of files to list")
+	browse := fs.Bool("browse", false, "Show the list interactively")
+	dryRun := fs.Bool("dry-run", false, "With -browse, only show what deleting would do")
+	var excludes listFlag
+	fs.Var(&excludes, "exclude", "Glob or re:regexp to skip, can be repeated")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: top-files [-min 500MB] [-limit 50] <drive or path>")
+	}
+
+	minSize, err := diskinfo.ParseSize(*minFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -min: %v", err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := newScanOptions(cfg.Scan, excludes)
+	if err != nil {
+		return err
+	}
+
+	root := scanRoot(positional[0])
+	files, errors, err := findLargestFiles(ctx, root, opts, minSize, *limit, printScanProgress)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *browse {
+		_, err := tea.NewProgram(newFileList("Largest files in "+root, files, *dryRun), tea.WithAltScreen()).Run()
+		return err
+	}
+
+	for _, f := range files {
+		fmt.Printf("%10s  %s  %s\n", diskinfo.FormatBytes(f.Size), locale.Date(f.ModTime.In(zone)), f.Path)
+	}
+	if errors > 0 {
+		fmt.Printf("\n%d entries could not be read\n", errors)
+	}
+
+	return nil
+}
+
+// fileList is a Bubble Tea model showing a list of files
+type fileList struct {
+	title  string
+	files  []FileEntry
+	cursor int
+	height int
+	status string
+	// dryRun only reports what a delete would do
+	dryRun bool
+	// confirming is set while a delete waits for confirmation
+	confirming bool
+}
+
+// newFileList creates a file list model
+func newFileList(title string, files []FileEntry, dryRun bool) fileList {
+	return fileList{title: title, files: files, dryRun: dryRun}
+}
+
+// Init initializes the list
+func (l fileList) Init() tea.Cmd {
+	return tea.WindowSize()
+}
+
+// Update handles key presses
+func (l fileList) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.height = msg.Height
+	case tea.KeyMsg:
+		if l.confirming {
+			l.confirming = false
+			if msg.String() == "y" && l.cursor < len(l.files) {
+				l.deleteSelected()
+			} else {
+				l.status = "Delete cancelled"
+			}
+			return l, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return l, tea.Quit
+		case "up", "k":
+			if l.cursor > 0 {
+				l.cursor--
+			}
+		case "down", "j":
+			if l.cursor < len(l.files)-1 {
+				l.cursor++
+			}
+		case "o", "enter":
+			if l.cursor < len(l.files) {
+				if err := openContainingFolder(l.files[l.cursor].Path); err != nil {
+					l.status = fmt.Sprintf("Error: %v", err)
+				} else {
+					l.status = "Opened " + filepath.Dir(l.files[l.cursor].Path)
+				}
+			}
+		case "d", "delete":
+			if l.cursor < len(l.files) {
+				f := l.files[l.cursor]
+				l.confirming = true
+				l.status = fmt.Sprintf("Move %s (%s) to the Recycle Bin? y/n", f.Path, diskinfo.FormatBytes(f.Size))
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// deleteSelected moves the selected file to the Recycle Bin and drops it from the list
+func (l *fileList) deleteSelected() {
+	f := l.files[l.cursor]
+	if l.dryRun {
+		l.status = fmt.Sprintf("Dry run: would move %s to the Recycle Bin", f.Path)
+		return
+	}
+	if err := moveToRecycleBin(f.Path, f.Size, false); err != nil {
+		l.status = fmt.Sprintf("Error: %v", err)
+		return
+	}
+
+	l.files = append(l.files[:l.cursor], l.files[l.cursor+1:]...)
+	if l.cursor >= len(l.files) && l.cursor > 0 {
+		l.cursor--
+	}
+	l.status = fmt.Sprintf("Moved %s (%s) to the Recycle Bin", f.Path, diskinfo.FormatBytes(f.Size))
+}
+
+// View renders the list
+func (l fileList) View() string {
+	var s strings.Builder
+
+	s.WriteString(tui.TitleStyle.Render(l.title))
+	s.WriteString("\n\n")
+
+	if len(l.files) == 0 {
+		s.WriteString("No files found\n")
+	}
+
+	visible := l.height - 8
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if l.cursor >= visible {
+		start = l.cursor - visible + 1
+	}
+
+	for i := start; i < len(l.files) && i < start+visible; i++ {
+		f := l.files[i]
+		line := fmt.Sprintf("%10s  %s  %s", diskinfo.FormatBytes(f.Size), locale.Date(f.ModTime), f.Path)
+		if i == l.cursor {
+			s.WriteString(tui.SelectedStyle.Render(line))
+		} else {
+			s.WriteString(line)
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	if l.status != "" {
+		s.WriteString(tui.HelpStyle.Render(l.status))
+		s.WriteString("\n")
+	}
+	s.WriteString(tui.HelpStyle.Render("↑↓: select • o: open containing folder • d: move to Recycle Bin • q: quit"))
+
+	return s.String()
+}
+
+// runCleanupCandidates lists large files that haven't been used for a long time
+func runCleanupCandidates(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("cleanup-candidates", flag.ExitOnError)
+	minFlag := fs.String("min", "100MB", "Only list files at least this big")
+	months := fs.Int("months", 6, "Only list files not modified or accessed for this many months")
+	limit := fs.Int("limit", 50, "Number of files to list")
+	browse := fs.Bool("browse", false, "Show the list interactively")
+	dryRun := fs.Bool("dry-run", false, "With -browse, only show what deleting would do")
+	var excludes listFlag
+	fs.Var(&excludes, "exclude", "Glob or re:regexp to skip, can be repeated")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: cleanup-candidates [-min 100MB] [-months 6] <drive or path>")
+	}
+
+	minSize, err := diskinfo.ParseSize(*minFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -min: %v", err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := newScanOptions(cfg.Scan, excludes)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, -*months, 0)
+	root := scanRoot(positional[0])
+
+	// Rank by size × age so big forgotten files come first
+	files, errors, err := findFiles(ctx, root, opts, *limit, func(f FileEntry) float64 {
+		lastUsed := f.LastUsed()
+		if f.Size < minSize || lastUsed.After(cutoff) {
+			return -1
+		}
+		return float64(f.Size) * now.Sub(lastUsed).Hours() / 24
+	}, printScanProgress)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *browse {
+		_, err := tea.NewProgram(newFileList("Cleanup candidates in "+root, files, *dryRun), tea.WithAltScreen()).Run()
+		return err
+	}
+
+	var total uint64
+	for _, f := range files {
+		fmt.Printf("%10s  last used %s (%s)  %s\n",
+			diskinfo.FormatBytes(f.Size), locale.Date(f.LastUsed().In(zone)), durationSince(f.LastUsed()), f.Path)
+		total += f.Size
+	}
+	fmt.Printf("\n%d files, %s in total\n", len(files), diskinfo.FormatBytes(total))
+	if errors > 0 {
+		fmt.Printf("%d entries could not be read\n", errors)
+	}
+
+	return nil
+}
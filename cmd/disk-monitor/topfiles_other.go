@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime isn't tracked outside Windows, the modification time is used instead
+func accessTime(info os.FileInfo) time.Time {
+	return time.Time{}
+}
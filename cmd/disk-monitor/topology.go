@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// runTopology prints which drives live on which physical disks, with the
+// health problems of each disk, so drives sharing a failing disk stand out
+func runTopology(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("topology", flag.ExitOnError)
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	layouts, other, errs := diskinfo.LoadTopology(ctx)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(layouts) == 0 && len(errs) > 0 {
+		return errs[0]
+	}
+
+	health := make(map[int]diskinfo.Health)
+	if cfg.Health.Enabled {
+		readings, errs := diskinfo.CollectHealth(ctx)
+		for _, err := range errs {
+			slog.Warn("health skipped", "err", err)
+		}
+		for _, h := range readings {
+			health[h.Disk] = h
+		}
+	}
+
+	trim := make(map[string]diskinfo.TrimStatus)
+	if cfg.Trim.Enabled {
+		var disks []diskinfo.DiskInfo
+		for _, l := range layouts {
+			for _, v := range l.Volumes {
+				disks = append(disks, diskinfo.DiskInfo{Drive: v.Drive})
+			}
+		}
+		statuses, errs := diskinfo.CollectTrim(ctx, disks)
+		for _, err := range errs {
+			slog.Warn("TRIM status skipped", "err", err)
+		}
+		for _, t := range statuses {
+			t.LastOptimized = t.LastOptimized.In(zone)
+			trim[t.Drive] = t
+		}
+	}
+
+	for _, l := range layouts {
+		fmt.Printf("%s", l.Label())
+		if l.Bus != "" {
+			fmt.Printf(", %s", l.Bus)
+		}
+		if l.Size > 0 {
+			fmt.Printf(", %s", diskinfo.FormatBytes(l.Size))
+		}
+		fmt.Println(":")
+		for _, v := range l.Volumes {
+			fmt.Printf("  %-8s %10s", v.Drive, diskinfo.FormatBytes(v.Length))
+			if l.Size > 0 {
+				fmt.Printf("  %7s of the disk", locale.Percent(float64(v.Length)/float64(l.Size)*100, 1))
+			}
+			fmt.Println()
+			if t, ok := trim[v.Drive]; ok {
+				fmt.Printf("  %-8s TRIM %s\n", "", t.Summary())
+			}
+		}
+		if len(l.Volumes) == 0 {
+			fmt.Println("  No drive letters")
+		} else if l.Size > l.Allocated() {
+			fmt.Printf("  %-8s %10s\n", "Other", diskinfo.FormatBytes(l.Size-l.Allocated()))
+		}
+		if h, ok := health[l.Number]; ok {
+			problems := cfg.Health.Problems(h)
+			if len(problems) == 0 {
+				fmt.Println("  Health:    no problems reported")
+			}
+			for _, problem := range problems {
+				fmt.Printf("  Warning:   %s\n", problem)
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(other) > 0 {
+		fmt.Printf("Not on a local disk: %v\n", other)
+	}
+	for _, err := range errs {
+		fmt.Printf("Drive %v\n", err)
+	}
+	return nil
+}
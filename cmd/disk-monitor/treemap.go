@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// treemapDepth is how many levels below the shown directory the treemap nests
+const treemapDepth = 2
+
+// treemapColors are the backgrounds by depth below the shown directory, two
+// shades each so neighbours stand apart
+var treemapColors = [][2]lipgloss.Color{
+	{"236", "236"},
+	{"24", "30"},
+	{"54", "90"},
+	{"94", "130"},
+}
+
+// treemapRect is a directory's block in the treemap, in terminal cells
+type treemapRect struct {
+	node       *DirNode
+	x, y, w, h int
+	depth      int
+	shade      int
+	// child is the index of the shown directory's child it belongs to, -1 for the directory itself
+	child int
+}
+
+// layoutTreemap splits a block between a directory's children by size, along
+// its longer side, and nests their children in turn down to maxDepth below the
+// block's name. The files directly in the directory and children too small
+// for a cell keep the directory's color.
+func layoutTreemap(rects []treemapRect, r treemapRect, maxDepth int) []treemapRect {
+	rects = append(rects, r)
+	if r.depth == maxDepth || len(r.node.Children) == 0 || r.node.Size == 0 {
+		return rects
+	}
+	// Nested blocks keep their top row for the name
+	if r.depth > 0 {
+		if r.h < 3 {
+			return rects
+		}
+		r.y, r.h = r.y+1, r.h-1
+	}
+
+	// Cells are about twice as tall as they are wide
+	horizontal := r.w >= 2*r.h
+	span := r.h
+	if horizontal {
+		span = r.w
+	}
+	var sum uint64
+	for i, child := range r.node.Children {
+		start := int(sum * uint64(span) / r.node.Size)
+		sum += child.Size
+		end := int(sum * uint64(span) / r.node.Size)
+		if end <= start {
+			continue
+		}
+		sub := treemapRect{node: child, x: r.x, y: r.y, w: r.w, h: r.h, depth: r.depth + 1, shade: i % 2, child: r.child}
+		if r.depth == 0 {
+			sub.child = i
+		}
+		if horizontal {
+			sub.x, sub.w = r.x+start, end-start
+		} else {
+			sub.y, sub.h = r.y+start, end-start
+		}
+		rects = layoutTreemap(rects, sub, maxDepth)
+	}
+	return rects
+}
+
+// renderTreemap draws node's subdirectories as nested blocks sized by bytes
+// and colored by depth, with the selected child highlighted
+func renderTreemap(node *DirNode, width, height, selected int) string {
+	if width < 1 || height < 1 {
+		return ""
+	}
+	rects := layoutTreemap(nil, treemapRect{node: node, w: width, h: height, child: -1}, treemapDepth)
+
+	// Deeper blocks come later and cover their parents
+	owner := make([][]int, height)
+	text := make([][]rune, height)
+	for y := range owner {
+		owner[y] = make([]int, width)
+		text[y] = []rune(strings.Repeat(" ", width))
+	}
+	for i, r := range rects {
+		for y := r.y; y < r.y+r.h; y++ {
+			for x := r.x; x < r.x+r.w; x++ {
+				owner[y][x] = i
+			}
+		}
+	}
+	// Label each block where its top row is still its own
+	for i, r := range rects {
+		if r.depth == 0 {
+			continue
+		}
+		label := []rune(" " + r.node.Name + " " + diskinfo.FormatBytes(r.node.Size))
+		for j, c := range label {
+			x := r.x + j
+			if x >= r.x+r.w || owner[r.y][x] != i {
+				break
+			}
+			text[r.y][x] = c
+		}
+	}
+
+	var s strings.Builder
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; {
+			i := owner[y][x]
+			end := x
+			for end < width && owner[y][end] == i {
+				end++
+			}
+			r := rects[i]
+			style := lipgloss.NewStyle().Foreground(lipgloss.Color("15")).
+				Background(treemapColors[min(r.depth, len(treemapColors)-1)][r.shade])
+			if r.child >= 0 && r.child == selected {
+				style = style.Foreground(lipgloss.Color("0")).Background(lipgloss.Color("214"))
+			}
+			s.WriteString(style.Render(string(text[y][x:end])))
+			x = end
+		}
+		s.WriteString("\n")
+	}
+	return s.String()
+}
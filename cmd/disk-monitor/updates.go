@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// updatesLookback is how far back the first collection looks for updates, so
+// the history already recorded gets its recent updates marked too
+const updatesLookback = 30 * 24 * time.Hour
+
+// collectUpdates reads the Windows updates installed since the newest snapshot
+// in store or the journal, so each update is recorded with one snapshot
+func collectUpdates(ctx context.Context, cfg *Config, zone *time.Location, store history.Store, now time.Time) ([]diskinfo.UpdateEvent, error) {
+	since := now.Add(-updatesLookback)
+	recent, err := history.LoadWindow(ctx, store, since)
+	if err != nil {
+		return nil, err
+	}
+	addJournaled(cfg, zone, recent, since)
+	if n := len(recent.Snapshots); n > 0 {
+		since = recent.Snapshots[n-1].Timestamp
+	}
+	return diskinfo.CollectUpdates(ctx, since)
+}
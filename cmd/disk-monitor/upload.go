@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// uploadContentType is the type of upload requests and responses
+const uploadContentType = "application/x-protobuf"
+
+// maxUploadSize limits the unpacked body of an upload
+const maxUploadSize = 64 << 20
+
+// uploadRequest asks the daemon loop to store the snapshots of an agent
+type uploadRequest struct {
+	snapshots []history.Snapshot
+	result    chan uploadResult
+}
+
+// uploadResult is the outcome of an upload
+type uploadResult struct {
+	stored, duplicates int
+	err                error
+}
+
+// getUploadQueuePath returns the file of snapshots waiting to be uploaded
+func getUploadQueuePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_upload_queue.jsonl")
+}
+
+// uploadSnapshots queues a snapshot for the daemon of upload.url and sends the
+// queue in batches. The queue is kept on disk, so snapshots taken while the
+// link is down go out with the next collection that gets through, and a batch
+// whose response was lost is sent again, which the daemon takes as duplicates.
+// It returns how many snapshots were sent.
+func uploadSnapshots(ctx context.Context, cfg UploadConfig, snapshot history.Snapshot) (int, error) {
+	queue := history.OpenJournal(getUploadQueuePath())
+	if err := queue.Write(snapshot); err != nil {
+		return 0, err
+	}
+	pending, err := queue.Snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	batch := cfg.Batch
+	if batch <= 0 {
+		batch = 100
+	}
+	timeout := 30 * time.Second
+	if cfg.Timeout != "" {
+		if timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+			return 0, fmt.Errorf("invalid upload timeout: %v", err)
+		}
+	}
+	client := &http.Client{Timeout: timeout}
+
+	sent := 0
+	for sent < len(pending) {
+		end := min(sent+batch, len(pending))
+		if err := sendUpload(ctx, client, cfg, pending[sent:end]); err != nil {
+			// What is left is sent again next time
+			if err := requeue(pending[sent:]); err != nil {
+				return sent, err
+			}
+			return sent, fmt.Errorf("%v, %d snapshots left to upload", err, len(pending)-sent)
+		}
+		sent = end
+	}
+	if err := os.Remove(getUploadQueuePath()); err != nil && !os.IsNotExist(err) {
+		return sent, err
+	}
+	return sent, nil
+}
+
+// requeue replaces the upload queue with the snapshots that weren't sent.
+// They are written next to it first, so a crash keeps the old queue.
+func requeue(snapshots []history.Snapshot) error {
+	tmpPath := getUploadQueuePath() + ".tmp"
+	os.Remove(tmpPath)
+	tmp := history.OpenJournal(tmpPath)
+	for _, s := range snapshots {
+		if err := tmp.Write(s); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, getUploadQueuePath())
+}
+
+// sendUpload posts one batch of snapshots as gzipped protobuf
+func sendUpload(ctx context.Context, client *http.Client, cfg UploadConfig, snapshots []history.Snapshot) error {
+	data, err := encodeUpload(snapshots)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(cfg.URL, "/") + "/api/upload"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", uploadContentType)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(data)))
+	}
+	res, err := decodeUploadResult(data)
+	if err != nil {
+		return err
+	}
+	if res.stored+res.duplicates != len(snapshots) {
+		return fmt.Errorf("%s took %d of %d snapshots", url, res.stored+res.duplicates, len(snapshots))
+	}
+	return nil
+}
+
+// readUpload reads the snapshots of an upload request body
+func readUpload(r *http.Request) ([]history.Snapshot, error) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxUploadSize {
+		return nil, fmt.Errorf("upload larger than %d bytes, lower upload.batch", maxUploadSize)
+	}
+	return decodeUpload(data)
+}
+
+// storeUploads appends the uploaded snapshots the store doesn't have yet. It
+// runs in the daemon loop, so it never overlaps a collection or a fold.
+func storeUploads(ctx context.Context, zone *time.Location, snapshots []history.Snapshot) uploadResult {
+	if len(snapshots) == 0 {
+		return uploadResult{}
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return uploadResult{err: err}
+	}
+	store, err := openStore(cfg, zone)
+	if err != nil {
+		return uploadResult{err: err}
+	}
+	defer store.Close()
+
+	pending, duplicates, err := unstoredSnapshots(ctx, store, snapshots)
+	if err != nil {
+		return uploadResult{err: err}
+	}
+	if len(pending) > 0 {
+		if err := store.Append(ctx, pending...); err != nil {
+			return uploadResult{err: err}
+		}
+	}
+	return uploadResult{stored: len(pending), duplicates: duplicates}
+}
+
+// unstoredSnapshots returns the snapshots the store doesn't have yet, by host
+// and time, and how many it has
+func unstoredSnapshots(ctx context.Context, store history.Store, snapshots []history.Snapshot) ([]history.Snapshot, int, error) {
+	if len(snapshots) == 0 {
+		return nil, 0, nil
+	}
+	from := snapshots[0].Timestamp
+	for _, s := range snapshots {
+		if s.Timestamp.Before(from) {
+			from = s.Timestamp
+		}
+	}
+	existing, err := store.Query(ctx, from, time.Time{}, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	key := func(s history.Snapshot) string {
+		return fmt.Sprintf("%s %d", s.Host, s.Timestamp.UnixNano())
+	}
+	stored := make(map[string]bool)
+	for _, s := range existing {
+		stored[key(s)] = true
+	}
+	var pending []history.Snapshot
+	duplicates := 0
+	for _, s := range snapshots {
+		if stored[key(s)] {
+			duplicates++
+			continue
+		}
+		stored[key(s)] = true
+		pending = append(pending, s)
+	}
+	return pending, duplicates, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// uploadHours returns the hours after hourAt(0) of snapshots
+func uploadHours(snapshots []history.Snapshot) []int {
+	var hours []int
+	for _, s := range snapshots {
+		hours = append(hours, int(s.Timestamp.Sub(hourAt(0))/time.Hour))
+	}
+	return hours
+}
+
+func TestUploadSnapshots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	uploads := make(chan uploadRequest)
+	go func() {
+		for {
+			select {
+			case req := <-uploads:
+				req.result <- storeUploads(ctx, time.UTC, req.snapshots)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	api := newAPIHandler("secret", time.UTC, nil, uploads, newSnapshotFeed())
+	// accept is how many more requests get through to the daemon; lose stores
+	// them but fails the response, as if it was lost on the way back
+	var accept atomic.Int32
+	var lose atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept.Add(-1) < 0 {
+			http.Error(w, "daemon unreachable", http.StatusBadGateway)
+			return
+		}
+		if lose.Load() {
+			api.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "response lost", http.StatusGatewayTimeout)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	cfg := UploadConfig{URL: srv.URL, Token: "secret", Batch: 2}
+
+	// Each call reads the queue from disk, like an agent that was restarted
+	// since the previous collection
+	steps := []struct {
+		name   string
+		accept int32
+		lose   bool
+		// hour is the snapshot collected, sent the snapshots that got through
+		// and queued the ones left for the next call
+		hour   int
+		sent   int
+		queued []int
+	}{
+		{name: "daemon down", accept: 0, hour: 0, queued: []int{0}},
+		{name: "still down", accept: 0, hour: 1, queued: []int{0, 1}},
+		{name: "down after the first batch", accept: 1, hour: 2, sent: 2, queued: []int{2}},
+		{name: "response lost", accept: 10, lose: true, hour: 3, queued: []int{2, 3}},
+		{name: "back up", accept: 10, hour: 4, sent: 3},
+	}
+	for _, step := range steps {
+		accept.Store(step.accept)
+		lose.Store(step.lose)
+		snapshot := history.Snapshot{Timestamp: hourAt(step.hour), Host: "agent",
+			Disks: []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100, FreeSpace: uint64(50 - step.hour)}}}
+		sent, err := uploadSnapshots(ctx, cfg, snapshot)
+		if (err == nil) != (step.queued == nil) {
+			t.Errorf("%s: uploadSnapshots error = %v", step.name, err)
+		}
+		if sent != step.sent {
+			t.Errorf("%s: sent %d snapshots, want %d", step.name, sent, step.sent)
+		}
+		queue, err := history.OpenJournal(getUploadQueuePath()).Snapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := uploadHours(queue); !slices.Equal(got, step.queued) {
+			t.Errorf("%s: queued %v, want %v", step.name, got, step.queued)
+		}
+	}
+	if _, err := os.Stat(getUploadQueuePath()); !os.IsNotExist(err) {
+		t.Errorf("queue left behind: %v", err)
+	}
+
+	// The batch whose response was lost is stored once
+	daemonCfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(daemonCfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	hist, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := uploadHours(hist.Snapshots), []int{0, 1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("daemon stored %v, want %v", got, want)
+	}
+}
+
+func TestUnstoredSnapshots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(cfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	disks := []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: 100}}
+	if err := store.Append(ctx,
+		history.Snapshot{Timestamp: hourAt(1), Host: "agent", Disks: disks},
+		history.Snapshot{Timestamp: hourAt(2), Host: "nas", Disks: disks},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded := []history.Snapshot{
+		{Timestamp: hourAt(1), Host: "agent", Note: "stored"},
+		{Timestamp: hourAt(1).In(time.FixedZone("UTC+3", 3*60*60)), Host: "agent", Note: "stored, other zone"},
+		{Timestamp: hourAt(1), Host: "desktop", Note: "other host"},
+		{Timestamp: hourAt(1).Add(time.Nanosecond), Host: "agent", Note: "a nanosecond later"},
+		{Timestamp: hourAt(2), Host: "agent", Note: "new"},
+		{Timestamp: hourAt(2), Host: "agent", Note: "twice in the batch"},
+		{Timestamp: hourAt(0), Host: "agent", Note: "older than the store"},
+	}
+	pending, duplicates, err := unstoredSnapshots(ctx, store, uploaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notes []string
+	for _, s := range pending {
+		notes = append(notes, s.Note)
+	}
+	if want := []string{"other host", "a nanosecond later", "new", "older than the store"}; !slices.Equal(notes, want) {
+		t.Errorf("unstoredSnapshots = %q, want %q", notes, want)
+	}
+	if duplicates != 3 {
+		t.Errorf("duplicates = %d, want 3", duplicates)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// errNoJournal is returned outside Windows, scans then always start from scratch
+var errNoJournal = fmt.Errorf("change journal is only available on Windows")
+
+// queryUsnCheckpoint has no journal to query
+func queryUsnCheckpoint(path string) (*usnCheckpoint, error) {
+	return nil, errNoJournal
+}
+
+// readUsnChanges has no journal to read
+func readUsnChanges(root string, since *usnCheckpoint) (*usnCheckpoint, []string, error) {
+	return nil, nil, errNoJournal
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Change journal control codes
+const (
+	FSCTL_QUERY_USN_JOURNAL = 0x000900f4
+	FSCTL_READ_USN_JOURNAL  = 0x000900bb
+)
+
+var (
+	kernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	openFileById              = kernel32.NewProc("OpenFileById")
+	getFinalPathNameByHandleW = kernel32.NewProc("GetFinalPathNameByHandleW")
+)
+
+// usnJournalData mirrors USN_JOURNAL_DATA_V0
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUsnJournalData mirrors READ_USN_JOURNAL_DATA_V0
+type readUsnJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIdDescriptor mirrors FILE_ID_DESCRIPTOR with a 64-bit file ID
+type fileIdDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileId int64
+	_      int64
+}
+
+// openVolume opens the volume containing path, e.g. \\.\C:, for journal access.
+// This requires administrator rights.
+func openVolume(path string) (syscall.Handle, error) {
+	volume := filepath.VolumeName(path)
+	if len(volume) != 2 || volume[1] != ':' {
+		return syscall.InvalidHandle, fmt.Errorf("change journal needs a drive letter path")
+	}
+
+	volumePath, err := syscall.UTF16PtrFromString(`\\.\` + volume)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+
+	return syscall.CreateFile(volumePath, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, 0, 0)
+}
+
+// queryJournal returns the change journal state of an open volume
+func queryJournal(volume syscall.Handle) (*usnJournalData, error) {
+	var data usnJournalData
+	var returned uint32
+	err := syscall.DeviceIoControl(volume, FSCTL_QUERY_USN_JOURNAL, nil, 0,
+		(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &returned, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query change journal: %v", err)
+	}
+	return &data, nil
+}
+
+// queryUsnCheckpoint returns the current end of the change journal of the volume containing path
+func queryUsnCheckpoint(path string) (*usnCheckpoint, error) {
+	volume, err := openVolume(path)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(volume)
+
+	data, err := queryJournal(volume)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usnCheckpoint{JournalID: data.UsnJournalID, NextUsn: data.NextUsn}, nil
+}
+
+// readUsnChanges returns the directories under root whose contents changed since
+// the checkpoint, together with the new checkpoint. It fails if the journal was
+// recreated or has wrapped past the checkpoint, in which case a full scan is needed.
+func readUsnChanges(root string, since *usnCheckpoint) (*usnCheckpoint, []string, error) {
+	volume, err := openVolume(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer syscall.CloseHandle(volume)
+
+	data, err := queryJournal(volume)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data.UsnJournalID != since.JournalID || since.NextUsn < data.FirstUsn {
+		return nil, nil, fmt.Errorf("change journal no longer covers the previous scan")
+	}
+
+	// Collect the parent directory of every changed entry
+	parents := make(map[uint64]bool)
+	buf := make([]byte, 64*1024)
+	read := readUsnJournalData{
+		StartUsn:     since.NextUsn,
+		ReasonMask:   0xFFFFFFFF,
+		UsnJournalID: data.UsnJournalID,
+	}
+
+	for read.StartUsn < data.NextUsn {
+		var returned uint32
+		err := syscall.DeviceIoControl(volume, FSCTL_READ_USN_JOURNAL,
+			(*byte)(unsafe.Pointer(&read)), uint32(unsafe.Sizeof(read)),
+			&buf[0], uint32(len(buf)), &returned, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read change journal: %v", err)
+		}
+		if returned <= 8 {
+			break
+		}
+
+		// The buffer starts with the next USN, followed by USN_RECORD_V2 entries
+		read.StartUsn = int64(binary.LittleEndian.Uint64(buf[0:8]))
+		for offset := uint32(8); offset+64 <= returned; {
+			recordLength := binary.LittleEndian.Uint32(buf[offset:])
+			if recordLength == 0 {
+				break
+			}
+			if major := binary.LittleEndian.Uint16(buf[offset+4:]); major != 2 {
+				return nil, nil, fmt.Errorf("unsupported change journal record version %d", major)
+			}
+			parents[binary.LittleEndian.Uint64(buf[offset+16:])] = true
+			offset += recordLength
+		}
+	}
+
+	// Resolve directory IDs to paths, skipping ones that no longer exist
+	var dirs []string
+	for id := range parents {
+		path, err := pathFromFileId(volume, id)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			dirs = append(dirs, path)
+		}
+	}
+
+	return &usnCheckpoint{JournalID: data.UsnJournalID, NextUsn: data.NextUsn}, dirs, nil
+}
+
+// pathFromFileId opens a file by its NTFS file reference number and returns its path
+func pathFromFileId(volume syscall.Handle, id uint64) (string, error) {
+	desc := fileIdDescriptor{
+		Size:   uint32(unsafe.Sizeof(fileIdDescriptor{})),
+		FileId: int64(id),
+	}
+
+	h, _, err := openFileById.Call(
+		uintptr(volume),
+		uintptr(unsafe.Pointer(&desc)),
+		0,
+		uintptr(syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE),
+		0,
+		uintptr(syscall.FILE_FLAG_BACKUP_SEMANTICS),
+	)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return "", err
+	}
+	defer syscall.CloseHandle(syscall.Handle(h))
+
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	n, _, err := getFinalPathNameByHandleW.Call(h, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+	if n == 0 || int(n) > len(buf) {
+		return "", err
+	}
+
+	path := syscall.UTF16ToString(buf[:n])
+	return strings.TrimPrefix(path, `\\?\`), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// Build information, set by release builds with
+// -ldflags "-X main.version=1.2.0 -X main.commit=abc1234 -X main.buildDate=2026-01-02T15:04:05Z"
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo describes the binary for bug reports
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	// Modified is set when the binary was built from a tree with uncommitted changes
+	Modified bool `json:"modified,omitempty"`
+	// CommitTime is the time of the commit, BuildDate that of a release build
+	CommitTime time.Time `json:"commit_time,omitzero"`
+	BuildDate  time.Time `json:"build_date,omitzero"`
+	GoVersion  string    `json:"go_version"`
+	Platform   string    `json:"platform"`
+	// HistorySchema is history.SchemaVersion
+	HistorySchema  int      `json:"history_schema"`
+	Backends       []string `json:"backends"`
+	Sinks          []string `json:"sinks"`
+	ReportFormats  []string `json:"report_formats"`
+	CloudProviders []string `json:"cloud_providers"`
+}
+
+// buildInfo fills in what the linker flags left out from the module and VCS
+// information Go embeds in every build
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:        version,
+		Commit:         commit,
+		GoVersion:      runtime.Version(),
+		Platform:       runtime.GOOS + "/" + runtime.GOARCH,
+		HistorySchema:  history.SchemaVersion,
+		Backends:       history.Backends(),
+		Sinks:          sinkTypeNames(),
+		CloudProviders: diskinfo.CloudProviders,
+	}
+	for format := range reportWriters {
+		info.ReportFormats = append(info.ReportFormats, format)
+	}
+	slices.Sort(info.ReportFormats)
+	info.BuildDate, _ = time.Parse(time.RFC3339, buildDate)
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			info.CommitTime, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// runVersion prints the build information, as JSON with -json
+func runVersion(ctx context.Context, zone *time.Location, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the build information as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	info := buildInfo()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	fmt.Printf("disk-monitor %s\n", info.Version)
+	if info.Commit != "" {
+		c := info.Commit
+		if len(c) > 12 {
+			c = c[:12]
+		}
+		if info.Modified {
+			c += " (modified)"
+		}
+		// Build times are the same everywhere, so they stay in UTC
+		if !info.CommitTime.IsZero() {
+			c += ", " + info.CommitTime.UTC().Format("2006-01-02 15:04 UTC")
+		}
+		fmt.Printf("  Commit:    %s\n", c)
+	}
+	if !info.BuildDate.IsZero() {
+		fmt.Printf("  Built:     %s\n", info.BuildDate.UTC().Format("2006-01-02 15:04 UTC"))
+	}
+	fmt.Printf("  Go:        %s %s\n", info.GoVersion, info.Platform)
+	fmt.Printf("  History:   schema %d, backends %s\n", info.HistorySchema, strings.Join(info.Backends, ", "))
+	fmt.Printf("  Sinks:     %s\n", strings.Join(info.Sinks, ", "))
+	fmt.Printf("  Reports:   %s\n", strings.Join(info.ReportFormats, ", "))
+	fmt.Printf("  Cloud:     %s\n", strings.Join(info.CloudProviders, ", "))
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package clipboard
+
+import "fmt"
+
+// Copy fails outside Windows
+func Copy(text string) error {
+	return fmt.Errorf("clipboard is only supported on Windows")
+}
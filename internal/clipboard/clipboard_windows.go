@@ -0,0 +1,65 @@
+package clipboard
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	user32           = syscall.NewLazyDLL("user32.dll")
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	emptyClipboard   = user32.NewProc("EmptyClipboard")
+	setClipboardData = user32.NewProc("SetClipboardData")
+
+	globalAlloc   = kernel32.NewProc("GlobalAlloc")
+	globalFree    = kernel32.NewProc("GlobalFree")
+	globalLock    = kernel32.NewProc("GlobalLock")
+	globalUnlock  = kernel32.NewProc("GlobalUnlock")
+	rtlMoveMemory = kernel32.NewProc("RtlMoveMemory")
+)
+
+// Clipboard constants
+const (
+	CF_UNICODETEXT = 13
+	GMEM_MOVEABLE  = 0x0002
+)
+
+// Copy places text on the Windows clipboard
+func Copy(text string) error {
+	data, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	size := uintptr(len(data) * 2)
+
+	if ret, _, err := openClipboard.Call(0); ret == 0 {
+		return fmt.Errorf("failed to open clipboard: %v", err)
+	}
+	defer closeClipboard.Call()
+
+	if ret, _, err := emptyClipboard.Call(); ret == 0 {
+		return fmt.Errorf("failed to empty clipboard: %v", err)
+	}
+
+	h, _, err := globalAlloc.Call(GMEM_MOVEABLE, size)
+	if h == 0 {
+		return fmt.Errorf("failed to allocate clipboard memory: %v", err)
+	}
+	ptr, _, err := globalLock.Call(h)
+	if ptr == 0 {
+		globalFree.Call(h)
+		return fmt.Errorf("failed to lock clipboard memory: %v", err)
+	}
+	rtlMoveMemory.Call(ptr, uintptr(unsafe.Pointer(&data[0])), size)
+	globalUnlock.Call(h)
+
+	// On success the clipboard owns the memory
+	if ret, _, err := setClipboardData.Call(CF_UNICODETEXT, h); ret == 0 {
+		globalFree.Call(h)
+		return fmt.Errorf("failed to set clipboard data: %v", err)
+	}
+	return nil
+}
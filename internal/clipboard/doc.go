@@ -0,0 +1,2 @@
+// Package clipboard places text on the Windows clipboard.
+package clipboard
@@ -0,0 +1,1804 @@
+// Package tui is the interactive Bubble Tea view of disk usage and its history.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guptarohit/asciigraph"
+
+	"github.com/valsaven/disk-monitor/internal/clipboard"
+	"github.com/valsaven/disk-monitor/pkg/analysis"
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Config holds the settings of the interactive view
+type Config struct {
+	// Store holds the history shown, each refresh appends a snapshot to it
+	Store history.Store
+	// Smoothing is the moving average the s key toggles, empty uses a 5 point average
+	Smoothing string
+	Forecast  analysis.ForecastConfig
+	Anomaly   analysis.AnomalyConfig
+	// Window is how much recent history is loaded, e.g. "90d", empty loads all of it.
+	// It is widened to what the forecast and growth rates need.
+	Window string
+	// Workers is how many drives are queried at once, 0 uses diskinfo.DefaultWorkers
+	Workers int
+	// Paths are directories and UNC shares collected and shown next to the drives
+	Paths []string
+	// Cloud are the cloud storage accounts collected and shown next to the drives
+	Cloud []diskinfo.CloudAccount
+	// Warning is shown as a banner above every view, e.g. after the history was recovered
+	Warning string
+	// UTC starts the view with times in UTC, u toggles between UTC and LocalZone
+	UTC bool
+	// LocalZone is the zone shown when not in UTC, nil for time.Local
+	LocalZone *time.Location
+	// Health reads the NVMe health of the physical disks with each refresh
+	Health bool
+	// HealthLimits are the thresholds of the health view
+	HealthLimits diskinfo.HealthLimits
+	// Pools reads the Storage Spaces pools with each refresh
+	Pools bool
+	// Savings reads the deduplication savings of ReFS drives with each refresh
+	Savings bool
+	// Host names this machine in history, empty uses history.LocalHost().
+	// h switches to the snapshots of the other hosts in the store.
+	Host string
+}
+
+// Model - Bubble Tea application model
+type Model struct {
+	ctx            context.Context
+	workers        chan struct{}
+	history        *history.History
+	all            *history.History
+	host           string
+	window         time.Duration
+	loc            *time.Location
+	config         Config
+	graphs         map[string][]float64
+	currentView    string
+	selectedDisk   int
+	width          int
+	height         int
+	err            error
+	loading        bool
+	status         string
+	spinner        spinner.Model
+	drives         []string
+	disks          []diskinfo.DiskInfo
+	unavailable    []*diskinfo.DriveError
+	health         []diskinfo.Health
+	topology       []diskinfo.DiskLayout
+	pools          []diskinfo.StoragePool
+	pending        int
+	savingsRead    bool
+	selectedHealth int
+	smoothing      string
+	baseline       string
+	baseSnapshot   *history.Snapshot
+	// stacked charts used and free space up to the total instead of a free space line
+	stacked bool
+}
+
+// viewType - display mode
+type viewType string
+
+const (
+	viewChart    viewType = "chart"
+	viewCurrent  viewType = "current"
+	viewChanges  viewType = "changes"
+	viewCalendar viewType = "calendar"
+	viewPatterns viewType = "patterns"
+	viewHealth   viewType = "health"
+	viewTopology viewType = "topology"
+)
+
+// viewOrder is the order tab cycles through the views
+var viewOrder = []viewType{viewCurrent, viewChart, viewChanges, viewCalendar, viewPatterns, viewHealth, viewTopology}
+
+// defaultSmoothing is used when smoothing is toggled on without a configured setting
+const defaultSmoothing = "5"
+
+// New creates a new model showing the history in cfg.Store.
+// Collection and storage calls are cancelled with ctx.
+func New(ctx context.Context, cfg Config) (Model, error) {
+	window, err := historyWindow(cfg)
+	if err != nil {
+		return Model{}, err
+	}
+	var from time.Time
+	if window > 0 {
+		from = time.Now().Add(-window)
+	}
+	hist, err := history.LoadWindow(ctx, cfg.Store, from)
+	if err != nil {
+		return Model{}, err
+	}
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = diskinfo.DefaultWorkers
+	}
+	if cfg.LocalZone == nil {
+		cfg.LocalZone = time.Local
+	}
+	if cfg.Host == "" {
+		cfg.Host = history.LocalHost()
+	}
+
+	m := Model{
+		ctx:         ctx,
+		workers:     make(chan struct{}, workers),
+		all:         hist,
+		host:        cfg.Host,
+		window:      window,
+		config:      cfg,
+		graphs:      make(map[string][]float64),
+		currentView: string(viewCurrent),
+		loading:     true,
+		status:      "Loading data...",
+		spinner:     s,
+		smoothing:   cfg.Smoothing,
+	}
+	m.setZone(cfg.UTC)
+	return m, nil
+}
+
+// setZone shows the history in UTC or the local zone. Labels and day and hour
+// buckets follow the zone of each timestamp, so it converts them all.
+func (m *Model) setZone(utc bool) {
+	m.loc = m.config.LocalZone
+	if utc {
+		m.loc = time.UTC
+	}
+	for i := range m.all.Snapshots {
+		m.all.Snapshots[i].Timestamp = m.all.Snapshots[i].Timestamp.In(m.loc)
+	}
+	for i := range m.all.Baselines {
+		m.all.Baselines[i].Timestamp = m.all.Baselines[i].Timestamp.In(m.loc)
+	}
+	if m.baseSnapshot != nil {
+		m.baseSnapshot.Timestamp = m.baseSnapshot.Timestamp.In(m.loc)
+	}
+	m.history = m.all.ForHost(m.host, m.config.Host)
+}
+
+// hosts returns the machines in history and this one, sorted
+func (m Model) hosts() []string {
+	hosts := m.all.Hosts(m.config.Host)
+	if !slices.Contains(hosts, m.config.Host) {
+		hosts = append(hosts, m.config.Host)
+		sort.Strings(hosts)
+	}
+	return hosts
+}
+
+// selectHost shows the history of another machine
+func (m *Model) selectHost(host string) {
+	m.host = host
+	m.history = m.all.ForHost(host, m.config.Host)
+	m.selectedDisk = 0
+	m.selectedHealth = 0
+	m.updateChart()
+}
+
+// isLocal reports whether the shown host is this machine, whose drives are
+// queried live; other hosts show their latest snapshot
+func (m Model) isLocal() bool {
+	return m.host == m.config.Host
+}
+
+// now is the current time in the shown zone
+func (m Model) now() time.Time {
+	return time.Now().In(m.loc)
+}
+
+// historyWindow returns how much history the model keeps, 0 for all of it
+func historyWindow(cfg Config) (time.Duration, error) {
+	if cfg.Window == "" {
+		return 0, nil
+	}
+	window, err := analysis.ParseDuration(cfg.Window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chart window: %v", err)
+	}
+	// An invalid forecast window is reported by the forecast itself
+	if need, err := analysis.LookBack(cfg.Forecast); err == nil {
+		window = max(window, need)
+	}
+	return window, nil
+}
+
+// Init initializes the model
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(
+		tea.WindowSize(),
+		m.spinner.Tick,
+		listDrivesCmd(m.config.Paths),
+		m.watchDrives(),
+		topologyCmd(m.ctx),
+	)
+}
+
+// listDrivesCmd command to enumerate drives and paths before collection
+func listDrivesCmd(paths []string) tea.Cmd {
+	return func() tea.Msg {
+		return drivesMsg{drives: diskinfo.Targets(paths)}
+	}
+}
+
+// collectDriveCmd returns a command that collects info for a single drive.
+// It waits for a slot in workers first, so only so many drives are queried at once.
+func collectDriveCmd(ctx context.Context, workers chan struct{}, drive string) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case workers <- struct{}{}:
+			defer func() { <-workers }()
+		case <-ctx.Done():
+			return driveInfoMsg{err: &diskinfo.DriveError{Drive: drive, Err: ctx.Err()}}
+		}
+		info, err := diskinfo.QueryDrive(ctx, drive)
+		return driveInfoMsg{info: info, err: err}
+	}
+}
+
+// collectCloudCmd returns a command that reads the quota of a cloud storage account.
+// It doesn't take a worker slot, the provider is queried over the internet.
+func collectCloudCmd(ctx context.Context, account diskinfo.CloudAccount) tea.Cmd {
+	return func() tea.Msg {
+		info, err := diskinfo.CloudSpace(ctx, account)
+		return driveInfoMsg{info: info, err: err}
+	}
+}
+
+// collectHealthCmd returns a command that reads the health log of the NVMe disks
+func collectHealthCmd(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		health, errs := diskinfo.CollectHealth(ctx)
+		return healthMsg{health: health, errs: errs}
+	}
+}
+
+// collectPoolsCmd returns a command that reads the Storage Spaces pools
+func collectPoolsCmd(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		pools, err := diskinfo.CollectPools(ctx)
+		return poolsMsg{pools: pools, err: err}
+	}
+}
+
+// collectSavingsCmd returns a command that fills in the deduplication savings of
+// a copy of disks
+func collectSavingsCmd(ctx context.Context, disks []diskinfo.DiskInfo) tea.Cmd {
+	disks = append([]diskinfo.DiskInfo(nil), disks...)
+	return func() tea.Msg {
+		err := diskinfo.CollectSavings(ctx, disks)
+		return savingsMsg{disks: disks, err: err}
+	}
+}
+
+// topologyCmd returns a command that maps the drives onto the physical disks
+func topologyCmd(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		layouts, _, _ := diskinfo.LoadTopology(ctx)
+		return topologyMsg{layouts: layouts}
+	}
+}
+
+// driveWatchInterval is how often the drive list is checked for plugged in or removed drives
+const driveWatchInterval = 5 * time.Second
+
+// watchDrivesCmd enumerates the drives and paths again after driveWatchInterval,
+// and asks the locked drives whether they were unlocked meanwhile
+func watchDrivesCmd(ctx context.Context, paths, locked []string) tea.Cmd {
+	return tea.Tick(driveWatchInterval, func(time.Time) tea.Msg {
+		msg := drivesChangedMsg{drives: diskinfo.Targets(paths)}
+		for _, drive := range locked {
+			_, err := diskinfo.QueryDrive(ctx, drive)
+			if err == nil {
+				msg.unlocked = true
+				break
+			}
+		}
+		return msg
+	})
+}
+
+// drivesChangedMsg message containing the drives found by the watch
+type drivesChangedMsg struct {
+	drives []string
+	// unlocked is set when a drive locked by BitLocker answers again
+	unlocked bool
+}
+
+// drivesMsg message containing the drives to collect
+type drivesMsg struct {
+	drives []string
+}
+
+// driveInfoMsg message containing the result for one drive
+type driveInfoMsg struct {
+	info *diskinfo.DiskInfo
+	err  error
+}
+
+// poolsMsg message containing the Storage Spaces pools
+type poolsMsg struct {
+	pools []diskinfo.StoragePool
+	err   error
+}
+
+// savingsMsg message containing the drives with their deduplication savings
+type savingsMsg struct {
+	disks []diskinfo.DiskInfo
+	err   error
+}
+
+// topologyMsg message containing the physical disks and their drives
+type topologyMsg struct {
+	layouts []diskinfo.DiskLayout
+}
+
+// healthMsg message containing the health of the NVMe disks
+type healthMsg struct {
+	health []diskinfo.Health
+	errs   []error
+}
+
+// Update handles messages
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if !m.loading {
+			m.status = ""
+		}
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return m, tea.Quit
+		case "tab":
+			if m.loading {
+				return m, nil
+			}
+			// Cycle through views
+			for i, v := range viewOrder {
+				if string(v) == m.currentView {
+					m.currentView = string(viewOrder[(i+1)%len(viewOrder)])
+					break
+				}
+			}
+			m.updateChart()
+		case "up", "k":
+			if m.loading {
+				return m, nil
+			}
+			if m.currentView == string(viewHealth) {
+				m.selectedHealth = max(m.selectedHealth-1, 0)
+			} else if m.selectedDisk > 0 {
+				m.selectedDisk--
+				m.updateChart()
+			}
+		case "down", "j":
+			if m.loading {
+				return m, nil
+			}
+			if m.currentView == string(viewHealth) {
+				m.selectedHealth = max(min(m.selectedHealth+1, len(m.history.HealthDisks())-1), 0)
+			} else if m.selectedDisk < len(m.selectableDrives())-1 {
+				m.selectedDisk++
+				m.updateChart()
+			}
+		case "s":
+			// Toggle moving-average smoothing
+			if m.smoothing != "" {
+				m.smoothing = ""
+			} else if m.config.Smoothing != "" {
+				m.smoothing = m.config.Smoothing
+			} else {
+				m.smoothing = defaultSmoothing
+			}
+		case "a":
+			// Toggle the stacked used and free chart
+			m.stacked = !m.stacked
+		case "u":
+			// Toggle between UTC and local time
+			m.setZone(m.loc != time.UTC)
+			if m.loc == time.UTC {
+				m.status = "Times in UTC"
+			} else {
+				m.status = "Times in local time"
+			}
+		case "b":
+			// Cycle through saved baselines, then back to none
+			m.baseline = m.history.NextBaseline(m.baseline)
+			m.baseSnapshot = nil
+			if m.baseline != "" {
+				// The baseline may be older than the loaded window
+				_, m.baseSnapshot, _ = m.history.FindStoredBaseline(m.ctx, m.config.Store, m.baseline)
+			}
+		case "h":
+			if m.loading {
+				return m, nil
+			}
+			// Cycle through the machines in history
+			hosts := m.hosts()
+			if len(hosts) < 2 {
+				m.status = "No other hosts in history"
+				break
+			}
+			i := slices.Index(hosts, m.host)
+			m.selectHost(hosts[(i+1)%len(hosts)])
+			m.status = "Showing " + m.host
+		case "y":
+			if m.loading {
+				return m, nil
+			}
+			if err := clipboard.Copy(m.summaryText()); err != nil {
+				m.status = fmt.Sprintf("Copy failed: %v", err)
+			} else {
+				m.status = "Summary copied to clipboard"
+			}
+		case "r":
+			if m.loading {
+				return m, nil
+			}
+			// Refresh data
+			m.loading = true
+			m.status = "Refreshing data..."
+			m.err = nil
+			return m, tea.Batch(m.spinner.Tick, listDrivesCmd(m.config.Paths), topologyCmd(m.ctx))
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		if !m.loading {
+			m.updateChart()
+		}
+	case spinner.TickMsg:
+		if !m.loading {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case drivesMsg:
+		// The cached list bounds the selection until the next collection
+		m.drives = msg.drives
+		if n := len(m.selectableDrives()); m.selectedDisk >= n {
+			m.selectedDisk = max(n-1, 0)
+		}
+		if len(msg.drives) == 0 {
+			m.err = fmt.Errorf("no drives found")
+			m.loading = false
+			return m, nil
+		}
+
+		// Query all drives in parallel, results arrive one by one
+		m.disks = nil
+		m.unavailable = nil
+		m.health = nil
+		m.pools = nil
+		m.savingsRead = false
+		m.pending = len(msg.drives)
+		cmds := make([]tea.Cmd, 0, len(msg.drives)+1)
+		for _, drive := range msg.drives {
+			cmds = append(cmds, collectDriveCmd(m.ctx, m.workers, drive))
+		}
+		for _, account := range m.config.Cloud {
+			m.pending++
+			cmds = append(cmds, collectCloudCmd(m.ctx, account))
+		}
+		if m.config.Health {
+			m.pending++
+			cmds = append(cmds, collectHealthCmd(m.ctx))
+		}
+		if m.config.Pools {
+			m.pending++
+			cmds = append(cmds, collectPoolsCmd(m.ctx))
+		}
+		return m, tea.Batch(cmds...)
+	case drivesChangedMsg:
+		if m.loading || (slices.Equal(msg.drives, m.drives) && !msg.unlocked) {
+			return m, m.watchDrives()
+		}
+		// A drive was plugged in, removed or unlocked, collect again
+		m.loading = true
+		m.status = "Drives changed, refreshing..."
+		if msg.unlocked {
+			m.status = "Drive unlocked, refreshing..."
+		}
+		m.err = nil
+		drives := msg.drives
+		return m, tea.Batch(m.watchDrives(), m.spinner.Tick, topologyCmd(m.ctx), func() tea.Msg {
+			return drivesMsg{drives: drives}
+		})
+	case driveInfoMsg:
+		m.pending--
+		if msg.err != nil {
+			m.addUnavailable(msg.err)
+		} else if msg.info != nil {
+			m.disks = append(m.disks, *msg.info)
+			sort.Slice(m.disks, func(i, j int) bool {
+				return m.disks[i].Drive < m.disks[j].Drive
+			})
+		}
+
+		if m.pending == 0 {
+			return m, m.collected()
+		}
+	case poolsMsg:
+		// A failed pool query leaves the pools out, like the health view
+		m.pending--
+		m.pools = msg.pools
+		if m.pending == 0 {
+			return m, m.collected()
+		}
+	case savingsMsg:
+		// Without savings the drives are saved as they are
+		m.pending--
+		m.disks = msg.disks
+		if m.pending == 0 {
+			m.finishCollection()
+		}
+	case topologyMsg:
+		m.topology = msg.layouts
+	case healthMsg:
+		// Disks whose health can't be read are left out of the health view only
+		m.pending--
+		m.health = msg.health
+		if m.pending == 0 {
+			return m, m.collected()
+		}
+	}
+
+	return m, nil
+}
+
+// collected is called once every drive has answered. The deduplication savings of
+// ReFS drives are read then, as the drives must be known first.
+func (m *Model) collected() tea.Cmd {
+	if m.config.Savings && !m.savingsRead {
+		m.savingsRead = true
+		m.pending++
+		return collectSavingsCmd(m.ctx, m.disks)
+	}
+	m.finishCollection()
+	return nil
+}
+
+// finishCollection saves the snapshot once every drive has answered
+func (m *Model) finishCollection() {
+	m.loading = false
+	if len(m.disks) == 0 {
+		if len(m.unavailable) == 0 {
+			m.err = fmt.Errorf("no drives found")
+		}
+		return
+	}
+
+	snapshot := history.Snapshot{
+		Timestamp: m.now(),
+		Disks:     m.disks,
+		Health:    m.health,
+		Pools:     m.pools,
+		Host:      m.config.Host,
+	}
+
+	m.addSnapshot(snapshot)
+	if err := m.config.Store.Append(m.ctx, snapshot); err != nil {
+		m.err = err
+	}
+
+	m.status = ""
+	m.updateChart()
+}
+
+// selectableDrives returns the attached drives and the drives only left in
+// history, sorted, so a removed drive's series stays reachable
+func (m Model) selectableDrives() []string {
+	if !m.isLocal() {
+		return m.history.Drives()
+	}
+	drives := slices.Clone(m.drives)
+	for _, drive := range m.history.Drives() {
+		if !slices.Contains(drives, drive) {
+			drives = append(drives, drive)
+		}
+	}
+	sort.Strings(drives)
+	return drives
+}
+
+// offline reports whether a drive in history is no longer attached. Only
+// this machine's drives are known to be attached.
+func (m Model) offline(drive string) bool {
+	return m.isLocal() && m.drives != nil && !slices.Contains(m.drives, drive)
+}
+
+// watchDrives returns the drive watch for the drives unavailable right now
+func (m Model) watchDrives() tea.Cmd {
+	var locked []string
+	for _, de := range m.unavailable {
+		if errors.Is(de, diskinfo.ErrDriveLocked) {
+			locked = append(locked, de.Drive)
+		}
+	}
+	return watchDrivesCmd(m.ctx, m.config.Paths, locked)
+}
+
+// addUnavailable records a drive that failed to respond, in drive order
+func (m *Model) addUnavailable(err error) {
+	var de *diskinfo.DriveError
+	if !errors.As(err, &de) {
+		m.err = err
+		return
+	}
+	m.unavailable = append(m.unavailable, de)
+	sort.Slice(m.unavailable, func(i, j int) bool {
+		return m.unavailable[i].Drive < m.unavailable[j].Drive
+	})
+}
+
+// addSnapshot appends a new snapshot and drops the ones that left the window,
+// so a long-running view doesn't grow without bound
+func (m *Model) addSnapshot(snapshot history.Snapshot) {
+	m.all.Snapshots = append(m.all.Snapshots, snapshot)
+	if m.window > 0 {
+		from := snapshot.Timestamp.Add(-m.window)
+		i := 0
+		for i < len(m.all.Snapshots) && m.all.Snapshots[i].Timestamp.Before(from) {
+			i++
+		}
+		m.all.Snapshots = m.all.Snapshots[i:]
+	}
+	m.history = m.all.ForHost(m.host, m.config.Host)
+}
+
+// collectData collects new data
+func (m *Model) collectData() {
+	disks, errs := diskinfo.CollectAll(m.ctx, m.config.Workers, m.config.Paths)
+	cloud, cloudErrs := diskinfo.CollectCloud(m.ctx, m.config.Cloud)
+	disks = append(disks, cloud...)
+	errs = append(errs, cloudErrs...)
+	m.unavailable = nil
+	for _, err := range errs {
+		m.addUnavailable(err)
+	}
+	if len(disks) == 0 {
+		m.err = fmt.Errorf("no drives found")
+		return
+	}
+
+	snapshot := history.Snapshot{
+		Timestamp: m.now(),
+		Disks:     disks,
+		Host:      m.config.Host,
+	}
+
+	m.addSnapshot(snapshot)
+	if err := m.config.Store.Append(m.ctx, snapshot); err != nil {
+		m.err = err
+	}
+}
+
+// updateChart updates graph data
+func (m *Model) updateChart() {
+	if len(m.history.Snapshots) < 2 {
+		return
+	}
+
+	// Gather data per drive
+	m.graphs = make(map[string][]float64)
+	for _, drive := range m.history.Drives() {
+		var data []float64
+		for _, snapshot := range m.history.Snapshots {
+			for _, disk := range snapshot.Disks {
+				if disk.Drive == drive {
+					spaceGB := float64(disk.FreeSpace) / diskinfo.Gigabyte()
+					data = append(data, spaceGB)
+					break
+				}
+			}
+		}
+		m.graphs[drive] = data
+	}
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var s strings.Builder
+
+	// Title
+	s.WriteString(TitleStyle.Render("Disk Space Monitor · " + m.host))
+	s.WriteString("\n\n")
+	if m.config.Warning != "" {
+		s.WriteString(WarningStyle.Render("Warning: " + m.config.Warning))
+		s.WriteString("\n\n")
+	}
+
+	if m.loading {
+		s.WriteString(m.spinner.View())
+		s.WriteString(" ")
+		s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Render(
+			fmt.Sprintf("%s\n\n", m.status)))
+		// Show drives that have already responded
+		if len(m.disks) > 0 {
+			s.WriteString(m.renderCurrentView())
+		}
+		return s.String()
+	}
+
+	if m.err != nil {
+		s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(
+			fmt.Sprintf("Error: %v\n", m.err)))
+	}
+
+	switch m.currentView {
+	case string(viewCurrent):
+		s.WriteString(m.renderCurrentView())
+	case string(viewChart):
+		s.WriteString(m.renderChartView())
+	case string(viewChanges):
+		s.WriteString(m.renderChangesView())
+	case string(viewCalendar):
+		s.WriteString(m.renderCalendarView())
+	case string(viewPatterns):
+		s.WriteString(m.renderPatternsView())
+	case string(viewHealth):
+		s.WriteString(m.renderHealthView())
+	case string(viewTopology):
+		s.WriteString(m.renderTopologyView())
+	}
+
+	// Help
+	s.WriteString("\n\n")
+	if m.status != "" {
+		s.WriteString(HelpStyle.Render(m.status))
+		s.WriteString("\n")
+	}
+	s.WriteString(HelpStyle.Render(
+		"tab: switch view • r: refresh • ↑↓: select drive • h: host • s: smoothing • a: stacked • b: baseline • u: UTC • y: copy • q: quit"))
+
+	return s.String()
+}
+
+// summaryText returns the current view's summary as plain text for the clipboard
+func (m Model) summaryText() string {
+	var s strings.Builder
+
+	switch m.currentView {
+	case string(viewCurrent):
+		disks, unavailable, _ := m.current()
+		for _, disk := range disks {
+			fmt.Fprintf(&s, "%s  Total: %s  Free: %s  Used: %s (%s)\n",
+				disk.Drive,
+				diskinfo.FormatBytes(disk.TotalSpace),
+				diskinfo.FormatBytes(disk.FreeSpace),
+				diskinfo.FormatBytes(disk.UsedSpace),
+				locale.Percent(float64(disk.UsedSpace)/float64(disk.TotalSpace)*100, 1))
+		}
+		for _, de := range unavailable {
+			fmt.Fprintln(&s, de.Error())
+		}
+	case string(viewChart):
+		drives := m.selectableDrives()
+		if m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+			break
+		}
+		now := m.now()
+		st, err := analysis.ComputeStats(m.history, drives[m.selectedDisk], now)
+		if err != nil {
+			fmt.Fprintf(&s, "Drive %s: %v\n", drives[m.selectedDisk], err)
+			break
+		}
+		analysis.WriteStats(&s, st)
+		if f, err := analysis.ForecastDrive(m.history, st.Drive, m.config.Forecast, now); err == nil {
+			fmt.Fprintf(&s, "  Full:      %s\n", analysis.FormatForecast(f, now))
+		}
+	case string(viewChanges), string(viewCalendar):
+		drives := m.selectableDrives()
+		if m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+			break
+		}
+		days, changes := analysis.DailyChanges(m.history.Series(drives[m.selectedDisk], time.Time{}))
+		fmt.Fprintf(&s, "Daily change of %s:\n", drives[m.selectedDisk])
+		for i, day := range days {
+			fmt.Fprintf(&s, "  %s  %s\n", locale.Date(day), diskinfo.FormatChange(changes[i]))
+		}
+	case string(viewPatterns):
+		drives := m.selectableDrives()
+		if m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+			break
+		}
+		drive := drives[m.selectedDisk]
+		analysis.WritePatterns(&s, drive, analysis.AnalyzePatterns(m.history.Series(drive, time.Time{})))
+	case string(viewHealth):
+		for _, name := range m.history.HealthDisks() {
+			points := m.history.HealthSeries(name)
+			fmt.Fprintln(&s, healthLine(points[len(points)-1].Health))
+		}
+	case string(viewTopology):
+		for _, l := range m.topology {
+			fmt.Fprintf(&s, "%s  %s  %s\n", l.Label(), diskinfo.FormatBytes(l.Size), strings.Join(l.Drives(), " "))
+		}
+	}
+
+	return s.String()
+}
+
+// current returns the drives and pools of the shown host: those just queried
+// for this machine, the latest snapshot's for the others
+func (m Model) current() ([]diskinfo.DiskInfo, []*diskinfo.DriveError, []diskinfo.StoragePool) {
+	if m.isLocal() {
+		return m.disks, m.unavailable, m.pools
+	}
+	if n := len(m.history.Snapshots); n > 0 {
+		last := m.history.Snapshots[n-1]
+		return last.Disks, nil, last.Pools
+	}
+	return nil, nil, nil
+}
+
+// renderCurrentView shows current disk state
+func (m Model) renderCurrentView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("Current disk status:"))
+	s.WriteString("\n\n")
+
+	disks, unavailable, pools := m.current()
+	if len(disks) == 0 && len(unavailable) == 0 {
+		s.WriteString("No drives found\n")
+		return s.String()
+	}
+
+	base := m.baseSnapshot
+	var selected string
+	if drives := m.selectableDrives(); m.selectedDisk < len(drives) {
+		selected = drives[m.selectedDisk]
+	}
+
+	others, images := diskinfo.GroupImages(disks)
+	for i, disk := range slices.Concat(others, images) {
+		if i == len(others) {
+			s.WriteString(HeaderStyle.Render("Mounted images:"))
+			s.WriteString("\n\n")
+		}
+		diskLine := fmt.Sprintf("%s  Total: %s  Free: %s  Used: %s (%s)",
+			DiskNameStyle.Render(disk.Drive),
+			diskinfo.FormatBytes(disk.TotalSpace),
+			diskinfo.FormatBytes(disk.FreeSpace),
+			diskinfo.FormatBytes(disk.UsedSpace),
+			locale.Percent(float64(disk.UsedSpace)/float64(disk.TotalSpace)*100, 1))
+		if disk.QuotaLimited() {
+			diskLine += QuotaStyle.Render(fmt.Sprintf("  Quota: %s free on volume", diskinfo.FormatBytes(disk.VolumeFree)))
+		}
+		if disk.IsReFS() {
+			diskLine += "  " + disk.FileSystemLabel()
+		}
+		if disk.Savings > 0 {
+			diskLine += fmt.Sprintf("  Saved: %s", diskinfo.FormatBytes(disk.Savings))
+		}
+		if disk.IsImage() {
+			diskLine += "  " + strings.ToUpper(disk.Image)
+		}
+		if base != nil {
+			if delta, ok := history.BaselineDelta(base, disk); ok {
+				diskLine += fmt.Sprintf("  Δ %s: %s", m.baseline, diskinfo.FormatChange(delta))
+			}
+		}
+
+		if disk.Drive == selected {
+			s.WriteString(SelectedStyle.Render(diskLine))
+		} else {
+			s.WriteString(diskLine)
+		}
+		s.WriteString("\n")
+
+		s.WriteString("  ")
+		s.WriteString(usageBar(float64(disk.UsedSpace) / float64(disk.TotalSpace)))
+		s.WriteString("\n\n")
+	}
+
+	// Drives that didn't answer are listed instead of silently left out, a
+	// locked BitLocker volume isn't an error
+	for _, de := range unavailable {
+		if errors.Is(de, diskinfo.ErrDriveLocked) {
+			s.WriteString(DiskNameStyle.Render(de.Drive) + "  " + OfflineStyle.Render("Locked"))
+		} else {
+			s.WriteString(UnavailableStyle.Render(de.Error()))
+		}
+		s.WriteString("\n")
+	}
+	if len(unavailable) > 0 {
+		s.WriteString("\n")
+	}
+
+	for _, pool := range pools {
+		s.WriteString(fmt.Sprintf("%s  Size: %s  Allocated: %s (%s)  Free: %s",
+			DiskNameStyle.Render("Pool "+pool.Name),
+			diskinfo.FormatBytes(pool.Size),
+			diskinfo.FormatBytes(pool.Allocated),
+			locale.Percent(pool.UsedPercent(), 1),
+			diskinfo.FormatBytes(pool.Free())))
+		s.WriteString("\n  ")
+		s.WriteString(usageBar(pool.UsedPercent() / 100))
+		s.WriteString("\n")
+		// Thin disks fill the pool while their volumes still show free space
+		if pool.Overcommitted() {
+			s.WriteString(QuotaStyle.Render(fmt.Sprintf("  Thin disks can grow by %s, the pool has %s free",
+				diskinfo.FormatBytes(pool.Growth()), diskinfo.FormatBytes(pool.Free()))))
+			s.WriteString("\n")
+		}
+		s.WriteString("\n")
+	}
+
+	// Last update info
+	if !m.loading && len(m.history.Snapshots) > 0 {
+		lastSnapshot := m.history.Snapshots[len(m.history.Snapshots)-1]
+		s.WriteString(HelpStyle.Render(fmt.Sprintf(
+			"Last update: %s",
+			locale.Timestamp(lastSnapshot.Timestamp))))
+	}
+
+	return s.String()
+}
+
+// usageBar draws a progress bar of the used share, colored by how full it is
+func usageBar(usedPercent float64) string {
+	barWidth := 50
+	filledWidth := min(max(int(usedPercent*float64(barWidth)), 0), barWidth)
+
+	bar := strings.Repeat("█", filledWidth) + strings.Repeat("░", barWidth-filledWidth)
+	barColor := lipgloss.Color("10") // Green
+	if usedPercent > 0.8 {
+		barColor = lipgloss.Color("9") // Red
+	} else if usedPercent > 0.6 {
+		barColor = lipgloss.Color("11") // Yellow
+	}
+	return lipgloss.NewStyle().Foreground(barColor).Render(bar)
+}
+
+// renderChartView displays the graph
+func (m Model) renderChartView() string {
+	var s strings.Builder
+
+	if m.stacked {
+		s.WriteString(HeaderStyle.Render("Used and free space over time:"))
+	} else {
+		s.WriteString(HeaderStyle.Render("Free space over time:"))
+	}
+	s.WriteString("\n\n")
+
+	if len(m.history.Snapshots) < 2 {
+		s.WriteString("Not enough data for a graph yet.\n")
+		s.WriteString("Run the program a few times to build history.\n")
+		return s.String()
+	}
+
+	// Graph height
+	height := m.height - 20
+	if height < 10 {
+		height = 10
+	}
+
+	// Get data for selected drive
+	drives := m.selectableDrives()
+	if m.selectedDisk >= 0 && m.selectedDisk < len(drives) {
+		selectedDrive := drives[m.selectedDisk]
+		var dataPoints []float64
+		var times []time.Time
+		var notes []string
+		var updates [][]diskinfo.UpdateEvent
+		var timeLabels []string
+		var lastTime time.Time
+
+		// Collect points and time labels
+		for i, snapshot := range m.history.Snapshots {
+			for _, disk := range snapshot.Disks {
+				if disk.Drive == selectedDrive {
+					dataPoints = append(dataPoints, float64(disk.FreeSpace)/diskinfo.Gigabyte())
+					times = append(times, snapshot.Timestamp)
+					notes = append(notes, snapshot.Note)
+					updates = append(updates, snapshot.Updates)
+					// Add time label every N points or for first/last
+					if i == 0 || i == len(m.history.Snapshots)-1 ||
+						snapshot.Timestamp.Sub(lastTime) > 12*time.Hour {
+						timeLabels = append(timeLabels, locale.Short(snapshot.Timestamp))
+						lastTime = snapshot.Timestamp
+					} else {
+						timeLabels = append(timeLabels, "")
+					}
+					break
+				}
+			}
+		}
+
+		if len(dataPoints) > 0 && m.stacked {
+			s.WriteString(m.renderStackedChart(selectedDrive, height))
+		} else if len(dataPoints) > 0 {
+			// Caption with drive info
+			unit := diskinfo.GigabyteUnit()
+			caption := fmt.Sprintf("Drive %s: Current: %s %s",
+				selectedDrive, locale.Float(dataPoints[len(dataPoints)-1], 1), unit)
+			if m.offline(selectedDrive) {
+				caption = fmt.Sprintf("Drive %s (offline): Last seen %s: %s %s",
+					selectedDrive, locale.Short(times[len(times)-1]), locale.Float(dataPoints[len(dataPoints)-1], 1), unit)
+			}
+			if len(dataPoints) > 1 {
+				change := dataPoints[len(dataPoints)-1] - dataPoints[0]
+				caption += fmt.Sprintf(", Change: %s %s", locale.Signed(change, 1), unit)
+			}
+
+			// Graph options
+			opts := []asciigraph.Option{
+				asciigraph.Height(height),
+				asciigraph.Width(m.width - 10),
+				asciigraph.Caption(caption),
+			}
+
+			// Add timestamps below the graph
+			maxLen := 0
+			for _, label := range timeLabels {
+				if len(label) > maxLen {
+					maxLen = len(label)
+				}
+			}
+
+			// Draw graph
+			plotted := dataPoints
+			if smoothed, err := analysis.Smooth(dataPoints, times, m.smoothing); err == nil && m.smoothing != "" {
+				plotted = smoothed
+				opts = append(opts, asciigraph.Caption(caption+fmt.Sprintf(" (smoothed: %s)", m.smoothing)))
+			}
+
+			// More points than columns are thinned to one per column, keeping the
+			// peaks; pos maps each point to its plotted column for the markers
+			pos := make([]int, len(plotted))
+			for i := range pos {
+				pos[i] = i
+			}
+			if width := m.width - 10; width > 0 && len(plotted) > width {
+				keep := analysis.Downsample(times, plotted, width)
+				thinned := make([]float64, len(keep))
+				timeLabels = make([]string, len(keep))
+				// Roughly one label per 12 columns instead of every 12 hours
+				labelEvery := max(12*time.Hour, times[len(times)-1].Sub(times[0])/time.Duration(max(width/12, 1)))
+				lastTime = time.Time{}
+				for k, i := range keep {
+					thinned[k] = plotted[i]
+					if k == 0 || k == len(keep)-1 || times[i].Sub(lastTime) > labelEvery {
+						timeLabels[k] = locale.Short(times[i])
+						lastTime = times[i]
+					}
+				}
+				k := 0
+				for i := range pos {
+					for k+1 < len(keep) && keep[k+1] <= i {
+						k++
+					}
+					pos[i] = k
+				}
+				plotted = thinned
+			}
+
+			graph := asciigraph.Plot(plotted, opts...)
+			s.WriteString(graph)
+			s.WriteString("\n")
+
+			// Time axis
+			pointWidth := (m.width - 10) / len(timeLabels)
+			axis := []rune(strings.Repeat(" ", len(timeLabels)*pointWidth+maxLen))
+			free := 0
+			for i, label := range timeLabels {
+				// Labels that would overlap the previous one are skipped
+				if label != "" && i*pointWidth >= free {
+					copy(axis[i*pointWidth:], []rune(label))
+					free = i*pointWidth + len(label) + 1
+				}
+			}
+			s.WriteString(strings.TrimRight(string(axis), " "))
+			s.WriteString("\n")
+
+			// Anomaly markers below the time axis
+			anomalies := analysis.DetectAnomalies(m.history.Series(selectedDrive, time.Time{}), m.config.Anomaly)
+			if len(anomalies) > 0 {
+				markers := []rune(strings.Repeat(" ", len(timeLabels)*pointWidth+1))
+				for _, a := range anomalies {
+					if a.Index < len(pos) {
+						markers[pos[a.Index]*pointWidth] = '▲'
+					}
+				}
+				s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(string(markers)))
+				s.WriteString("\n")
+			}
+
+			// Note markers
+			hasNotes := false
+			noteMarkers := []rune(strings.Repeat(" ", len(timeLabels)*pointWidth+1))
+			for i, note := range notes {
+				if note != "" {
+					noteMarkers[pos[i]*pointWidth] = '◆'
+					hasNotes = true
+				}
+			}
+			if hasNotes {
+				s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Render(string(noteMarkers)))
+				s.WriteString("\n")
+			}
+
+			// Windows update markers, at the first snapshot after the install
+			hasUpdates := false
+			updateMarkers := []rune(strings.Repeat(" ", len(timeLabels)*pointWidth+1))
+			for i, u := range updates {
+				if len(u) > 0 {
+					updateMarkers[pos[i]*pointWidth] = '↻'
+					hasUpdates = true
+				}
+			}
+			if hasUpdates {
+				s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Render(string(updateMarkers)))
+				s.WriteString("\n")
+			}
+			s.WriteString("\n")
+
+			// Stats
+			var min, max, sum float64
+			min = dataPoints[0]
+			max = dataPoints[0]
+			for _, v := range dataPoints {
+				if v < min {
+					min = v
+				}
+				if v > max {
+					max = v
+				}
+				sum += v
+			}
+			avg := sum / float64(len(dataPoints))
+
+			s.WriteString(fmt.Sprintf("Stats for period:\n"))
+			s.WriteString(fmt.Sprintf("  Min: %s %s\n", locale.Float(min, 1), unit))
+			s.WriteString(fmt.Sprintf("  Max: %s %s\n", locale.Float(max, 1), unit))
+			s.WriteString(fmt.Sprintf("  Avg: %s %s\n", locale.Float(avg, 1), unit))
+			s.WriteString(fmt.Sprintf("  Range: %s %s\n", locale.Float(max-min, 1), unit))
+
+			if st, err := analysis.ComputeStats(m.history, selectedDrive, m.now()); err == nil && len(st.Growth) > 0 {
+				s.WriteString("  Growth:")
+				for _, g := range st.Growth {
+					s.WriteString(fmt.Sprintf(" %s %s", g.Window, analysis.FormatRate(g.BytesPerDay)))
+				}
+				s.WriteString("\n")
+			}
+
+			if f, err := analysis.ForecastDrive(m.history, selectedDrive, m.config.Forecast, m.now()); err == nil {
+				s.WriteString(fmt.Sprintf("  Full:  %s\n", analysis.FormatForecast(f, m.now())))
+			}
+
+			if hasNotes {
+				s.WriteString("\nNotes:\n")
+				for i, note := range notes {
+					if note != "" {
+						s.WriteString(fmt.Sprintf("  ◆ %s  %s\n", locale.Short(times[i]), note))
+					}
+				}
+			}
+
+			if hasUpdates {
+				s.WriteString("\nWindows updates:\n")
+				for i, u := range updates {
+					if len(u) == 0 {
+						continue
+					}
+					// The change is measured up to the snapshot after the install
+					change := ""
+					if i > 0 {
+						change = fmt.Sprintf("  (%s %s)", locale.Signed(dataPoints[i]-dataPoints[i-1], 1), unit)
+					}
+					for _, e := range u {
+						s.WriteString(fmt.Sprintf("  ↻ %s  %s%s\n", locale.Short(e.Time.In(m.loc)), e.Title, change))
+						change = ""
+					}
+				}
+			}
+
+			if len(anomalies) > 0 {
+				s.WriteString("\nAnomalies:\n")
+				for _, a := range anomalies {
+					s.WriteString(fmt.Sprintf("  ▲ %s  %s of %s %s\n",
+						locale.Short(a.Time), a.Kind(), locale.Signed(a.Change/diskinfo.Gigabyte(), 1), unit))
+				}
+			}
+		}
+	}
+
+	// Drive legend
+	s.WriteString("\nDrives: ")
+	for i, drive := range drives {
+		if i > 0 {
+			s.WriteString("  ")
+		}
+		style := lipgloss.NewStyle().Foreground(lineColors[i%len(lineColors)])
+		label := drive
+		if m.offline(drive) {
+			style = OfflineStyle
+			label += " (offline)"
+		}
+		if i == m.selectedDisk {
+			style = style.Bold(true).Underline(true)
+		}
+		s.WriteString(style.Render(label))
+	}
+
+	return s.String()
+}
+
+// renderStackedChart fills used space from the bottom and free space on top
+// of it up to the drive's total at each snapshot, so a volume that was
+// expanded shows as a step in the total rather than a jump in free space
+func (m Model) renderStackedChart(drive string, height int) string {
+	var s strings.Builder
+
+	var times []time.Time
+	var used, total []uint64
+	var maxTotal uint64
+	for _, snapshot := range m.history.Snapshots {
+		for _, disk := range snapshot.Disks {
+			if disk.Drive == drive {
+				times = append(times, snapshot.Timestamp)
+				used = append(used, disk.TotalSpace-min(disk.FreeSpace, disk.TotalSpace))
+				total = append(total, disk.TotalSpace)
+				maxTotal = max(maxTotal, disk.TotalSpace)
+				break
+			}
+		}
+	}
+	if len(times) == 0 || maxTotal == 0 {
+		s.WriteString("No data for this drive.\n")
+		return s.String()
+	}
+
+	// One column per snapshot, thinned or stretched to the width
+	const labelWidth = 10
+	width := max(m.width-labelWidth-2, 10)
+	unit := diskinfo.GigabyteUnit()
+	usedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	freeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	for row := height - 1; row >= 0; row-- {
+		label := ""
+		if row == height-1 || row%4 == 0 {
+			label = locale.Float(float64(maxTotal)*float64(row+1)/float64(height)/diskinfo.Gigabyte(), 0)
+		}
+		s.WriteString(fmt.Sprintf("%*s ┤", labelWidth-2, label))
+		for c := 0; c < width; c++ {
+			i := c * len(times) / width
+			usedRows := int(math.Round(float64(used[i]) / float64(maxTotal) * float64(height)))
+			totalRows := int(math.Round(float64(total[i]) / float64(maxTotal) * float64(height)))
+			switch {
+			case row < usedRows:
+				s.WriteString(usedStyle.Render("█"))
+			case row < totalRows:
+				s.WriteString(freeStyle.Render("█"))
+			default:
+				s.WriteString(" ")
+			}
+		}
+		s.WriteString("\n")
+	}
+	first, last := locale.Short(times[0]), locale.Short(times[len(times)-1])
+	s.WriteString(fmt.Sprintf("%*s └%s\n", labelWidth-2, "", strings.Repeat("─", width)))
+	s.WriteString(fmt.Sprintf("%*s  %s%*s\n", labelWidth-2, "", first, max(width-len(first), len(last)+1), last))
+
+	n := len(times) - 1
+	s.WriteString(fmt.Sprintf("\nDrive %s: %s of %s %s used (%s)  ", drive,
+		locale.Float(float64(used[n])/diskinfo.Gigabyte(), 1), locale.Float(float64(total[n])/diskinfo.Gigabyte(), 1), unit,
+		locale.Percent(float64(used[n])/float64(total[n])*100, 1)))
+	s.WriteString(usedStyle.Render("█"))
+	s.WriteString(" used  ")
+	s.WriteString(freeStyle.Render("█"))
+	s.WriteString(" free\n")
+
+	var resized bool
+	for i := 1; i < len(total); i++ {
+		if total[i] == total[i-1] {
+			continue
+		}
+		if !resized {
+			s.WriteString("\nResized:\n")
+			resized = true
+		}
+		s.WriteString(fmt.Sprintf("  %s  %s → %s\n", locale.Short(times[i]),
+			diskinfo.FormatBytes(total[i-1]), diskinfo.FormatBytes(total[i])))
+	}
+
+	return s.String()
+}
+
+// renderPatternsView shows average change by day of week and hour of day
+func (m Model) renderPatternsView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("Change patterns:"))
+	s.WriteString("\n\n")
+
+	drives := m.selectableDrives()
+	if len(m.history.Snapshots) == 0 || m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+		s.WriteString("No history yet.\n")
+		return s.String()
+	}
+	drive := drives[m.selectedDisk]
+	ps := analysis.AnalyzePatterns(m.history.Series(drive, time.Time{}))
+
+	s.WriteString(DiskNameStyle.Render("Drive " + drive))
+	s.WriteString("\n\nBy day of week:\n")
+	var weekdays []analysis.PatternBucket
+	var weekdayLabels []string
+	for i := 0; i < 7; i++ {
+		wd := time.Weekday((i + 1) % 7)
+		weekdays = append(weekdays, ps.Weekday[wd])
+		weekdayLabels = append(weekdayLabels, wd.String()[:3])
+	}
+	s.WriteString(renderPatternBars(weekdayLabels, weekdays))
+
+	if !ps.HasHours() {
+		s.WriteString("\nBy hour of day: " + analysis.HourGapNote + "\n")
+		return s.String()
+	}
+	s.WriteString("\nBy hour of day:\n")
+	var hours []analysis.PatternBucket
+	var hourLabels []string
+	for h, b := range ps.Hour {
+		hours = append(hours, b)
+		hourLabels = append(hourLabels, fmt.Sprintf("%02d:00", h))
+	}
+	s.WriteString(renderPatternBars(hourLabels, hours))
+
+	return s.String()
+}
+
+// renderChangesView shows a bar per day of how much free space the selected
+// drive lost or gained, so the days that consume space stand out and steady
+// growth can be told from bursts
+func (m Model) renderChangesView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("Daily change:"))
+	s.WriteString("\n\n")
+
+	drives := m.selectableDrives()
+	if m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+		s.WriteString("No history yet.\n")
+		return s.String()
+	}
+	drive := drives[m.selectedDisk]
+	days, changes := analysis.DailyChanges(m.history.Series(drive, time.Time{}))
+	s.WriteString(DiskNameStyle.Render("Drive " + drive))
+	s.WriteString("\n\n")
+	if len(changes) == 0 {
+		s.WriteString("Not enough history yet, the first bar shows after two days of snapshots.\n")
+		return s.String()
+	}
+
+	// The most recent days that fit, up to three columns each
+	const labelWidth = 12
+	cols := max(m.width-labelWidth-2, 10)
+	if len(changes) > cols {
+		days, changes = days[len(days)-cols:], changes[len(changes)-cols:]
+	}
+	barWidth := min(max(cols/len(changes), 1), 3)
+	half := max((m.height-18)/2, 3)
+
+	// Each side has its own scale, so a big cleanup doesn't flatten the daily consumption
+	var maxGained, maxUsed float64
+	for _, c := range changes {
+		maxGained, maxUsed = max(maxGained, c), max(maxUsed, -c)
+	}
+	gained := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	used := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+	// Gains above the zero line, consumption below it
+	for row := half; row >= -half; row-- {
+		label := ""
+		switch row {
+		case half:
+			label = diskinfo.FormatChange(maxGained)
+		case 0:
+			label = "0"
+		case -half:
+			label = diskinfo.FormatChange(-maxUsed)
+		}
+		s.WriteString(fmt.Sprintf("%*s ", labelWidth, label))
+		for _, c := range changes {
+			height := 0
+			if c > 0 {
+				height = int(math.Ceil(c / maxGained * float64(half)))
+			} else if c < 0 {
+				height = -int(math.Ceil(-c / maxUsed * float64(half)))
+			}
+			cell := strings.Repeat(" ", barWidth)
+			switch {
+			case row == 0:
+				cell = strings.Repeat("─", barWidth)
+			case row > 0 && height >= row:
+				cell = gained.Render(strings.Repeat("█", barWidth))
+			case row < 0 && height <= row:
+				cell = used.Render(strings.Repeat("█", barWidth))
+			}
+			s.WriteString(cell)
+		}
+		s.WriteString("\n")
+	}
+	first, last := locale.DayMonth(days[0]), locale.DayMonth(days[len(days)-1])
+	span := len(changes) * barWidth
+	s.WriteString(fmt.Sprintf("%*s %s%*s\n", labelWidth, "", first, max(span-len(first), len(last)+1), last))
+
+	// How bursty the consumption is: the median day against the worst one
+	var consumed []float64
+	worst := 0
+	for i, c := range changes {
+		if c < 0 {
+			consumed = append(consumed, -c)
+		}
+		if c < changes[worst] {
+			worst = i
+		}
+	}
+	s.WriteString(fmt.Sprintf("\n%d of %d days used space", len(consumed), len(changes)))
+	if len(consumed) > 0 {
+		slices.Sort(consumed)
+		s.WriteString(fmt.Sprintf(", median %s, most %s on %s", diskinfo.FormatBytes(uint64(consumed[len(consumed)/2])),
+			diskinfo.FormatBytes(uint64(-changes[worst])), locale.Date(days[worst])))
+	}
+	s.WriteString("\n")
+
+	return s.String()
+}
+
+// calendarColors are the cells of the calendar by how much space the day used,
+// from a little to the most of any day shown
+var calendarColors = []lipgloss.Color{"52", "88", "124", "196"}
+
+// renderCalendarView shows the selected drive's daily change as a calendar,
+// one row per weekday and one column per week, so weekly cycles and unusual
+// days stand out
+func (m Model) renderCalendarView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("Calendar:"))
+	s.WriteString("\n\n")
+
+	drives := m.selectableDrives()
+	if m.selectedDisk < 0 || m.selectedDisk >= len(drives) {
+		s.WriteString("No history yet.\n")
+		return s.String()
+	}
+	drive := drives[m.selectedDisk]
+	days, changes := analysis.DailyChanges(m.history.Series(drive, time.Time{}))
+	s.WriteString(DiskNameStyle.Render("Drive " + drive))
+	s.WriteString("\n\n")
+	if len(changes) == 0 {
+		s.WriteString("Not enough history yet, the first day shows after two days of snapshots.\n")
+		return s.String()
+	}
+
+	byDay := make(map[string]float64, len(changes))
+	var maxUsed float64
+	for i, day := range days {
+		byDay[day.Format("2006-01-02")] = changes[i]
+		maxUsed = max(maxUsed, -changes[i])
+	}
+
+	// As many weeks as fit, ending with the week of the last day
+	const cellWidth = 2
+	last := days[len(days)-1]
+	first := days[0]
+	weeks := int(last.Sub(first).Hours()/24/7) + 2
+	weeks = min(weeks, max((m.width-6)/cellWidth, 4))
+	lastMonday := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, last.Location())
+	lastMonday = lastMonday.AddDate(0, 0, -(int(lastMonday.Weekday())+6)%7)
+	start := lastMonday.AddDate(0, 0, -7*(weeks-1))
+
+	// Month names over the first week of each month
+	labels := []rune(strings.Repeat(" ", weeks*cellWidth))
+	for w := 0; w < weeks; w++ {
+		monday := start.AddDate(0, 0, 7*w)
+		name := []rune(monday.Month().String()[:3])
+		if monday.Day() <= 7 && w*cellWidth+len(name) <= len(labels) {
+			copy(labels[w*cellWidth:], name)
+		}
+	}
+	s.WriteString("     " + string(labels) + "\n")
+
+	freed := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	empty := lipgloss.NewStyle().Foreground(lipgloss.Color("237"))
+	for d := 0; d < 7; d++ {
+		s.WriteString(fmt.Sprintf("%-4s ", time.Weekday((d + 1) % 7).String()[:3]))
+		for w := 0; w < weeks; w++ {
+			day := start.AddDate(0, 0, 7*w+d)
+			change, ok := byDay[day.Format("2006-01-02")]
+			switch {
+			case !ok:
+				s.WriteString(empty.Render("· "))
+			case change >= 0:
+				s.WriteString(freed.Render("■ "))
+			default:
+				level := 0
+				if maxUsed > 0 {
+					level = min(int(-change/maxUsed*float64(len(calendarColors))), len(calendarColors)-1)
+				}
+				s.WriteString(lipgloss.NewStyle().Foreground(calendarColors[level]).Render("■ "))
+			}
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\nUsed: less ")
+	for _, c := range calendarColors {
+		s.WriteString(lipgloss.NewStyle().Foreground(c).Render("■ "))
+	}
+	s.WriteString(fmt.Sprintf("more (%s)  ", diskinfo.FormatBytes(uint64(maxUsed))))
+	s.WriteString(freed.Render("■"))
+	s.WriteString(" freed  ")
+	s.WriteString(empty.Render("·"))
+	s.WriteString(" no data\n")
+
+	return s.String()
+}
+
+// renderPatternBars draws one horizontal bar per bucket, red for consumption and green for freed space
+func renderPatternBars(labels []string, buckets []analysis.PatternBucket) string {
+	var s strings.Builder
+	const barWidth = 30
+
+	var maxAbs float64
+	for _, b := range buckets {
+		if v := b.Average(); v > maxAbs {
+			maxAbs = v
+		} else if -v > maxAbs {
+			maxAbs = -v
+		}
+	}
+
+	for i, b := range buckets {
+		if b.Count == 0 {
+			continue
+		}
+		avg := b.Average()
+		width := 0
+		if maxAbs > 0 {
+			width = int(avg / maxAbs * barWidth)
+		}
+
+		color := lipgloss.Color("10")
+		if width < 0 {
+			width = -width
+			color = lipgloss.Color("9")
+		}
+
+		s.WriteString(fmt.Sprintf("  %-6s %12s ", labels[i], diskinfo.FormatChange(avg)))
+		s.WriteString(lipgloss.NewStyle().Foreground(color).Render(strings.Repeat("█", width)))
+		s.WriteString("\n")
+	}
+
+	return s.String()
+}
+
+// healthLine summarizes one health reading on a line
+func healthLine(h diskinfo.Health) string {
+	line := fmt.Sprintf("%s  Used: %s  Spare: %s (threshold %s)  Media errors: %d",
+		h.Label(), locale.Percent(float64(h.PercentageUsed), 0), locale.Percent(float64(h.AvailableSpare), 0),
+		locale.Percent(float64(h.SpareThreshold), 0), h.MediaErrors)
+	if h.DataWritten > 0 {
+		line += "  Written: " + diskinfo.FormatBytes(h.DataWritten)
+	}
+	return line
+}
+
+// renderHealthView shows the NVMe health of each disk and charts the selected
+// one's wear and spare capacity against the limits, and its writes per day
+func (m Model) renderHealthView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("NVMe health:"))
+	s.WriteString("\n\n")
+
+	names := m.history.HealthDisks()
+	if len(names) == 0 {
+		s.WriteString("No health readings yet, only NVMe disks report them.\n")
+		return s.String()
+	}
+	selected := min(m.selectedHealth, len(names)-1)
+
+	for i, name := range names {
+		points := m.history.HealthSeries(name)
+		last := points[len(points)-1].Health
+		line := healthLine(last)
+		if i == selected {
+			s.WriteString(SelectedStyle.Render(line))
+		} else {
+			s.WriteString(line)
+		}
+		s.WriteString("\n")
+		for _, problem := range m.config.HealthLimits.Problems(last) {
+			s.WriteString(ProblemStyle.Render("  " + problem))
+			s.WriteString("\n")
+		}
+	}
+	s.WriteString("\n")
+
+	points := m.history.HealthSeries(names[selected])
+	if len(points) < 2 {
+		s.WriteString("Not enough readings for a graph yet.\n")
+		return s.String()
+	}
+
+	// Wear and spare share the percent scale, the limits are drawn as flat lines
+	last := points[len(points)-1].Health
+	used := make([]float64, len(points))
+	spare := make([]float64, len(points))
+	for i, p := range points {
+		used[i] = float64(p.PercentageUsed)
+		spare[i] = float64(p.AvailableSpare)
+	}
+	series := [][]float64{used, spare}
+	colors := []asciigraph.AnsiColor{asciigraph.Red, asciigraph.Green}
+	legends := []string{"Used", "Spare"}
+	if limit := m.config.HealthLimits.PercentageUsed; limit > 0 {
+		series = append(series, flatLine(len(points), float64(limit)))
+		colors = append(colors, asciigraph.DarkOrange)
+		legends = append(legends, "Used limit")
+	}
+	if limit := m.config.HealthLimits.SpareLimit(last); limit > 0 {
+		series = append(series, flatLine(len(points), float64(limit)))
+		colors = append(colors, asciigraph.Gray)
+		legends = append(legends, "Spare limit")
+	}
+
+	// The write rate chart takes half the room once there are two days of readings
+	height := max(m.height-20-2*len(names), 8)
+	days, writes := analysis.DailyWrites(points)
+	if len(writes) >= 2 {
+		height = max(height/2, 5)
+	}
+	s.WriteString(asciigraph.PlotMany(series,
+		asciigraph.Height(height),
+		asciigraph.Width(m.width-10),
+		asciigraph.LowerBound(0),
+		asciigraph.UpperBound(100),
+		asciigraph.SeriesColors(colors...),
+		asciigraph.SeriesLegends(legends...),
+		asciigraph.Caption(fmt.Sprintf("%s, %s to %s", last.Label(),
+			locale.Date(points[0].Time), locale.Date(points[len(points)-1].Time))),
+	))
+	s.WriteString("\n")
+
+	if len(writes) >= 2 {
+		perDay := make([]float64, len(writes))
+		for i, w := range writes {
+			perDay[i] = w / diskinfo.Gigabyte()
+		}
+		caption := fmt.Sprintf("Written per day (%s), %s to %s", diskinfo.GigabyteUnit(),
+			locale.DayMonth(days[0]), locale.DayMonth(days[len(days)-1]))
+		if rates := analysis.WriteRates(points, m.now()); len(rates) > 0 {
+			rate := rates[len(rates)-1]
+			caption += fmt.Sprintf(", %s average %s", rate.Window, analysis.FormatWriteRate(rate.BytesPerDay))
+			if left, ok := analysis.EnduranceDaysLeft(last, rate.BytesPerDay); ok {
+				caption += ", endurance " + analysis.FormatEndurance(left)
+			}
+		}
+		s.WriteString("\n")
+		s.WriteString(asciigraph.Plot(perDay,
+			asciigraph.Height(height),
+			asciigraph.Width(m.width-10),
+			asciigraph.LowerBound(0),
+			asciigraph.Caption(caption),
+		))
+		s.WriteString("\n")
+	}
+
+	return s.String()
+}
+
+// flatLine returns a series of n equal values
+func flatLine(n int, value float64) []float64 {
+	line := make([]float64, n)
+	for i := range line {
+		line[i] = value
+	}
+	return line
+}
+
+// renderTopologyView shows the physical disks with the drives on them, so
+// drives sharing a disk with health problems stand out
+func (m Model) renderTopologyView() string {
+	var s strings.Builder
+
+	s.WriteString(HeaderStyle.Render("Physical disks:"))
+	s.WriteString("\n\n")
+
+	if !m.isLocal() {
+		s.WriteString("The physical disks are only known for this machine.\n")
+		return s.String()
+	}
+	if len(m.topology) == 0 {
+		s.WriteString("No physical disks found.\n")
+		return s.String()
+	}
+
+	var selected string
+	if drives := m.selectableDrives(); m.selectedDisk < len(drives) {
+		selected = drives[m.selectedDisk]
+	}
+	health := make(map[int]diskinfo.Health)
+	for _, h := range m.health {
+		health[h.Disk] = h
+	}
+
+	const barWidth = 50
+	for _, l := range m.topology {
+		header := DiskNameStyle.Render(l.Label())
+		if l.Bus != "" || l.Size > 0 {
+			header += fmt.Sprintf("  %s %s", l.Bus, diskinfo.FormatBytes(l.Size))
+		}
+		s.WriteString(header)
+		s.WriteString("\n")
+
+		// One colored segment per drive in disk order, the rest has no drive letter or is unallocated
+		var bar strings.Builder
+		used := 0
+		for i, v := range l.Volumes {
+			width := 0
+			if l.Size > 0 {
+				width = min(int(float64(v.Length)/float64(l.Size)*barWidth), barWidth-used)
+			}
+			bar.WriteString(lipgloss.NewStyle().Foreground(lineColors[i%len(lineColors)]).Render(strings.Repeat("█", width)))
+			used += width
+		}
+		bar.WriteString(strings.Repeat("░", barWidth-used))
+		s.WriteString("  ")
+		s.WriteString(bar.String())
+		s.WriteString("\n")
+
+		for i, v := range l.Volumes {
+			line := fmt.Sprintf("%s %-6s %s", lipgloss.NewStyle().Foreground(lineColors[i%len(lineColors)]).Render("■"),
+				v.Drive, diskinfo.FormatBytes(v.Length))
+			s.WriteString("  ")
+			if v.Drive == selected {
+				s.WriteString(SelectedStyle.Render(line))
+			} else {
+				s.WriteString(line)
+			}
+			s.WriteString("\n")
+		}
+		if len(l.Volumes) == 0 {
+			s.WriteString(HelpStyle.Render("  No drive letters"))
+			s.WriteString("\n")
+		}
+		if h, ok := health[l.Number]; ok {
+			for _, problem := range m.config.HealthLimits.Problems(h) {
+				s.WriteString(ProblemStyle.Render("  " + problem))
+				s.WriteString("\n")
+			}
+		}
+		s.WriteString("\n")
+	}
+
+	return s.String()
+}
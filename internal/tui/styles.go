@@ -0,0 +1,57 @@
+package tui
+
+import "github.com/charmbracelet/lipgloss"
+
+// UI styles, shared with the other interactive views of the command
+var (
+	TitleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86")).
+			MarginBottom(1)
+
+	HeaderStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("170"))
+
+	DiskNameStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86"))
+
+	HelpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+
+	SelectedStyle = lipgloss.NewStyle().
+			Background(lipgloss.Color("237")).
+			Bold(true)
+
+	UnavailableStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("208"))
+
+	WarningStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color("214")).
+			Padding(0, 1)
+
+	OfflineStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("245")).
+			Italic(true)
+
+	QuotaStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("215"))
+
+	ProblemStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9"))
+
+	// Colors for graph lines
+	lineColors = []lipgloss.Color{
+		lipgloss.Color("9"),   // Red
+		lipgloss.Color("10"),  // Green
+		lipgloss.Color("11"),  // Yellow
+		lipgloss.Color("12"),  // Blue
+		lipgloss.Color("13"),  // Magenta
+		lipgloss.Color("14"),  // Cyan
+		lipgloss.Color("202"), // Orange
+		lipgloss.Color("199"), // Pink
+	}
+)
@@ -0,0 +1,636 @@
+// Package analysis forecasts, summarizes and looks for patterns in drive history.
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// Forecast models
+const (
+	ModelLinear = "linear"
+	ModelExp    = "exp"
+)
+
+// Forecast holds a days-until-full estimate for one drive
+type Forecast struct {
+	Drive     string
+	Model     string
+	Samples   int
+	Span      time.Duration
+	FreeSpace uint64
+	// Rate is the fitted change of free space in bytes per day
+	Rate float64
+	// Filling is false when the trend never reaches the reserve
+	Filling           bool
+	EstimatedFullDate time.Time
+	// EarliestFullDate and LatestFullDate bound the estimate at 95% confidence.
+	// LatestFullDate is zero when the slow end of the interval never fills up.
+	EarliestFullDate time.Time
+	LatestFullDate   time.Time
+}
+
+// DaysUntilFull returns the estimated number of days left from now
+func (f *Forecast) DaysUntilFull(now time.Time) float64 {
+	if !f.Filling {
+		return math.Inf(1)
+	}
+	days := f.EstimatedFullDate.Sub(now).Hours() / 24
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// discountSavings returns the points with changes of deduplication savings taken
+// out of their free space, measured against the last point. Deduplication frees
+// space in jumps as it optimizes files, which isn't the data shrinking.
+func discountSavings(points []history.Point) []history.Point {
+	if len(points) == 0 {
+		return points
+	}
+	last := points[len(points)-1].Savings
+	if !slices.ContainsFunc(points, func(p history.Point) bool { return p.Savings != last }) {
+		return points
+	}
+
+	result := make([]history.Point, len(points))
+	for i, p := range points {
+		free := float64(p.Free) + float64(last) - float64(p.Savings)
+		p.Free = uint64(math.Max(free, 0))
+		result[i] = p
+	}
+	return result
+}
+
+// linearFit fits y = a + b*x by least squares and returns the standard error of b
+func linearFit(xs, ys []float64) (a, b, seB float64) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		sxx += dx * dx
+		sxy += dx * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return meanY, 0, 0
+	}
+
+	b = sxy / sxx
+	a = meanY - b*meanX
+
+	if len(xs) > 2 {
+		var sse float64
+		for i := range xs {
+			r := ys[i] - (a + b*xs[i])
+			sse += r * r
+		}
+		seB = math.Sqrt(sse / (n - 2) / sxx)
+	}
+
+	return a, b, seB
+}
+
+// tCritical95 returns the two-sided 95% Student's t value for the given degrees of freedom
+func tCritical95(dof int) float64 {
+	table := []float64{
+		12.71, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	if dof < 1 {
+		return 0
+	}
+	if dof <= len(table) {
+		return table[dof-1]
+	}
+	return 1.96
+}
+
+// ForecastDrive estimates when a drive will fill up based on recent history
+func ForecastDrive(h *history.History, drive string, cfg ForecastConfig, now time.Time) (*Forecast, error) {
+	window, err := ParseDuration(cfg.Window)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forecast window: %v", err)
+	}
+	reserve, err := diskinfo.ParseSize(cfg.Reserve)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forecast reserve: %v", err)
+	}
+
+	points := discountSavings(h.Series(drive, now.Add(-window)))
+	if len(points) < 2 {
+		return nil, fmt.Errorf("not enough data for %s in the last %s", drive, cfg.Window)
+	}
+
+	last := points[len(points)-1]
+	f := &Forecast{
+		Drive:     drive,
+		Model:     cfg.Model,
+		Samples:   len(points),
+		Span:      last.Time.Sub(points[0].Time),
+		FreeSpace: last.Free,
+	}
+
+	// x is days since the first sample, y is free space (or its log)
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = p.Time.Sub(points[0].Time).Hours() / 24
+		ys[i] = float64(p.Free)
+	}
+
+	target := float64(reserve)
+	switch cfg.Model {
+	case ModelLinear:
+	case ModelExp:
+		// Exponential decay never reaches zero, so full means
+		// the reserve or 1% of capacity, whichever is larger
+		if floor := float64(last.Total) / 100; target < floor {
+			target = floor
+		}
+		for i := range ys {
+			ys[i] = math.Log(math.Max(ys[i], 1))
+		}
+		target = math.Log(math.Max(target, 1))
+	default:
+		return nil, fmt.Errorf("unknown forecast model %q", cfg.Model)
+	}
+
+	a, b, seB := linearFit(xs, ys)
+	xLast := xs[len(xs)-1]
+	yLast := a + b*xLast
+
+	if cfg.Model == ModelExp {
+		f.Rate = float64(last.Free) * (math.Exp(b) - 1)
+	} else {
+		f.Rate = b
+	}
+
+	// fullAt extrapolates from the fitted current value using the given slope
+	fullAt := func(slope float64) (time.Time, bool) {
+		if slope >= 0 {
+			return time.Time{}, false
+		}
+		days := (target - yLast) / slope
+		if days < 0 {
+			days = 0
+		}
+		return last.Time.Add(time.Duration(days * 24 * float64(time.Hour))), true
+	}
+
+	f.EstimatedFullDate, f.Filling = fullAt(b)
+	if !f.Filling {
+		return f, nil
+	}
+
+	margin := tCritical95(len(points)-2) * seB
+	f.EarliestFullDate, _ = fullAt(b - margin)
+	f.LatestFullDate, _ = fullAt(b + margin)
+
+	return f, nil
+}
+
+// GrowthWindows are the periods growth rates are reported for
+var GrowthWindows = []string{"7d", "30d", "90d"}
+
+// LookBack returns how much recent history ForecastDrive and the growth rates of
+// ComputeStats use, so callers can load just that window
+func LookBack(cfg ForecastConfig) (time.Duration, error) {
+	window, err := ParseDuration(cfg.Window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid forecast window: %v", err)
+	}
+	for _, w := range GrowthWindows {
+		d, _ := ParseDuration(w)
+		window = max(window, d)
+	}
+	return window, nil
+}
+
+// GrowthRate is the fitted change of used space over a window
+type GrowthRate struct {
+	Window string
+	// BytesPerDay is positive when the drive is filling up
+	BytesPerDay float64
+	Samples     int
+}
+
+// DriveStats summarizes the history of one drive
+type DriveStats struct {
+	Drive   string
+	Samples int
+	Current history.Point
+	MinFree uint64
+	MaxFree uint64
+	AvgFree float64
+	Growth  []GrowthRate
+	// FreePercentiles is the distribution of free space over all samples
+	FreePercentiles Percentiles
+	// DailyChangePercentiles is the distribution of day-over-day free space change
+	DailyChangePercentiles Percentiles
+	Days                   int
+	// FilesPerDay is the trend of the file records in use over the last 30
+	// days, for drives that record them
+	FilesPerDay float64
+}
+
+// Percentiles holds the 5th, 50th and 95th percentile of a distribution
+type Percentiles struct {
+	P5  float64
+	P50 float64
+	P95 float64
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values using linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// computePercentiles returns p5/p50/p95 of values
+func computePercentiles(values []float64) Percentiles {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return Percentiles{
+		P5:  percentile(sorted, 5),
+		P50: percentile(sorted, 50),
+		P95: percentile(sorted, 95),
+	}
+}
+
+// dailyChanges returns the change of free space between the last samples of consecutive days
+func dailyChanges(points []history.Point) []float64 {
+	_, changes := DailyChanges(points)
+	return changes
+}
+
+// DailyChanges returns the change of free space on each day, from the last
+// samples of consecutive days with samples, dated by the later day
+func DailyChanges(points []history.Point) (days []time.Time, changes []float64) {
+	// Last sample of each day, in order
+	var dayEnds []history.Point
+	var prevDay string
+	for _, p := range points {
+		day := p.Time.Format("2006-01-02")
+		if day != prevDay {
+			dayEnds = append(dayEnds, p)
+			prevDay = day
+		}
+		dayEnds[len(dayEnds)-1] = p
+	}
+
+	for i := 1; i < len(dayEnds); i++ {
+		days = append(days, dayEnds[i].Time)
+		changes = append(changes, float64(dayEnds[i].Free)-float64(dayEnds[i-1].Free))
+	}
+	return days, changes
+}
+
+// FitRate fits used space over the window and returns bytes per day, without
+// the space deduplication freed on the way
+func FitRate(points []history.Point) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+	points = discountSavings(points)
+
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = p.Time.Sub(points[0].Time).Hours() / 24
+		ys[i] = float64(p.Total) - float64(p.Free)
+	}
+
+	_, b, _ := linearFit(xs, ys)
+	return b, true
+}
+
+// ComputeStats builds the statistics for a drive over its full history
+func ComputeStats(h *history.History, drive string, now time.Time) (*DriveStats, error) {
+	points := h.Series(drive, time.Time{})
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no data for %s", drive)
+	}
+
+	st := &DriveStats{
+		Drive:   drive,
+		Samples: len(points),
+		Current: points[len(points)-1],
+		MinFree: points[0].Free,
+		MaxFree: points[0].Free,
+	}
+
+	var sum float64
+	for _, p := range points {
+		if p.Free < st.MinFree {
+			st.MinFree = p.Free
+		}
+		if p.Free > st.MaxFree {
+			st.MaxFree = p.Free
+		}
+		sum += float64(p.Free)
+	}
+	st.AvgFree = sum / float64(len(points))
+
+	free := make([]float64, len(points))
+	for i, p := range points {
+		free[i] = float64(p.Free)
+	}
+	st.FreePercentiles = computePercentiles(free)
+
+	changes := dailyChanges(discountSavings(points))
+	st.Days = len(changes)
+	st.DailyChangePercentiles = computePercentiles(changes)
+
+	var days, files []float64
+	for _, p := range h.Series(drive, now.AddDate(0, 0, -30)) {
+		if p.MaxFiles > 0 {
+			days = append(days, p.Time.Sub(now).Hours()/24)
+			files = append(files, float64(p.Files))
+		}
+	}
+	if len(files) >= 2 {
+		_, st.FilesPerDay, _ = linearFit(days, files)
+	}
+
+	for _, w := range GrowthWindows {
+		d, _ := ParseDuration(w)
+		windowPoints := h.Series(drive, now.Add(-d))
+		if rate, ok := FitRate(windowPoints); ok {
+			st.Growth = append(st.Growth, GrowthRate{
+				Window:      w,
+				BytesPerDay: rate,
+				Samples:     len(windowPoints),
+			})
+		}
+	}
+
+	return st, nil
+}
+
+// Anomaly is an abnormal change of free space between two samples
+type Anomaly struct {
+	Index int
+	Time  time.Time
+	// Change is the free space delta in bytes, negative for a sudden drop
+	Change float64
+	// Typical is the baseline change the delta was compared against
+	Typical float64
+}
+
+// Kind describes the direction of the anomaly
+func (a Anomaly) Kind() string {
+	if a.Change < 0 {
+		return "sudden drop"
+	}
+	return "unexpected free"
+}
+
+// median returns the median of values, sorting a copy
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// DetectAnomalies flags changes that deviate strongly from a rolling baseline.
+// The baseline is the median and median absolute deviation of the preceding deltas.
+func DetectAnomalies(points []history.Point, cfg AnomalyConfig) []Anomaly {
+	minChange, err := diskinfo.ParseSize(cfg.MinChange)
+	if err != nil {
+		return nil
+	}
+	window := cfg.Window
+	if window < 5 {
+		window = 5
+	}
+
+	// A jump deduplication explains is not an anomaly
+	points = discountSavings(points)
+	deltas := make([]float64, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		deltas = append(deltas, float64(points[i].Free)-float64(points[i-1].Free))
+	}
+
+	var anomalies []Anomaly
+	for i, delta := range deltas {
+		start := i - window
+		if start < 0 {
+			start = 0
+		}
+		// Need some history before judging
+		if i-start < 5 {
+			continue
+		}
+
+		baseline := deltas[start:i]
+		med := median(baseline)
+		deviations := make([]float64, len(baseline))
+		for j, d := range baseline {
+			deviations[j] = math.Abs(d - med)
+		}
+		scale := 1.4826 * median(deviations)
+
+		threshold := math.Max(cfg.Sensitivity*scale, float64(minChange))
+		if math.Abs(delta-med) > threshold {
+			anomalies = append(anomalies, Anomaly{
+				Index:   i + 1,
+				Time:    points[i+1].Time,
+				Change:  delta,
+				Typical: med,
+			})
+		}
+	}
+
+	return anomalies
+}
+
+// Downsample picks n of the points with largest-triangle-three-buckets: the first
+// and last point are kept, and from each bucket in between the point that best
+// preserves the shape, so peaks and drops survive. It returns the indexes of the
+// kept points in order, or every index when there are no more than n points.
+func Downsample(times []time.Time, values []float64, n int) []int {
+	n = max(n, 3)
+	if len(values) <= n {
+		keep := make([]int, len(values))
+		for i := range keep {
+			keep[i] = i
+		}
+		return keep
+	}
+
+	// x is seconds since the first point, so uneven sampling keeps its shape
+	x := func(i int) float64 { return times[i].Sub(times[0]).Seconds() }
+	every := float64(len(values)-2) / float64(n-2)
+
+	keep := make([]int, 0, n)
+	keep = append(keep, 0)
+	a := 0
+	for b := 0; b < n-2; b++ {
+		// Average of the next bucket, the third corner of the triangles
+		next, end := int(float64(b+1)*every)+1, min(int(float64(b+2)*every)+1, len(values))
+		var avgX, avgY float64
+		for j := next; j < end; j++ {
+			avgX += x(j)
+			avgY += values[j]
+		}
+		avgX /= float64(end - next)
+		avgY /= float64(end - next)
+
+		best, bestArea := -1, -1.0
+		for j := int(float64(b)*every) + 1; j < next; j++ {
+			area := math.Abs((x(a)-avgX)*(values[j]-values[a]) - (x(a)-x(j))*(avgY-values[a]))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		keep = append(keep, best)
+		a = best
+	}
+	return append(keep, len(values)-1)
+}
+
+// Smooth applies a trailing moving average to values.
+// The setting is either a point count ("5") or a time window ("6h", "1d").
+func Smooth(values []float64, times []time.Time, setting string) ([]float64, error) {
+	if setting == "" || len(values) == 0 {
+		return values, nil
+	}
+
+	smoothed := make([]float64, len(values))
+
+	if n, err := strconv.Atoi(setting); err == nil {
+		if n < 1 {
+			return nil, fmt.Errorf("invalid smoothing %q", setting)
+		}
+		var sum float64
+		for i, v := range values {
+			sum += v
+			if i >= n {
+				sum -= values[i-n]
+			}
+			count := n
+			if i+1 < n {
+				count = i + 1
+			}
+			smoothed[i] = sum / float64(count)
+		}
+		return smoothed, nil
+	}
+
+	window, err := ParseDuration(setting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smoothing %q", setting)
+	}
+
+	start := 0
+	var sum float64
+	for i, v := range values {
+		sum += v
+		for times[i].Sub(times[start]) > window {
+			sum -= values[start]
+			start++
+		}
+		smoothed[i] = sum / float64(i-start+1)
+	}
+
+	return smoothed, nil
+}
+
+// PatternBucket accumulates free space changes falling into one weekday or hour
+type PatternBucket struct {
+	Total float64
+	// Count is the number of distinct days (or day-hours) that contributed
+	Count int
+}
+
+// Average returns the mean change per occurrence of the bucket
+func (b PatternBucket) Average() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return b.Total / float64(b.Count)
+}
+
+// PatternStats holds free space change aggregated by day of week and hour of day
+type PatternStats struct {
+	Weekday [7]PatternBucket
+	Hour    [24]PatternBucket
+}
+
+// HourGapNote explains empty hour buckets, which are left when the snapshots
+// are further apart than MaxHourGap, e.g. with the default sampling
+const HourGapNote = "needs snapshots at most 2h apart"
+
+// MaxHourGap is the longest gap between samples whose change is attributed to
+// an hour of day
+const MaxHourGap = 2 * time.Hour
+
+// HasHours reports whether any change could be pinned to an hour of day
+func (ps *PatternStats) HasHours() bool {
+	for _, b := range ps.Hour {
+		if b.Count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzePatterns attributes each change between samples to the weekday and hour it ended in.
+// Changes spanning long gaps are skipped, since they can't be pinned to a time slot.
+func AnalyzePatterns(points []history.Point) *PatternStats {
+	ps := &PatternStats{}
+	seenDays := make(map[string]bool)
+	seenHours := make(map[string]bool)
+
+	points = discountSavings(points)
+	for i := 1; i < len(points); i++ {
+		p := points[i]
+		gap := p.Time.Sub(points[i-1].Time)
+		delta := float64(p.Free) - float64(points[i-1].Free)
+
+		if gap <= 24*time.Hour {
+			wd := p.Time.Weekday()
+			ps.Weekday[wd].Total += delta
+			if day := p.Time.Format("2006-01-02"); !seenDays[day] {
+				seenDays[day] = true
+				ps.Weekday[wd].Count++
+			}
+		}
+
+		if gap <= MaxHourGap {
+			h := p.Time.Hour()
+			ps.Hour[h].Total += delta
+			if hour := p.Time.Format("2006-01-02 15"); !seenHours[hour] {
+				seenHours[hour] = true
+				ps.Hour[h].Count++
+			}
+		}
+	}
+
+	return ps
+}
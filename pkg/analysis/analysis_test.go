@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+var start = time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+
+// daily returns a history of drive C:\ with one snapshot a day of each free value
+func daily(total uint64, free ...uint64) *history.History {
+	h := &history.History{}
+	for i, f := range free {
+		h.Snapshots = append(h.Snapshots, history.Snapshot{
+			Timestamp: start.AddDate(0, 0, i),
+			Disks:     []diskinfo.DiskInfo{{Drive: `C:\`, TotalSpace: total, FreeSpace: f, UsedSpace: total - f}},
+		})
+	}
+	return h
+}
+
+func TestForecastDrive(t *testing.T) {
+	linear := ForecastConfig{Window: "30d", Model: ModelLinear, Reserve: "0"}
+
+	// Deduplication frees 50 on day 5, which isn't the data shrinking
+	deduped := daily(1000, 100, 90, 80, 70, 60, 100, 90, 80)
+	for i := 5; i < len(deduped.Snapshots); i++ {
+		deduped.Snapshots[i].Disks[0].Savings = 50
+	}
+
+	tests := []struct {
+		name     string
+		h        *history.History
+		cfg      ForecastConfig
+		wantErr  bool
+		filling  bool
+		rate     float64
+		fullDays float64
+	}{
+		{name: "steady fill", h: daily(1000, 100, 90, 80, 70, 60, 50, 40, 30, 20, 10), cfg: linear,
+			filling: true, rate: -10, fullDays: 1},
+		{name: "reserve", h: daily(1000, 100, 90, 80, 70, 60, 50, 40, 30, 20, 10),
+			cfg:     ForecastConfig{Window: "30d", Model: ModelLinear, Reserve: "30"},
+			filling: true, rate: -10, fullDays: 0},
+		{name: "freeing up", h: daily(1000, 10, 20, 30), cfg: linear, filling: false, rate: 10},
+		{name: "deduplication", h: deduped, cfg: linear, filling: true, rate: -10, fullDays: 8},
+		{name: "halving", h: daily(1<<20, 1<<16, 1<<15, 1<<14, 1<<13),
+			cfg:     ForecastConfig{Window: "30d", Model: ModelExp, Reserve: "0"},
+			filling: true, rate: -(1 << 12)},
+		{name: "one sample", h: daily(1000, 100), cfg: linear, wantErr: true},
+		{name: "outside the window", h: daily(1000, 100, 90, 80),
+			cfg: ForecastConfig{Window: "12h", Model: ModelLinear, Reserve: "0"}, wantErr: true},
+		{name: "invalid window", h: daily(1000, 100, 90), cfg: ForecastConfig{Window: "soon", Model: ModelLinear, Reserve: "0"}, wantErr: true},
+		{name: "invalid reserve", h: daily(1000, 100, 90), cfg: ForecastConfig{Window: "30d", Model: ModelLinear, Reserve: "lots"}, wantErr: true},
+		{name: "unknown model", h: daily(1000, 100, 90), cfg: ForecastConfig{Window: "30d", Model: "cubic", Reserve: "0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := tt.h.Snapshots[len(tt.h.Snapshots)-1].Timestamp
+			f, err := ForecastDrive(tt.h, `C:\`, tt.cfg, last)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ForecastDrive = %+v, want an error", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Filling != tt.filling {
+				t.Fatalf("Filling = %v, want %v", f.Filling, tt.filling)
+			}
+			if math.Abs(f.Rate-tt.rate) > 1e-6*math.Abs(tt.rate) {
+				t.Errorf("Rate = %v, want %v", f.Rate, tt.rate)
+			}
+			if !f.Filling {
+				if !math.IsInf(f.DaysUntilFull(last), 1) {
+					t.Errorf("DaysUntilFull = %v, want +Inf", f.DaysUntilFull(last))
+				}
+				return
+			}
+			if tt.cfg.Model == ModelLinear {
+				if got := f.DaysUntilFull(last); math.Abs(got-tt.fullDays) > 1e-6 {
+					t.Errorf("DaysUntilFull = %v, want %v", got, tt.fullDays)
+				}
+			}
+			if f.EarliestFullDate.After(f.EstimatedFullDate) ||
+				(!f.LatestFullDate.IsZero() && f.LatestFullDate.Before(f.EstimatedFullDate)) {
+				t.Errorf("full on %s, outside its interval %s to %s", f.EstimatedFullDate, f.EarliestFullDate, f.LatestFullDate)
+			}
+		})
+	}
+}
+
+// changes returns points of one drive a day apart whose free space changes by deltas
+func changes(deltas ...float64) []history.Point {
+	points := []history.Point{{Time: start, Free: 1 << 40, Total: 2 << 40}}
+	for i, d := range deltas {
+		prev := points[len(points)-1]
+		points = append(points, history.Point{
+			Time:  start.AddDate(0, 0, i+1),
+			Free:  uint64(float64(prev.Free) + d),
+			Total: prev.Total,
+		})
+	}
+	return points
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	cfg := AnomalyConfig{Window: 10, Sensitivity: 3, MinChange: "1000"}
+
+	// Deduplication frees 5000 with the last change
+	deduped := changes(-100, -100, -100, -100, -100, -100, -100, 5000)
+	deduped[len(deduped)-1].Savings = 5100
+
+	tests := []struct {
+		name   string
+		points []history.Point
+		cfg    AnomalyConfig
+		// want are the anomalies found, Time is checked against the point at Index
+		want []Anomaly
+	}{
+		{name: "steady", points: changes(-100, -100, -100, -100, -100, -100, -100, -100), cfg: cfg},
+		{name: "sudden drop", points: changes(-100, -100, -100, -100, -100, -100, -5000, -100), cfg: cfg,
+			want: []Anomaly{{Index: 7, Change: -5000, Typical: -100}}},
+		{name: "drop and free", points: changes(-100, -100, -100, -100, -100, -100, -5000, -100, 3000), cfg: cfg,
+			want: []Anomaly{{Index: 7, Change: -5000, Typical: -100}, {Index: 9, Change: 3000, Typical: -100}}},
+		{name: "below min change", points: changes(-100, -100, -100, -100, -100, -100, -900, -100), cfg: cfg},
+		{name: "too early to judge", points: changes(-100, -100, -5000, -100, -100, -100), cfg: cfg},
+		{name: "noisy baseline", points: changes(-100, -3000, 2000, -2500, 1500, -2800, -4000), cfg: cfg},
+		{name: "deduplication", points: deduped, cfg: cfg},
+		{name: "invalid min change", points: changes(-100, -100, -100, -100, -100, -100, -5000),
+			cfg: AnomalyConfig{Window: 10, Sensitivity: 3, MinChange: "lots"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectAnomalies(tt.points, tt.cfg)
+			if len(got) != len(tt.want) {
+				t.Fatalf("DetectAnomalies = %+v, want %+v", got, tt.want)
+			}
+			for i, a := range got {
+				w := tt.want[i]
+				if a.Index != w.Index || a.Change != w.Change || a.Typical != w.Typical {
+					t.Errorf("anomaly %d = %+v, want %+v", i, a, w)
+				}
+				if !a.Time.Equal(tt.points[w.Index].Time) {
+					t.Errorf("anomaly %d at %s, want %s", i, a.Time, tt.points[w.Index].Time)
+				}
+				if kind := a.Kind(); (w.Change < 0) != (kind == "sudden drop") {
+					t.Errorf("anomaly %d is a %s", i, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	// series returns n hourly values, 0 except for the given peaks
+	series := func(n int, peaks ...int) ([]time.Time, []float64) {
+		times := make([]time.Time, n)
+		values := make([]float64, n)
+		for i := range times {
+			times[i] = start.Add(time.Duration(i) * time.Hour)
+		}
+		for _, p := range peaks {
+			values[p] = 100
+		}
+		return times, values
+	}
+
+	tests := []struct {
+		name  string
+		count int
+		peaks []int
+		n     int
+		// want is the number of points kept
+		want int
+	}{
+		{name: "fewer than n", count: 5, n: 10, want: 5},
+		{name: "exactly n", count: 10, n: 10, want: 10},
+		{name: "empty", count: 0, n: 10, want: 0},
+		{name: "n below 3", count: 10, n: 1, want: 3},
+		{name: "peaks survive", count: 1000, peaks: []int{137, 512, 901}, n: 50, want: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			times, values := series(tt.count, tt.peaks...)
+			keep := Downsample(times, values, tt.n)
+			if len(keep) != tt.want {
+				t.Fatalf("kept %d points, want %d", len(keep), tt.want)
+			}
+			if len(keep) == 0 {
+				return
+			}
+			if keep[0] != 0 || keep[len(keep)-1] != tt.count-1 {
+				t.Errorf("kept %v, want the first and last point", keep)
+			}
+			for i := 1; i < len(keep); i++ {
+				if keep[i] <= keep[i-1] {
+					t.Fatalf("kept %v, not in order", keep)
+				}
+			}
+			for _, p := range tt.peaks {
+				if !slices.Contains(keep, p) {
+					t.Errorf("peak %d was dropped", p)
+				}
+			}
+		})
+	}
+}
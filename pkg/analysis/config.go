@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ForecastConfig holds settings for days-until-full estimation
+type ForecastConfig struct {
+	// Window is how much recent history the regression is fitted over, e.g. "30d"
+	Window string `json:"window"`
+	// Model is "linear" or "exp" (exponential decay of free space)
+	Model string `json:"model"`
+	// Reserve is the free space at which a drive counts as full, e.g. "1GB"
+	Reserve string `json:"reserve"`
+}
+
+// AnomalyConfig holds settings for detecting abnormal changes
+type AnomalyConfig struct {
+	// Window is the number of preceding changes used as the baseline
+	Window int `json:"window"`
+	// Sensitivity is how many deviations from the baseline count as abnormal
+	Sensitivity float64 `json:"sensitivity"`
+	// MinChange ignores changes smaller than this, e.g. "1GB"
+	MinChange string `json:"min_change"`
+}
+
+// ParseDuration parses a duration that may also use d (days) and w (weeks)
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	unit := s[len(s)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		day := 24 * time.Hour
+		if unit == 'w' {
+			day *= 7
+		}
+		return time.Duration(n * float64(day)), nil
+	}
+
+	return time.ParseDuration(s)
+}
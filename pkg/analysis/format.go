@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// FormatForecast describes a forecast in one line
+func FormatForecast(f *Forecast, now time.Time) string {
+	if !f.Filling {
+		return "not filling up"
+	}
+
+	s := fmt.Sprintf("in %.0f days (%s", f.DaysUntilFull(now), locale.Date(f.EstimatedFullDate))
+	if f.LatestFullDate.IsZero() {
+		s += fmt.Sprintf(", 95%% CI %s – never)", locale.Date(f.EarliestFullDate))
+	} else if !f.EarliestFullDate.Equal(f.LatestFullDate) {
+		s += fmt.Sprintf(", 95%% CI %s – %s)",
+			locale.Date(f.EarliestFullDate), locale.Date(f.LatestFullDate))
+	} else {
+		s += ")"
+	}
+	return s
+}
+
+// WriteStats writes the statistics of one drive
+func WriteStats(w io.Writer, st *DriveStats) {
+	fmt.Fprintf(w, "Drive %s (%d samples):\n", st.Drive, st.Samples)
+	fmt.Fprintf(w, "  Free:      %s of %s\n", diskinfo.FormatBytes(st.Current.Free), diskinfo.FormatBytes(st.Current.Total))
+	if st.Current.Savings > 0 {
+		fmt.Fprintf(w, "  Savings:   %s by deduplication, left out of growth\n", diskinfo.FormatBytes(st.Current.Savings))
+	}
+	if st.Current.MaxFiles > 0 {
+		fmt.Fprintf(w, "  Files:     %s of %s (%s), %s/day\n", locale.Int(st.Current.Files), locale.Int(st.Current.MaxFiles),
+			locale.Percent(float64(st.Current.Files)/float64(st.Current.MaxFiles)*100, 1), locale.Signed(st.FilesPerDay, 0))
+	}
+	fmt.Fprintf(w, "  Min free:  %s\n", diskinfo.FormatBytes(st.MinFree))
+	fmt.Fprintf(w, "  Max free:  %s\n", diskinfo.FormatBytes(st.MaxFree))
+	fmt.Fprintf(w, "  Avg free:  %s\n", diskinfo.FormatBytes(uint64(st.AvgFree)))
+	for _, g := range st.Growth {
+		fmt.Fprintf(w, "  Growth %-4s %s\n", g.Window+":", FormatRate(g.BytesPerDay))
+	}
+	fmt.Fprintf(w, "  Free p5/p50/p95:          %s / %s / %s\n",
+		diskinfo.FormatBytes(uint64(st.FreePercentiles.P5)),
+		diskinfo.FormatBytes(uint64(st.FreePercentiles.P50)),
+		diskinfo.FormatBytes(uint64(st.FreePercentiles.P95)))
+	if st.Days > 0 {
+		fmt.Fprintf(w, "  Daily change p5/p50/p95:  %s / %s / %s (%d days)\n",
+			diskinfo.FormatChange(st.DailyChangePercentiles.P5),
+			diskinfo.FormatChange(st.DailyChangePercentiles.P50),
+			diskinfo.FormatChange(st.DailyChangePercentiles.P95),
+			st.Days)
+	}
+}
+
+// FormatRate formats a growth rate in GB/day, or GiB/day
+func FormatRate(bytesPerDay float64) string {
+	return locale.Signed(bytesPerDay/diskinfo.Gigabyte(), 2) + " " + diskinfo.GigabyteUnit() + "/day"
+}
+
+// WritePatterns writes the average free space change of a drive by weekday and hour
+func WritePatterns(w io.Writer, drive string, ps *PatternStats) {
+	fmt.Fprintf(w, "Drive %s, average free space change:\n", drive)
+	fmt.Fprintln(w, "  By day of week:")
+	for i := 0; i < 7; i++ {
+		// Start the week on Monday
+		wd := time.Weekday((i + 1) % 7)
+		b := ps.Weekday[wd]
+		if b.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "    %-4s %12s  (%d days)\n", wd.String()[:3], diskinfo.FormatChange(b.Average()), b.Count)
+	}
+	if !ps.HasHours() {
+		fmt.Fprintf(w, "  By hour of day: %s\n", HourGapNote)
+		return
+	}
+	fmt.Fprintln(w, "  By hour of day:")
+	for h, b := range ps.Hour {
+		if b.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "    %02d:00 %12s  (%d samples)\n", h, diskinfo.FormatChange(b.Average()), b.Count)
+	}
+}
@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"github.com/valsaven/disk-monitor/pkg/history"
+)
+
+// WriteRate is the average host writes of a physical disk over a window
+type WriteRate struct {
+	Window      string
+	BytesPerDay float64
+	Samples     int
+}
+
+// written returns the bytes written between two readings, 0 if the counter went back
+func written(from, to diskinfo.Health) float64 {
+	if to.DataWritten < from.DataWritten {
+		return 0
+	}
+	return float64(to.DataWritten - from.DataWritten)
+}
+
+// withWrites drops the readings without a write counter, taken before it was recorded
+func withWrites(points []history.HealthPoint) []history.HealthPoint {
+	var kept []history.HealthPoint
+	for _, p := range points {
+		if p.DataWritten > 0 {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// WriteRates returns the average host writes per day over each of GrowthWindows,
+// for the windows with at least two readings
+func WriteRates(points []history.HealthPoint, now time.Time) []WriteRate {
+	points = withWrites(points)
+	var rates []WriteRate
+	for _, w := range GrowthWindows {
+		d, _ := ParseDuration(w)
+		since := now.Add(-d)
+		first := -1
+		for i, p := range points {
+			if !p.Time.Before(since) {
+				first = i
+				break
+			}
+		}
+		if first < 0 || first == len(points)-1 {
+			continue
+		}
+		from, to := points[first], points[len(points)-1]
+		days := to.Time.Sub(from.Time).Hours() / 24
+		if days <= 0 {
+			continue
+		}
+		rates = append(rates, WriteRate{
+			Window:      w,
+			BytesPerDay: written(from.Health, to.Health) / days,
+			Samples:     len(points) - first,
+		})
+	}
+	return rates
+}
+
+// DailyWrites returns the bytes written on each day, from the last readings of
+// consecutive days with readings, dated by the later day
+func DailyWrites(points []history.HealthPoint) (days []time.Time, bytes []float64) {
+	points = withWrites(points)
+	var dayEnds []history.HealthPoint
+	var prevDay string
+	for _, p := range points {
+		day := p.Time.Format("2006-01-02")
+		if day != prevDay {
+			dayEnds = append(dayEnds, p)
+			prevDay = day
+		}
+		dayEnds[len(dayEnds)-1] = p
+	}
+
+	for i := 1; i < len(dayEnds); i++ {
+		days = append(days, dayEnds[i].Time)
+		bytes = append(bytes, written(dayEnds[i-1].Health, dayEnds[i].Health))
+	}
+	return days, bytes
+}
+
+// EnduranceDaysLeft estimates the days until a disk reaches 100% used at the
+// given write rate. The drive doesn't report its rating, so the bytes written per
+// percent used so far stand in for it. False until a percent is used.
+func EnduranceDaysLeft(h diskinfo.Health, bytesPerDay float64) (float64, bool) {
+	if h.PercentageUsed <= 0 || h.DataWritten == 0 || bytesPerDay <= 0 {
+		return 0, false
+	}
+	if h.PercentageUsed >= 100 {
+		return 0, true
+	}
+	perPercent := float64(h.DataWritten) / float64(h.PercentageUsed)
+	return float64(100-h.PercentageUsed) * perPercent / bytesPerDay, true
+}
+
+// FormatWriteRate formats host writes per day, e.g. "42.0 GB/day"
+func FormatWriteRate(bytesPerDay float64) string {
+	return diskinfo.FormatBytes(uint64(max(bytesPerDay, 0))) + "/day"
+}
+
+// FormatEndurance formats EnduranceDaysLeft, e.g. "about 6.5 years at this rate"
+func FormatEndurance(days float64) string {
+	switch {
+	case days <= 0:
+		return "rated endurance used up"
+	case days < 60:
+		return fmt.Sprintf("about %.0f days at this rate", days)
+	default:
+		return fmt.Sprintf("about %.1f years at this rate", days/365)
+	}
+}
@@ -0,0 +1,172 @@
+package diskinfo
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"time"
+	"unsafe"
+
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// BenchFile is the temporary file of a benchmark. It bypasses the file cache,
+// so offsets and buffers must be multiples of BenchAlign, and is deleted on Close.
+type BenchFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Close() error
+}
+
+// Benchmark block sizes. Unbuffered I/O needs sector aligned offsets, 4 KiB
+// covers the sector size of current disks.
+const (
+	BenchAlign     = 4096
+	benchSeqBlock  = 1 << 20
+	benchRandBlock = 4096
+)
+
+// Benchmark is the result of a benchmark run on one drive
+type Benchmark struct {
+	Drive string `json:"drive"`
+	// Size is the size of the test file
+	Size uint64 `json:"size"`
+	// SeqRead and SeqWrite are in bytes per second, 1 MiB blocks
+	SeqRead  float64 `json:"seq_read"`
+	SeqWrite float64 `json:"seq_write"`
+	// RandRead and RandWrite are 4 KiB operations per second at queue depth 1
+	RandRead  float64 `json:"rand_read"`
+	RandWrite float64 `json:"rand_write"`
+}
+
+// BenchOptions configure a benchmark run
+type BenchOptions struct {
+	// Size of the test file, rounded down to whole 1 MiB blocks
+	Size uint64
+	// Duration each random test runs for
+	Duration time.Duration
+}
+
+// alignedBuffer returns a buffer of n bytes starting at a BenchAlign boundary
+func alignedBuffer(n int) []byte {
+	buf := make([]byte, n+BenchAlign)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % BenchAlign); rem != 0 {
+		offset = BenchAlign - rem
+	}
+	return buf[offset : offset+n]
+}
+
+// RunBenchmark writes and reads a temporary file on drive, sequentially and at
+// random offsets. The file is removed afterwards, also when ctx is cancelled.
+func RunBenchmark(ctx context.Context, drive string, opts BenchOptions) (*Benchmark, error) {
+	size := opts.Size / benchSeqBlock * benchSeqBlock
+	if size == 0 {
+		return nil, fmt.Errorf("benchmark size must be at least 1 MB")
+	}
+	if opts.Duration <= 0 {
+		return nil, fmt.Errorf("benchmark duration must be positive")
+	}
+
+	// The test file shouldn't be what fills the drive up
+	info, err := collectDrive(ctx, drive)
+	if err != nil {
+		return nil, err
+	}
+	if info.FreeSpace < size*2 {
+		return nil, fmt.Errorf("%s has %s free, the benchmark needs twice its %s file",
+			drive, FormatBytes(info.FreeSpace), FormatBytes(size))
+	}
+
+	f, err := currentSystem().CreateBenchFile(drive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the test file: %v", err)
+	}
+	defer f.Close()
+
+	b := &Benchmark{Drive: drive, Size: size}
+	// Random data keeps compressing and deduplicating drives honest
+	buf := alignedBuffer(benchSeqBlock)
+	rand.Read(buf)
+
+	if b.SeqWrite, err = benchSequential(ctx, f, buf, size, true); err != nil {
+		return nil, fmt.Errorf("sequential write: %v", err)
+	}
+	if b.SeqRead, err = benchSequential(ctx, f, buf, size, false); err != nil {
+		return nil, fmt.Errorf("sequential read: %v", err)
+	}
+	if b.RandWrite, err = benchRandom(ctx, f, buf[:benchRandBlock], size, opts.Duration, true); err != nil {
+		return nil, fmt.Errorf("random write: %v", err)
+	}
+	if b.RandRead, err = benchRandom(ctx, f, buf[:benchRandBlock], size, opts.Duration, false); err != nil {
+		return nil, fmt.Errorf("random read: %v", err)
+	}
+	return b, nil
+}
+
+// benchSequential writes or reads the whole file in order and returns bytes per second
+func benchSequential(ctx context.Context, f BenchFile, buf []byte, size uint64, write bool) (float64, error) {
+	start := time.Now()
+	for off := uint64(0); off < size; off += uint64(len(buf)) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		var err error
+		if write {
+			_, err = f.WriteAt(buf, int64(off))
+		} else {
+			_, err = f.ReadAt(buf, int64(off))
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if write {
+		if err := f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	return float64(size) / time.Since(start).Seconds(), nil
+}
+
+// benchRandom writes or reads blocks at random offsets for d and returns operations per second
+func benchRandom(ctx context.Context, f BenchFile, buf []byte, size uint64, d time.Duration, write bool) (float64, error) {
+	blocks := size / uint64(len(buf))
+	start := time.Now()
+	var ops int
+	for time.Since(start) < d {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		off := int64(mrand.Uint64N(blocks)) * int64(len(buf))
+		var err error
+		if write {
+			_, err = f.WriteAt(buf, off)
+		} else {
+			_, err = f.ReadAt(buf, off)
+		}
+		if err != nil {
+			return 0, err
+		}
+		ops++
+	}
+	if write {
+		if err := f.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	return float64(ops) / time.Since(start).Seconds(), nil
+}
+
+// FormatThroughput formats bytes per second, e.g. "3.2 GB/s"
+func FormatThroughput(bytesPerSecond float64) string {
+	return FormatBytes(uint64(bytesPerSecond)) + "/s"
+}
+
+// FormatIOPS formats operations per second, e.g. "45210 IOPS"
+func FormatIOPS(ops float64) string {
+	return locale.Float(ops, 0) + " IOPS"
+}
@@ -0,0 +1,35 @@
+package diskinfo
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// CreateFile flags of a benchmark file
+const (
+	FILE_FLAG_NO_BUFFERING    = 0x20000000
+	FILE_FLAG_WRITE_THROUGH   = 0x80000000
+	FILE_FLAG_DELETE_ON_CLOSE = 0x04000000
+	FILE_ATTRIBUTE_TEMPORARY  = 0x00000100
+)
+
+// benchFileName is the name of the test file in the benchmarked directory
+const benchFileName = "disk-monitor-bench.tmp"
+
+// CreateBenchFile creates the test file without the file cache and with writes
+// going straight to the disk, so the benchmark measures the disk and not RAM.
+// Windows deletes it when the last handle closes, also if the process dies.
+func (windowsSystem) CreateBenchFile(dir string) (BenchFile, error) {
+	path := filepath.Join(dir, benchFileName)
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.CREATE_ALWAYS,
+		FILE_ATTRIBUTE_TEMPORARY|FILE_FLAG_NO_BUFFERING|FILE_FLAG_WRITE_THROUGH|FILE_FLAG_DELETE_ON_CLOSE, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
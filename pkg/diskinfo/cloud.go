@@ -0,0 +1,226 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cloud storage providers of CloudAccount
+const (
+	CloudOneDrive = "onedrive"
+	CloudGoogle   = "gdrive"
+	CloudDropbox  = "dropbox"
+)
+
+// CloudProviders lists the providers whose quota can be collected
+var CloudProviders = []string{CloudOneDrive, CloudGoogle, CloudDropbox}
+
+// CloudTimeout is how long CollectCloud waits for a provider, longer than
+// QueryTimeout as it goes over the internet
+const CloudTimeout = 10 * time.Second
+
+// CloudAccount is a cloud storage account whose quota is collected like a drive
+type CloudAccount struct {
+	// Provider is one of CloudProviders
+	Provider string `json:"provider"`
+	// Name tells accounts of the same provider apart, e.g. "work"
+	Name string `json:"name"`
+	// Token is an OAuth access token of the provider's API
+	Token string `json:"token,omitempty"`
+	// TokenFile is read for the token on every collection instead, so another
+	// tool can keep a short-lived token fresh
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// Drive returns the name the account is stored under, e.g. `onedrive:work\`
+func (a CloudAccount) Drive() string {
+	return NormalizePath(strings.ToLower(a.Provider) + ":" + a.Name)
+}
+
+// token returns the configured token, or the one in TokenFile
+func (a CloudAccount) token() (string, error) {
+	if a.TokenFile == "" {
+		if a.Token == "" {
+			return "", fmt.Errorf("no token configured")
+		}
+		return a.Token, nil
+	}
+	data, err := os.ReadFile(a.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// IsCloudDrive reports whether drive is the name of a cloud account rather
+// than a drive or a monitored path
+func IsCloudDrive(drive string) bool {
+	provider, _, ok := strings.Cut(drive, ":")
+	if !ok || len(provider) < 2 {
+		return false
+	}
+	for _, p := range CloudProviders {
+		if strings.EqualFold(provider, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectCloud reads the quota of each account, waiting at most CloudTimeout
+// for each. Accounts that fail are left out and reported in errs as a *DriveError.
+func CollectCloud(ctx context.Context, accounts []CloudAccount) (disks []DiskInfo, errs []error) {
+	infos := make([]*DiskInfo, len(accounts))
+	failures := make([]error, len(accounts))
+	var wg sync.WaitGroup
+	for i, a := range accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			infos[i], failures[i] = CloudSpace(ctx, a)
+		}()
+	}
+	wg.Wait()
+
+	for i := range accounts {
+		if failures[i] != nil {
+			errs = append(errs, failures[i])
+			continue
+		}
+		disks = append(disks, *infos[i])
+	}
+	return disks, errs
+}
+
+// CloudSpace reads the quota of one account, failures are a *DriveError
+func CloudSpace(ctx context.Context, a CloudAccount) (*DiskInfo, error) {
+	drive := a.Drive()
+	if !IsCloudDrive(drive) {
+		return nil, &DriveError{Drive: drive, Err: ErrDriveUnavailable, Cause: fmt.Errorf(
+			"unknown cloud provider %q, use one of %s", a.Provider, strings.Join(CloudProviders, ", "))}
+	}
+	ctx, cancel := context.WithTimeout(ctx, CloudTimeout)
+	defer cancel()
+
+	used, total, err := currentSystem().CloudQuota(ctx, a)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &DriveError{Drive: drive, Err: ErrDriveTimeout}
+		}
+		return nil, &DriveError{Drive: drive, Err: ErrDriveUnavailable, Cause: err}
+	}
+	// Accounts over quota have nothing left, not a wrapped around free space
+	free := uint64(0)
+	if total > used {
+		free = total - used
+	}
+	return &DiskInfo{
+		Drive:      drive,
+		TotalSpace: total,
+		FreeSpace:  free,
+		UsedSpace:  used,
+	}, nil
+}
+
+// Quota endpoints of the providers
+const (
+	oneDriveQuotaURL = "https://graph.microsoft.com/v1.0/me/drive?$select=quota"
+	googleQuotaURL   = "https://www.googleapis.com/drive/v3/about?fields=storageQuota"
+	dropboxQuotaURL  = "https://api.dropboxapi.com/2/users/get_space_usage"
+)
+
+// queryCloudQuota asks the provider's API for the used and total bytes of an account
+func queryCloudQuota(ctx context.Context, a CloudAccount) (used, total uint64, err error) {
+	token, err := a.token()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch strings.ToLower(a.Provider) {
+	case CloudOneDrive:
+		var resp struct {
+			Quota struct {
+				Total uint64 `json:"total"`
+				Used  uint64 `json:"used"`
+			} `json:"quota"`
+		}
+		if err := cloudRequest(ctx, http.MethodGet, oneDriveQuotaURL, token, &resp); err != nil {
+			return 0, 0, err
+		}
+		return resp.Quota.Used, resp.Quota.Total, nil
+
+	case CloudGoogle:
+		// The sizes are int64 strings, and the limit is missing for unlimited storage
+		var resp struct {
+			StorageQuota struct {
+				Limit string `json:"limit"`
+				Usage string `json:"usage"`
+			} `json:"storageQuota"`
+		}
+		if err := cloudRequest(ctx, http.MethodGet, googleQuotaURL, token, &resp); err != nil {
+			return 0, 0, err
+		}
+		if resp.StorageQuota.Limit == "" {
+			return 0, 0, fmt.Errorf("the account has no storage limit")
+		}
+		if used, err = strconv.ParseUint(resp.StorageQuota.Usage, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid usage %q", resp.StorageQuota.Usage)
+		}
+		if total, err = strconv.ParseUint(resp.StorageQuota.Limit, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid limit %q", resp.StorageQuota.Limit)
+		}
+		return used, total, nil
+
+	case CloudDropbox:
+		// Team accounts share the team's allocation
+		var resp struct {
+			Used       uint64 `json:"used"`
+			Allocation struct {
+				Tag       string `json:".tag"`
+				Allocated uint64 `json:"allocated"`
+			} `json:"allocation"`
+		}
+		if err := cloudRequest(ctx, http.MethodPost, dropboxQuotaURL, token, &resp); err != nil {
+			return 0, 0, err
+		}
+		return resp.Used, resp.Allocation.Allocated, nil
+	}
+	return 0, 0, fmt.Errorf("unknown cloud provider %q", a.Provider)
+}
+
+// cloudRequest sends an authorized request without a body and decodes the JSON answer into v
+func cloudRequest(ctx context.Context, method, url, token string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("token rejected (%s)", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid answer: %v", err)
+	}
+	return nil
+}
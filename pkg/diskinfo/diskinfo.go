@@ -0,0 +1,290 @@
+// Package diskinfo queries the space of local Windows drives. The queries go
+// through a System, which a Fake replaces for tests and development elsewhere.
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskInfo holds disk information
+type DiskInfo struct {
+	Drive      string `json:"drive"`
+	TotalSpace uint64 `json:"total_space"`
+	FreeSpace  uint64 `json:"free_space"`
+	UsedSpace  uint64 `json:"used_space"`
+	// VolumeFree is the free space of the whole volume. FreeSpace is what the
+	// caller may use, which is less when a disk quota applies. 0 when not recorded.
+	VolumeFree uint64 `json:"volume_free,omitempty"`
+	// FileSystem is the file system of the volume, e.g. NTFS or ReFS, empty when unknown
+	FileSystem string `json:"file_system,omitempty"`
+	// BlockClone is set for volumes whose files can share clusters, so copies
+	// take no space until they are changed
+	BlockClone bool `json:"block_clone,omitempty"`
+	// IntegrityStreams is set for volumes that checksum file data
+	IntegrityStreams bool `json:"integrity_streams,omitempty"`
+	// DevDrive is set for Dev Drives, volumes meant for source trees, build
+	// output and package caches rather than data that is kept
+	DevDrive bool `json:"dev_drive,omitempty"`
+	// Image is the kind of disk image the volume is mounted from, one of the
+	// Image* constants, empty for a volume on a disk
+	Image string `json:"image,omitempty"`
+	// Savings is the space deduplication saves, used space is what remains
+	Savings uint64 `json:"savings,omitempty"`
+	// Files is how many file records the volume uses, MFT records on NTFS, and
+	// MaxFiles how many it can have. A volume runs out of them with bytes to
+	// spare. 0 when not recorded.
+	Files    uint64 `json:"files,omitempty"`
+	MaxFiles uint64 `json:"max_files,omitempty"`
+}
+
+// IsReFS reports whether the volume is formatted with ReFS
+func (d DiskInfo) IsReFS() bool {
+	return strings.EqualFold(d.FileSystem, "ReFS")
+}
+
+// FileSystemLabel names the file system with the features that change how its
+// space reads, e.g. "ReFS Dev Drive (block cloning, integrity streams)"
+func (d DiskInfo) FileSystemLabel() string {
+	name := d.FileSystem
+	if d.DevDrive {
+		name += " Dev Drive"
+	}
+	var features []string
+	if d.BlockClone {
+		features = append(features, "block cloning")
+	}
+	if d.IntegrityStreams {
+		features = append(features, "integrity streams")
+	}
+	if len(features) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(features, ", ") + ")"
+}
+
+// LogicalUsed returns the size of the data on the volume before deduplication
+func (d DiskInfo) LogicalUsed() uint64 {
+	return d.UsedSpace + d.Savings
+}
+
+// VolumeFreeSpace returns VolumeFree, or FreeSpace for entries recorded without it
+func (d DiskInfo) VolumeFreeSpace() uint64 {
+	if d.VolumeFree == 0 {
+		return d.FreeSpace
+	}
+	return d.VolumeFree
+}
+
+// IsImage reports whether the volume is mounted from a disk image
+func (d DiskInfo) IsImage() bool {
+	return d.Image != ""
+}
+
+// FilesPercent returns the share of the file records in use, 0 when not recorded
+func (d DiskInfo) FilesPercent() float64 {
+	if d.MaxFiles == 0 {
+		return 0
+	}
+	return float64(d.Files) / float64(d.MaxFiles) * 100
+}
+
+// QuotaLimited reports whether a disk quota leaves the caller less than the volume has free
+func (d DiskInfo) QuotaLimited() bool {
+	return d.VolumeFree > d.FreeSpace
+}
+
+// QueryTimeout is how long a drive query waits by default before giving up,
+// see QueryPolicy
+const QueryTimeout = 2 * time.Second
+
+// GetDiskSpace retrieves space info for a drive or a directory on it, failures are a *DriveError.
+// The query itself can't be interrupted, so it is left running in the
+// background when ctx is done first.
+func GetDiskSpace(ctx context.Context, drive string) (*DiskInfo, error) {
+	type result struct {
+		info *DiskInfo
+		err  error
+	}
+	done := make(chan result, 1)
+
+	sys := currentSystem()
+	go func() {
+		free, total, volumeFree, err := sys.DiskFreeSpace(drive)
+		if errors.Is(err, ErrDriveLocked) {
+			done <- result{err: &DriveError{Drive: drive, Err: ErrDriveLocked}}
+			return
+		}
+		if err != nil {
+			done <- result{err: &DriveError{Drive: drive, Err: ErrDriveUnavailable, Cause: err}}
+			return
+		}
+		info := &DiskInfo{
+			Drive:      drive,
+			TotalSpace: total,
+			FreeSpace:  free,
+			UsedSpace:  total - free,
+			VolumeFree: volumeFree,
+		}
+		tagVolume(sys, info)
+		tagImage(sys, info)
+		done <- result{info: info}
+	}()
+
+	select {
+	case r := <-done:
+		return r.info, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &DriveError{Drive: drive, Err: ErrDriveTimeout}
+		}
+		return nil, &DriveError{Drive: drive, Err: ctx.Err()}
+	}
+}
+
+// AvailableDrives returns list of available local drives
+func AvailableDrives() []string {
+	drives := []string{}
+
+	sys := currentSystem()
+	driveBits := sys.LogicalDrives()
+	for i := 0; i < 26; i++ {
+		if driveBits&(1<<uint(i)) != 0 {
+			drive := fmt.Sprintf("%c:\\", 'A'+i)
+			// Check drive type
+			driveType := DriveType(drive)
+			// Skip CD-ROM and network drives, and mounted images if excluded
+			if driveType != DRIVE_CDROM && driveType != DRIVE_REMOTE && !excludedImage(sys, drive) {
+				drives = append(drives, drive)
+			}
+		}
+	}
+
+	return drives
+}
+
+// NormalizePath turns a monitored directory or UNC share into the form it is
+// queried and stored in: backslashes, an upper case drive letter and a trailing
+// backslash, which GetDiskFreeSpaceEx needs for UNC shares. "c" becomes `C:\`.
+func NormalizePath(path string) string {
+	path = strings.TrimRight(strings.ReplaceAll(strings.TrimSpace(path), "/", `\`), `\`)
+	if path == "" {
+		return path
+	}
+	if len(path) == 1 {
+		path += ":"
+	}
+	if path[1] == ':' {
+		path = strings.ToUpper(path[:1]) + path[1:]
+	}
+	return path + `\`
+}
+
+// Targets returns the available drives followed by the monitored paths,
+// normalized and without the ones that are already a drive root
+func Targets(paths []string) []string {
+	targets := AvailableDrives()
+	for _, path := range paths {
+		path = NormalizePath(path)
+		if path == "" || containsFold(targets, path) {
+			continue
+		}
+		targets = append(targets, path)
+	}
+	return targets
+}
+
+// containsFold reports whether list holds s, ignoring case like Windows paths do
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Drive type constants
+const (
+	DRIVE_UNKNOWN     = 0
+	DRIVE_NO_ROOT_DIR = 1
+	DRIVE_REMOVABLE   = 2
+	DRIVE_FIXED       = 3
+	DRIVE_REMOTE      = 4
+	DRIVE_CDROM       = 5
+	DRIVE_RAMDISK     = 6
+)
+
+// DriveType returns the type of the drive
+func DriveType(drive string) uint32 {
+	return currentSystem().DriveType(drive)
+}
+
+// DefaultWorkers is how many drives CollectAll queries at once when not told otherwise
+const DefaultWorkers = 4
+
+// CollectAll gathers info for all drives and the monitored paths, each queried
+// as its QueryPolicy says. At most workers drives are queried at once, so dozens of
+// slow network volumes aren't all hit together; 0 uses DefaultWorkers.
+// Drives that fail are left out of the result and reported in errs, both in the order of Targets.
+func CollectAll(ctx context.Context, workers int, paths []string) (disks []DiskInfo, errs []error) {
+	return CollectDrives(ctx, workers, Targets(paths))
+}
+
+// CollectDrives is CollectAll for a list of drives and paths, e.g. the
+// Targets that are due
+func CollectDrives(ctx context.Context, workers int, drives []string) (disks []DiskInfo, errs []error) {
+	if len(drives) == 0 {
+		return nil, nil
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	workers = min(workers, len(drives))
+
+	// One slot per drive keeps the drive order
+	infos := make([]*DiskInfo, len(drives))
+	failures := make([]error, len(drives))
+
+	// Each worker takes the next drive until the queue is drained
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				infos[i], failures[i] = collectDrive(ctx, drives[i])
+			}
+		}()
+	}
+	for i := range drives {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	// Collect results
+	for i := range drives {
+		if failures[i] != nil {
+			errs = append(errs, failures[i])
+			continue
+		}
+		disks = append(disks, *infos[i])
+	}
+
+	return disks, errs
+}
+
+// collectDrive queries one drive for CollectAll.
+// Once ctx is done the drives still queued fail without being queried.
+func collectDrive(ctx context.Context, drive string) (*DiskInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &DriveError{Drive: drive, Err: err}
+	}
+	return QueryDrive(ctx, drive)
+}
@@ -0,0 +1,204 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFake makes the queries of a test go to f until it ends
+func useFake(t *testing.T, f *Fake) {
+	t.Helper()
+	prev := SetSystem(f)
+	t.Cleanup(func() { SetSystem(prev) })
+}
+
+func TestGetDiskSpace(t *testing.T) {
+	f := NewFake()
+	f.SetDrive(`C:\`, FakeDrive{Total: 500 << 30, Free: 120 << 30})
+	f.SetDrive(`D:\`, FakeDrive{Total: 100 << 30, Free: 10 << 30, VolumeFree: 40 << 30})
+	f.SetDrive(`E:\`, FakeDrive{Total: 100 << 30, Err: ErrDriveLocked})
+	f.SetDrive(`F:\`, FakeDrive{Total: 100 << 30, Err: errors.New("the device is not ready")})
+	f.SetDrive(`G:\`, FakeDrive{Total: 100 << 30, Free: 50 << 30, Delay: 200 * time.Millisecond})
+	useFake(t, f)
+
+	tests := []struct {
+		drive      string
+		wantErr    error
+		wantUsed   uint64
+		volumeFree uint64
+	}{
+		{drive: `C:\`, wantUsed: 380 << 30, volumeFree: 120 << 30},
+		{drive: `D:\`, wantUsed: 90 << 30, volumeFree: 40 << 30},
+		{drive: `C:\Users`, wantUsed: 380 << 30, volumeFree: 120 << 30},
+		{drive: `E:\`, wantErr: ErrDriveLocked},
+		{drive: `F:\`, wantErr: ErrDriveUnavailable},
+		{drive: `X:\`, wantErr: ErrDriveUnavailable},
+		{drive: `G:\`, wantErr: ErrDriveTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.drive, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			info, err := GetDiskSpace(ctx, tt.drive)
+			if tt.wantErr != nil {
+				var de *DriveError
+				if !errors.As(err, &de) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetDiskSpace(%q) error = %v, want a DriveError for %v", tt.drive, err, tt.wantErr)
+				}
+				if de.Drive != tt.drive {
+					t.Errorf("DriveError.Drive = %q, want %q", de.Drive, tt.drive)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetDiskSpace(%q): %v", tt.drive, err)
+			}
+			if info.UsedSpace != tt.wantUsed || info.VolumeFree != tt.volumeFree {
+				t.Errorf("GetDiskSpace(%q) used %d, volume free %d, want %d and %d",
+					tt.drive, info.UsedSpace, info.VolumeFree, tt.wantUsed, tt.volumeFree)
+			}
+			if info.FileSystem != "NTFS" {
+				t.Errorf("GetDiskSpace(%q) file system %q, want NTFS", tt.drive, info.FileSystem)
+			}
+		})
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	f := NewFake()
+	// The later drives answer first, the result keeps the order of the drives
+	// and then the monitored paths
+	f.SetDrive(`C:\`, FakeDrive{Total: 100, Free: 10, Delay: 30 * time.Millisecond})
+	f.SetDrive(`D:\`, FakeDrive{Total: 100, Free: 20, Err: ErrDriveLocked})
+	f.SetDrive(`E:\`, FakeDrive{Total: 100, Free: 30, Delay: 20 * time.Millisecond})
+	f.SetDrive(`F:\`, FakeDrive{Type: DRIVE_REMOTE, Total: 100, Free: 40})
+	f.SetDrive(`G:\`, FakeDrive{Type: DRIVE_CDROM, Total: 100, Free: 50})
+	f.SetDrive(`H:\`, FakeDrive{Type: DRIVE_REMOVABLE, Total: 100, Free: 60})
+	f.SetDrive(`\\nas\backups`, FakeDrive{Type: DRIVE_REMOTE, Total: 100, Free: 70})
+	useFake(t, f)
+
+	if got, want := AvailableDrives(), []string{`C:\`, `D:\`, `E:\`, `H:\`}; !slices.Equal(got, want) {
+		t.Errorf("AvailableDrives = %q, want %q without network and CD drives", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{"one worker", 1},
+		{"default workers", 0},
+		{"more workers than drives", 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disks, errs := CollectAll(context.Background(), tt.workers, []string{`//nas/backups/`, "c"})
+			var got []string
+			for _, d := range disks {
+				got = append(got, d.Drive)
+			}
+			if want := []string{`C:\`, `E:\`, `H:\`, `\\nas\backups\`}; !slices.Equal(got, want) {
+				t.Errorf("drives = %q, want %q", got, want)
+			}
+			var de *DriveError
+			if len(errs) != 1 || !errors.As(errs[0], &de) || de.Drive != `D:\` || !errors.Is(errs[0], ErrDriveLocked) {
+				t.Errorf("errs = %v, want D:\\ locked", errs)
+			}
+		})
+	}
+
+	// A cancelled collection reports each drive as cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.SetDrive(`C:\`, FakeDrive{Total: 100, Free: 10, Delay: time.Second})
+	disks, errs := CollectAll(ctx, 1, nil)
+	if len(disks) != 0 || len(errs) != 4 {
+		t.Fatalf("CollectAll after cancel = %d drives, %d errors, want 0 and 4", len(disks), len(errs))
+	}
+	for _, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error %v, want context.Canceled", err)
+		}
+	}
+}
+
+func TestLoadFake(t *testing.T) {
+	f, err := LoadFake(filepath.Join("..", "..", "testdata", "simulation", "drives.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free, total, _, err := f.DiskFreeSpace("c"); err != nil || total != 512<<30 || free != 169.5*(1<<30) {
+		t.Errorf("C: of the fixture = %d free of %d, %v", free, total, err)
+	}
+	if _, _, _, err := f.DiskFreeSpace(`H:\`); err == nil || err.Error() != "The device is not ready." {
+		t.Errorf("H: of the fixture fails with %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		fixture string
+		err     string
+	}{
+		{"not json", `{"drives": `, "invalid fixture"},
+		{"unknown type", `{"drives": {"C:\\": {"type": "floppy"}}}`, `unknown type "floppy"`},
+		{"bad size", `{"drives": {"C:\\": {"total": "lots"}}}`, "invalid total"},
+		{"free over total", `{"drives": {"C:\\": {"total": "1GB", "free": "2GB"}}}`, "free is larger than total"},
+		{"bad delay", `{"drives": {"C:\\": {"delay": "soon"}}}`, "invalid delay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "drives.json")
+			if err := os.WriteFile(path, []byte(tt.fixture), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadFake(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("LoadFake error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestQueryDrive(t *testing.T) {
+	f := NewFake()
+	f.SetDrive(`C:\`, FakeDrive{Total: 100, Free: 10, Delay: 50 * time.Millisecond})
+	f.SetDrive(`D:\`, FakeDrive{Total: 100, Free: 20, Delay: 50 * time.Millisecond})
+	f.SetDrive(`E:\`, FakeDrive{Total: 100, Err: ErrDriveLocked})
+	useFake(t, f)
+	SetQueryPolicies(QueryPolicy{Timeout: 10 * time.Millisecond},
+		map[string]QueryPolicy{"d": {Timeout: time.Second}, `e:\`: {Retries: 3}})
+	t.Cleanup(func() { SetQueryPolicies(QueryPolicy{}, nil) })
+
+	if p := PolicyFor(`D:\`); p.Timeout != time.Second {
+		t.Errorf("PolicyFor(D:) = %+v, want its own timeout", p)
+	}
+	if p := PolicyFor(`E:\`); p.Timeout != QueryTimeout || p.Retries != 3 {
+		t.Errorf("PolicyFor(E:) = %+v, want 3 retries and the default timeout", p)
+	}
+
+	tests := []struct {
+		drive   string
+		wantErr error
+	}{
+		{drive: `C:\`, wantErr: ErrDriveTimeout},
+		{drive: `D:\`},
+		// Retrying would take a RetryDelay each time
+		{drive: `E:\`, wantErr: ErrDriveLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.drive, func(t *testing.T) {
+			start := time.Now()
+			_, err := QueryDrive(context.Background(), tt.drive)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("QueryDrive(%q) = %v, want %v", tt.drive, err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed >= RetryDelay {
+				t.Errorf("QueryDrive(%q) took %s, tried again", tt.drive, elapsed)
+			}
+		})
+	}
+}
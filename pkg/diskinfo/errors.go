@@ -0,0 +1,41 @@
+package diskinfo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons a drive query fails, test for them with errors.Is
+var (
+	ErrDriveTimeout     = errors.New("timeout")
+	ErrDriveUnavailable = errors.New("unavailable")
+	// ErrDriveLocked is a BitLocker volume that hasn't been unlocked, which
+	// answers again once it is
+	ErrDriveLocked = errors.New("locked by BitLocker")
+)
+
+// DriveError is a failed query of one drive. Err is ErrDriveTimeout,
+// ErrDriveUnavailable, ErrDriveLocked or the error of a cancelled context.
+type DriveError struct {
+	Drive string
+	Err   error
+	// Cause is the system error behind ErrDriveUnavailable
+	Cause error
+}
+
+// Error reads like "E:\ unavailable (timeout)" or "E:\ locked by BitLocker"
+func (e *DriveError) Error() string {
+	if e.Err == ErrDriveLocked {
+		return fmt.Sprintf("%s %v", e.Drive, e.Err)
+	}
+	reason := e.Err
+	if e.Cause != nil {
+		reason = e.Cause
+	}
+	return fmt.Sprintf("%s unavailable (%v)", e.Drive, reason)
+}
+
+// Unwrap returns the reason, so errors.Is matches the sentinel errors
+func (e *DriveError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,785 @@
+package diskinfo
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeDrive is a simulated drive of a Fake
+type FakeDrive struct {
+	// Type is one of the DRIVE_* constants, 0 counts as DRIVE_FIXED
+	Type  uint32
+	Total uint64
+	Free  uint64
+	// VolumeFree is the free space of the volume when a quota limits Free, 0 for no quota
+	VolumeFree uint64
+	// Delay makes each query this slow, to simulate a hung network or USB drive
+	Delay time.Duration
+	// Err fails each query, ErrDriveLocked simulates a locked BitLocker volume
+	Err error
+	// Extents place the volume on the physical disks, none for a volume
+	// that isn't on a local disk
+	Extents []Extent
+	// FileSystem is the file system name, "" counts as NTFS
+	FileSystem string
+	// Flags are the FILE_* flags of the file system
+	Flags uint32
+	// DevDrive simulates a Dev Drive
+	DevDrive bool
+	// Savings is the space deduplication saves on the volume
+	Savings uint64
+	// Image is the kind of disk image the volume is mounted from, "" for none
+	Image string
+	// Files and MaxFiles are the file records in use and the most the volume
+	// can have, 0 when not simulated
+	Files    uint64
+	MaxFiles uint64
+	// ReadSpeed and WriteSpeed are the sequential throughput of benchmarks in
+	// bytes per second, ReadIOPS and WriteIOPS their random 4 KiB operations
+	// per second. 0 uses the speed of a SATA SSD.
+	ReadSpeed  uint64
+	WriteSpeed uint64
+	ReadIOPS   uint64
+	WriteIOPS  uint64
+	// Fragmentation is the free space fragmentation, nil when it can't be read
+	Fragmentation *Fragmentation
+	// Trim is the TRIM status, nil when it can't be read
+	Trim *TrimStatus
+}
+
+// FakeDisk is a simulated physical disk of a Fake
+type FakeDisk struct {
+	PhysicalDisk
+	// Health is the NVMe health log, nil for disks that aren't NVMe
+	Health *Health
+}
+
+// Fake is an in-memory System for tests and for running on other platforms
+type Fake struct {
+	mu     sync.Mutex
+	drives map[string]FakeDrive
+	disks  map[int]FakeDisk
+	pools  []StoragePool
+	// oneDrive holds the OneDrive folders by path
+	oneDrive map[string]OneDriveFolder
+	updates  []UpdateEvent
+}
+
+// NewFake returns a Fake without drives
+func NewFake() *Fake {
+	return &Fake{drives: make(map[string]FakeDrive), disks: make(map[int]FakeDisk), oneDrive: make(map[string]OneDriveFolder)}
+}
+
+// SetDisk adds or replaces a physical disk
+func (f *Fake) SetDisk(disk int, d FakeDisk) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d.Number = disk
+	if d.Health != nil {
+		h := *d.Health
+		h.Disk = disk
+		d.Health = &h
+	}
+	f.disks[disk] = d
+}
+
+// PhysicalDisks returns the simulated disk numbers, sorted
+func (f *Fake) PhysicalDisks() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var disks []int
+	for disk := range f.disks {
+		disks = append(disks, disk)
+	}
+	sort.Ints(disks)
+	return disks
+}
+
+// NVMeHealth returns the simulated health log, ErrNotNVMe for unknown disks
+func (f *Fake) NVMeHealth(disk int) (*Health, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.disks[disk]
+	if !ok || d.Health == nil {
+		return nil, ErrNotNVMe
+	}
+	h := *d.Health
+	return &h, nil
+}
+
+// SetPools replaces the Storage Spaces pools
+func (f *Fake) SetPools(pools []StoragePool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pools = append([]StoragePool(nil), pools...)
+}
+
+// StoragePools returns the simulated pools
+func (f *Fake) StoragePools(ctx context.Context) ([]StoragePool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]StoragePool(nil), f.pools...), nil
+}
+
+// PhysicalDisk returns the simulated disk
+func (f *Fake) PhysicalDisk(disk int) (*PhysicalDisk, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.disks[disk]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the file specified")
+	}
+	pd := d.PhysicalDisk
+	return &pd, nil
+}
+
+// VolumeExtents returns the simulated extents, ErrNotLocal for drives without them
+func (f *Fake) VolumeExtents(drive string) ([]Extent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(drive)]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the path specified")
+	}
+	// A locked volume still has its place on the disk
+	if d.Err != nil && d.Err != ErrDriveLocked {
+		return nil, d.Err
+	}
+	if len(d.Extents) == 0 {
+		return nil, ErrNotLocal
+	}
+	return append([]Extent(nil), d.Extents...), nil
+}
+
+// VolumeInformation returns the simulated file system of a drive root or share
+func (f *Fake) VolumeInformation(root string) (*Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(root)]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	vol := &Volume{FileSystem: d.FileSystem, Flags: d.Flags, DevDrive: d.DevDrive}
+	if vol.FileSystem == "" {
+		vol.FileSystem = "NTFS"
+	}
+	return vol, nil
+}
+
+// MountedImage returns the simulated image a drive root is mounted from
+func (f *Fake) MountedImage(root string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(root)]
+	if !ok {
+		return "", fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return "", d.Err
+	}
+	return d.Image, nil
+}
+
+// FileRecords returns the simulated file records of a drive root
+func (f *Fake) FileRecords(root string) (used, max uint64, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(root)]
+	if !ok {
+		return 0, 0, fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return 0, 0, d.Err
+	}
+	return d.Files, d.MaxFiles, nil
+}
+
+// SpaceSavings returns the simulated savings of the drives that have them
+func (f *Fake) SpaceSavings(ctx context.Context) (map[string]uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	savings := make(map[string]uint64)
+	for drive, d := range f.drives {
+		if d.Savings > 0 {
+			savings[drive] = d.Savings
+		}
+	}
+	return savings, nil
+}
+
+// Fragmentation returns the simulated fragmentation of a drive root
+func (f *Fake) Fragmentation(ctx context.Context, drive string) (*Fragmentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(drive)]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	if d.Fragmentation == nil {
+		return nil, fmt.Errorf("access is denied")
+	}
+	frag := *d.Fragmentation
+	return &frag, nil
+}
+
+// Trim returns the simulated TRIM status of a drive root
+func (f *Fake) Trim(drive string) (supported, enabled bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(drive)]
+	if !ok {
+		return false, false, fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return false, false, d.Err
+	}
+	if d.Trim == nil {
+		return false, false, fmt.Errorf("the request is not supported")
+	}
+	return d.Trim.Supported, d.Trim.Enabled, nil
+}
+
+// LastOptimized returns the simulated optimizer runs of the drives
+func (f *Fake) LastOptimized(ctx context.Context) (map[string]time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	optimized := make(map[string]time.Time)
+	for key, d := range f.drives {
+		if d.Trim != nil && !d.Trim.LastOptimized.IsZero() {
+			optimized[key] = d.Trim.LastOptimized
+		}
+	}
+	return optimized, nil
+}
+
+// SetUpdates replaces the installed updates
+func (f *Fake) SetUpdates(updates []UpdateEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append([]UpdateEvent(nil), updates...)
+}
+
+// UpdateEvents returns the simulated updates installed after since
+func (f *Fake) UpdateEvents(ctx context.Context, since time.Time) ([]UpdateEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var events []UpdateEvent
+	for _, u := range f.updates {
+		if u.Time.After(since) {
+			events = append(events, u)
+		}
+	}
+	return events, nil
+}
+
+// SetOneDrive adds or replaces a OneDrive folder, e.g. to simulate files being downloaded
+func (f *Fake) SetOneDrive(folder OneDriveFolder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.oneDrive[folder.Folder] = folder
+}
+
+// OneDriveFolders returns the simulated OneDrive folders, sorted
+func (f *Fake) OneDriveFolders() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var folders []string
+	for folder := range f.oneDrive {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// OneDriveUsage returns the simulated OneDrive folder
+func (f *Fake) OneDriveUsage(ctx context.Context, folder string) (*OneDriveFolder, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	od, ok := f.oneDrive[folder]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the path specified")
+	}
+	return &od, nil
+}
+
+// CloudQuota returns the simulated drive named like the account, e.g. `onedrive:work\`,
+// after its delay. The token isn't checked.
+func (f *Fake) CloudQuota(ctx context.Context, account CloudAccount) (used, total uint64, err error) {
+	f.mu.Lock()
+	d, ok := f.drives[account.Drive()]
+	f.mu.Unlock()
+	if !ok {
+		return 0, 0, fmt.Errorf("404 Not Found: no such account")
+	}
+
+	select {
+	case <-time.After(d.Delay):
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+	if d.Err != nil {
+		return 0, 0, d.Err
+	}
+	return d.Total - d.Free, d.Total, nil
+}
+
+// CreateBenchFile returns a test file that takes as long as the drive's simulated
+// speeds and keeps no data
+func (f *Fake) CreateBenchFile(dir string) (BenchFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[volumeRoot(fakeKey(dir))]
+	if !ok {
+		return nil, fmt.Errorf("the system cannot find the path specified")
+	}
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	return &fakeBenchFile{drive: d}, nil
+}
+
+// fakeBenchFile paces reads and writes to the speeds of a FakeDrive
+type fakeBenchFile struct {
+	drive FakeDrive
+	// due is when the operations so far would have finished
+	due time.Time
+}
+
+// wait adds the time of one operation and sleeps once the caller is a
+// millisecond ahead of the drive, as sleeping for every 4 KiB block would be too coarse
+func (b *fakeBenchFile) wait(n int, speed, iops uint64) {
+	var d time.Duration
+	if n > benchRandBlock {
+		d = time.Duration(float64(n) / float64(cmp.Or(speed, 500<<20)) * float64(time.Second))
+	} else {
+		d = time.Second / time.Duration(cmp.Or(iops, 10000))
+	}
+	// Oversleeping is made up by the next operations, only an idle file starts over
+	if now := time.Now(); b.due.Before(now.Add(-100 * time.Millisecond)) {
+		b.due = now
+	}
+	b.due = b.due.Add(d)
+	if ahead := time.Until(b.due); ahead > time.Millisecond {
+		time.Sleep(ahead)
+	}
+}
+
+// ReadAt takes as long as the simulated read speed
+func (b *fakeBenchFile) ReadAt(p []byte, off int64) (int, error) {
+	b.wait(len(p), b.drive.ReadSpeed, b.drive.ReadIOPS)
+	return len(p), nil
+}
+
+// WriteAt takes as long as the simulated write speed
+func (b *fakeBenchFile) WriteAt(p []byte, off int64) (int, error) {
+	b.wait(len(p), b.drive.WriteSpeed, b.drive.WriteIOPS)
+	return len(p), nil
+}
+
+// Sync has nothing to flush
+func (b *fakeBenchFile) Sync() error { return nil }
+
+// Close has nothing to remove
+func (b *fakeBenchFile) Close() error { return nil }
+
+// fakeKey normalizes "c", "C:" and "C:\" to "C:\", and paths like NormalizePath
+func fakeKey(drive string) string {
+	return NormalizePath(drive)
+}
+
+// isRoot reports whether key is a drive root like "C:\"
+func isRoot(key string) bool {
+	return len(key) == 3 && key[1] == ':'
+}
+
+// SetDrive adds or replaces a drive, e.g. SetDrive(`C:\`, FakeDrive{...}).
+// A directory or UNC share like `\\nas\backups` can be set too.
+func (f *Fake) SetDrive(drive string, d FakeDrive) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drives[fakeKey(drive)] = d
+}
+
+// RemoveDrive simulates a drive being unplugged
+func (f *Fake) RemoveDrive(drive string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.drives, fakeKey(drive))
+}
+
+// Drives returns the simulated drive roots, sorted
+func (f *Fake) Drives() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var drives []string
+	for drive := range f.drives {
+		if isRoot(drive) {
+			drives = append(drives, drive)
+		}
+	}
+	sort.Strings(drives)
+	return drives
+}
+
+// LogicalDrives sets a bit per simulated drive letter
+func (f *Fake) LogicalDrives() uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var bits uint32
+	for drive := range f.drives {
+		if c := drive[0]; isRoot(drive) && c >= 'A' && c <= 'Z' {
+			bits |= 1 << (c - 'A')
+		}
+	}
+	return bits
+}
+
+// DriveType returns the simulated type, DRIVE_NO_ROOT_DIR for unknown drives
+func (f *Fake) DriveType(drive string) uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.drives[fakeKey(drive)]
+	if !ok {
+		return DRIVE_NO_ROOT_DIR
+	}
+	if d.Type == DRIVE_UNKNOWN {
+		return DRIVE_FIXED
+	}
+	return d.Type
+}
+
+// DiskFreeSpace returns the simulated sizes after the drive's delay.
+// A directory without its own entry reports the drive it is on.
+func (f *Fake) DiskFreeSpace(drive string) (free, total, volumeFree uint64, err error) {
+	f.mu.Lock()
+	key := fakeKey(drive)
+	d, ok := f.drives[key]
+	if !ok && len(key) > 3 && key[1] == ':' {
+		d, ok = f.drives[key[:3]]
+	}
+	f.mu.Unlock()
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("the system cannot find the path specified")
+	}
+
+	time.Sleep(d.Delay)
+	if d.Err != nil {
+		return 0, 0, 0, d.Err
+	}
+	volumeFree = d.VolumeFree
+	if volumeFree == 0 {
+		volumeFree = d.Free
+	}
+	return d.Free, d.Total, volumeFree, nil
+}
+
+// fakeDriveTypes maps the type names of fixture files to DRIVE_* constants
+var fakeDriveTypes = map[string]uint32{
+	"":          DRIVE_FIXED,
+	"fixed":     DRIVE_FIXED,
+	"removable": DRIVE_REMOVABLE,
+	"remote":    DRIVE_REMOTE,
+	"cdrom":     DRIVE_CDROM,
+	"ramdisk":   DRIVE_RAMDISK,
+}
+
+// LoadFake reads a simulation fixture:
+//
+//	{"drives": {"C:\\": {"type": "fixed", "total": "500GB", "free": "120GB", "volume_free": "150GB", "delay": "3s", "error": "..."}}}
+//
+// Sizes are parsed like ParseSize, delay like time.ParseDuration. Keys may also
+// be directories or UNC shares, which are only queried when monitored as paths,
+// and cloud accounts like "onedrive:work", which answer when configured.
+// "locked": true simulates a BitLocker volume that hasn't been unlocked.
+// A ReFS drive sets "file_system": "ReFS", which supports block cloning and
+// integrity streams, and the space deduplication saves it with "savings".
+// "dev_drive": true makes it a Dev Drive, which is always ReFS. "files" and
+// "max_files" are the file records in use and the most the volume can have.
+// "image": "vhdx" mounts the volume from a disk image, "iso", "vhd", "vhdx" or
+// "vhdset". Benchmarks run at "read_speed" and "write_speed" per second and
+// "read_iops" and "write_iops".
+// Free space fragmentation is read from
+// "fragmentation": {"free_extents": 4000, "largest_free": "2GB", "seek_penalty": true}
+// and the TRIM status from "trim": {"supported": true, "enabled": true, "last_optimized": "72h"},
+// where last_optimized is how long ago the storage optimizer ran.
+// A drive is placed on a physical disk with "disk": 0, after the drives before
+// it. Physical disks are listed by number with their bus, NVMe by default, and
+// for NVMe the fields of Health:
+//
+//	{"disks": {"0": {"model": "...", "size": "1TB", "bus": "NVMe", "percentage_used": 12, "available_spare": 100}}}
+//
+// Storage Spaces pools list their virtual disks:
+//
+//	{"pools": [{"name": "Pool", "size": "8TB", "allocated": "5TB", "virtual_disks": [{"name": "Data", "size": "10TB", "allocated": "2TB", "footprint": "4TB", "thin": true}]}]}
+//
+// OneDrive folders give the size of their files and how much of it is downloaded:
+//
+//	{"onedrive": [{"folder": "C:\\Users\\me\\OneDrive", "logical": "180GB", "on_disk": "42GB", "files": 12000, "online_only": 9000, "pinned": "5GB"}]}
+//
+// Installed Windows updates have the time they finished installing:
+//
+//	{"updates": [{"time": "2026-09-09T03:12:00Z", "title": "2026-09 Cumulative Update for Windows 11 (KB5043076)"}]}
+func LoadFake(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture struct {
+		Drives map[string]struct {
+			Type  string `json:"type"`
+			Total string `json:"total"`
+			Free  string `json:"free"`
+			// VolumeFree simulates a quota that leaves less than the volume has free
+			VolumeFree string `json:"volume_free"`
+			Delay      string `json:"delay"`
+			Error      string `json:"error"`
+			Locked     bool   `json:"locked"`
+			Disk       *int   `json:"disk"`
+			FileSystem string `json:"file_system"`
+			Savings    string `json:"savings"`
+			DevDrive   bool   `json:"dev_drive"`
+			Files      uint64 `json:"files"`
+			MaxFiles   uint64 `json:"max_files"`
+			Image      string `json:"image"`
+			// Benchmark speeds, sizes per second and operations per second
+			ReadSpeed  string `json:"read_speed"`
+			WriteSpeed string `json:"write_speed"`
+			ReadIOPS   uint64 `json:"read_iops"`
+			WriteIOPS  uint64 `json:"write_iops"`
+			// Fragmentation of the free space, which is taken from free
+			Fragmentation *struct {
+				FreeExtents uint64 `json:"free_extents"`
+				LargestFree string `json:"largest_free"`
+				SeekPenalty bool   `json:"seek_penalty"`
+			} `json:"fragmentation"`
+			Trim *struct {
+				Supported     bool   `json:"supported"`
+				Enabled       bool   `json:"enabled"`
+				LastOptimized string `json:"last_optimized"`
+			} `json:"trim"`
+		} `json:"drives"`
+		Disks map[string]struct {
+			Health
+			Bus  string `json:"bus"`
+			Size string `json:"size"`
+		} `json:"disks"`
+		Pools []struct {
+			Name         string `json:"name"`
+			Size         string `json:"size"`
+			Allocated    string `json:"allocated"`
+			VirtualDisks []struct {
+				Name      string `json:"name"`
+				Size      string `json:"size"`
+				Allocated string `json:"allocated"`
+				Footprint string `json:"footprint"`
+				Thin      bool   `json:"thin"`
+			} `json:"virtual_disks"`
+		} `json:"pools"`
+		OneDrive []struct {
+			Folder     string `json:"folder"`
+			Logical    string `json:"logical"`
+			OnDisk     string `json:"on_disk"`
+			Files      uint64 `json:"files"`
+			OnlineOnly uint64 `json:"online_only"`
+			Pinned     string `json:"pinned"`
+		} `json:"onedrive"`
+		Updates []UpdateEvent `json:"updates"`
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %v", path, err)
+	}
+
+	// Drives are placed on their disks in drive order
+	names := make([]string, 0, len(fixture.Drives))
+	for drive := range fixture.Drives {
+		names = append(names, drive)
+	}
+	sort.Strings(names)
+	offsets := make(map[int]uint64)
+
+	f := NewFake()
+	for _, drive := range names {
+		fd := fixture.Drives[drive]
+		if drive == "" {
+			return nil, fmt.Errorf("invalid fixture %s: empty drive name", path)
+		}
+		var d FakeDrive
+		var ok bool
+		if d.Type, ok = fakeDriveTypes[strings.ToLower(fd.Type)]; !ok {
+			return nil, fmt.Errorf("drive %s: unknown type %q", drive, fd.Type)
+		}
+		// A failing drive needs no sizes
+		if fd.Total != "" {
+			if d.Total, err = ParseSize(fd.Total); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid total: %v", drive, err)
+			}
+		}
+		if fd.Free != "" {
+			if d.Free, err = ParseSize(fd.Free); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid free: %v", drive, err)
+			}
+		}
+		if d.Free > d.Total {
+			return nil, fmt.Errorf("drive %s: free is larger than total", drive)
+		}
+		if fd.VolumeFree != "" {
+			if d.VolumeFree, err = ParseSize(fd.VolumeFree); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid volume_free: %v", drive, err)
+			}
+			if d.VolumeFree < d.Free {
+				return nil, fmt.Errorf("drive %s: volume_free is smaller than free", drive)
+			}
+		}
+		if fd.Delay != "" {
+			if d.Delay, err = time.ParseDuration(fd.Delay); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid delay: %v", drive, err)
+			}
+		}
+		if fd.Error != "" {
+			d.Err = errors.New(fd.Error)
+		}
+		if fd.Locked {
+			d.Err = ErrDriveLocked
+		}
+		d.FileSystem = fd.FileSystem
+		d.DevDrive = fd.DevDrive
+		if d.DevDrive && d.FileSystem == "" {
+			d.FileSystem = "ReFS"
+		}
+		if strings.EqualFold(d.FileSystem, "ReFS") {
+			d.Flags = FILE_SUPPORTS_BLOCK_REFCOUNTING | FILE_SUPPORTS_INTEGRITY_STREAMS
+		}
+		d.ReadIOPS, d.WriteIOPS = fd.ReadIOPS, fd.WriteIOPS
+		if fd.Files > fd.MaxFiles {
+			return nil, fmt.Errorf("drive %s: files is larger than max_files", drive)
+		}
+		d.Files, d.MaxFiles = fd.Files, fd.MaxFiles
+		switch fd.Image {
+		case "", ImageISO, ImageVHD, ImageVHDX, ImageVHDSet:
+			d.Image = fd.Image
+		default:
+			return nil, fmt.Errorf("drive %s: unknown image %q", drive, fd.Image)
+		}
+		if ff := fd.Fragmentation; ff != nil {
+			frag := &Fragmentation{FreeExtents: ff.FreeExtents, Free: d.Free, SeekPenalty: ff.SeekPenalty}
+			if frag.LargestFree, err = ParseSize(ff.LargestFree); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid largest_free: %v", drive, err)
+			}
+			d.Fragmentation = frag
+		}
+		if ft := fd.Trim; ft != nil {
+			d.Trim = &TrimStatus{Supported: ft.Supported, Enabled: ft.Enabled}
+			if ft.LastOptimized != "" {
+				ago, err := time.ParseDuration(ft.LastOptimized)
+				if err != nil {
+					return nil, fmt.Errorf("drive %s: invalid last_optimized: %v", drive, err)
+				}
+				d.Trim.LastOptimized = time.Now().Add(-ago).UTC()
+			}
+		}
+		if fd.ReadSpeed != "" {
+			if d.ReadSpeed, err = ParseSize(fd.ReadSpeed); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid read_speed: %v", drive, err)
+			}
+		}
+		if fd.WriteSpeed != "" {
+			if d.WriteSpeed, err = ParseSize(fd.WriteSpeed); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid write_speed: %v", drive, err)
+			}
+		}
+		if fd.Savings != "" {
+			if d.Savings, err = ParseSize(fd.Savings); err != nil {
+				return nil, fmt.Errorf("drive %s: invalid savings: %v", drive, err)
+			}
+		}
+		if fd.Disk != nil {
+			d.Extents = []Extent{{Disk: *fd.Disk, Offset: offsets[*fd.Disk], Length: d.Total}}
+			offsets[*fd.Disk] += d.Total
+		}
+		f.SetDrive(drive, d)
+	}
+	for number, fd := range fixture.Disks {
+		disk, err := strconv.Atoi(number)
+		if err != nil || disk < 0 {
+			return nil, fmt.Errorf("invalid fixture %s: disk %q is not a disk number", path, number)
+		}
+		d := FakeDisk{PhysicalDisk: PhysicalDisk{Model: fd.Model, Serial: fd.Serial, Bus: fd.Bus}}
+		if d.Bus == "" {
+			d.Bus = "NVMe"
+		}
+		if fd.Size != "" {
+			if d.Size, err = ParseSize(fd.Size); err != nil {
+				return nil, fmt.Errorf("disk %s: invalid size: %v", number, err)
+			}
+		}
+		if strings.EqualFold(d.Bus, "NVMe") {
+			h := fd.Health
+			d.Health = &h
+		}
+		f.SetDisk(disk, d)
+	}
+	var pools []StoragePool
+	for _, fp := range fixture.Pools {
+		p := StoragePool{Name: fp.Name}
+		if p.Size, err = ParseSize(fp.Size); err != nil {
+			return nil, fmt.Errorf("pool %s: invalid size: %v", fp.Name, err)
+		}
+		if p.Allocated, err = ParseSize(fp.Allocated); err != nil {
+			return nil, fmt.Errorf("pool %s: invalid allocated: %v", fp.Name, err)
+		}
+		for _, fv := range fp.VirtualDisks {
+			vd := VirtualDisk{Name: fv.Name, Thin: fv.Thin}
+			if vd.Size, err = ParseSize(fv.Size); err != nil {
+				return nil, fmt.Errorf("virtual disk %s: invalid size: %v", fv.Name, err)
+			}
+			if vd.Allocated, err = ParseSize(fv.Allocated); err != nil {
+				return nil, fmt.Errorf("virtual disk %s: invalid allocated: %v", fv.Name, err)
+			}
+			vd.Footprint = vd.Allocated
+			if fv.Footprint != "" {
+				if vd.Footprint, err = ParseSize(fv.Footprint); err != nil {
+					return nil, fmt.Errorf("virtual disk %s: invalid footprint: %v", fv.Name, err)
+				}
+			}
+			p.VirtualDisks = append(p.VirtualDisks, vd)
+		}
+		pools = append(pools, p)
+	}
+	f.SetPools(pools)
+	for _, fo := range fixture.OneDrive {
+		od := OneDriveFolder{Folder: fo.Folder, Files: fo.Files, OnlineOnly: fo.OnlineOnly}
+		if od.Logical, err = ParseSize(fo.Logical); err != nil {
+			return nil, fmt.Errorf("onedrive %s: invalid logical: %v", fo.Folder, err)
+		}
+		if od.OnDisk, err = ParseSize(fo.OnDisk); err != nil {
+			return nil, fmt.Errorf("onedrive %s: invalid on_disk: %v", fo.Folder, err)
+		}
+		if fo.Pinned != "" {
+			if od.Pinned, err = ParseSize(fo.Pinned); err != nil {
+				return nil, fmt.Errorf("onedrive %s: invalid pinned: %v", fo.Folder, err)
+			}
+		}
+		if od.OnDisk > od.Logical {
+			return nil, fmt.Errorf("onedrive %s: on_disk is larger than logical", fo.Folder)
+		}
+		f.SetOneDrive(od)
+	}
+	f.SetUpdates(fixture.Updates)
+	return f, nil
+}
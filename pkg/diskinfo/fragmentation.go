@@ -0,0 +1,106 @@
+package diskinfo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Fragmentation describes how scattered the free space of a volume is
+type Fragmentation struct {
+	Drive string `json:"drive"`
+	// FreeExtents is the number of runs of free clusters
+	FreeExtents uint64 `json:"free_extents"`
+	// LargestFree is the largest run of free clusters in bytes
+	LargestFree uint64 `json:"largest_free"`
+	// Free is the free space the bitmap accounts for in bytes
+	Free uint64 `json:"free"`
+	// SeekPenalty is set for volumes on spinning disks, where scattered free
+	// space makes writes slow
+	SeekPenalty bool `json:"seek_penalty,omitempty"`
+}
+
+// Percent returns the share of the free space outside the largest free extent,
+// the free space fragmentation defrag reports
+func (f Fragmentation) Percent() float64 {
+	if f.Free == 0 {
+		return 0
+	}
+	return float64(f.Free-min(f.LargestFree, f.Free)) / float64(f.Free) * 100
+}
+
+// Media returns "HDD" or "SSD"
+func (f Fragmentation) Media() string {
+	if f.SeekPenalty {
+		return "HDD"
+	}
+	return "SSD"
+}
+
+// freeRuns counts the runs of free clusters in a volume bitmap, where a set bit
+// is an allocated cluster, carried over from one chunk of the bitmap to the next
+type freeRuns struct {
+	extents, largest, free, current uint64
+}
+
+// add counts the first bits of bitmap, least significant bit of each byte first
+func (r *freeRuns) add(bitmap []byte, bits uint64) {
+	for i := uint64(0); i < bits; {
+		b := bitmap[i/8]
+		// Whole bytes are the common case on big volumes
+		if i%8 == 0 && bits-i >= 8 && (b == 0 || b == 0xff) {
+			if b == 0 {
+				if r.current == 0 {
+					r.extents++
+				}
+				r.current += 8
+				r.free += 8
+			} else {
+				r.end()
+			}
+			i += 8
+			continue
+		}
+		if b&(1<<(i%8)) == 0 {
+			if r.current == 0 {
+				r.extents++
+			}
+			r.current++
+			r.free++
+		} else {
+			r.end()
+		}
+		i++
+	}
+}
+
+// end closes the current run of free clusters
+func (r *freeRuns) end() {
+	r.largest = max(r.largest, r.current)
+	r.current = 0
+}
+
+// CollectFragmentation reads the free space fragmentation of the local drive
+// roots among disks. The volume bitmap is read in full, which takes a while on
+// large volumes and usually needs to run as administrator.
+func CollectFragmentation(ctx context.Context, disks []DiskInfo) (frags []Fragmentation, errs []error) {
+	sys := currentSystem()
+	for _, d := range disks {
+		if !isRoot(d.Drive) {
+			continue
+		}
+		if t := sys.DriveType(d.Drive); t != DRIVE_FIXED && t != DRIVE_REMOVABLE {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return frags, append(errs, err)
+		}
+		f, err := sys.Fragmentation(ctx, d.Drive)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", d.Drive, err))
+			continue
+		}
+		f.Drive = d.Drive
+		frags = append(frags, *f)
+	}
+	return frags, errs
+}
@@ -0,0 +1,101 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Volume bitmap and seek penalty queries of winioctl.h
+const (
+	FSCTL_GET_VOLUME_BITMAP          = 0x0009006f
+	StorageDeviceSeekPenaltyProperty = 7
+)
+
+var getDiskFreeSpaceW = kernel32.NewProc("GetDiskFreeSpaceW")
+
+// bitmapChunk is how much of the volume bitmap is read per call, 8M clusters
+const bitmapChunk = 1 << 20
+
+// Fragmentation walks the volume bitmap of FSCTL_GET_VOLUME_BITMAP. Reading the
+// bitmap takes a volume handle opened for reading, which needs administrator rights.
+func (windowsSystem) Fragmentation(ctx context.Context, drive string) (*Fragmentation, error) {
+	drive = NormalizePath(drive)
+	clusterSize, err := clusterSize(drive)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := syscall.UTF16PtrFromString(`\\.\` + strings.TrimSuffix(drive, `\`))
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(path, syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	// VOLUME_BITMAP_BUFFER: StartingLcn and BitmapSize, then a bit per cluster
+	const header = 16
+	le := binary.LittleEndian
+	buf := make([]byte, header+bitmapChunk)
+	var runs freeRuns
+	for lcn := uint64(0); ; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var input [8]byte
+		le.PutUint64(input[:], lcn)
+		var returned uint32
+		err := syscall.DeviceIoControl(h, FSCTL_GET_VOLUME_BITMAP, &input[0], uint32(len(input)),
+			&buf[0], uint32(len(buf)), &returned, nil)
+		if err != nil && err != syscall.ERROR_MORE_DATA {
+			return nil, fmt.Errorf("failed to read the volume bitmap: %v", err)
+		}
+		if returned < header {
+			return nil, fmt.Errorf("short volume bitmap")
+		}
+
+		// BitmapSize counts the clusters from StartingLcn to the end of the volume
+		start, remaining := le.Uint64(buf[0:]), le.Uint64(buf[8:])
+		bits := min(remaining, uint64(returned-header)*8)
+		runs.add(buf[header:], bits)
+		if err == nil || bits == 0 {
+			break
+		}
+		lcn = start + bits
+	}
+	runs.end()
+
+	return &Fragmentation{
+		FreeExtents: runs.extents,
+		LargestFree: runs.largest * clusterSize,
+		Free:        runs.free * clusterSize,
+		SeekPenalty: queryDeviceFlag(h, StorageDeviceSeekPenaltyProperty),
+	}, nil
+}
+
+// clusterSize calls GetDiskFreeSpaceW for the bytes per cluster of a drive root
+func clusterSize(root string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return 0, err
+	}
+	var sectorsPerCluster, bytesPerSector, freeClusters, totalClusters uint32
+	ret, _, callErr := getDiskFreeSpaceW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&sectorsPerCluster)),
+		uintptr(unsafe.Pointer(&bytesPerSector)),
+		uintptr(unsafe.Pointer(&freeClusters)),
+		uintptr(unsafe.Pointer(&totalClusters)),
+	)
+	if ret == 0 {
+		return 0, callErr
+	}
+	return uint64(sectorsPerCluster) * uint64(bytesPerSector), nil
+}
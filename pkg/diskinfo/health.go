@@ -0,0 +1,130 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// ErrNotNVMe is returned by System.NVMeHealth for disks that aren't NVMe
+var ErrNotNVMe = errors.New("not an NVMe disk")
+
+// Health is the NVMe SMART / health information of a physical disk
+type Health struct {
+	// Disk is the physical disk number, N for \\.\PhysicalDriveN
+	Disk   int    `json:"disk"`
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	// CriticalWarning holds the drive's own warning bits, 0 when all is well
+	CriticalWarning uint8 `json:"critical_warning,omitempty"`
+	// PercentageUsed estimates how much of the rated endurance is used, it may pass 100
+	PercentageUsed int `json:"percentage_used"`
+	// AvailableSpare is the spare capacity left in percent, the drive warns below SpareThreshold
+	AvailableSpare int `json:"available_spare"`
+	SpareThreshold int `json:"spare_threshold"`
+	// MediaErrors counts unrecovered data integrity errors
+	MediaErrors uint64 `json:"media_errors"`
+	// DataWritten is the lifetime host writes in bytes
+	DataWritten uint64 `json:"data_written"`
+}
+
+// Name identifies the disk across reboots and renumbering: its serial number,
+// or PhysicalDriveN without one
+func (h Health) Name() string {
+	if h.Serial != "" {
+		return h.Serial
+	}
+	return fmt.Sprintf("PhysicalDrive%d", h.Disk)
+}
+
+// Label names the disk for display, e.g. "Disk 0 (Samsung SSD 980 1TB)"
+func (h Health) Label() string {
+	if h.Model == "" {
+		return fmt.Sprintf("Disk %d", h.Disk)
+	}
+	return fmt.Sprintf("Disk %d (%s)", h.Disk, h.Model)
+}
+
+// nvmeHealthLogSize is the size of the SMART / health information log page
+const nvmeHealthLogSize = 512
+
+// NVMeDataUnit is the unit the log counts data read and written in, 1000 sectors of 512 bytes
+const NVMeDataUnit = 512000
+
+// ParseHealthLog decodes the NVMe SMART / health information log page (log
+// identifier 02h). Counters are 128-bit, only the low 64 bits are kept.
+func ParseHealthLog(log []byte) (*Health, error) {
+	if len(log) < nvmeHealthLogSize {
+		return nil, fmt.Errorf("health log is %d bytes, want %d", len(log), nvmeHealthLogSize)
+	}
+	return &Health{
+		CriticalWarning: log[0],
+		AvailableSpare:  int(log[3]),
+		SpareThreshold:  int(log[4]),
+		PercentageUsed:  int(log[5]),
+		MediaErrors:     binary.LittleEndian.Uint64(log[160:]),
+		DataWritten:     binary.LittleEndian.Uint64(log[48:]) * NVMeDataUnit,
+	}, nil
+}
+
+// HealthLimits are the thresholds disk health is checked against
+type HealthLimits struct {
+	// PercentageUsed warns at this share of the rated endurance, 0 disables it
+	PercentageUsed int `json:"percentage_used"`
+	// AvailableSpare warns at or below this spare percentage, 0 uses the drive's own threshold
+	AvailableSpare int `json:"available_spare"`
+	// MediaErrors warns at this many media errors, 0 disables it
+	MediaErrors uint64 `json:"media_errors"`
+}
+
+// SpareLimit returns the spare percentage h is warned at, the drive's own
+// threshold unless AvailableSpare is set
+func (l HealthLimits) SpareLimit(h Health) int {
+	if l.AvailableSpare > 0 {
+		return l.AvailableSpare
+	}
+	return h.SpareThreshold
+}
+
+// Problems describes each limit h breaks, nil for a healthy disk
+func (l HealthLimits) Problems(h Health) []string {
+	var problems []string
+	if h.CriticalWarning != 0 {
+		problems = append(problems, fmt.Sprintf("critical warning 0x%02x", h.CriticalWarning))
+	}
+	if l.PercentageUsed > 0 && h.PercentageUsed >= l.PercentageUsed {
+		problems = append(problems, fmt.Sprintf("%s of its endurance used", locale.Percent(float64(h.PercentageUsed), 0)))
+	}
+	if spare := l.SpareLimit(h); spare > 0 && h.AvailableSpare <= spare {
+		problems = append(problems, fmt.Sprintf("only %s spare left", locale.Percent(float64(h.AvailableSpare), 0)))
+	}
+	if l.MediaErrors > 0 && h.MediaErrors >= l.MediaErrors {
+		problems = append(problems, fmt.Sprintf("%d media errors", h.MediaErrors))
+	}
+	return problems
+}
+
+// CollectHealth reads the health log of each NVMe disk, in disk order.
+// Other disks are skipped, NVMe disks that fail are reported in errs.
+func CollectHealth(ctx context.Context) (health []Health, errs []error) {
+	sys := currentSystem()
+	for _, disk := range sys.PhysicalDisks() {
+		if err := ctx.Err(); err != nil {
+			return health, append(errs, err)
+		}
+		h, err := sys.NVMeHealth(disk)
+		if errors.Is(err, ErrNotNVMe) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("disk %d health: %v", disk, err))
+			continue
+		}
+		h.Disk = disk
+		health = append(health, *h)
+	}
+	return health, errs
+}
@@ -0,0 +1,151 @@
+package diskinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Storage query codes of winioctl.h
+const (
+	IOCTL_STORAGE_QUERY_PROPERTY = 0x002d1400
+
+	StorageDeviceProperty                 = 0
+	StorageDeviceProtocolSpecificProperty = 50
+	PropertyStandardQuery                 = 0
+
+	ProtocolTypeNvme          = 3
+	NVMeDataTypeLogPage       = 2
+	NVME_LOG_PAGE_HEALTH_INFO = 0x02
+	BusTypeNvme               = 17
+)
+
+// maxPhysicalDisks is how many \\.\PhysicalDriveN are tried, numbers can have gaps
+const maxPhysicalDisks = 32
+
+// openPhysicalDisk opens a disk for property queries only, which needs no administrator rights
+func openPhysicalDisk(disk int) (syscall.Handle, error) {
+	path, err := syscall.UTF16PtrFromString(fmt.Sprintf(`\\.\PhysicalDrive%d`, disk))
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	return syscall.CreateFile(path, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, 0, 0)
+}
+
+// queryDeviceFlag reads the BOOLEAN after Version and Size of a storage property
+// descriptor, such as DEVICE_SEEK_PENALTY_DESCRIPTOR or DEVICE_TRIM_DESCRIPTOR.
+// It is false when the device doesn't answer.
+func queryDeviceFlag(h syscall.Handle, property uint32) bool {
+	query := make([]byte, 12)
+	binary.LittleEndian.PutUint32(query[0:], property)
+	binary.LittleEndian.PutUint32(query[4:], PropertyStandardQuery)
+
+	var desc [12]byte
+	var returned uint32
+	err := syscall.DeviceIoControl(h, IOCTL_STORAGE_QUERY_PROPERTY, &query[0], uint32(len(query)),
+		&desc[0], uint32(len(desc)), &returned, nil)
+	return err == nil && returned >= 9 && desc[8] != 0
+}
+
+// PhysicalDisks returns the \\.\PhysicalDriveN that can be opened
+func (windowsSystem) PhysicalDisks() []int {
+	var disks []int
+	for disk := 0; disk < maxPhysicalDisks; disk++ {
+		h, err := openPhysicalDisk(disk)
+		if err != nil {
+			continue
+		}
+		syscall.CloseHandle(h)
+		disks = append(disks, disk)
+	}
+	return disks
+}
+
+// NVMeHealth reads the SMART / health log page through the storage protocol
+// pass-through of IOCTL_STORAGE_QUERY_PROPERTY
+func (windowsSystem) NVMeHealth(disk int) (*Health, error) {
+	h, err := openPhysicalDisk(disk)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	model, serial, bus, err := queryDeviceDescriptor(h)
+	if err != nil {
+		return nil, err
+	}
+	if bus != BusTypeNvme {
+		return nil, ErrNotNVMe
+	}
+
+	// STORAGE_PROPERTY_QUERY followed by STORAGE_PROTOCOL_SPECIFIC_DATA and room
+	// for the log; the reply puts STORAGE_PROTOCOL_DATA_DESCRIPTOR in its place
+	const header, protocolData = 8, 40
+	buf := make([]byte, header+protocolData+nvmeHealthLogSize)
+	le := binary.LittleEndian
+	le.PutUint32(buf[0:], StorageDeviceProtocolSpecificProperty)
+	le.PutUint32(buf[4:], PropertyStandardQuery)
+	le.PutUint32(buf[header:], ProtocolTypeNvme)
+	le.PutUint32(buf[header+4:], NVMeDataTypeLogPage)
+	le.PutUint32(buf[header+8:], NVME_LOG_PAGE_HEALTH_INFO)
+	le.PutUint32(buf[header+16:], protocolData)
+	le.PutUint32(buf[header+20:], nvmeHealthLogSize)
+
+	var returned uint32
+	err = syscall.DeviceIoControl(h, IOCTL_STORAGE_QUERY_PROPERTY, &buf[0], uint32(len(buf)),
+		&buf[0], uint32(len(buf)), &returned, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read health log: %v", err)
+	}
+
+	// The log follows the protocol data at the offset it gives
+	start := header + int(le.Uint32(buf[header+16:]))
+	if le.Uint32(buf[header+20:]) < nvmeHealthLogSize || start+nvmeHealthLogSize > len(buf) {
+		return nil, fmt.Errorf("short health log")
+	}
+	health, err := ParseHealthLog(buf[start : start+nvmeHealthLogSize])
+	if err != nil {
+		return nil, err
+	}
+	health.Model, health.Serial = model, serial
+	return health, nil
+}
+
+// queryDeviceDescriptor reads the product, serial number and bus type of STORAGE_DEVICE_DESCRIPTOR
+func queryDeviceDescriptor(h syscall.Handle) (model, serial string, bus uint32, err error) {
+	query := make([]byte, 12)
+	binary.LittleEndian.PutUint32(query[0:], StorageDeviceProperty)
+	binary.LittleEndian.PutUint32(query[4:], PropertyStandardQuery)
+
+	buf := make([]byte, 1024)
+	var returned uint32
+	err = syscall.DeviceIoControl(h, IOCTL_STORAGE_QUERY_PROPERTY, &query[0], uint32(len(query)),
+		&buf[0], uint32(len(buf)), &returned, nil)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to query device: %v", err)
+	}
+	if returned < 32 {
+		return "", "", 0, fmt.Errorf("short device descriptor")
+	}
+	buf = buf[:returned]
+
+	le := binary.LittleEndian
+	model = descriptorString(buf, le.Uint32(buf[16:]))
+	serial = descriptorString(buf, le.Uint32(buf[24:]))
+	return model, serial, le.Uint32(buf[28:]), nil
+}
+
+// descriptorString reads a NUL-terminated string at offset, 0 means none
+func descriptorString(buf []byte, offset uint32) string {
+	if offset == 0 || int(offset) >= len(buf) {
+		return ""
+	}
+	s := buf[offset:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(string(s))
+}
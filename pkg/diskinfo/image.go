@@ -0,0 +1,84 @@
+package diskinfo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of disk image a volume can be mounted from
+const (
+	ImageISO    = "iso"
+	ImageVHD    = "vhd"
+	ImageVHDX   = "vhdx"
+	ImageVHDSet = "vhdset"
+)
+
+// Images selects what happens to volumes mounted from disk images, which come
+// and go with whatever was double-clicked last
+type Images string
+
+const (
+	// ImagesInclude collects them like any other drive
+	ImagesInclude Images = "include"
+	// ImagesExclude leaves them out of the available drives
+	ImagesExclude Images = "exclude"
+	// ImagesGroup collects them but lists them apart from the other drives
+	ImagesGroup Images = "group"
+)
+
+// images is the process wide choice, set once at startup
+var images = ImagesInclude
+
+// SetImages selects what happens to mounted images from now on, an empty
+// value includes them
+func SetImages(mode Images) error {
+	switch Images(strings.ToLower(string(mode))) {
+	case "", ImagesInclude:
+		images = ImagesInclude
+	case ImagesExclude:
+		images = ImagesExclude
+	case ImagesGroup:
+		images = ImagesGroup
+	default:
+		return fmt.Errorf("invalid images %q, use include, exclude or group", mode)
+	}
+	return nil
+}
+
+// CurrentImages returns what happens to mounted images
+func CurrentImages() Images {
+	return images
+}
+
+// GroupImages splits disks into the drives and the mounted images when images
+// are grouped, otherwise all of them are drives. The order is kept.
+func GroupImages(disks []DiskInfo) (drives, mounted []DiskInfo) {
+	if images != ImagesGroup {
+		return disks, nil
+	}
+	for _, d := range disks {
+		if d.IsImage() {
+			mounted = append(mounted, d)
+		} else {
+			drives = append(drives, d)
+		}
+	}
+	return drives, mounted
+}
+
+// tagImage sets the image d is mounted from. A volume that doesn't answer
+// counts as one on a disk.
+func tagImage(sys System, d *DiskInfo) {
+	if image, err := sys.MountedImage(volumeRoot(d.Drive)); err == nil {
+		d.Image = image
+	}
+}
+
+// excludedImage reports whether a drive root is left out as a mounted image
+func excludedImage(sys System, drive string) bool {
+	if images != ImagesExclude {
+		return false
+	}
+	image, err := sys.MountedImage(drive)
+	return err == nil && image != ""
+}
@@ -0,0 +1,82 @@
+package diskinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	virtdisk                        = syscall.NewLazyDLL("virtdisk.dll")
+	getStorageDependencyInformation = virtdisk.NewProc("GetStorageDependencyInformation")
+)
+
+// Storage dependency query of virtdisk.h
+const (
+	GET_STORAGE_DEPENDENCY_FLAG_NONE  = 0
+	STORAGE_DEPENDENCY_INFO_VERSION_2 = 2
+
+	// ERROR_VIRTDISK_NOT_VIRTUAL_DISK is returned for volumes on a physical disk
+	ERROR_VIRTDISK_NOT_VIRTUAL_DISK syscall.Errno = 0xC03A0015
+)
+
+// virtualStorageTypes names the DeviceId values of VIRTUAL_STORAGE_TYPE
+var virtualStorageTypes = map[uint32]string{
+	1: ImageISO,
+	2: ImageVHD,
+	3: ImageVHDX,
+	4: ImageVHDSet,
+}
+
+// MountedImage asks virtdisk which virtual disk a drive root sits on, through
+// the root directory like isDevDrive so no admin rights are needed. Other
+// volumes, e.g. UNC shares, aren't images.
+func (windowsSystem) MountedImage(root string) (string, error) {
+	if !isRoot(root) {
+		return "", nil
+	}
+	rootPath, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return "", err
+	}
+	h, err := syscall.CreateFile(rootPath, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(h)
+
+	// STORAGE_DEPENDENCY_INFO: Version, NumberEntries and the entries, of
+	// which the first is the disk the volume is mounted from. The names the
+	// entries point to are written after them.
+	buf := make([]byte, 4096)
+	for {
+		binary.LittleEndian.PutUint32(buf, STORAGE_DEPENDENCY_INFO_VERSION_2)
+		var used uint32
+		ret, _, _ := getStorageDependencyInformation.Call(uintptr(h), GET_STORAGE_DEPENDENCY_FLAG_NONE,
+			uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+		switch errno := syscall.Errno(ret); {
+		case errno == 0:
+		case errno == ERROR_VIRTDISK_NOT_VIRTUAL_DISK:
+			return "", nil
+		case errno == syscall.ERROR_INSUFFICIENT_BUFFER && int(used) > len(buf):
+			buf = make([]byte, used)
+			continue
+		default:
+			return "", errno
+		}
+		break
+	}
+	if binary.LittleEndian.Uint32(buf[4:]) == 0 {
+		return "", nil
+	}
+	// STORAGE_DEPENDENCY_INFO_TYPE_2 starts with DependencyTypeFlags and
+	// ProviderSpecificFlags, then VIRTUAL_STORAGE_TYPE with DeviceId first
+	device := binary.LittleEndian.Uint32(buf[8+8:])
+	image, ok := virtualStorageTypes[device]
+	if !ok {
+		return "", fmt.Errorf("unknown virtual storage type %d", device)
+	}
+	return image, nil
+}
@@ -0,0 +1,71 @@
+package diskinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// OneDriveFolder is the Files On-Demand state of a OneDrive folder. Online-only
+// files are placeholders that take no local space until they are opened.
+type OneDriveFolder struct {
+	Folder string `json:"folder"`
+	// Logical is the size of all files, what the folder takes once fully downloaded
+	Logical uint64 `json:"logical"`
+	// OnDisk is the size of the files whose data is on the local drive
+	OnDisk uint64 `json:"on_disk"`
+	Files  uint64 `json:"files"`
+	// OnlineOnly counts the files whose data is only in the cloud
+	OnlineOnly uint64 `json:"online_only"`
+	// Pinned is the size of the files set to always keep on this device
+	Pinned uint64 `json:"pinned,omitempty"`
+}
+
+// Drive returns the drive root the folder is on, e.g. `C:\`
+func (f OneDriveFolder) Drive() string {
+	return volumeRoot(NormalizePath(f.Folder))
+}
+
+// OnlineOnlySize returns the size of the files that are only in the cloud
+func (f OneDriveFolder) OnlineOnlySize() uint64 {
+	if f.OnDisk > f.Logical {
+		return 0
+	}
+	return f.Logical - f.OnDisk
+}
+
+// Summary describes the folder on one line, e.g.
+// "180.0 GiB, 42.0 GiB on disk, 138.0 GiB in 9,000 online-only files"
+func (f OneDriveFolder) Summary() string {
+	s := fmt.Sprintf("%s, %s on disk, %s in %s online-only files", FormatBytes(f.Logical),
+		FormatBytes(f.OnDisk), FormatBytes(f.OnlineOnlySize()), locale.Int(f.OnlineOnly))
+	if f.Pinned > 0 {
+		s += fmt.Sprintf(", %s always kept", FormatBytes(f.Pinned))
+	}
+	return s
+}
+
+// CollectOneDrive sizes the OneDrive folders, or those the signed in accounts
+// sync to when folders is empty. Folders that can't be read are reported in errs.
+func CollectOneDrive(ctx context.Context, folders []string) (usage []OneDriveFolder, errs []error) {
+	sys := currentSystem()
+	if len(folders) == 0 {
+		folders = sys.OneDriveFolders()
+	}
+	for _, folder := range folders {
+		if err := ctx.Err(); err != nil {
+			return usage, append(errs, err)
+		}
+		folder = strings.TrimRight(folder, `\/`)
+		f, err := sys.OneDriveUsage(ctx, folder)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", folder, err))
+			continue
+		}
+		f.Folder = folder
+		usage = append(usage, *f)
+	}
+	return usage, errs
+}
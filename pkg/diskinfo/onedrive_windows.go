@@ -0,0 +1,76 @@
+package diskinfo
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Cloud file attributes of winnt.h
+const (
+	FILE_ATTRIBUTE_OFFLINE               = 0x00001000
+	FILE_ATTRIBUTE_PINNED                = 0x00080000
+	FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS = 0x00400000
+)
+
+// OneDriveFolders reads the folders the OneDrive client sets for the signed in
+// personal and work accounts
+func (windowsSystem) OneDriveFolders() []string {
+	var folders []string
+	for _, name := range []string{"OneDriveConsumer", "OneDriveCommercial", "OneDrive"} {
+		folder := os.Getenv(name)
+		if folder == "" || containsFold(folders, folder) {
+			continue
+		}
+		folders = append(folders, folder)
+	}
+	return folders
+}
+
+// OneDriveUsage walks the folder and sorts its files by their cloud attributes.
+// The entries come from the directory listing, so placeholders aren't downloaded.
+func (windowsSystem) OneDriveUsage(ctx context.Context, folder string) (*OneDriveFolder, error) {
+	f := &OneDriveFolder{}
+	err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable subfolders are left out, only the folder itself must open
+			if path == folder {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() || d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size := uint64(info.Size())
+		f.Files++
+		f.Logical += size
+
+		var attrs uint32
+		if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+			attrs = data.FileAttributes
+		}
+		if attrs&(FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS|FILE_ATTRIBUTE_OFFLINE) != 0 {
+			f.OnlineOnly++
+			return nil
+		}
+		f.OnDisk += size
+		if attrs&FILE_ATTRIBUTE_PINNED != 0 {
+			f.Pinned += size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
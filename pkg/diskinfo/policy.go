@@ -0,0 +1,73 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryPolicy is how long a drive query waits for an answer and how often it
+// is tried again, e.g. for an external disk that takes seconds to spin up
+type QueryPolicy struct {
+	// Timeout of each try, 0 uses QueryTimeout
+	Timeout time.Duration
+	// Retries is how many more tries follow a timeout or an error
+	Retries int
+}
+
+// RetryDelay is the pause before a failed query is tried again
+const RetryDelay = time.Second
+
+var (
+	policyMu      sync.RWMutex
+	defaultPolicy QueryPolicy
+	drivePolicies map[string]QueryPolicy
+)
+
+// SetQueryPolicies sets the policy of all drive queries, drives overrides it
+// by drive or monitored path
+func SetQueryPolicies(def QueryPolicy, drives map[string]QueryPolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	defaultPolicy = def
+	drivePolicies = make(map[string]QueryPolicy, len(drives))
+	for drive, p := range drives {
+		drivePolicies[strings.ToLower(NormalizePath(drive))] = p
+	}
+}
+
+// PolicyFor returns the query policy of a drive, with its timeout filled in
+func PolicyFor(drive string) QueryPolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	p, ok := drivePolicies[strings.ToLower(NormalizePath(drive))]
+	if !ok {
+		p = defaultPolicy
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = QueryTimeout
+	}
+	return p
+}
+
+// QueryDrive is GetDiskSpace with the timeout and retries of the drive's policy.
+// A locked drive isn't tried again, it won't answer until it is unlocked.
+func QueryDrive(ctx context.Context, drive string) (*DiskInfo, error) {
+	p := PolicyFor(drive)
+	for try := 0; ; try++ {
+		qctx, cancel := context.WithTimeout(ctx, p.Timeout)
+		info, err := GetDiskSpace(qctx, drive)
+		cancel()
+		if err == nil || try >= p.Retries || errors.Is(err, ErrDriveLocked) || ctx.Err() != nil {
+			return info, err
+		}
+
+		select {
+		case <-time.After(RetryDelay):
+		case <-ctx.Done():
+			return nil, &DriveError{Drive: drive, Err: ctx.Err()}
+		}
+	}
+}
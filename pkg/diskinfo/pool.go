@@ -0,0 +1,70 @@
+package diskinfo
+
+import "context"
+
+// StoragePool is a Storage Spaces pool and the virtual disks carved from it
+type StoragePool struct {
+	Name string `json:"name"`
+	// Size is the raw capacity of the pool's physical disks
+	Size uint64 `json:"size"`
+	// Allocated is the capacity taken by virtual disks, mirrors and parity included
+	Allocated    uint64        `json:"allocated"`
+	VirtualDisks []VirtualDisk `json:"virtual_disks,omitempty"`
+}
+
+// VirtualDisk is a storage space, the disk its volumes live on
+type VirtualDisk struct {
+	Name string `json:"name"`
+	// Size is the capacity the volumes see
+	Size uint64 `json:"size"`
+	// Allocated is how much of Size is backed by the pool, less than Size for a thin disk
+	Allocated uint64 `json:"allocated"`
+	// Footprint is the pool capacity the disk takes, Allocated times its copies
+	Footprint uint64 `json:"footprint"`
+	// Thin disks take pool capacity as they are written to
+	Thin bool `json:"thin,omitempty"`
+}
+
+// Free returns the pool capacity not allocated yet
+func (p StoragePool) Free() uint64 {
+	if p.Allocated > p.Size {
+		return 0
+	}
+	return p.Size - p.Allocated
+}
+
+// UsedPercent returns the allocated share of the pool
+func (p StoragePool) UsedPercent() float64 {
+	if p.Size == 0 {
+		return 0
+	}
+	return float64(p.Allocated) / float64(p.Size) * 100
+}
+
+// Growth returns how much more pool capacity the thin disks take once they are
+// written full, at their current footprint per allocated byte
+func (p StoragePool) Growth() uint64 {
+	var growth float64
+	for _, vd := range p.VirtualDisks {
+		if !vd.Thin || vd.Size <= vd.Allocated {
+			continue
+		}
+		copies := 1.0
+		if vd.Allocated > 0 && vd.Footprint > 0 {
+			copies = float64(vd.Footprint) / float64(vd.Allocated)
+		}
+		growth += float64(vd.Size-vd.Allocated) * copies
+	}
+	return uint64(growth)
+}
+
+// Overcommitted reports whether the thin disks can outgrow the pool, so it
+// fills up while their volumes still show free space
+func (p StoragePool) Overcommitted() bool {
+	return p.Growth() > p.Free()
+}
+
+// CollectPools returns the Storage Spaces pools, none when the machine has no pools
+func CollectPools(ctx context.Context) ([]StoragePool, error) {
+	return currentSystem().StoragePools(ctx)
+}
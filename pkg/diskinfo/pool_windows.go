@@ -0,0 +1,48 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// poolScript lists the pools other than the primordial one of unpooled disks,
+// with their virtual disks, as JSON in the shape of StoragePool
+const poolScript = `$ErrorActionPreference = 'Stop'
+$pools = @(Get-StoragePool -IsPrimordial $false | ForEach-Object {
+  [pscustomobject]@{
+    name = $_.FriendlyName
+    size = [uint64]$_.Size
+    allocated = [uint64]$_.AllocatedSize
+    virtual_disks = @($_ | Get-VirtualDisk | ForEach-Object {
+      [pscustomobject]@{
+        name = $_.FriendlyName
+        size = [uint64]$_.Size
+        allocated = [uint64]$_.AllocatedSize
+        footprint = [uint64]$_.FootprintOnPool
+        thin = ($_.ProvisioningType -eq 1)
+      }
+    })
+  }
+})
+ConvertTo-Json -InputObject $pools -Depth 4 -Compress`
+
+// StoragePools asks the Storage module of PowerShell, the storage management
+// WMI classes have no simpler API
+func (windowsSystem) StoragePools(ctx context.Context) ([]StoragePool, error) {
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", poolScript).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to list storage pools: %v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to list storage pools: %v", err)
+	}
+
+	var pools []StoragePool
+	if err := json.Unmarshal(out, &pools); err != nil {
+		return nil, fmt.Errorf("unexpected storage pool list: %v", err)
+	}
+	return pools, nil
+}
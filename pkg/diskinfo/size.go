@@ -0,0 +1,121 @@
+package diskinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// Units selects the multiple sizes are shown and parsed with
+type Units string
+
+// Size units. Windows shows binary sizes with decimal names, disk vendors
+// advertise decimal sizes.
+const (
+	// UnitsBinary counts in 1024s and shows KiB, MiB, GiB
+	UnitsBinary Units = "binary"
+	// UnitsDecimal counts in 1000s and shows KB, MB, GB
+	UnitsDecimal Units = "decimal"
+)
+
+// units is the process wide choice, set once at startup
+var units = UnitsBinary
+
+// SetUnits selects binary or decimal sizes for everything formatted or parsed
+// afterwards, an empty value selects binary
+func SetUnits(u Units) error {
+	switch Units(strings.ToLower(string(u))) {
+	case "", UnitsBinary:
+		units = UnitsBinary
+	case UnitsDecimal:
+		units = UnitsDecimal
+	default:
+		return fmt.Errorf("invalid units %q, use binary or decimal", u)
+	}
+	return nil
+}
+
+// CurrentUnits returns the units sizes are shown in
+func CurrentUnits() Units {
+	return units
+}
+
+// UnitBase returns the multiple between units, 1024 or 1000
+func UnitBase() uint64 {
+	if units == UnitsDecimal {
+		return 1000
+	}
+	return 1024
+}
+
+// Gigabyte returns the bytes in the GB or GiB sizes are shown in
+func Gigabyte() float64 {
+	b := float64(UnitBase())
+	return b * b * b
+}
+
+// GigabyteUnit names the unit of Gigabyte, "GiB" or "GB"
+func GigabyteUnit() string {
+	return UnitName('G')
+}
+
+// UnitName names a unit by its prefix letter, e.g. 'M' is "MiB" or "MB"
+func UnitName(prefix byte) string {
+	if units == UnitsDecimal {
+		return string(prefix) + "B"
+	}
+	return string(prefix) + "iB"
+}
+
+// FormatBytes formats bytes into human-readable string
+func FormatBytes(bytes uint64) string {
+	unit := UnitBase()
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return locale.Float(float64(bytes)/float64(div), 1) + " " + UnitName("KMGTPE"[exp])
+}
+
+// FormatChange formats a signed byte delta
+func FormatChange(delta float64) string {
+	if delta < 0 {
+		return "-" + FormatBytes(uint64(-delta))
+	}
+	return "+" + FormatBytes(uint64(delta))
+}
+
+// ParseSize parses a size such as "500MB" or "1.5GiB" into bytes. KiB, MiB,
+// GiB are always binary, KB, MB, GB follow the units setting.
+func ParseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := uint64(1)
+	base := UnitBase()
+	if strings.HasSuffix(s, "I") {
+		base = 1024
+		s = s[:len(s)-1]
+	}
+	if s != "" {
+		if i := strings.Index("KMGTPE", s[len(s)-1:]); i >= 0 {
+			for ; i >= 0; i-- {
+				multiplier *= base
+			}
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+
+	return uint64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,78 @@
+package diskinfo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// System is the operating system API behind the drive queries
+type System interface {
+	// LogicalDrives returns a bitmask of the drive letters in use, bit 0 is A:
+	LogicalDrives() uint32
+	// DriveType returns one of the DRIVE_* constants
+	DriveType(drive string) uint32
+	// DiskFreeSpace returns the bytes available to the caller, the total size and
+	// the free bytes of the whole volume, which are more when a quota applies.
+	// A locked BitLocker volume fails with ErrDriveLocked.
+	DiskFreeSpace(drive string) (free, total, volumeFree uint64, err error)
+	// PhysicalDisks returns the numbers of the physical disks, N for \\.\PhysicalDriveN
+	PhysicalDisks() []int
+	// NVMeHealth reads the health log of a physical disk, ErrNotNVMe for other disks
+	NVMeHealth(disk int) (*Health, error)
+	// PhysicalDisk returns the model, bus and size of a physical disk
+	PhysicalDisk(disk int) (*PhysicalDisk, error)
+	// VolumeExtents returns where a drive lives on the physical disks, ErrNotLocal
+	// for volumes without one
+	VolumeExtents(drive string) ([]Extent, error)
+	// StoragePools returns the Storage Spaces pools, none without Storage Spaces
+	StoragePools(ctx context.Context) ([]StoragePool, error)
+	// VolumeInformation returns the file system of a volume root like `C:\`
+	VolumeInformation(root string) (*Volume, error)
+	// MountedImage returns the kind of disk image a volume root is mounted
+	// from, one of the Image* constants, or "" for a volume on a disk
+	MountedImage(root string) (string, error)
+	// FileRecords returns how many file records an NTFS volume root uses and
+	// how many it can have
+	FileRecords(root string) (used, max uint64, err error)
+	// SpaceSavings returns the space deduplication saves by drive root
+	SpaceSavings(ctx context.Context) (map[string]uint64, error)
+	// Fragmentation reads how scattered the free space of a drive root is
+	Fragmentation(ctx context.Context, drive string) (*Fragmentation, error)
+	// Trim reports whether the disk under a drive root takes TRIM and whether the
+	// file system sends it
+	Trim(drive string) (supported, enabled bool, err error)
+	// LastOptimized returns when the storage optimizer last ran by drive root
+	LastOptimized(ctx context.Context) (map[string]time.Time, error)
+	// UpdateEvents returns the Windows updates that finished installing after since
+	UpdateEvents(ctx context.Context, since time.Time) ([]UpdateEvent, error)
+	// OneDriveFolders returns the folders OneDrive syncs for the signed in accounts
+	OneDriveFolders() []string
+	// OneDriveUsage sizes the files of a OneDrive folder, online-only ones apart
+	OneDriveUsage(ctx context.Context, folder string) (*OneDriveFolder, error)
+	// CloudQuota returns the used and total bytes of a cloud storage account
+	CloudQuota(ctx context.Context, account CloudAccount) (used, total uint64, err error)
+	// CreateBenchFile creates the temporary file of a benchmark in a drive or directory
+	CreateBenchFile(dir string) (BenchFile, error)
+}
+
+var (
+	systemMu sync.RWMutex
+	system   = defaultSystem()
+)
+
+// SetSystem replaces the API used by all queries and returns the previous one
+func SetSystem(s System) System {
+	systemMu.Lock()
+	defer systemMu.Unlock()
+	prev := system
+	system = s
+	return prev
+}
+
+// currentSystem returns the API in use
+func currentSystem() System {
+	systemMu.RLock()
+	defer systemMu.RUnlock()
+	return system
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package diskinfo
+
+// defaultSystem has no drives outside Windows, SetSystem installs a Fake
+func defaultSystem() System {
+	return NewFake()
+}
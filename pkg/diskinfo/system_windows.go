@@ -0,0 +1,68 @@
+package diskinfo
+
+import (
+	"context"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	getDiskFreeSpaceExW = kernel32.NewProc("GetDiskFreeSpaceExW")
+	getDriveTypeW       = kernel32.NewProc("GetDriveTypeW")
+	getLogicalDrives    = kernel32.NewProc("GetLogicalDrives")
+)
+
+// windowsSystem calls kernel32
+type windowsSystem struct{}
+
+// defaultSystem returns the real Windows API
+func defaultSystem() System {
+	return windowsSystem{}
+}
+
+// LogicalDrives calls GetLogicalDrives
+func (windowsSystem) LogicalDrives() uint32 {
+	ret, _, _ := getLogicalDrives.Call()
+	return uint32(ret)
+}
+
+// DriveType calls GetDriveTypeW
+func (windowsSystem) DriveType(drive string) uint32 {
+	drivePath, err := syscall.UTF16PtrFromString(drive)
+	if err != nil {
+		return DRIVE_UNKNOWN
+	}
+	ret, _, _ := getDriveTypeW.Call(uintptr(unsafe.Pointer(drivePath)))
+	return uint32(ret)
+}
+
+// FVE_E_LOCKED_VOLUME is returned for a BitLocker volume that is still locked
+const FVE_E_LOCKED_VOLUME syscall.Errno = 0x80310000
+
+// DiskFreeSpace calls GetDiskFreeSpaceExW
+func (windowsSystem) DiskFreeSpace(drive string) (free, total, volumeFree uint64, err error) {
+	drivePath, err := syscall.UTF16PtrFromString(drive)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	ret, _, callErr := getDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(drivePath)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&volumeFree)),
+	)
+	if ret == 0 {
+		if callErr == FVE_E_LOCKED_VOLUME {
+			return 0, 0, 0, ErrDriveLocked
+		}
+		return 0, 0, 0, callErr
+	}
+	return free, total, volumeFree, nil
+}
+
+// CloudQuota asks the provider's web API
+func (windowsSystem) CloudQuota(ctx context.Context, account CloudAccount) (used, total uint64, err error) {
+	return queryCloudQuota(ctx, account)
+}
@@ -0,0 +1,119 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotLocal is returned by System.VolumeExtents for volumes without a
+// physical disk, such as RAM disks
+var ErrNotLocal = errors.New("not on a local disk")
+
+// PhysicalDisk describes a physical disk
+type PhysicalDisk struct {
+	// Number is N for \\.\PhysicalDriveN
+	Number int    `json:"number"`
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	// Bus is how the disk is attached, e.g. NVMe, SATA or USB
+	Bus  string `json:"bus,omitempty"`
+	Size uint64 `json:"size"`
+}
+
+// Label names the disk for display, e.g. "Disk 0 (Samsung SSD 980 1TB)"
+func (d PhysicalDisk) Label() string {
+	return Health{Disk: d.Number, Model: d.Model}.Label()
+}
+
+// Extent is the part of a physical disk a volume takes, a volume spanning
+// several disks has one per disk
+type Extent struct {
+	Disk   int    `json:"disk"`
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+}
+
+// VolumeExtent is a drive's extent on one disk
+type VolumeExtent struct {
+	Drive string `json:"drive"`
+	Extent
+}
+
+// DiskLayout is a physical disk with the volumes on it, ordered by offset
+type DiskLayout struct {
+	PhysicalDisk
+	Volumes []VolumeExtent `json:"volumes"`
+}
+
+// Allocated returns the bytes taken by the volumes with a drive letter,
+// partitions without one such as EFI and recovery aren't counted
+func (l DiskLayout) Allocated() uint64 {
+	var total uint64
+	for _, v := range l.Volumes {
+		total += v.Length
+	}
+	return total
+}
+
+// Drives returns the drive letters on the disk, in offset order
+func (l DiskLayout) Drives() []string {
+	var drives []string
+	for _, v := range l.Volumes {
+		drives = append(drives, v.Drive)
+	}
+	return drives
+}
+
+// LoadTopology maps the available drives onto the physical disks, in disk
+// order. Drives that aren't on a local disk are returned in other, drives and
+// disks that fail to answer in errs.
+func LoadTopology(ctx context.Context) (layouts []DiskLayout, other []string, errs []error) {
+	sys := currentSystem()
+	byNumber := make(map[int]int)
+	for _, number := range sys.PhysicalDisks() {
+		if err := ctx.Err(); err != nil {
+			return layouts, other, append(errs, err)
+		}
+		disk, err := sys.PhysicalDisk(number)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("disk %d: %v", number, err))
+			continue
+		}
+		disk.Number = number
+		byNumber[number] = len(layouts)
+		layouts = append(layouts, DiskLayout{PhysicalDisk: *disk})
+	}
+
+	for _, drive := range AvailableDrives() {
+		if err := ctx.Err(); err != nil {
+			return layouts, other, append(errs, err)
+		}
+		extents, err := sys.VolumeExtents(drive)
+		if errors.Is(err, ErrNotLocal) {
+			other = append(other, drive)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, &DriveError{Drive: drive, Err: err})
+			continue
+		}
+		for _, e := range extents {
+			i, ok := byNumber[e.Disk]
+			if !ok {
+				// A disk that couldn't be opened still shows what is on it
+				i = len(layouts)
+				byNumber[e.Disk] = i
+				layouts = append(layouts, DiskLayout{PhysicalDisk: PhysicalDisk{Number: e.Disk}})
+			}
+			layouts[i].Volumes = append(layouts[i].Volumes, VolumeExtent{Drive: drive, Extent: e})
+		}
+	}
+
+	sort.Slice(layouts, func(i, j int) bool { return layouts[i].Number < layouts[j].Number })
+	for _, l := range layouts {
+		sort.Slice(l.Volumes, func(i, j int) bool { return l.Volumes[i].Offset < l.Volumes[j].Offset })
+	}
+	return layouts, other, errs
+}
@@ -0,0 +1,130 @@
+package diskinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Disk and volume query codes of winioctl.h
+const (
+	IOCTL_DISK_GET_DRIVE_GEOMETRY_EX     = 0x000700a0
+	IOCTL_VOLUME_GET_VOLUME_DISK_EXTENTS = 0x00560000
+
+	// ERROR_INVALID_FUNCTION is returned for volumes without disk extents, e.g. RAM disks
+	ERROR_INVALID_FUNCTION syscall.Errno = 1
+)
+
+// busTypes names the STORAGE_BUS_TYPE values of STORAGE_DEVICE_DESCRIPTOR
+var busTypes = map[uint32]string{
+	1:  "SCSI",
+	2:  "ATAPI",
+	3:  "ATA",
+	4:  "1394",
+	5:  "SSA",
+	6:  "Fibre Channel",
+	7:  "USB",
+	8:  "RAID",
+	9:  "iSCSI",
+	10: "SAS",
+	11: "SATA",
+	12: "SD",
+	13: "MMC",
+	14: "Virtual",
+	15: "File-backed virtual",
+	16: "Storage Spaces",
+	17: "NVMe",
+	18: "SCM",
+	19: "UFS",
+}
+
+// PhysicalDisk reads the device descriptor and size of \\.\PhysicalDriveN
+func (windowsSystem) PhysicalDisk(disk int) (*PhysicalDisk, error) {
+	h, err := openPhysicalDisk(disk)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	model, serial, bus, err := queryDeviceDescriptor(h)
+	if err != nil {
+		return nil, err
+	}
+
+	// DISK_GEOMETRY_EX is a 24 byte DISK_GEOMETRY followed by the disk size
+	buf := make([]byte, 256)
+	var returned uint32
+	err = syscall.DeviceIoControl(h, IOCTL_DISK_GET_DRIVE_GEOMETRY_EX, nil, 0,
+		&buf[0], uint32(len(buf)), &returned, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk size: %v", err)
+	}
+	if returned < 32 {
+		return nil, fmt.Errorf("short disk geometry")
+	}
+
+	name, ok := busTypes[bus]
+	if !ok {
+		name = fmt.Sprintf("bus %d", bus)
+	}
+	return &PhysicalDisk{
+		Number: disk,
+		Model:  model,
+		Serial: serial,
+		Bus:    name,
+		Size:   binary.LittleEndian.Uint64(buf[24:]),
+	}, nil
+}
+
+// VolumeExtents asks the volume of a drive letter for its disk extents
+func (windowsSystem) VolumeExtents(drive string) ([]Extent, error) {
+	drive = NormalizePath(drive)
+	if len(drive) != 3 || drive[1] != ':' {
+		return nil, ErrNotLocal
+	}
+	path, err := syscall.UTF16PtrFromString(`\\.\` + strings.TrimSuffix(drive, `\`))
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(path, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	// VOLUME_DISK_EXTENTS: the count padded to 8 bytes, then 24 byte DISK_EXTENTs
+	const header, extentSize = 8, 24
+	for n := 4; ; n *= 4 {
+		buf := make([]byte, header+n*extentSize)
+		var returned uint32
+		err = syscall.DeviceIoControl(h, IOCTL_VOLUME_GET_VOLUME_DISK_EXTENTS, nil, 0,
+			&buf[0], uint32(len(buf)), &returned, nil)
+		if err == syscall.ERROR_MORE_DATA && n < 1024 {
+			continue
+		}
+		if err == ERROR_INVALID_FUNCTION {
+			return nil, ErrNotLocal
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read volume extents: %v", err)
+		}
+
+		le := binary.LittleEndian
+		count := int(le.Uint32(buf))
+		if header+count*extentSize > int(returned) {
+			return nil, fmt.Errorf("short volume extents")
+		}
+		extents := make([]Extent, count)
+		for i := range extents {
+			e := buf[header+i*extentSize:]
+			extents[i] = Extent{
+				Disk:   int(le.Uint32(e)),
+				Offset: le.Uint64(e[8:]),
+				Length: le.Uint64(e[16:]),
+			}
+		}
+		return extents, nil
+	}
+}
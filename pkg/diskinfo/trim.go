@@ -0,0 +1,80 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/locale"
+)
+
+// TrimStatus is whether a drive passes deleted blocks on to its disk and when
+// Windows last optimized it
+type TrimStatus struct {
+	Drive string `json:"drive"`
+	// Supported is set when the disk under the drive takes TRIM, hard disks don't
+	Supported bool `json:"supported"`
+	// Enabled is false when DisableDeleteNotify turns TRIM off for the drive's file system
+	Enabled bool `json:"enabled"`
+	// LastOptimized is the last retrim or defragmentation by the storage
+	// optimizer, zero when the event log has none
+	LastOptimized time.Time `json:"last_optimized,omitzero"`
+}
+
+// Disabled reports whether the disk takes TRIM but the file system doesn't send it
+func (t TrimStatus) Disabled() bool {
+	return t.Supported && !t.Enabled
+}
+
+// Summary describes the status on one line, with LastOptimized in its own zone
+func (t TrimStatus) Summary() string {
+	var s string
+	switch {
+	case !t.Supported:
+		s = "not supported by the disk"
+	case !t.Enabled:
+		s = "disabled (DisableDeleteNotify)"
+	default:
+		s = "enabled"
+	}
+	if !t.LastOptimized.IsZero() {
+		s += ", last optimized " + locale.DateTime(t.LastOptimized)
+	} else if t.Supported {
+		s += ", never optimized"
+	}
+	return s
+}
+
+// CollectTrim reads the TRIM status of the local drive roots among disks
+func CollectTrim(ctx context.Context, disks []DiskInfo) (statuses []TrimStatus, errs []error) {
+	sys := currentSystem()
+	optimized, err := sys.LastOptimized(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, d := range disks {
+		if !isRoot(d.Drive) {
+			continue
+		}
+		if t := sys.DriveType(d.Drive); t != DRIVE_FIXED && t != DRIVE_REMOVABLE {
+			continue
+		}
+		supported, enabled, err := sys.Trim(d.Drive)
+		// A locked volume has no file system to ask yet
+		if errors.Is(err, ErrDriveLocked) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", d.Drive, err))
+			continue
+		}
+		statuses = append(statuses, TrimStatus{
+			Drive:         d.Drive,
+			Supported:     supported,
+			Enabled:       enabled,
+			LastOptimized: optimized[d.Drive],
+		})
+	}
+	return statuses, errs
+}
@@ -0,0 +1,113 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// StorageDeviceTrimProperty queries DEVICE_TRIM_DESCRIPTOR
+const StorageDeviceTrimProperty = 8
+
+// fileSystemKey holds the DisableDeleteNotify settings of fsutil
+const fileSystemKey = `SYSTEM\CurrentControlSet\Control\FileSystem`
+
+// Trim asks the disk under a volume whether it takes TRIM, and the file system
+// settings whether deletes are passed on to it
+func (s windowsSystem) Trim(drive string) (supported, enabled bool, err error) {
+	drive = NormalizePath(drive)
+	vol, err := s.VolumeInformation(drive)
+	if err == FVE_E_LOCKED_VOLUME {
+		return false, false, ErrDriveLocked
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	path, err := syscall.UTF16PtrFromString(`\\.\` + strings.TrimSuffix(drive, `\`))
+	if err != nil {
+		return false, false, err
+	}
+	h, err := syscall.CreateFile(path, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return false, false, err
+	}
+	defer syscall.CloseHandle(h)
+	supported = queryDeviceFlag(h, StorageDeviceTrimProperty)
+
+	value := "DisableDeleteNotification"
+	if strings.EqualFold(vol.FileSystem, "ReFS") {
+		value = "RefsDisableDeleteNotification"
+	}
+	// A missing value leaves TRIM on
+	disabled, err := registryDWORD(fileSystemKey, value)
+	return supported, err != nil || disabled == 0, nil
+}
+
+// registryDWORD reads a REG_DWORD value below HKEY_LOCAL_MACHINE
+func registryDWORD(key, value string) (uint32, error) {
+	keyPath, err := syscall.UTF16PtrFromString(key)
+	if err != nil {
+		return 0, err
+	}
+	name, err := syscall.UTF16PtrFromString(value)
+	if err != nil {
+		return 0, err
+	}
+	var k syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, keyPath, 0, syscall.KEY_READ, &k); err != nil {
+		return 0, err
+	}
+	defer syscall.RegCloseKey(k)
+
+	var typ, v uint32
+	n := uint32(unsafe.Sizeof(v))
+	if err := syscall.RegQueryValueEx(k, name, nil, &typ, (*byte)(unsafe.Pointer(&v)), &n); err != nil {
+		return 0, err
+	}
+	if typ != syscall.REG_DWORD {
+		return 0, fmt.Errorf("%s is not a DWORD", value)
+	}
+	return v, nil
+}
+
+// optimizedScript finds the latest "storage optimizer successfully completed"
+// event of each drive letter, which Get-WinEvent returns newest first
+const optimizedScript = `$ErrorActionPreference = 'Stop'
+$runs = @{}
+$events = Get-WinEvent -FilterHashtable @{ LogName = 'Application'; ProviderName = 'Microsoft-Windows-Defrag'; Id = 258 } -ErrorAction SilentlyContinue
+foreach ($e in $events) {
+  if ($e.Properties.Count -lt 2) { continue }
+  if ($e.Properties[1].Value -match '\(([A-Za-z]:)\)') {
+    $drive = $Matches[1].ToUpper()
+    if (-not $runs.ContainsKey($drive)) { $runs[$drive] = $e.TimeCreated.ToUniversalTime().ToString('o') }
+  }
+}
+ConvertTo-Json -InputObject $runs -Compress`
+
+// LastOptimized reads the storage optimizer's events from the Application log
+func (windowsSystem) LastOptimized(ctx context.Context) (map[string]time.Time, error) {
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", optimizedScript).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to read optimizer events: %v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to read optimizer events: %v", err)
+	}
+
+	var runs map[string]time.Time
+	if err := json.Unmarshal(out, &runs); err != nil {
+		return nil, fmt.Errorf("unexpected optimizer events: %v", err)
+	}
+	optimized := make(map[string]time.Time)
+	for drive, t := range runs {
+		optimized[NormalizePath(drive)] = t
+	}
+	return optimized, nil
+}
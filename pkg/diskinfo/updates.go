@@ -0,0 +1,24 @@
+package diskinfo
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// UpdateEvent is a Windows update or feature package that finished installing
+type UpdateEvent struct {
+	Time time.Time `json:"time"`
+	// Title names the update, e.g. "2026-09 Cumulative Update for Windows 11 (KB5043076)"
+	Title string `json:"title"`
+}
+
+// CollectUpdates reads the updates installed after since, oldest first
+func CollectUpdates(ctx context.Context, since time.Time) ([]UpdateEvent, error) {
+	events, err := currentSystem().UpdateEvents(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
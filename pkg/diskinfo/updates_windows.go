@@ -0,0 +1,53 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// updatesScript lists the successful installs the Windows Update client logs
+// to the System log (event 19) and the servicing stack to the Setup log (event
+// 2), which also covers updates installed offline or with DISM. %s is the
+// start time in UTC.
+const updatesScript = `$ErrorActionPreference = 'Stop'
+$since = [DateTime]::Parse('%s').ToLocalTime()
+$updates = @()
+$events = @(Get-WinEvent -FilterHashtable @{ LogName = 'System'; ProviderName = 'Microsoft-Windows-WindowsUpdateClient'; Id = 19; StartTime = $since } -ErrorAction SilentlyContinue)
+$events += @(Get-WinEvent -FilterHashtable @{ LogName = 'Setup'; Id = 2; StartTime = $since } -ErrorAction SilentlyContinue)
+foreach ($e in $events) {
+  if ($e.Properties.Count -lt 1) { continue }
+  $updates += @{ time = $e.TimeCreated.ToUniversalTime().ToString('o'); title = [string]$e.Properties[0].Value }
+}
+ConvertTo-Json -InputObject $updates -Compress`
+
+// UpdateEvents reads the update installations from the System and Setup logs
+func (windowsSystem) UpdateEvents(ctx context.Context, since time.Time) ([]UpdateEvent, error) {
+	script := fmt.Sprintf(updatesScript, since.UTC().Format(time.RFC3339))
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to read update events: %v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to read update events: %v", err)
+	}
+
+	var events []UpdateEvent
+	if err := json.Unmarshal(out, &events); err != nil {
+		return nil, fmt.Errorf("unexpected update events: %v", err)
+	}
+	// The servicing stack logs each package of an update, keep one per title
+	seen := make(map[string]bool)
+	kept := events[:0]
+	for _, e := range events {
+		if e.Title == "" || seen[e.Title] {
+			continue
+		}
+		seen[e.Title] = true
+		kept = append(kept, e)
+	}
+	return kept, nil
+}
@@ -0,0 +1,101 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// File system flags of GetVolumeInformation
+const (
+	FILE_SUPPORTS_INTEGRITY_STREAMS = 0x04000000
+	FILE_SUPPORTS_BLOCK_REFCOUNTING = 0x08000000
+)
+
+// Volume is the file system of a drive
+type Volume struct {
+	// FileSystem is the file system name, e.g. NTFS or ReFS
+	FileSystem string
+	// Flags are the FILE_* flags of GetVolumeInformation
+	Flags uint32
+	// DevDrive is set for a Dev Drive
+	DevDrive bool
+}
+
+// volumeRoot returns the root of the volume a path is on: `C:\` for
+// `C:\VMs\` and `\\nas\backups\` for `\\nas\backups\daily\`
+func volumeRoot(path string) string {
+	if len(path) >= 3 && path[1] == ':' {
+		return path[:3]
+	}
+	if strings.HasPrefix(path, `\\`) {
+		parts := strings.SplitN(path[2:], `\`, 3)
+		if len(parts) >= 2 {
+			return `\\` + parts[0] + `\` + parts[1] + `\`
+		}
+	}
+	return path
+}
+
+// tagVolume sets the file system of d from its volume. Drives that don't answer
+// stay untagged, the space query already succeeded.
+func tagVolume(sys System, d *DiskInfo) {
+	vol, err := sys.VolumeInformation(volumeRoot(d.Drive))
+	if err != nil {
+		return
+	}
+	d.FileSystem = vol.FileSystem
+	d.BlockClone = vol.Flags&FILE_SUPPORTS_BLOCK_REFCOUNTING != 0
+	d.IntegrityStreams = vol.Flags&FILE_SUPPORTS_INTEGRITY_STREAMS != 0
+	d.DevDrive = vol.DevDrive
+}
+
+// CollectFiles fills in the file records of the NTFS drive roots of disks.
+// Reading them takes administrator rights, so the first drive that is denied
+// stops it instead of each one failing in turn.
+func CollectFiles(ctx context.Context, disks []DiskInfo) (errs []error) {
+	sys := currentSystem()
+	for i, d := range disks {
+		if !isRoot(d.Drive) || !strings.EqualFold(d.FileSystem, "NTFS") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return append(errs, err)
+		}
+		used, max, err := sys.FileRecords(d.Drive)
+		if errors.Is(err, os.ErrPermission) {
+			return append(errs, fmt.Errorf("reading file records needs administrator rights: %v", err))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", d.Drive, err))
+			continue
+		}
+		disks[i].Files, disks[i].MaxFiles = used, max
+	}
+	return errs
+}
+
+// CollectSavings fills in the space deduplication saves on the ReFS drive roots
+// of disks. The savings are only queried when there is one.
+func CollectSavings(ctx context.Context, disks []DiskInfo) error {
+	var refs bool
+	for _, d := range disks {
+		refs = refs || (d.IsReFS() && isRoot(d.Drive))
+	}
+	if !refs {
+		return nil
+	}
+
+	savings, err := currentSystem().SpaceSavings(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range disks {
+		if disks[i].IsReFS() && isRoot(disks[i].Drive) {
+			disks[i].Savings = savings[disks[i].Drive]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package diskinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var getVolumeInformationW = kernel32.NewProc("GetVolumeInformationW")
+
+// Persistent volume state query of winioctl.h
+const (
+	FSCTL_QUERY_PERSISTENT_VOLUME_STATE = 0x0009023c
+	PERSISTENT_VOLUME_STATE_DEV_VOLUME  = 0x00002000
+)
+
+// VolumeInformation calls GetVolumeInformationW on a volume root and asks ReFS
+// volumes whether they are a Dev Drive
+func (windowsSystem) VolumeInformation(root string) (*Volume, error) {
+	rootPath, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uint32
+	name := make([]uint16, syscall.MAX_PATH+1)
+	ret, _, callErr := getVolumeInformationW.Call(
+		uintptr(unsafe.Pointer(rootPath)),
+		0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&flags)),
+		uintptr(unsafe.Pointer(&name[0])),
+		uintptr(len(name)),
+	)
+	if ret == 0 {
+		return nil, callErr
+	}
+	vol := &Volume{FileSystem: syscall.UTF16ToString(name), Flags: flags}
+	// Dev Drives are always ReFS, Windows before 11 23H2 doesn't know the flag
+	if strings.EqualFold(vol.FileSystem, "ReFS") {
+		vol.DevDrive, _ = isDevDrive(rootPath)
+	}
+	return vol, nil
+}
+
+// isDevDrive queries the persistent state of the volume through its root directory,
+// which needs no admin rights unlike opening the volume
+func isDevDrive(root *uint16) (bool, error) {
+	h, err := syscall.CreateFile(root, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.CloseHandle(h)
+
+	// FILE_FS_PERSISTENT_VOLUME_INFORMATION: VolumeFlags, FlagMask, Version, Reserved
+	var info [4]uint32
+	info[1] = PERSISTENT_VOLUME_STATE_DEV_VOLUME
+	info[2] = 1
+	var returned uint32
+	err = syscall.DeviceIoControl(h, FSCTL_QUERY_PERSISTENT_VOLUME_STATE,
+		(*byte)(unsafe.Pointer(&info[0])), uint32(unsafe.Sizeof(info)),
+		(*byte)(unsafe.Pointer(&info[0])), uint32(unsafe.Sizeof(info)), &returned, nil)
+	if err != nil {
+		return false, err
+	}
+	return info[0]&PERSISTENT_VOLUME_STATE_DEV_VOLUME != 0, nil
+}
+
+// savingsScript lists the volumes Data Deduplication saves space on, none when
+// the feature isn't installed
+const savingsScript = `$ErrorActionPreference = 'Stop'
+$volumes = @()
+if (Get-Command Get-DedupVolume -ErrorAction SilentlyContinue) {
+  $volumes = @(Get-DedupVolume | ForEach-Object {
+    [pscustomobject]@{ volume = $_.Volume; saved = [uint64]$_.SavedSpace }
+  })
+}
+ConvertTo-Json -InputObject $volumes -Compress`
+
+// SpaceSavings asks the Deduplication module of PowerShell. Block cloning has no
+// such count, cloned files just share their clusters.
+func (windowsSystem) SpaceSavings(ctx context.Context) (map[string]uint64, error) {
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", savingsScript).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to read space savings: %v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to read space savings: %v", err)
+	}
+
+	var volumes []struct {
+		Volume string `json:"volume"`
+		Saved  uint64 `json:"saved"`
+	}
+	if err := json.Unmarshal(out, &volumes); err != nil {
+		return nil, fmt.Errorf("unexpected space savings: %v", err)
+	}
+	savings := make(map[string]uint64)
+	for _, v := range volumes {
+		savings[NormalizePath(v.Volume)] = v.Saved
+	}
+	return savings, nil
+}
@@ -0,0 +1,46 @@
+package history
+
+import "time"
+
+// Alert statuses
+const (
+	// AlertFiring alerts are notified as they start and escalate
+	AlertFiring = "firing"
+	// AlertAcknowledged alerts aren't notified again until they recover
+	AlertAcknowledged = "acknowledged"
+	// AlertSilenced alerts are held back by a silence or maintenance window
+	AlertSilenced = "silenced"
+)
+
+// AlertState is what is known about an alert that is raised, kept in the store
+// so restarts don't notify of it again and its recovery reaches the sinks that
+// were notified
+type AlertState struct {
+	// Host is the machine raising the alert
+	Host  string `json:"host,omitempty"`
+	Kind  string `json:"kind"`
+	Drive string `json:"drive"`
+	// Status is AlertFiring, AlertAcknowledged or AlertSilenced
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Since is when the alert started firing
+	Since time.Time `json:"since"`
+	// LastNotified is when a sink was last notified of it, zero if none was
+	LastNotified time.Time `json:"last_notified,omitzero"`
+	// Sinks are the names of the sinks notified of it
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// alertStatesInUTC is inUTC for alert states
+func alertStatesInUTC(states []AlertState) []AlertState {
+	result := make([]AlertState, len(states))
+	for i, st := range states {
+		st.Since = st.Since.UTC()
+		if !st.LastNotified.IsZero() {
+			st.LastNotified = st.LastNotified.UTC()
+		}
+		result[i] = st
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// Baseline tags a snapshot with a name for later comparison
+type Baseline struct {
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// FindBaseline returns the baseline with the given name and the snapshot it tags
+func (h *History) FindBaseline(name string) (*Baseline, *Snapshot, error) {
+	for i := range h.Baselines {
+		b := &h.Baselines[i]
+		if b.Name != name {
+			continue
+		}
+		for j := range h.Snapshots {
+			if h.Snapshots[j].Timestamp.Equal(b.Timestamp) {
+				return b, &h.Snapshots[j], nil
+			}
+		}
+		return b, nil, fmt.Errorf("snapshot of baseline %q is no longer in history", name)
+	}
+	return nil, nil, fmt.Errorf("baseline %q not found", name)
+}
+
+// FindStoredBaseline is FindBaseline for a History loaded with LoadWindow:
+// a baseline snapshot outside the window is read from the store.
+func (h *History) FindStoredBaseline(ctx context.Context, s Store, name string) (*Baseline, *Snapshot, error) {
+	b, snapshot, err := h.FindBaseline(name)
+	if b == nil || snapshot != nil {
+		return b, snapshot, err
+	}
+	snapshots, qerr := s.Query(ctx, b.Timestamp, b.Timestamp.Add(time.Nanosecond), "")
+	if qerr != nil {
+		return b, nil, qerr
+	}
+	if len(snapshots) == 0 {
+		return b, nil, err
+	}
+	return b, &snapshots[0], nil
+}
+
+// BaselineDelta returns the change of free space of a disk since the baseline snapshot
+func BaselineDelta(base *Snapshot, disk diskinfo.DiskInfo) (float64, bool) {
+	for _, d := range base.Disks {
+		if d.Drive == disk.Drive {
+			return float64(disk.FreeSpace) - float64(d.FreeSpace), true
+		}
+	}
+	return 0, false
+}
+
+// NextBaseline returns the name of the baseline after current, or "" after the last one
+func (h *History) NextBaseline(current string) string {
+	if current == "" {
+		if len(h.Baselines) > 0 {
+			return h.Baselines[0].Name
+		}
+		return ""
+	}
+	for i, b := range h.Baselines {
+		if b.Name == current && i+1 < len(h.Baselines) {
+			return h.Baselines[i+1].Name
+		}
+	}
+	return ""
+}
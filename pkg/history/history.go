@@ -0,0 +1,414 @@
+// Package history stores disk snapshots over time and extracts per-drive series from them.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// SchemaVersion is the revision of the snapshot format. Fields are only ever
+// added, so older files read fine; it goes up with each added field.
+const SchemaVersion = 4
+
+// Snapshot represents a snapshot of all disks at a point in time
+type Snapshot struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Disks     []diskinfo.DiskInfo `json:"disks"`
+	Note      string              `json:"note,omitempty"`
+	// Host is the machine that took the snapshot, empty for snapshots recorded
+	// before hosts were, which count as the local machine's
+	Host string `json:"host,omitempty"`
+	// Health holds the NVMe health of the physical disks, when collected
+	Health []diskinfo.Health `json:"health,omitempty"`
+	// Pools holds the Storage Spaces pools, when collected
+	Pools []diskinfo.StoragePool `json:"pools,omitempty"`
+	// Benchmarks holds the results of a bench run
+	Benchmarks []diskinfo.Benchmark `json:"benchmarks,omitempty"`
+	// Fragmentation holds the free space fragmentation of the drives, when collected
+	Fragmentation []diskinfo.Fragmentation `json:"fragmentation,omitempty"`
+	// Trim holds the TRIM status of the drives, when collected
+	Trim []diskinfo.TrimStatus `json:"trim,omitempty"`
+	// OneDrive holds the Files On-Demand state of the OneDrive folders, when collected
+	OneDrive []diskinfo.OneDriveFolder `json:"onedrive,omitempty"`
+	// Updates holds the Windows updates installed since the previous snapshot
+	Updates []diskinfo.UpdateEvent `json:"updates,omitempty"`
+	// Runs are the drives whose reading the following snapshots of the host
+	// repeat, up to the time given, in a stored file; those snapshots leave the
+	// drive out. Loaded snapshots are expanded and have none.
+	Runs map[string]time.Time `json:"runs,omitempty"`
+}
+
+// History holds the full history of snapshots
+type History struct {
+	Snapshots []Snapshot `json:"snapshots"`
+	Baselines []Baseline `json:"baselines,omitempty"`
+	// AlertStates are the alerts raised by the latest snapshots of each host
+	AlertStates []AlertState `json:"alert_states,omitempty"`
+}
+
+// Point is a single measurement of one drive
+type Point struct {
+	Time  time.Time
+	Free  uint64
+	Total uint64
+	// Savings is the space deduplication saved at the time
+	Savings uint64
+	// Files and MaxFiles are the file records in use and the most the volume
+	// can have, 0 when not recorded
+	Files    uint64
+	MaxFiles uint64
+}
+
+// HealthPoint is a single health reading of one physical disk
+type HealthPoint struct {
+	Time time.Time
+	diskinfo.Health
+}
+
+// PoolPoint is a single reading of one Storage Spaces pool
+type PoolPoint struct {
+	Time time.Time
+	diskinfo.StoragePool
+}
+
+// BenchPoint is a single benchmark result of one drive
+type BenchPoint struct {
+	Time time.Time
+	diskinfo.Benchmark
+}
+
+// FragmentationPoint is a single fragmentation reading of one drive
+type FragmentationPoint struct {
+	Time time.Time
+	diskinfo.Fragmentation
+}
+
+// OneDrivePoint is a single reading of one OneDrive folder
+type OneDrivePoint struct {
+	Time time.Time
+	diskinfo.OneDriveFolder
+}
+
+// ErrHistoryCorrupt is returned when stored history can't be decoded
+var ErrHistoryCorrupt = errors.New("history is corrupt")
+
+// DefaultPath returns the path of the history file in the home directory
+func DefaultPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_history.json")
+}
+
+// Load loads history from a file, a missing file is an empty history. Snapshots
+// come in time order, whatever order they were appended in.
+func Load(path string) (*History, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &History{Snapshots: []Snapshot{}}, nil
+		}
+		return nil, err
+	}
+
+	var h History
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrHistoryCorrupt, path, err)
+	}
+	expandRuns(h.Snapshots)
+	sortByTime(h.Snapshots)
+
+	return &h, nil
+}
+
+// Save saves history to a file. The previous file is kept as path.bak, and the
+// new one is written and synced next to it first, so a failed write or a power
+// loss never replaces good data. Unchanged drive readings are stored once per
+// run, see compressRuns.
+func Save(path string, h *History) error {
+	stored := *h
+	stored.Snapshots = compressRuns(h.Snapshots)
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(path, path+".bak"); err != nil && !os.IsNotExist(err) {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeSynced writes a file and waits for it to reach the disk
+func writeSynced(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Drives returns all drives present in history, sorted
+func (h *History) Drives() []string {
+	driveMap := make(map[string]bool)
+	for _, snapshot := range h.Snapshots {
+		for _, disk := range snapshot.Disks {
+			driveMap[disk.Drive] = true
+		}
+	}
+
+	var drives []string
+	for drive := range driveMap {
+		drives = append(drives, drive)
+	}
+	sort.Strings(drives)
+
+	return drives
+}
+
+// Hosts returns the machines that took the snapshots, sorted. Untagged
+// snapshots count as local's.
+func (h *History) Hosts(local string) []string {
+	hostMap := make(map[string]bool)
+	for _, snapshot := range h.Snapshots {
+		hostMap[snapshotHost(snapshot, local)] = true
+	}
+
+	var hosts []string
+	for host := range hostMap {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// ForHost returns the snapshots taken by one machine with all baselines.
+// Untagged snapshots count as local's.
+func (h *History) ForHost(host, local string) *History {
+	result := &History{Snapshots: []Snapshot{}, Baselines: h.Baselines}
+	for _, snapshot := range h.Snapshots {
+		if snapshotHost(snapshot, local) == host {
+			result.Snapshots = append(result.Snapshots, snapshot)
+		}
+	}
+	return result
+}
+
+// LocalHost returns the name the snapshots of this machine are tagged with
+func LocalHost() string {
+	host, _ := os.Hostname()
+	return host
+}
+
+// snapshotHost returns the machine that took a snapshot, local when untagged
+func snapshotHost(s Snapshot, local string) string {
+	if s.Host == "" {
+		return local
+	}
+	return s.Host
+}
+
+// DevDrive reports whether the latest reading of a drive was a Dev Drive
+func (h *History) DevDrive(drive string) bool {
+	for i := len(h.Snapshots) - 1; i >= 0; i-- {
+		for _, disk := range h.Snapshots[i].Disks {
+			if disk.Drive == drive {
+				return disk.DevDrive
+			}
+		}
+	}
+	return false
+}
+
+// Trim returns the latest TRIM status of a drive, nil when none was recorded
+func (h *History) Trim(drive string) *diskinfo.TrimStatus {
+	for i := len(h.Snapshots) - 1; i >= 0; i-- {
+		for _, t := range h.Snapshots[i].Trim {
+			if t.Drive == drive {
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// Series extracts the measurements of a drive taken at or after since
+func (h *History) Series(drive string, since time.Time) []Point {
+	var points []Point
+	for _, snapshot := range h.Snapshots {
+		if snapshot.Timestamp.Before(since) {
+			continue
+		}
+		for _, disk := range snapshot.Disks {
+			if disk.Drive == drive {
+				points = append(points, Point{
+					Time:     snapshot.Timestamp,
+					Free:     disk.FreeSpace,
+					Total:    disk.TotalSpace,
+					Savings:  disk.Savings,
+					Files:    disk.Files,
+					MaxFiles: disk.MaxFiles,
+				})
+				break
+			}
+		}
+	}
+
+	return points
+}
+
+// HealthDisks returns the names of all physical disks with health readings,
+// ordered by their latest disk number
+func (h *History) HealthDisks() []string {
+	numbers := make(map[string]int)
+	for _, snapshot := range h.Snapshots {
+		for _, health := range snapshot.Health {
+			numbers[health.Name()] = health.Disk
+		}
+	}
+
+	var disks []string
+	for disk := range numbers {
+		disks = append(disks, disk)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		if numbers[disks[i]] != numbers[disks[j]] {
+			return numbers[disks[i]] < numbers[disks[j]]
+		}
+		return disks[i] < disks[j]
+	})
+
+	return disks
+}
+
+// HealthSeries extracts the health readings of a physical disk by its Name
+func (h *History) HealthSeries(name string) []HealthPoint {
+	var points []HealthPoint
+	for _, snapshot := range h.Snapshots {
+		for _, health := range snapshot.Health {
+			if health.Name() == name {
+				points = append(points, HealthPoint{Time: snapshot.Timestamp, Health: health})
+				break
+			}
+		}
+	}
+
+	return points
+}
+
+// Pools returns the names of all Storage Spaces pools in history, sorted
+func (h *History) Pools() []string {
+	poolMap := make(map[string]bool)
+	for _, snapshot := range h.Snapshots {
+		for _, pool := range snapshot.Pools {
+			poolMap[pool.Name] = true
+		}
+	}
+
+	var pools []string
+	for pool := range poolMap {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+
+	return pools
+}
+
+// BenchmarkSeries extracts the benchmark results of a drive, oldest first
+func (h *History) BenchmarkSeries(drive string) []BenchPoint {
+	var points []BenchPoint
+	for _, snapshot := range h.Snapshots {
+		for _, b := range snapshot.Benchmarks {
+			if b.Drive == drive {
+				points = append(points, BenchPoint{Time: snapshot.Timestamp, Benchmark: b})
+				break
+			}
+		}
+	}
+
+	return points
+}
+
+// FragmentationSeries extracts the fragmentation readings of a drive, oldest first
+func (h *History) FragmentationSeries(drive string) []FragmentationPoint {
+	var points []FragmentationPoint
+	for _, snapshot := range h.Snapshots {
+		for _, f := range snapshot.Fragmentation {
+			if f.Drive == drive {
+				points = append(points, FragmentationPoint{Time: snapshot.Timestamp, Fragmentation: f})
+				break
+			}
+		}
+	}
+
+	return points
+}
+
+// OneDriveFolders returns the OneDrive folders in history, sorted
+func (h *History) OneDriveFolders() []string {
+	seen := make(map[string]bool)
+	var folders []string
+	for _, snapshot := range h.Snapshots {
+		for _, f := range snapshot.OneDrive {
+			if !seen[f.Folder] {
+				seen[f.Folder] = true
+				folders = append(folders, f.Folder)
+			}
+		}
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// OneDriveSeries extracts the readings of a OneDrive folder, oldest first
+func (h *History) OneDriveSeries(folder string) []OneDrivePoint {
+	var points []OneDrivePoint
+	for _, snapshot := range h.Snapshots {
+		for _, f := range snapshot.OneDrive {
+			if f.Folder == folder {
+				points = append(points, OneDrivePoint{Time: snapshot.Timestamp, OneDriveFolder: f})
+				break
+			}
+		}
+	}
+
+	return points
+}
+
+// PoolSeries extracts the readings of a pool taken at or after since
+func (h *History) PoolSeries(name string, since time.Time) []PoolPoint {
+	var points []PoolPoint
+	for _, snapshot := range h.Snapshots {
+		if snapshot.Timestamp.Before(since) {
+			continue
+		}
+		for _, pool := range snapshot.Pools {
+			if pool.Name == name {
+				points = append(points, PoolPoint{Time: snapshot.Timestamp, StoragePool: pool})
+				break
+			}
+		}
+	}
+
+	return points
+}
@@ -0,0 +1,122 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// JournalPath returns the journal file kept next to a store's file
+func JournalPath(storePath string) string {
+	return storePath + ".journal"
+}
+
+// Journal is an append-only file of snapshots that haven't been folded into a
+// Store yet. Each snapshot is one JSON line, synced to disk before Write
+// returns, so a crash loses at most the line being written, which is skipped
+// when reading.
+type Journal struct {
+	path string
+}
+
+// OpenJournal returns the journal at path, the file is created on the first write
+func OpenJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Write appends a snapshot and syncs the file
+func (j *Journal) Write(snapshot Snapshot) error {
+	data, err := json.Marshal(inUTC([]Snapshot{snapshot})[0])
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// A line cut short by a crash is ended first, so it doesn't take this one with it
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Snapshots reads the journaled snapshots in the order written, skipping lines
+// cut short by a crash. A missing journal is empty.
+func (j *Journal) Snapshots() ([]Snapshot, error) {
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var snapshot Snapshot
+			if json.Unmarshal(line, &snapshot) == nil {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		if err == io.EOF {
+			return snapshots, nil
+		}
+	}
+}
+
+// Fold appends the journaled snapshots to s and removes the journal. Snapshots
+// s already has, from a fold cut short before the journal was removed, aren't
+// added twice. It returns how many were appended.
+func (j *Journal) Fold(ctx context.Context, s Store) (int, error) {
+	snapshots, err := j.Snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []Snapshot
+	if len(snapshots) > 0 {
+		existing, err := s.Query(ctx, snapshots[0].Timestamp, time.Time{}, "")
+		if err != nil {
+			return 0, err
+		}
+		stored := make(map[int64]bool)
+		for _, snapshot := range existing {
+			stored[snapshot.Timestamp.UnixNano()] = true
+		}
+		for _, snapshot := range snapshots {
+			if !stored[snapshot.Timestamp.UnixNano()] {
+				pending = append(pending, snapshot)
+			}
+		}
+	}
+	if len(pending) > 0 {
+		if err := s.Append(ctx, pending...); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return len(pending), err
+	}
+	return len(pending), nil
+}
@@ -0,0 +1,65 @@
+package history
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	snapshots := []Snapshot{
+		{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+		{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`C:\`, 40)}},
+		{Timestamp: at(2), Disks: []diskinfo.DiskInfo{disk(`C:\`, 30)}},
+	}
+	s, path := openTestStore(t, BackendJSON, ShardNone)
+	defer s.Close()
+	j := OpenJournal(JournalPath(path))
+
+	if got, err := j.Snapshots(); err != nil || got != nil {
+		t.Fatalf("Snapshots of a missing journal = %v, %v, want none", got, err)
+	}
+	if n, err := j.Fold(ctx, s); err != nil || n != 0 {
+		t.Fatalf("Fold of a missing journal = %d, %v, want 0", n, err)
+	}
+
+	for _, snapshot := range snapshots[:2] {
+		if err := j.Write(snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A crash cuts the next line short, the write after it starts a line of its own
+	f, err := os.OpenFile(JournalPath(path), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"timestamp": "2026-09-01T0`)
+	f.Close()
+	if err := j.Write(snapshots[2]); err != nil {
+		t.Fatal(err)
+	}
+	got, err := j.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareSnapshots(t, got, snapshots)
+
+	// A fold cut short stored the first snapshot without removing the journal
+	if err := s.Append(ctx, snapshots[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := j.Fold(ctx, s); err != nil || n != 2 {
+		t.Errorf("Fold = %d, %v, want 2 appended", n, err)
+	}
+	if _, err := os.Stat(JournalPath(path)); !os.IsNotExist(err) {
+		t.Errorf("journal left after the fold: %v", err)
+	}
+	h, err := s.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareSnapshots(t, h.Snapshots, snapshots)
+}
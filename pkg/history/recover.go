@@ -0,0 +1,227 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Where Recover found the snapshots it kept
+const (
+	RecoveredReadable = "readable part"
+	RecoveredBackup   = "backup"
+	RecoveredNothing  = "nothing"
+)
+
+// Recovery describes what Recover did
+type Recovery struct {
+	// MovedTo is where the corrupt file was moved
+	MovedTo string
+	// Source is RecoveredReadable, RecoveredBackup or RecoveredNothing
+	Source    string
+	Snapshots int
+}
+
+// String summarizes the recovery for a warning
+func (r *Recovery) String() string {
+	if r.Source == RecoveredNothing {
+		return fmt.Sprintf("history was corrupt and nothing could be recovered, the damaged file was moved to %s", r.MovedTo)
+	}
+	return fmt.Sprintf("history was corrupt, kept %d snapshots from its %s; the damaged file was moved to %s",
+		r.Snapshots, r.Source, r.MovedTo)
+}
+
+// Recover moves a corrupt history file aside and rebuilds it from the snapshots
+// that can still be read from it, or from the backup Save keeps, whichever has
+// more. An empty path uses the backend's default file.
+func Recover(ctx context.Context, backend, path string) (*Recovery, error) {
+	b, ok := backends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+	if path == "" {
+		path = BackendPath(backend)
+	}
+
+	rec := &Recovery{Source: RecoveredNothing}
+	h := &History{}
+	if readable, err := readRecoverable(backend, path); err == nil && len(readable.Snapshots) > 0 {
+		h, rec.Source = readable, RecoveredReadable
+	}
+	if backup, err := readRecoverable(backend, path+".bak"); err == nil && len(backup.Snapshots) > len(h.Snapshots) {
+		h, rec.Source = backup, RecoveredBackup
+	}
+	rec.Snapshots = len(h.Snapshots)
+
+	// Earlier corrupt files are never overwritten
+	rec.MovedTo = fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	for i := 2; ; i++ {
+		if _, err := os.Stat(rec.MovedTo); os.IsNotExist(err) {
+			break
+		}
+		rec.MovedTo = fmt.Sprintf("%s.corrupt-%s-%d", path, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(path, rec.MovedTo); err != nil {
+		return nil, fmt.Errorf("failed to move the corrupt history aside: %v", err)
+	}
+
+	store, err := b.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	if err := store.Append(ctx, h.Snapshots...); err != nil {
+		return nil, err
+	}
+	if err := store.SaveBaselines(ctx, h.Baselines); err != nil {
+		return nil, err
+	}
+	if err := store.SaveAlertStates(ctx, h.AlertStates); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// readRecoverable reads what is still intact in a history file of a backend
+func readRecoverable(backend, path string) (*History, error) {
+	// Opening a missing SQLite or bbolt file would create it
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	switch backend {
+	case BackendJSON:
+		return readJSONPrefix(path)
+	case BackendJSONL:
+		return readJSONLLines(path)
+	case BackendBolt:
+		return readBoltValues(path)
+	default:
+		// SQLite can't be read around the damage, only its backup helps
+		s, err := openSQLiteStore(path)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		return s.Load(context.Background())
+	}
+}
+
+// readJSONPrefix decodes a JSON history up to the first damage, typically a
+// write cut short. Baselines and alert states are only kept if they come before it.
+func readJSONPrefix(path string) (*History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &History{}
+	var runs runExpander
+	dec := json.NewDecoder(bufio.NewReader(f))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return h, nil
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return h, nil
+		}
+		switch key {
+		case "snapshots":
+			if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+				return h, nil
+			}
+			for dec.More() {
+				var snapshot Snapshot
+				if err := dec.Decode(&snapshot); err != nil {
+					return h, nil
+				}
+				runs.expand(&snapshot)
+				h.Snapshots = append(h.Snapshots, snapshot)
+			}
+			if _, err := dec.Token(); err != nil {
+				return h, nil
+			}
+		case "baselines":
+			var baselines []Baseline
+			if err := dec.Decode(&baselines); err != nil {
+				return h, nil
+			}
+			h.Baselines = baselines
+		case "alert_states":
+			var states []AlertState
+			if err := dec.Decode(&states); err != nil {
+				return h, nil
+			}
+			h.AlertStates = states
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return h, nil
+			}
+		}
+	}
+	return h, nil
+}
+
+// readJSONLLines reads a JSONL history, skipping the lines that don't decode
+func readJSONLLines(path string) (*History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &History{}
+	var runs runExpander
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec jsonlRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		if rec.Snapshot != nil {
+			runs.expand(rec.Snapshot)
+		}
+		rec.apply(h)
+	}
+	return h, scanner.Err()
+}
+
+// readBoltValues reads a bbolt history, skipping the values that don't decode
+func readBoltValues(path string) (*History, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	h := &History{}
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(boltSnapshots); b != nil {
+			b.ForEach(func(k, v []byte) error {
+				var snapshot Snapshot
+				if json.Unmarshal(v, &snapshot) == nil {
+					h.Snapshots = append(h.Snapshots, snapshot)
+				}
+				return nil
+			})
+		}
+		if b := tx.Bucket(boltMeta); b != nil {
+			if v := b.Get(boltBaselines); v != nil {
+				json.Unmarshal(v, &h.Baselines)
+			}
+			if v := b.Get(boltAlertStates); v != nil {
+				json.Unmarshal(v, &h.AlertStates)
+			}
+		}
+		return nil
+	})
+	return h, err
+}
@@ -0,0 +1,108 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	snapshots := []Snapshot{
+		{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+		{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`C:\`, 40)}},
+		{Timestamp: at(2), Disks: []diskinfo.DiskInfo{disk(`C:\`, 30)}},
+	}
+
+	tests := []struct {
+		name    string
+		backend string
+		// corrupt damages the file of a store holding the snapshots
+		corrupt func(t *testing.T, path string)
+		source  string
+		kept    int
+	}{
+		{name: "json cut short", backend: BackendJSON, corrupt: func(t *testing.T, path string) {
+			data := readFile(t, path)
+			writeFile(t, path, data[:bytes.LastIndex(data, []byte(`"timestamp"`))])
+		}, source: RecoveredReadable, kept: 2},
+		{name: "json from backup", backend: BackendJSON, corrupt: func(t *testing.T, path string) {
+			writeFile(t, path, []byte(`{"snapshots": [`))
+		}, source: RecoveredBackup, kept: 2},
+		{name: "jsonl bad line", backend: BackendJSONL, corrupt: func(t *testing.T, path string) {
+			writeFile(t, path, append(readFile(t, path), "{\"snapshot\": \n"...))
+		}, source: RecoveredReadable, kept: 3},
+		{name: "bolt bad value", backend: BackendBolt, corrupt: func(t *testing.T, path string) {
+			db, err := bolt.Open(path, 0644, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			err = db.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket(boltSnapshots).Put([]byte("garbage"), []byte("{"))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, source: RecoveredReadable, kept: 3},
+		{name: "nothing left", backend: BackendSQLite, corrupt: func(t *testing.T, path string) {
+			writeFile(t, path, []byte("not a database"))
+		}, source: RecoveredNothing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, path := openTestStore(t, tt.backend, ShardNone)
+			// Two appends leave the first two snapshots in the backup of a JSON history
+			if err := s.Append(ctx, snapshots[:2]...); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Append(ctx, snapshots[2]); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+			tt.corrupt(t, path)
+
+			rec, err := Recover(ctx, tt.backend, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Source != tt.source || rec.Snapshots != tt.kept {
+				t.Errorf("Recover = %s, want %d snapshots from the %s", rec, tt.kept, tt.source)
+			}
+			if _, err := os.Stat(rec.MovedTo); err != nil {
+				t.Errorf("corrupt file not kept: %v", err)
+			}
+
+			s, err = Open(tt.backend, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			h, err := s.Load(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareSnapshots(t, h.Snapshots, snapshots[:tt.kept])
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
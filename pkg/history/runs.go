@@ -0,0 +1,125 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// runKey is a drive of one host
+type runKey struct {
+	host, drive string
+}
+
+// compressRuns returns the snapshots as the JSON and JSONL files store them:
+// a drive whose reading and place among the disks don't change over
+// consecutive snapshots of its host is only kept in the first, whose Runs say
+// until when it stays the same, and left out of the others. The snapshots
+// themselves are all kept, so no time resolution is lost. The argument isn't
+// modified.
+func compressRuns(snapshots []Snapshot) []Snapshot {
+	type run struct {
+		start, index int
+		disk         diskinfo.DiskInfo
+		until        time.Time
+	}
+	open := make(map[runKey]run)
+	stored := make([]Snapshot, len(snapshots))
+	for i, s := range snapshots {
+		disks := make([]diskinfo.DiskInfo, 0, len(s.Disks))
+		seen := make(map[string]bool, len(s.Disks))
+		for j, d := range s.Disks {
+			key := runKey{s.Host, d.Drive}
+			seen[d.Drive] = true
+			// A run only goes forward in time, so its last snapshot is the one at until
+			if r, ok := open[key]; ok && r.disk == d && r.index == j && s.Timestamp.After(r.until) {
+				first := &stored[r.start]
+				if first.Runs == nil {
+					first.Runs = make(map[string]time.Time)
+				}
+				first.Runs[d.Drive] = s.Timestamp
+				r.until = s.Timestamp
+				open[key] = r
+				continue
+			}
+			open[key] = run{start: i, index: j, disk: d, until: s.Timestamp}
+			disks = append(disks, d)
+		}
+		// A snapshot of the host without the drive ends its run
+		for key := range open {
+			if key.host == s.Host && !seen[key.drive] {
+				delete(open, key)
+			}
+		}
+		stored[i] = s
+		stored[i].Disks = disks
+		stored[i].Runs = nil
+	}
+	return stored
+}
+
+// runExpander puts back the drives compressRuns left out, one snapshot at a
+// time in the order they are stored, so a file can be expanded while it is read
+type runExpander struct {
+	open map[runKey]openRun
+}
+
+// openRun is a drive left out of the snapshots of its host up to until
+type openRun struct {
+	disk  diskinfo.DiskInfo
+	until time.Time
+	// index is where the drive was among the disks of the first snapshot
+	index int
+}
+
+// expand restores the drives of a snapshot and records the runs it starts
+func (e *runExpander) expand(s *Snapshot) {
+	if e.open == nil {
+		e.open = make(map[runKey]openRun)
+	}
+	if len(e.open) > 0 {
+		present := make(map[string]bool, len(s.Disks))
+		for _, d := range s.Disks {
+			present[d.Drive] = true
+		}
+		var missing []openRun
+		for key, r := range e.open {
+			if key.host != s.Host {
+				continue
+			}
+			if s.Timestamp.After(r.until) {
+				delete(e.open, key)
+				continue
+			}
+			if !present[key.drive] {
+				missing = append(missing, r)
+			}
+			if s.Timestamp.Equal(r.until) {
+				delete(e.open, key)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool {
+			return missing[i].index < missing[j].index
+		})
+		for _, r := range missing {
+			i := min(r.index, len(s.Disks))
+			s.Disks = append(s.Disks[:i], append([]diskinfo.DiskInfo{r.disk}, s.Disks[i:]...)...)
+		}
+	}
+
+	for i, d := range s.Disks {
+		if until, ok := s.Runs[d.Drive]; ok {
+			e.open[runKey{s.Host, d.Drive}] = openRun{disk: d, until: until, index: i}
+		}
+	}
+	s.Runs = nil
+}
+
+// expandRuns restores the snapshots compressRuns stored, in place
+func expandRuns(snapshots []Snapshot) {
+	var e runExpander
+	for i := range snapshots {
+		e.expand(&snapshots[i])
+	}
+}
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+var runTests = []struct {
+	name      string
+	snapshots []Snapshot
+	// stored is how many disks each snapshot keeps once compressed
+	stored []int
+}{
+	{
+		name: "unchanged drive",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+			{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 11)}},
+			{Timestamp: at(2), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 12)}},
+		},
+		stored: []int{2, 1, 1},
+	},
+	{
+		name: "unchanged second drive",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+			{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`C:\`, 51), disk(`D:\`, 10)}},
+			{Timestamp: at(2), Disks: []diskinfo.DiskInfo{disk(`C:\`, 52), disk(`D:\`, 10)}},
+		},
+		stored: []int{2, 1, 1},
+	},
+	{
+		name: "drive missing ends its run",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+			{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+			{Timestamp: at(2), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+		},
+		stored: []int{2, 0, 1},
+	},
+	{
+		name: "hosts interleaved",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Host: "a", Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+			{Timestamp: at(1), Host: "b", Disks: []diskinfo.DiskInfo{disk(`C:\`, 20)}},
+			{Timestamp: at(2), Host: "a", Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+			{Timestamp: at(3), Host: "b", Disks: []diskinfo.DiskInfo{disk(`C:\`, 21)}},
+		},
+		stored: []int{1, 1, 0, 1},
+	},
+	{
+		name: "drives reordered",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+			{Timestamp: at(1), Disks: []diskinfo.DiskInfo{disk(`D:\`, 10), disk(`C:\`, 50)}},
+		},
+		stored: []int{2, 2},
+	},
+	{
+		name: "same time twice",
+		snapshots: []Snapshot{
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+			{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+		},
+		stored: []int{1, 1},
+	},
+}
+
+// equalSnapshots compares expanded snapshots, which keep no runs
+func equalSnapshots(t *testing.T, got, want []Snapshot) {
+	t.Helper()
+	compareSnapshots(t, got, want)
+	for i := range got {
+		if got[i].Runs != nil {
+			t.Errorf("snapshot %d kept runs %v", i, got[i].Runs)
+		}
+	}
+}
+
+func TestRunsRoundTrip(t *testing.T) {
+	for _, tt := range runTests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := compressRuns(tt.snapshots)
+			for i, s := range stored {
+				if len(s.Disks) != tt.stored[i] {
+					t.Errorf("snapshot %d stores %d disks, want %d", i, len(s.Disks), tt.stored[i])
+				}
+			}
+			for i, s := range tt.snapshots {
+				if s.Runs != nil || len(s.Disks) == 0 {
+					t.Fatalf("compressRuns modified snapshot %d", i)
+				}
+			}
+
+			expandRuns(stored)
+			equalSnapshots(t, stored, tt.snapshots)
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, tt := range runTests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.json")
+			if err := Save(path, &History{Snapshots: tt.snapshots}); err != nil {
+				t.Fatal(err)
+			}
+			// A second save keeps the first file as the backup
+			if err := Save(path, &History{Snapshots: tt.snapshots}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path + ".bak"); err != nil {
+				t.Errorf("no backup: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed := false
+			for i, n := range tt.stored {
+				compressed = compressed || n < len(tt.snapshots[i].Disks)
+			}
+			if got := strings.Contains(string(data), `"runs"`); got != compressed {
+				t.Errorf("file has runs: %v, want %v", got, compressed)
+			}
+
+			h, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalSnapshots(t, h.Snapshots, tt.snapshots)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	h, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || len(h.Snapshots) != 0 {
+		t.Errorf("Load of a missing file = %v, %v, want an empty history", h, err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"snapshots": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(corrupt); !errors.Is(err, ErrHistoryCorrupt) {
+		t.Errorf("Load of a truncated file = %v, want %v", err, ErrHistoryCorrupt)
+	}
+
+	// Snapshots appended out of order come back sorted
+	unsorted := filepath.Join(dir, "unsorted.json")
+	data := `{"snapshots": [
+		{"timestamp": "2026-09-01T02:00:00Z", "disks": [{"drive": "C:\\", "total_space": 100, "free_space": 40}]},
+		{"timestamp": "2026-09-01T01:00:00Z", "disks": [{"drive": "C:\\", "total_space": 100, "free_space": 50}]}
+	]}`
+	if err := os.WriteFile(unsorted, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h, err = Load(unsorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Snapshots) != 2 || !h.Snapshots[0].Timestamp.Before(h.Snapshots[1].Timestamp) {
+		t.Errorf("Load kept the file order: %v", h.Snapshots)
+	}
+}
@@ -0,0 +1,401 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store persists snapshots, baselines and alert states. Timestamps are written in UTC,
+// callers convert them to the zone they display.
+type Store interface {
+	// Load reads the full history, snapshots in time order
+	Load(ctx context.Context) (*History, error)
+	// Baselines reads the named baselines without the snapshots
+	Baselines(ctx context.Context) ([]Baseline, error)
+	// Append adds snapshots, older ones than the stored ones take their place in time
+	Append(ctx context.Context, snapshots ...Snapshot) error
+	// Query returns the snapshots taken in [from, to) in time order, zero times leave that end open.
+	// A non-empty drive keeps only that drive's measurements and the snapshots containing it.
+	Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error)
+	// Prune deletes the snapshots taken before t and returns how many were removed
+	Prune(ctx context.Context, before time.Time) (int, error)
+	// RemoveDrive deletes the measurements of a drive, and the snapshots left without any.
+	// It returns how many measurements were removed.
+	RemoveDrive(ctx context.Context, drive string) (int, error)
+	// Thin keeps only the first snapshot of each every period among those taken
+	// before t, the ones saved as baselines and the ones holding benchmarks.
+	// It returns how many were removed.
+	Thin(ctx context.Context, before time.Time, every time.Duration) (int, error)
+	// Compact reclaims the space left by removed data
+	Compact(ctx context.Context) error
+	// SaveBaselines replaces all named baselines
+	SaveBaselines(ctx context.Context, baselines []Baseline) error
+	// AlertStates reads the state of the raised alerts
+	AlertStates(ctx context.Context) ([]AlertState, error)
+	// SaveAlertStates replaces all alert states
+	SaveAlertStates(ctx context.Context, states []AlertState) error
+	Close() error
+}
+
+// Storage backends
+const (
+	BackendJSON   = "json"
+	BackendJSONL  = "jsonl"
+	BackendSQLite = "sqlite"
+	BackendBolt   = "bolt"
+)
+
+// backends opens a store of each type, with the extension of its default file
+var backends = map[string]struct {
+	ext  string
+	open func(path string) (Store, error)
+}{
+	BackendJSON:   {"json", openJSONStore},
+	BackendJSONL:  {"jsonl", openJSONLStore},
+	BackendSQLite: {"db", openSQLiteStore},
+	BackendBolt:   {"bolt", openBoltStore},
+}
+
+// Backends returns the names of the storage backends, sorted
+func Backends() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackendPath returns the default history file of a backend in the home directory
+func BackendPath(backend string) string {
+	if backend == BackendJSON || backends[backend].ext == "" {
+		return DefaultPath()
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "disk_monitor_history."+backends[backend].ext)
+}
+
+// BackendOf returns the backend of a history file from its extension, json
+// when it isn't one of the backends' own
+func BackendOf(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	for name, b := range backends {
+		if b.ext == ext {
+			return name
+		}
+	}
+	return BackendJSON
+}
+
+// Open opens the store of a backend, an empty path uses the backend's default file
+func Open(backend, path string) (Store, error) {
+	b, ok := backends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q, use one of: %s", backend, strings.Join(Backends(), ", "))
+	}
+	if path == "" {
+		path = BackendPath(backend)
+	}
+	return b.open(path)
+}
+
+// Copy writes all snapshots, baselines and alert states of src into dst
+func Copy(ctx context.Context, dst, src Store) (int, error) {
+	h, err := src.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := dst.Append(ctx, h.Snapshots...); err != nil {
+		return 0, err
+	}
+	if err := dst.SaveBaselines(ctx, h.Baselines); err != nil {
+		return 0, err
+	}
+	if err := dst.SaveAlertStates(ctx, h.AlertStates); err != nil {
+		return 0, err
+	}
+	return len(h.Snapshots), nil
+}
+
+// LoadWindow reads the snapshots taken since from and all baselines, so memory
+// grows with the window instead of the whole history. A zero from reads everything.
+// Baselines may tag snapshots outside the window, see FindStoredBaseline.
+func LoadWindow(ctx context.Context, s Store, from time.Time) (*History, error) {
+	if from.IsZero() {
+		return s.Load(ctx)
+	}
+	snapshots, err := s.Query(ctx, from, time.Time{}, "")
+	if err != nil {
+		return nil, err
+	}
+	baselines, err := s.Baselines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshots == nil {
+		snapshots = []Snapshot{}
+	}
+	return &History{Snapshots: snapshots, Baselines: baselines}, nil
+}
+
+// inRange reports whether t is in [from, to), zero times leave that end open
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// forDrive narrows a snapshot to one drive, false if it doesn't contain it
+func forDrive(s Snapshot, drive string) (Snapshot, bool) {
+	if drive == "" {
+		return s, true
+	}
+	for _, d := range s.Disks {
+		if d.Drive == drive {
+			s.Disks = append(s.Disks[:0:0], d)
+			return s, true
+		}
+	}
+	return s, false
+}
+
+// inUTC returns copies of snapshots with UTC timestamps, so stored history reads
+// the same on machines in different time zones
+func inUTC(snapshots []Snapshot) []Snapshot {
+	result := make([]Snapshot, len(snapshots))
+	for i, s := range snapshots {
+		s.Timestamp = s.Timestamp.UTC()
+		result[i] = s
+	}
+	return result
+}
+
+// baselinesInUTC is inUTC for baselines
+func baselinesInUTC(baselines []Baseline) []Baseline {
+	result := make([]Baseline, len(baselines))
+	for i, b := range baselines {
+		b.Timestamp = b.Timestamp.UTC()
+		result[i] = b
+	}
+	return result
+}
+
+// withoutDrive removes a drive's measurements from snapshots held in memory,
+// dropping the snapshots left empty
+func withoutDrive(snapshots []Snapshot, drive string) ([]Snapshot, int) {
+	removed := 0
+	kept := snapshots[:0]
+	for _, s := range snapshots {
+		disks := s.Disks[:0:0]
+		for _, d := range s.Disks {
+			if d.Drive == drive {
+				removed++
+				continue
+			}
+			disks = append(disks, d)
+		}
+		if len(disks) == 0 && len(s.Disks) > 0 {
+			continue
+		}
+		s.Disks = disks
+		kept = append(kept, s)
+	}
+	return kept, removed
+}
+
+// thinned reports which of the snapshot times, in time order, Thin removes.
+// The snapshots marked in benched are kept, benched may be nil.
+func thinned(times []time.Time, benched []bool, before time.Time, every time.Duration, baselines []Baseline) []bool {
+	spared := make(map[int64]bool)
+	for _, b := range baselines {
+		spared[b.Timestamp.UnixNano()] = true
+	}
+
+	drop := make([]bool, len(times))
+	var period int64
+	seen := false
+	for i, t := range times {
+		if !t.Before(before) || spared[t.UnixNano()] || (benched != nil && benched[i]) {
+			continue
+		}
+		p := t.UnixNano() / int64(every)
+		if seen && p == period {
+			drop[i] = true
+			continue
+		}
+		period, seen = p, true
+	}
+	return drop
+}
+
+// thinSnapshots applies Thin to snapshots held in memory
+func thinSnapshots(snapshots []Snapshot, before time.Time, every time.Duration, baselines []Baseline) ([]Snapshot, int) {
+	times := make([]time.Time, len(snapshots))
+	benched := make([]bool, len(snapshots))
+	for i, s := range snapshots {
+		times[i] = s.Timestamp
+		benched[i] = len(s.Benchmarks) > 0
+	}
+	drop := thinned(times, benched, before, every, baselines)
+
+	kept := snapshots[:0]
+	for i, s := range snapshots {
+		if !drop[i] {
+			kept = append(kept, s)
+		}
+	}
+	return kept, len(snapshots) - len(kept)
+}
+
+// sortByTime puts snapshots appended out of order, e.g. imported ones, in time
+// order. Snapshots taken at the same time keep their order.
+func sortByTime(snapshots []Snapshot) {
+	byTime := func(a, b Snapshot) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	}
+	if !slices.IsSortedFunc(snapshots, byTime) {
+		slices.SortStableFunc(snapshots, byTime)
+	}
+}
+
+// filterSnapshots applies a Query to snapshots held in memory
+func filterSnapshots(snapshots []Snapshot, from, to time.Time, drive string) []Snapshot {
+	var result []Snapshot
+	for _, s := range snapshots {
+		if !inRange(s.Timestamp, from, to) {
+			continue
+		}
+		if s, ok := forDrive(s, drive); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// jsonStore keeps the whole history in one indented JSON file, the original format
+type jsonStore struct {
+	path string
+}
+
+// openJSONStore opens a JSON history file, it is created on the first write
+func openJSONStore(path string) (Store, error) {
+	return &jsonStore{path: path}, nil
+}
+
+// Load reads the file
+func (s *jsonStore) Load(ctx context.Context) (*History, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Load(s.path)
+}
+
+// Baselines reads the file, it holds snapshots and baselines together
+func (s *jsonStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return h.Baselines, nil
+}
+
+// update loads the history, applies a change and saves it back
+func (s *jsonStore) update(ctx context.Context, change func(h *History)) error {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return err
+	}
+	change(h)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return Save(s.path, h)
+}
+
+// Append rewrites the file with the new snapshots
+func (s *jsonStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	return s.update(ctx, func(h *History) {
+		h.Snapshots = append(h.Snapshots, inUTC(snapshots)...)
+	})
+}
+
+// Query filters the loaded history
+func (s *jsonStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterSnapshots(h.Snapshots, from, to, drive), nil
+}
+
+// Prune rewrites the file without the old snapshots
+func (s *jsonStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := s.update(ctx, func(h *History) {
+		kept := h.Snapshots[:0]
+		for _, snapshot := range h.Snapshots {
+			if snapshot.Timestamp.Before(before) {
+				removed++
+				continue
+			}
+			kept = append(kept, snapshot)
+		}
+		h.Snapshots = kept
+	})
+	return removed, err
+}
+
+// RemoveDrive rewrites the file without the drive
+func (s *jsonStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	removed := 0
+	err := s.update(ctx, func(h *History) {
+		h.Snapshots, removed = withoutDrive(h.Snapshots, drive)
+	})
+	return removed, err
+}
+
+// Thin rewrites the file with fewer old snapshots
+func (s *jsonStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	removed := 0
+	err := s.update(ctx, func(h *History) {
+		h.Snapshots, removed = thinSnapshots(h.Snapshots, before, every, h.Baselines)
+	})
+	return removed, err
+}
+
+// Compact has nothing to do, every write rewrites the whole file
+func (s *jsonStore) Compact(ctx context.Context) error {
+	return nil
+}
+
+// SaveBaselines rewrites the file with the new baselines
+func (s *jsonStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	return s.update(ctx, func(h *History) {
+		h.Baselines = baselinesInUTC(baselines)
+	})
+}
+
+// AlertStates reads the file, like Baselines
+func (s *jsonStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return h.AlertStates, nil
+}
+
+// SaveAlertStates rewrites the file with the new alert states
+func (s *jsonStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	return s.update(ctx, func(h *History) {
+		h.AlertStates = alertStatesInUTC(states)
+	})
+}
+
+// Close has nothing to release
+func (s *jsonStore) Close() error {
+	return nil
+}
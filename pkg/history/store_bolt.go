@@ -0,0 +1,367 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+// Bolt buckets. Snapshots are keyed by their big-endian Unix nanosecond time,
+// so cursor order is time order.
+var (
+	boltSnapshots   = []byte("snapshots")
+	boltMeta        = []byte("meta")
+	boltBaselines   = []byte("baselines")
+	boltAlertStates = []byte("alert_states")
+)
+
+// boltStore keeps history in a bbolt key/value file
+type boltStore struct {
+	path string
+	db   *bolt.DB
+}
+
+// openBoltStore opens or creates a bbolt history file
+func openBoltStore(path string) (Store, error) {
+	s := &boltStore{path: path}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// boltCorrupt marks errors about a damaged file with ErrHistoryCorrupt.
+// A truncated file has no sentinel error, only its message.
+func boltCorrupt(path string, wrapped, err error) error {
+	if errors.Is(err, berrors.ErrInvalid) || errors.Is(err, berrors.ErrChecksum) ||
+		errors.Is(err, berrors.ErrVersionMismatch) || strings.Contains(err.Error(), "file size too small") {
+		return fmt.Errorf("%w: %s: %v", ErrHistoryCorrupt, path, err)
+	}
+	return wrapped
+}
+
+// open opens the file and creates the buckets
+func (s *boltStore) open() error {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return boltCorrupt(s.path, fmt.Errorf("failed to open %s: %v", s.path, err), err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltSnapshots, boltMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+// boltKey encodes a snapshot time as a sortable key
+func boltKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// scan calls fn for each snapshot in [from, to)
+func (s *boltStore) scan(ctx context.Context, from, to time.Time, fn func(snapshot Snapshot) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltSnapshots).Cursor()
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek(boltKey(from))
+		}
+		for ; k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !to.IsZero() && bytes.Compare(k, boltKey(to)) >= 0 {
+				break
+			}
+			var snapshot Snapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				return fmt.Errorf("%w: %s: snapshot %x: %v", ErrHistoryCorrupt, s.path, k, err)
+			}
+			if err := fn(snapshot); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Load reads all snapshots and baselines
+func (s *boltStore) Load(ctx context.Context) (*History, error) {
+	h := &History{Snapshots: []Snapshot{}}
+	err := s.scan(ctx, time.Time{}, time.Time{}, func(snapshot Snapshot) error {
+		h.Snapshots = append(h.Snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Baselines, err = s.Baselines(ctx); err != nil {
+		return nil, err
+	}
+	if h.AlertStates, err = s.AlertStates(ctx); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Baselines reads the baselines key of the meta bucket
+func (s *boltStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var baselines []Baseline
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltMeta).Get(boltBaselines); v != nil {
+			if err := json.Unmarshal(v, &baselines); err != nil {
+				return fmt.Errorf("%w: %s: baselines: %v", ErrHistoryCorrupt, s.path, err)
+			}
+		}
+		return nil
+	})
+	return baselines, err
+}
+
+// Append stores the snapshots in one transaction. A snapshot taken at the same
+// nanosecond as an existing one replaces it.
+func (s *boltStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltSnapshots)
+		for _, snapshot := range inUTC(snapshots) {
+			v, err := json.Marshal(snapshot)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(boltKey(snapshot.Timestamp), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query seeks to the start of the range
+func (s *boltStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	var result []Snapshot
+	err := s.scan(ctx, from, to, func(snapshot Snapshot) error {
+		if snapshot, ok := forDrive(snapshot, drive); ok {
+			result = append(result, snapshot)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Prune deletes the keys before t
+func (s *boltStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltSnapshots).Cursor()
+		end := boltKey(before)
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// RemoveDrive rewrites the snapshots containing the drive in one transaction
+func (s *boltStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltSnapshots)
+		// Changes are applied after the scan, the cursor isn't safe to mutate under
+		changed := make(map[string][]byte)
+		err := b.ForEach(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var snapshot Snapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				return fmt.Errorf("%w: %s: snapshot %x: %v", ErrHistoryCorrupt, s.path, k, err)
+			}
+			kept, n := withoutDrive([]Snapshot{snapshot}, drive)
+			if n == 0 {
+				return nil
+			}
+			removed += n
+			changed[string(k)] = nil
+			if len(kept) > 0 {
+				v, err := json.Marshal(kept[0])
+				if err != nil {
+					return err
+				}
+				changed[string(k)] = v
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range changed {
+			if v == nil {
+				err = b.Delete([]byte(k))
+			} else {
+				err = b.Put([]byte(k), v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Thin deletes the old snapshots between the kept ones in one transaction
+func (s *boltStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var baselines []Baseline
+		if v := tx.Bucket(boltMeta).Get(boltBaselines); v != nil {
+			if err := json.Unmarshal(v, &baselines); err != nil {
+				return fmt.Errorf("%w: %s: baselines: %v", ErrHistoryCorrupt, s.path, err)
+			}
+		}
+
+		// The keys are the snapshot times, so the values needn't be decoded.
+		// The benchmarks key only appears in the JSON of snapshots that have some.
+		b := tx.Bucket(boltSnapshots)
+		var keys [][]byte
+		var times []time.Time
+		var benched []bool
+		c := b.Cursor()
+		end := boltKey(before)
+		for k, v := c.First(); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+			times = append(times, time.Unix(0, int64(binary.BigEndian.Uint64(k))))
+			benched = append(benched, bytes.Contains(v, []byte(`"benchmarks":`)))
+		}
+
+		for i, drop := range thinned(times, benched, before, every, baselines) {
+			if !drop {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := b.Delete(keys[i]); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Compact copies the data into a fresh file, bbolt never shrinks a file in place
+func (s *boltStore) Compact(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmp := s.path + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0644, nil)
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, s.db, 0); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		s.open()
+		return err
+	}
+	return s.open()
+}
+
+// SaveBaselines stores the baselines as one JSON value
+func (s *boltStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v, err := json.Marshal(baselinesInUTC(baselines))
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMeta).Put(boltBaselines, v)
+	})
+}
+
+// AlertStates reads the alert states key of the meta bucket
+func (s *boltStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var states []AlertState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltMeta).Get(boltAlertStates); v != nil {
+			if err := json.Unmarshal(v, &states); err != nil {
+				return fmt.Errorf("%w: %s: alert states: %v", ErrHistoryCorrupt, s.path, err)
+			}
+		}
+		return nil
+	})
+	return states, err
+}
+
+// SaveAlertStates stores the alert states as one JSON value
+func (s *boltStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v, err := json.Marshal(alertStatesInUTC(states))
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMeta).Put(boltAlertStates, v)
+	})
+}
+
+// Close closes the file
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,281 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// jsonlRecord is one line of a JSONL history file: a snapshot, or the current
+// list of baselines or alert states, which replaces any earlier one
+type jsonlRecord struct {
+	Snapshot  *Snapshot  `json:"snapshot,omitempty"`
+	Baselines []Baseline `json:"baselines,omitempty"`
+	// AlertStates is a pointer so an empty list still marks the record as one
+	AlertStates *[]AlertState `json:"alert_states,omitempty"`
+}
+
+// apply adds a record to a history being read
+func (rec jsonlRecord) apply(h *History) {
+	switch {
+	case rec.Snapshot != nil:
+		h.Snapshots = append(h.Snapshots, *rec.Snapshot)
+	case rec.AlertStates != nil:
+		h.AlertStates = *rec.AlertStates
+	default:
+		h.Baselines = rec.Baselines
+	}
+}
+
+// alertStatesRecord returns the record replacing the alert states
+func alertStatesRecord(states []AlertState) jsonlRecord {
+	states = alertStatesInUTC(states)
+	return jsonlRecord{AlertStates: &states}
+}
+
+// jsonlStore appends one line per snapshot, so collecting doesn't rewrite the file
+type jsonlStore struct {
+	path string
+}
+
+// openJSONLStore opens a JSONL history file, it is created on the first write
+func openJSONLStore(path string) (Store, error) {
+	return &jsonlStore{path: path}, nil
+}
+
+// scan calls fn for each record of the file, a missing file has none.
+// Snapshots come with the drives their runs left out put back.
+func (s *jsonlStore) scan(ctx context.Context, fn func(rec jsonlRecord)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var runs runExpander
+	line := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%w: %s:%d: %v", ErrHistoryCorrupt, s.path, line, err)
+		}
+		if rec.Snapshot != nil {
+			runs.expand(rec.Snapshot)
+		}
+		fn(rec)
+	}
+	return scanner.Err()
+}
+
+// Load reads every line of the file
+func (s *jsonlStore) Load(ctx context.Context) (*History, error) {
+	h := &History{Snapshots: []Snapshot{}}
+	err := s.scan(ctx, func(rec jsonlRecord) {
+		rec.apply(h)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByTime(h.Snapshots)
+	return h, nil
+}
+
+// Baselines returns the last list of baselines in the file
+func (s *jsonlStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	var baselines []Baseline
+	err := s.scan(ctx, func(rec jsonlRecord) {
+		if rec.Snapshot == nil && rec.AlertStates == nil {
+			baselines = rec.Baselines
+		}
+	})
+	return baselines, err
+}
+
+// appendRecords adds lines to the end of a file
+func appendRecords(path string, records []jsonlRecord) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rewrite replaces the file with one line per snapshot, the baselines and the
+// alert states. Unchanged drive readings are stored once per run, lines
+// appended later keep all drives until the next rewrite.
+func (s *jsonlStore) rewrite(h *History) error {
+	snapshots := compressRuns(h.Snapshots)
+	records := make([]jsonlRecord, 0, len(h.Snapshots)+2)
+	if len(h.Baselines) > 0 {
+		records = append(records, jsonlRecord{Baselines: h.Baselines})
+	}
+	if len(h.AlertStates) > 0 {
+		records = append(records, alertStatesRecord(h.AlertStates))
+	}
+	for i := range snapshots {
+		records = append(records, jsonlRecord{Snapshot: &snapshots[i]})
+	}
+
+	tmp := s.path + ".tmp"
+	os.Remove(tmp)
+	if err := appendRecords(tmp, records); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Append adds one line per snapshot
+func (s *jsonlStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	snapshots = inUTC(snapshots)
+	records := make([]jsonlRecord, len(snapshots))
+	for i := range snapshots {
+		records[i] = jsonlRecord{Snapshot: &snapshots[i]}
+	}
+	return appendRecords(s.path, records)
+}
+
+// Query filters the lines as they are read, only the matches are kept in memory
+func (s *jsonlStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	var result []Snapshot
+	err := s.scan(ctx, func(rec jsonlRecord) {
+		if rec.Snapshot == nil || !inRange(rec.Snapshot.Timestamp, from, to) {
+			return
+		}
+		if snapshot, ok := forDrive(*rec.Snapshot, drive); ok {
+			result = append(result, snapshot)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByTime(result)
+	return result, nil
+}
+
+// Prune rewrites the file without the old snapshots
+func (s *jsonlStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	kept := h.Snapshots[:0]
+	for _, snapshot := range h.Snapshots {
+		if !snapshot.Timestamp.Before(before) {
+			kept = append(kept, snapshot)
+		}
+	}
+	removed := len(h.Snapshots) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	h.Snapshots = kept
+	return removed, s.rewrite(h)
+}
+
+// RemoveDrive rewrites the file without the drive
+func (s *jsonlStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	h.Snapshots, removed = withoutDrive(h.Snapshots, drive)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.rewrite(h)
+}
+
+// Thin rewrites the file with fewer old snapshots
+func (s *jsonlStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	h.Snapshots, removed = thinSnapshots(h.Snapshots, before, every, h.Baselines)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.rewrite(h)
+}
+
+// Compact drops the baseline and alert state lists replaced by later ones
+func (s *jsonlStore) Compact(ctx context.Context) error {
+	h, err := s.Load(ctx)
+	if err != nil {
+		return err
+	}
+	return s.rewrite(h)
+}
+
+// SaveBaselines appends the new list of baselines
+func (s *jsonlStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return appendRecords(s.path, []jsonlRecord{{Baselines: baselinesInUTC(baselines)}})
+}
+
+// AlertStates returns the last list of alert states in the file
+func (s *jsonlStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	var states []AlertState
+	err := s.scan(ctx, func(rec jsonlRecord) {
+		if rec.AlertStates != nil {
+			states = *rec.AlertStates
+		}
+	})
+	return states, err
+}
+
+// SaveAlertStates appends the new list of alert states
+func (s *jsonlStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return appendRecords(s.path, []jsonlRecord{alertStatesRecord(states)})
+}
+
+// Close has nothing to release
+func (s *jsonlStore) Close() error {
+	return nil
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// memoryStore keeps a history in memory only. Dry runs apply their changes to
+// one, so they go through the same code as real runs without touching the disk.
+type memoryStore struct {
+	h *History
+}
+
+// NewMemoryStore returns a store holding a copy of h, nothing it does is ever written
+func NewMemoryStore(h *History) Store {
+	return &memoryStore{h: &History{
+		Snapshots:   inUTC(h.Snapshots),
+		Baselines:   baselinesInUTC(h.Baselines),
+		AlertStates: alertStatesInUTC(h.AlertStates),
+	}}
+}
+
+// Load returns a copy of the history, so callers can't change the store
+func (s *memoryStore) Load(ctx context.Context) (*History, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &History{
+		Snapshots:   slices.Clone(s.h.Snapshots),
+		Baselines:   slices.Clone(s.h.Baselines),
+		AlertStates: slices.Clone(s.h.AlertStates),
+	}, nil
+}
+
+// Baselines returns a copy of the baselines
+func (s *memoryStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.h.Baselines), nil
+}
+
+// Append adds the snapshots
+func (s *memoryStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.h.Snapshots = append(s.h.Snapshots, inUTC(snapshots)...)
+	sortByTime(s.h.Snapshots)
+	return nil
+}
+
+// Query filters the history
+func (s *memoryStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return filterSnapshots(s.h.Snapshots, from, to, drive), nil
+}
+
+// Prune drops the old snapshots
+func (s *memoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	kept := s.h.Snapshots[:0]
+	for _, snapshot := range s.h.Snapshots {
+		if !snapshot.Timestamp.Before(before) {
+			kept = append(kept, snapshot)
+		}
+	}
+	removed := len(s.h.Snapshots) - len(kept)
+	s.h.Snapshots = kept
+	return removed, nil
+}
+
+// RemoveDrive drops the drive's measurements
+func (s *memoryStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	removed := 0
+	s.h.Snapshots, removed = withoutDrive(s.h.Snapshots, drive)
+	return removed, nil
+}
+
+// Thin drops old snapshots
+func (s *memoryStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	removed := 0
+	s.h.Snapshots, removed = thinSnapshots(s.h.Snapshots, before, every, s.h.Baselines)
+	return removed, nil
+}
+
+// Compact has nothing to reclaim
+func (s *memoryStore) Compact(ctx context.Context) error {
+	return nil
+}
+
+// SaveBaselines replaces the baselines
+func (s *memoryStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.h.Baselines = baselinesInUTC(baselines)
+	return nil
+}
+
+// AlertStates returns a copy of the alert states
+func (s *memoryStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.h.AlertStates), nil
+}
+
+// SaveAlertStates replaces the alert states
+func (s *memoryStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.h.AlertStates = alertStatesInUTC(states)
+	return nil
+}
+
+// Close has nothing to release
+func (s *memoryStore) Close() error {
+	return nil
+}
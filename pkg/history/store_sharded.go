@@ -0,0 +1,409 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ways to split the history into files
+const (
+	ShardNone  = ""
+	ShardMonth = "month"
+)
+
+// shardMonth is how the month is written in the name of a shard
+const shardMonth = "2006-01"
+
+// OpenSharded opens the store of a backend split into files as shard says.
+// With ShardMonth the snapshots of each month are kept in their own file next
+// to path, history-2024-06.json for history.json, and path keeps the baselines,
+// alert states and the snapshots stored before the history was split, until
+// Compact moves them into the shards. ShardNone is Open.
+func OpenSharded(backend, path, shard string) (Store, error) {
+	switch shard {
+	case ShardNone:
+		return Open(backend, path)
+	case ShardMonth:
+	default:
+		return nil, fmt.Errorf("unknown storage shard %q, use %q or leave it empty", shard, ShardMonth)
+	}
+	base, err := Open(backend, path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = BackendPath(backend)
+	}
+	return &shardedStore{backend: backend, path: path, base: base}, nil
+}
+
+// ShardFiles returns the files of a history split as shard says that exist,
+// the base file first and then the shards in time order
+func ShardFiles(backend, path, shard string) ([]string, error) {
+	if path == "" {
+		path = BackendPath(backend)
+	}
+	var files []string
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	if shard == ShardNone {
+		return files, nil
+	}
+	shards, err := listShards(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shards {
+		files = append(files, sh.path)
+	}
+	return files, nil
+}
+
+// RecoverSharded is Recover for a history split as shard says: it repairs the
+// first of its files that is corrupt
+func RecoverSharded(ctx context.Context, backend, path, shard string) (*Recovery, error) {
+	files, err := ShardFiles(backend, path, shard)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		store, err := Open(backend, f)
+		if err == nil {
+			_, err = store.Load(ctx)
+			store.Close()
+		}
+		if errors.Is(err, ErrHistoryCorrupt) {
+			return Recover(ctx, backend, f)
+		}
+	}
+	return nil, fmt.Errorf("none of the history files is corrupt")
+}
+
+// shard is the file of one month of snapshots
+type shard struct {
+	// month is the first instant of the month, in UTC
+	month time.Time
+	path  string
+}
+
+// end returns when the month of the shard ends
+func (sh shard) end() time.Time {
+	return sh.month.AddDate(0, 1, 0)
+}
+
+// shardPath returns the file of the month of t next to the base file
+func shardPath(base string, t time.Time) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + t.UTC().Format(shardMonth) + ext
+}
+
+// listShards finds the shards of a base file, in time order
+func listShards(base string) ([]shard, error) {
+	dir := filepath.Dir(base)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(filepath.Base(base), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var shards []shard
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		month, err := time.Parse(shardMonth, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		shards = append(shards, shard{month: month, path: filepath.Join(dir, name)})
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].month.Before(shards[j].month) })
+	return shards, nil
+}
+
+// shardedStore keeps the snapshots of each month in a file of their own, so
+// no file grows without end and old months are deleted by removing theirs
+type shardedStore struct {
+	backend string
+	// path is the base file, see OpenSharded
+	path string
+	base Store
+}
+
+// withShard opens the store of a shard for one call
+func (s *shardedStore) withShard(path string, fn func(Store) error) error {
+	store, err := Open(s.backend, path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return fn(store)
+}
+
+// Load reads the base file and all shards
+func (s *shardedStore) Load(ctx context.Context) (*History, error) {
+	h, err := s.base.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shards {
+		err := s.withShard(sh.path, func(store Store) error {
+			snapshots, err := store.Query(ctx, time.Time{}, time.Time{}, "")
+			h.Snapshots = append(h.Snapshots, snapshots...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortByTime(h.Snapshots)
+	return h, nil
+}
+
+// Baselines reads the baselines of the base file
+func (s *shardedStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	return s.base.Baselines(ctx)
+}
+
+// Append adds the snapshots to the shards of their months
+func (s *shardedStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	var paths []string
+	byShard := make(map[string][]Snapshot)
+	for _, snapshot := range snapshots {
+		path := shardPath(s.path, snapshot.Timestamp)
+		if _, ok := byShard[path]; !ok {
+			paths = append(paths, path)
+		}
+		byShard[path] = append(byShard[path], snapshot)
+	}
+	for _, path := range paths {
+		err := s.withShard(path, func(store Store) error {
+			return store.Append(ctx, byShard[path]...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query reads only the shards of the months in [from, to)
+func (s *shardedStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	result, err := s.base.Query(ctx, from, to, drive)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shards {
+		if (!to.IsZero() && !sh.month.Before(to)) || (!from.IsZero() && !sh.end().After(from)) {
+			continue
+		}
+		err := s.withShard(sh.path, func(store Store) error {
+			snapshots, err := store.Query(ctx, from, to, drive)
+			result = append(result, snapshots...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortByTime(result)
+	return result, nil
+}
+
+// Prune deletes the shards of the months that ended before t, and prunes the
+// one t falls in
+func (s *shardedStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	removed, err := s.base.Prune(ctx, before)
+	if err != nil {
+		return removed, err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return removed, err
+	}
+	for _, sh := range shards {
+		if !sh.month.Before(before) {
+			break
+		}
+		err := s.withShard(sh.path, func(store Store) error {
+			if sh.end().After(before) {
+				n, err := store.Prune(ctx, before)
+				removed += n
+				return err
+			}
+			snapshots, err := store.Query(ctx, time.Time{}, time.Time{}, "")
+			removed += len(snapshots)
+			return err
+		})
+		if err != nil {
+			return removed, err
+		}
+		if !sh.end().After(before) {
+			if err := removeShard(sh.path); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeShard deletes the file of a shard and the backup Save keeps of it
+func removeShard(path string) error {
+	for _, f := range []string{path, path + ".bak"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveDrive removes the drive from the base file and every shard
+func (s *shardedStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	removed, err := s.base.RemoveDrive(ctx, drive)
+	if err != nil {
+		return removed, err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return removed, err
+	}
+	for _, sh := range shards {
+		err := s.withShard(sh.path, func(store Store) error {
+			n, err := store.RemoveDrive(ctx, drive)
+			removed += n
+			return err
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Thin thins each shard of the months before t by the baselines of the base
+// file, which the shards don't have. A period spanning two months keeps a
+// snapshot in each.
+func (s *shardedStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	removed, err := s.base.Thin(ctx, before, every)
+	if err != nil {
+		return removed, err
+	}
+	baselines, err := s.base.Baselines(ctx)
+	if err != nil {
+		return removed, err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return removed, err
+	}
+	for _, sh := range shards {
+		if !sh.month.Before(before) {
+			break
+		}
+		var snapshots []Snapshot
+		err := s.withShard(sh.path, func(store Store) (err error) {
+			snapshots, err = store.Query(ctx, time.Time{}, time.Time{}, "")
+			return err
+		})
+		if err != nil {
+			return removed, err
+		}
+		kept, n := thinSnapshots(snapshots, before, every, baselines)
+		if n == 0 {
+			continue
+		}
+		if err := s.rewriteShard(ctx, sh.path, kept); err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// rewriteShard replaces the snapshots of a shard, writing the new file next to
+// it first so a failure leaves the old one in place
+func (s *shardedStore) rewriteShard(ctx context.Context, path string, snapshots []Snapshot) error {
+	tmp := path + ".rewrite"
+	if err := removeShard(tmp); err != nil {
+		return err
+	}
+	err := s.withShard(tmp, func(store Store) error {
+		return store.Append(ctx, snapshots...)
+	})
+	if err != nil {
+		removeShard(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Compact moves the snapshots of the base file into the shards and compacts
+// every file
+func (s *shardedStore) Compact(ctx context.Context) error {
+	unsharded, err := s.base.Query(ctx, time.Time{}, time.Time{}, "")
+	if err != nil {
+		return err
+	}
+	if len(unsharded) > 0 {
+		// Appended before they are pruned, so a failure leaves them stored twice, not lost
+		if err := s.Append(ctx, unsharded...); err != nil {
+			return err
+		}
+		last := unsharded[len(unsharded)-1].Timestamp
+		if _, err := s.base.Prune(ctx, last.Add(time.Nanosecond)); err != nil {
+			return err
+		}
+	}
+	if err := s.base.Compact(ctx); err != nil {
+		return err
+	}
+	shards, err := listShards(s.path)
+	if err != nil {
+		return err
+	}
+	for _, sh := range shards {
+		if err := s.withShard(sh.path, func(store Store) error { return store.Compact(ctx) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveBaselines replaces the baselines of the base file
+func (s *shardedStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	return s.base.SaveBaselines(ctx, baselines)
+}
+
+// AlertStates reads the alert states of the base file
+func (s *shardedStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	return s.base.AlertStates(ctx)
+}
+
+// SaveAlertStates replaces the alert states of the base file
+func (s *shardedStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	return s.base.SaveAlertStates(ctx, states)
+}
+
+// Close closes the base file, the shards are only open during a call
+func (s *shardedStore) Close() error {
+	return s.base.Close()
+}
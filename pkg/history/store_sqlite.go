@@ -0,0 +1,793 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteSchema creates the tables on first use. Times are Unix nanoseconds.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id        INTEGER PRIMARY KEY,
+	timestamp INTEGER NOT NULL,
+	note      TEXT NOT NULL DEFAULT '',
+	host      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS snapshots_timestamp ON snapshots (timestamp);
+CREATE TABLE IF NOT EXISTS disks (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	drive       TEXT NOT NULL,
+	total_space INTEGER NOT NULL,
+	free_space  INTEGER NOT NULL,
+	used_space  INTEGER NOT NULL,
+	volume_free INTEGER NOT NULL DEFAULT 0,
+	file_system TEXT NOT NULL DEFAULT '',
+	block_clone INTEGER NOT NULL DEFAULT 0,
+	integrity   INTEGER NOT NULL DEFAULT 0,
+	savings     INTEGER NOT NULL DEFAULT 0,
+	dev_drive   INTEGER NOT NULL DEFAULT 0,
+	files       INTEGER NOT NULL DEFAULT 0,
+	max_files   INTEGER NOT NULL DEFAULT 0,
+	image       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS disks_snapshot ON disks (snapshot_id, drive);
+CREATE TABLE IF NOT EXISTS health (
+	snapshot_id      INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	disk             INTEGER NOT NULL,
+	model            TEXT NOT NULL DEFAULT '',
+	serial           TEXT NOT NULL DEFAULT '',
+	critical_warning INTEGER NOT NULL DEFAULT 0,
+	percentage_used  INTEGER NOT NULL,
+	available_spare  INTEGER NOT NULL,
+	spare_threshold  INTEGER NOT NULL,
+	media_errors     INTEGER NOT NULL,
+	data_written     INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS health_snapshot ON health (snapshot_id);
+CREATE TABLE IF NOT EXISTS pools (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	name        TEXT NOT NULL,
+	size        INTEGER NOT NULL,
+	allocated   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pools_snapshot ON pools (snapshot_id);
+CREATE TABLE IF NOT EXISTS benchmarks (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	drive       TEXT NOT NULL,
+	size        INTEGER NOT NULL,
+	seq_read    REAL NOT NULL,
+	seq_write   REAL NOT NULL,
+	rand_read   REAL NOT NULL,
+	rand_write  REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS benchmarks_snapshot ON benchmarks (snapshot_id);
+CREATE TABLE IF NOT EXISTS fragmentation (
+	snapshot_id  INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	drive        TEXT NOT NULL,
+	free_extents INTEGER NOT NULL,
+	largest_free INTEGER NOT NULL,
+	free         INTEGER NOT NULL,
+	seek_penalty INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS fragmentation_snapshot ON fragmentation (snapshot_id);
+CREATE TABLE IF NOT EXISTS trim (
+	snapshot_id    INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	drive          TEXT NOT NULL,
+	supported      INTEGER NOT NULL,
+	enabled        INTEGER NOT NULL,
+	last_optimized INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS trim_snapshot ON trim (snapshot_id);
+CREATE TABLE IF NOT EXISTS onedrive (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	folder      TEXT NOT NULL,
+	logical     INTEGER NOT NULL,
+	on_disk     INTEGER NOT NULL,
+	files       INTEGER NOT NULL,
+	online_only INTEGER NOT NULL,
+	pinned      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS onedrive_snapshot ON onedrive (snapshot_id);
+CREATE TABLE IF NOT EXISTS virtual_disks (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	pool        TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	size        INTEGER NOT NULL,
+	allocated   INTEGER NOT NULL,
+	footprint   INTEGER NOT NULL,
+	thin        INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS virtual_disks_snapshot ON virtual_disks (snapshot_id);
+CREATE TABLE IF NOT EXISTS updates (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	time        INTEGER NOT NULL,
+	title       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS updates_snapshot ON updates (snapshot_id);
+CREATE TABLE IF NOT EXISTS baselines (
+	name      TEXT PRIMARY KEY,
+	timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS alert_states (
+	host          TEXT NOT NULL,
+	kind          TEXT NOT NULL,
+	drive         TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	severity      TEXT NOT NULL,
+	message       TEXT NOT NULL,
+	since         INTEGER NOT NULL,
+	last_notified INTEGER NOT NULL DEFAULT 0,
+	sinks         TEXT NOT NULL DEFAULT ''
+);
+`
+
+// sqliteColumns are columns added after the first release, which older databases
+// get on open. Each is table, column and its definition.
+var sqliteColumns = [][3]string{
+	{"disks", "volume_free", "INTEGER NOT NULL DEFAULT 0"},
+	{"health", "data_written", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "file_system", "TEXT NOT NULL DEFAULT ''"},
+	{"disks", "block_clone", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "integrity", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "savings", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "dev_drive", "INTEGER NOT NULL DEFAULT 0"},
+	{"snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+	{"disks", "files", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "max_files", "INTEGER NOT NULL DEFAULT 0"},
+	{"disks", "image", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateSQLite adds the sqliteColumns a database doesn't have yet
+func migrateSQLite(db *sql.DB) error {
+	for _, c := range sqliteColumns {
+		if _, err := db.Exec(fmt.Sprintf("SELECT %s FROM %s LIMIT 0", c[1], c[0])); err == nil {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c[0], c[1], c[2])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteCorrupt marks errors about a damaged database file with ErrHistoryCorrupt
+func sqliteCorrupt(path string, err error) error {
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		if code := se.Code() & 0xff; code == sqlite3.SQLITE_CORRUPT || code == sqlite3.SQLITE_NOTADB {
+			return fmt.Errorf("%w: %s: %v", ErrHistoryCorrupt, path, err)
+		}
+	}
+	return err
+}
+
+// sqliteStore keeps history in an SQLite database
+type sqliteStore struct {
+	path string
+	db   *sql.DB
+}
+
+// openSQLiteStore opens or creates an SQLite history database
+func openSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		if err := sqliteCorrupt(path, err); errors.Is(err, ErrHistoryCorrupt) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade %s: %v", path, err)
+	}
+	return &sqliteStore{path: path, db: db}, nil
+}
+
+// timeFilter returns the conditions on s.timestamp selecting [from, to)
+func timeFilter(from, to time.Time) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if !from.IsZero() {
+		where = append(where, "s.timestamp >= ?")
+		args = append(args, from.UnixNano())
+	}
+	if !to.IsZero() {
+		where = append(where, "s.timestamp < ?")
+		args = append(args, to.UnixNano())
+	}
+	return where, args
+}
+
+// querySnapshots reads snapshots with their disks, health and pools, ordered by time
+func (s *sqliteStore) querySnapshots(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	where, args := timeFilter(from, to)
+	if drive != "" {
+		where = append(where, "d.drive = ?")
+		args = append(args, drive)
+	}
+	// Snapshots of imported health readings have no disks, their columns are empty
+	query := `SELECT s.id, s.timestamp, s.note, s.host, COALESCE(d.drive, ''), COALESCE(d.total_space, 0),
+		COALESCE(d.free_space, 0), COALESCE(d.used_space, 0), COALESCE(d.volume_free, 0), COALESCE(d.file_system, ''),
+		COALESCE(d.block_clone, 0), COALESCE(d.integrity, 0), COALESCE(d.savings, 0), COALESCE(d.dev_drive, 0),
+		COALESCE(d.files, 0), COALESCE(d.max_files, 0), COALESCE(d.image, '')
+		FROM snapshots s LEFT JOIN disks d ON d.snapshot_id = s.id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY s.timestamp, s.id, d.rowid"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+	index := make(map[int64]int)
+	lastID := int64(-1)
+	for rows.Next() {
+		var id, ts int64
+		var note, host string
+		var d diskinfo.DiskInfo
+		if err := rows.Scan(&id, &ts, &note, &host, &d.Drive, &d.TotalSpace, &d.FreeSpace, &d.UsedSpace, &d.VolumeFree,
+			&d.FileSystem, &d.BlockClone, &d.IntegrityStreams, &d.Savings, &d.DevDrive, &d.Files, &d.MaxFiles, &d.Image); err != nil {
+			return nil, err
+		}
+		if id != lastID {
+			index[id] = len(snapshots)
+			snapshots = append(snapshots, Snapshot{Timestamp: time.Unix(0, ts).UTC(), Note: note, Host: host})
+			lastID = id
+		}
+		if d.Drive != "" {
+			last := &snapshots[len(snapshots)-1]
+			last.Disks = append(last.Disks, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := s.queryHealth(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryPools(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryBenchmarks(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryFragmentation(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryTrim(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryOneDrive(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	if err := s.queryUpdates(ctx, from, to, snapshots, index); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// queryBenchmarks adds the benchmark results to the snapshots read by querySnapshots
+func (s *sqliteStore) queryBenchmarks(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT b.snapshot_id, b.drive, b.size, b.seq_read, b.seq_write, b.rand_read, b.rand_write
+		FROM benchmarks b JOIN snapshots s ON s.id = b.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY b.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var b diskinfo.Benchmark
+		if err := rows.Scan(&id, &b.Drive, &b.Size, &b.SeqRead, &b.SeqWrite, &b.RandRead, &b.RandWrite); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].Benchmarks = append(snapshots[i].Benchmarks, b)
+		}
+	}
+	return rows.Err()
+}
+
+// queryFragmentation adds the fragmentation readings to the snapshots read by querySnapshots
+func (s *sqliteStore) queryFragmentation(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT f.snapshot_id, f.drive, f.free_extents, f.largest_free, f.free, f.seek_penalty
+		FROM fragmentation f JOIN snapshots s ON s.id = f.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY f.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var f diskinfo.Fragmentation
+		if err := rows.Scan(&id, &f.Drive, &f.FreeExtents, &f.LargestFree, &f.Free, &f.SeekPenalty); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].Fragmentation = append(snapshots[i].Fragmentation, f)
+		}
+	}
+	return rows.Err()
+}
+
+// queryTrim adds the TRIM statuses to the snapshots read by querySnapshots
+func (s *sqliteStore) queryTrim(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT t.snapshot_id, t.drive, t.supported, t.enabled, t.last_optimized
+		FROM trim t JOIN snapshots s ON s.id = t.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY t.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, optimized int64
+		var t diskinfo.TrimStatus
+		if err := rows.Scan(&id, &t.Drive, &t.Supported, &t.Enabled, &optimized); err != nil {
+			return err
+		}
+		if optimized != 0 {
+			t.LastOptimized = time.Unix(0, optimized).UTC()
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].Trim = append(snapshots[i].Trim, t)
+		}
+	}
+	return rows.Err()
+}
+
+// queryOneDrive adds the OneDrive folders to the snapshots read by querySnapshots
+func (s *sqliteStore) queryOneDrive(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT o.snapshot_id, o.folder, o.logical, o.on_disk, o.files, o.online_only, o.pinned
+		FROM onedrive o JOIN snapshots s ON s.id = o.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY o.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var f diskinfo.OneDriveFolder
+		if err := rows.Scan(&id, &f.Folder, &f.Logical, &f.OnDisk, &f.Files, &f.OnlineOnly, &f.Pinned); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].OneDrive = append(snapshots[i].OneDrive, f)
+		}
+	}
+	return rows.Err()
+}
+
+// queryUpdates adds the installed updates to the snapshots read by querySnapshots
+func (s *sqliteStore) queryUpdates(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT u.snapshot_id, u.time, u.title
+		FROM updates u JOIN snapshots s ON s.id = u.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY u.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, t int64
+		var u diskinfo.UpdateEvent
+		if err := rows.Scan(&id, &t, &u.Title); err != nil {
+			return err
+		}
+		u.Time = time.Unix(0, t).UTC()
+		if i, ok := index[id]; ok {
+			snapshots[i].Updates = append(snapshots[i].Updates, u)
+		}
+	}
+	return rows.Err()
+}
+
+// queryHealth adds the health readings to the snapshots read by querySnapshots,
+// index maps snapshot ids to their place
+func (s *sqliteStore) queryHealth(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	query := `SELECT h.snapshot_id, h.disk, h.model, h.serial, h.critical_warning, h.percentage_used, h.available_spare, h.spare_threshold, h.media_errors, h.data_written
+		FROM health h JOIN snapshots s ON s.id = h.snapshot_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY h.rowid"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var h diskinfo.Health
+		if err := rows.Scan(&id, &h.Disk, &h.Model, &h.Serial, &h.CriticalWarning, &h.PercentageUsed, &h.AvailableSpare, &h.SpareThreshold, &h.MediaErrors, &h.DataWritten); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].Health = append(snapshots[i].Health, h)
+		}
+	}
+	return rows.Err()
+}
+
+// queryPools adds the pools and their virtual disks to the snapshots read by
+// querySnapshots, like queryHealth
+func (s *sqliteStore) queryPools(ctx context.Context, from, to time.Time, snapshots []Snapshot, index map[int64]int) error {
+	where, args := timeFilter(from, to)
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT p.snapshot_id, p.name, p.size, p.allocated
+		FROM pools p JOIN snapshots s ON s.id = p.snapshot_id`+filter+" ORDER BY p.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var p diskinfo.StoragePool
+		if err := rows.Scan(&id, &p.Name, &p.Size, &p.Allocated); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			snapshots[i].Pools = append(snapshots[i].Pools, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	vrows, err := s.db.QueryContext(ctx, `SELECT v.snapshot_id, v.pool, v.name, v.size, v.allocated, v.footprint, v.thin
+		FROM virtual_disks v JOIN snapshots s ON s.id = v.snapshot_id`+filter+" ORDER BY v.rowid", args...)
+	if err != nil {
+		return err
+	}
+	defer vrows.Close()
+	for vrows.Next() {
+		var id int64
+		var pool string
+		var vd diskinfo.VirtualDisk
+		if err := vrows.Scan(&id, &pool, &vd.Name, &vd.Size, &vd.Allocated, &vd.Footprint, &vd.Thin); err != nil {
+			return err
+		}
+		i, ok := index[id]
+		if !ok {
+			continue
+		}
+		for j := range snapshots[i].Pools {
+			if p := &snapshots[i].Pools[j]; p.Name == pool {
+				p.VirtualDisks = append(p.VirtualDisks, vd)
+				break
+			}
+		}
+	}
+	return vrows.Err()
+}
+
+// Load reads all snapshots and baselines
+func (s *sqliteStore) Load(ctx context.Context) (*History, error) {
+	snapshots, err := s.querySnapshots(ctx, time.Time{}, time.Time{}, "")
+	if err != nil {
+		return nil, sqliteCorrupt(s.path, err)
+	}
+	baselines, err := s.Baselines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states, err := s.AlertStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &History{Snapshots: snapshots, Baselines: baselines, AlertStates: states}, nil
+}
+
+// Baselines reads the baselines table
+func (s *sqliteStore) Baselines(ctx context.Context) ([]Baseline, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, timestamp FROM baselines ORDER BY rowid")
+	if err != nil {
+		return nil, sqliteCorrupt(s.path, err)
+	}
+	defer rows.Close()
+	var baselines []Baseline
+	for rows.Next() {
+		var b Baseline
+		var ts int64
+		if err := rows.Scan(&b.Name, &ts); err != nil {
+			return nil, err
+		}
+		b.Timestamp = time.Unix(0, ts).UTC()
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+// Append inserts the snapshots in one transaction
+func (s *sqliteStore) Append(ctx context.Context, snapshots ...Snapshot) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, snapshot := range snapshots {
+		res, err := tx.ExecContext(ctx, "INSERT INTO snapshots (timestamp, note, host) VALUES (?, ?, ?)", snapshot.Timestamp.UnixNano(), snapshot.Note, snapshot.Host)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, d := range snapshot.Disks {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO disks (snapshot_id, drive, total_space, free_space, used_space, volume_free, file_system, block_clone, integrity, savings, dev_drive, files, max_files, image) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				id, d.Drive, d.TotalSpace, d.FreeSpace, d.UsedSpace, d.VolumeFree, d.FileSystem, d.BlockClone, d.IntegrityStreams, d.Savings, d.DevDrive, d.Files, d.MaxFiles, d.Image); err != nil {
+				return err
+			}
+		}
+		for _, h := range snapshot.Health {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO health (snapshot_id, disk, model, serial, critical_warning, percentage_used, available_spare, spare_threshold, media_errors, data_written) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				id, h.Disk, h.Model, h.Serial, h.CriticalWarning, h.PercentageUsed, h.AvailableSpare, h.SpareThreshold, h.MediaErrors, h.DataWritten); err != nil {
+				return err
+			}
+		}
+		for _, p := range snapshot.Pools {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO pools (snapshot_id, name, size, allocated) VALUES (?, ?, ?, ?)",
+				id, p.Name, p.Size, p.Allocated); err != nil {
+				return err
+			}
+			for _, vd := range p.VirtualDisks {
+				if _, err := tx.ExecContext(ctx, "INSERT INTO virtual_disks (snapshot_id, pool, name, size, allocated, footprint, thin) VALUES (?, ?, ?, ?, ?, ?, ?)",
+					id, p.Name, vd.Name, vd.Size, vd.Allocated, vd.Footprint, vd.Thin); err != nil {
+					return err
+				}
+			}
+		}
+		for _, b := range snapshot.Benchmarks {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO benchmarks (snapshot_id, drive, size, seq_read, seq_write, rand_read, rand_write) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				id, b.Drive, b.Size, b.SeqRead, b.SeqWrite, b.RandRead, b.RandWrite); err != nil {
+				return err
+			}
+		}
+		for _, f := range snapshot.Fragmentation {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO fragmentation (snapshot_id, drive, free_extents, largest_free, free, seek_penalty) VALUES (?, ?, ?, ?, ?, ?)",
+				id, f.Drive, f.FreeExtents, f.LargestFree, f.Free, f.SeekPenalty); err != nil {
+				return err
+			}
+		}
+		for _, t := range snapshot.Trim {
+			var optimized int64
+			if !t.LastOptimized.IsZero() {
+				optimized = t.LastOptimized.UnixNano()
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO trim (snapshot_id, drive, supported, enabled, last_optimized) VALUES (?, ?, ?, ?, ?)",
+				id, t.Drive, t.Supported, t.Enabled, optimized); err != nil {
+				return err
+			}
+		}
+		for _, f := range snapshot.OneDrive {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO onedrive (snapshot_id, folder, logical, on_disk, files, online_only, pinned) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				id, f.Folder, f.Logical, f.OnDisk, f.Files, f.OnlineOnly, f.Pinned); err != nil {
+				return err
+			}
+		}
+		for _, u := range snapshot.Updates {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO updates (snapshot_id, time, title) VALUES (?, ?, ?)",
+				id, u.Time.UnixNano(), u.Title); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Query selects the snapshots in the database
+func (s *sqliteStore) Query(ctx context.Context, from, to time.Time, drive string) ([]Snapshot, error) {
+	return s.querySnapshots(ctx, from, to, drive)
+}
+
+// Prune deletes old snapshots, their disks go with them
+func (s *sqliteStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM snapshots WHERE timestamp < ?", before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// RemoveDrive deletes the drive's rows and the snapshots left without disks
+func (s *sqliteStore) RemoveDrive(ctx context.Context, drive string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM disks WHERE drive = ?", drive).Scan(&n); err != nil {
+		return 0, err
+	}
+	// Snapshots that never had disks, like imported health readings, are kept
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE id IN (SELECT snapshot_id FROM disks WHERE drive = ?)
+		AND id NOT IN (SELECT snapshot_id FROM disks WHERE drive <> ?)`, drive, drive); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM disks WHERE drive = ?", drive); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// Thin deletes the old snapshots between the kept ones in one transaction,
+// their disks go with them
+func (s *sqliteStore) Thin(ctx context.Context, before time.Time, every time.Duration) (int, error) {
+	baselines, err := s.Baselines(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, timestamp, EXISTS (SELECT 1 FROM benchmarks b WHERE b.snapshot_id = snapshots.id)
+		FROM snapshots WHERE timestamp < ? ORDER BY timestamp, id`, before.UnixNano())
+	if err != nil {
+		return 0, sqliteCorrupt(s.path, err)
+	}
+	var ids []int64
+	var times []time.Time
+	var benched []bool
+	for rows.Next() {
+		var id, ts int64
+		var bench bool
+		if err := rows.Scan(&id, &ts, &bench); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		times = append(times, time.Unix(0, ts))
+		benched = append(benched, bench)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i, drop := range thinned(times, benched, before, every, baselines) {
+		if !drop {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM snapshots WHERE id = ?", ids[i]); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+	return removed, tx.Commit()
+}
+
+// Compact vacuums the database file
+func (s *sqliteStore) Compact(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "VACUUM")
+	return err
+}
+
+// SaveBaselines replaces the baselines table
+func (s *sqliteStore) SaveBaselines(ctx context.Context, baselines []Baseline) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM baselines"); err != nil {
+		return err
+	}
+	for _, b := range baselines {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO baselines (name, timestamp) VALUES (?, ?)", b.Name, b.Timestamp.UnixNano()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AlertStates reads the alert_states table. The notified sinks are one
+// newline separated column.
+func (s *sqliteStore) AlertStates(ctx context.Context) ([]AlertState, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT host, kind, drive, status, severity, message, since, last_notified, sinks FROM alert_states ORDER BY rowid")
+	if err != nil {
+		return nil, sqliteCorrupt(s.path, err)
+	}
+	defer rows.Close()
+	var states []AlertState
+	for rows.Next() {
+		var st AlertState
+		var since, notified int64
+		var sinks string
+		if err := rows.Scan(&st.Host, &st.Kind, &st.Drive, &st.Status, &st.Severity, &st.Message, &since, &notified, &sinks); err != nil {
+			return nil, err
+		}
+		st.Since = time.Unix(0, since).UTC()
+		if notified != 0 {
+			st.LastNotified = time.Unix(0, notified).UTC()
+		}
+		if sinks != "" {
+			st.Sinks = strings.Split(sinks, "\n")
+		}
+		states = append(states, st)
+	}
+	return states, rows.Err()
+}
+
+// SaveAlertStates replaces the alert_states table
+func (s *sqliteStore) SaveAlertStates(ctx context.Context, states []AlertState) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM alert_states"); err != nil {
+		return err
+	}
+	for _, st := range states {
+		var notified int64
+		if !st.LastNotified.IsZero() {
+			notified = st.LastNotified.UnixNano()
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO alert_states (host, kind, drive, status, severity, message, since, last_notified, sinks) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			st.Host, st.Kind, st.Drive, st.Status, st.Severity, st.Message, st.Since.UnixNano(), notified, strings.Join(st.Sinks, "\n")); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valsaven/disk-monitor/pkg/diskinfo"
+)
+
+// disk is a reading of drive with free bytes out of 100
+func disk(drive string, free uint64) diskinfo.DiskInfo {
+	return diskinfo.DiskInfo{Drive: drive, TotalSpace: 100, FreeSpace: free, UsedSpace: 100 - free}
+}
+
+// at is the time of the nth hourly snapshot, at(-1) is the month before
+func at(n int) time.Time {
+	return time.Date(2026, 9, 1, n, 0, 0, 0, time.UTC)
+}
+
+// openTestStore opens a store of a backend in a temporary directory
+func openTestStore(t *testing.T, backend, shard string) (Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history."+backends[backend].ext)
+	s, err := OpenSharded(backend, path, shard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	snapshots := []Snapshot{
+		{Timestamp: at(-1), Host: "nas", Disks: []diskinfo.DiskInfo{disk(`C:\`, 60)}},
+		{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50), disk(`D:\`, 10)}},
+		{Timestamp: at(1), Note: "after cleanup", Disks: []diskinfo.DiskInfo{disk(`C:\`, 70)}},
+	}
+	baselines := []Baseline{{Name: "before", Timestamp: at(0)}}
+	states := []AlertState{{Kind: "threshold", Drive: `C:\`, Status: AlertFiring, Severity: "warning",
+		Message: "C:\\ is 90% full", Since: at(0)}}
+
+	for _, backend := range Backends() {
+		for _, shard := range []string{ShardNone, ShardMonth} {
+			t.Run(backend+"/"+shard, func(t *testing.T) {
+				s, path := openTestStore(t, backend, shard)
+				// The older snapshot takes its place in time
+				if err := s.Append(ctx, snapshots[1], snapshots[2]); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Append(ctx, snapshots[0]); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveBaselines(ctx, baselines); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveAlertStates(ctx, states); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if files, err := ShardFiles(backend, path, shard); err != nil || (shard == ShardMonth) != (len(files) == 3) {
+					t.Errorf("ShardFiles = %q, %v, want the base file and one per month split", files, err)
+				}
+
+				// All of it is read back after reopening
+				s, err := OpenSharded(backend, path, shard)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				h, err := s.Load(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				compareSnapshots(t, h.Snapshots, snapshots)
+				if !reflect.DeepEqual(h.Baselines, baselines) {
+					t.Errorf("baselines = %v, want %v", h.Baselines, baselines)
+				}
+				if got, err := s.Baselines(ctx); err != nil || !reflect.DeepEqual(got, baselines) {
+					t.Errorf("Baselines = %v, %v, want %v", got, err, baselines)
+				}
+				if got, err := s.AlertStates(ctx); err != nil || !reflect.DeepEqual(got, states) {
+					t.Errorf("AlertStates = %v, %v, want %v", got, err, states)
+				}
+
+				got, err := s.Query(ctx, at(0), at(1), "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				compareSnapshots(t, got, snapshots[1:2])
+				got, err = s.Query(ctx, time.Time{}, time.Time{}, `D:\`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				compareSnapshots(t, got, []Snapshot{{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`D:\`, 10)}}})
+
+				if n, err := s.Prune(ctx, at(0)); err != nil || n != 1 {
+					t.Errorf("Prune = %d, %v, want 1 removed", n, err)
+				}
+				if n, err := s.RemoveDrive(ctx, `D:\`); err != nil || n != 1 {
+					t.Errorf("RemoveDrive = %d, %v, want 1 removed", n, err)
+				}
+				if err := s.Compact(ctx); err != nil {
+					t.Fatal(err)
+				}
+				h, err = s.Load(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				compareSnapshots(t, h.Snapshots, []Snapshot{
+					{Timestamp: at(0), Disks: []diskinfo.DiskInfo{disk(`C:\`, 50)}},
+					snapshots[2],
+				})
+			})
+		}
+	}
+}
+
+// compareSnapshots compares snapshots by instant rather than time zone
+func compareSnapshots(t *testing.T, got, want []Snapshot) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) || got[i].Host != want[i].Host || got[i].Note != want[i].Note {
+			t.Errorf("snapshot %d is %s of %q (%q), want %s of %q (%q)", i,
+				got[i].Timestamp, got[i].Host, got[i].Note, want[i].Timestamp, want[i].Host, want[i].Note)
+		}
+		if !reflect.DeepEqual(got[i].Disks, want[i].Disks) {
+			t.Errorf("snapshot %d disks = %v, want %v", i, got[i].Disks, want[i].Disks)
+		}
+	}
+}
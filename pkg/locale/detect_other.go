@@ -0,0 +1,8 @@
+//go:build !windows
+
+package locale
+
+// systemLocale has nothing beyond the environment outside Windows
+func systemLocale() string {
+	return ""
+}
@@ -0,0 +1,24 @@
+package locale
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	getUserDefaultLocaleNameW = kernel32.NewProc("GetUserDefaultLocaleName")
+)
+
+// LOCALE_NAME_MAX_LENGTH of winnls.h
+const LOCALE_NAME_MAX_LENGTH = 85
+
+// systemLocale calls GetUserDefaultLocaleName, e.g. "de-DE"
+func systemLocale() string {
+	buf := make([]uint16, LOCALE_NAME_MAX_LENGTH)
+	n, _, _ := getUserDefaultLocaleNameW.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
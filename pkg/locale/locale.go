@@ -0,0 +1,206 @@
+// Package locale formats numbers and dates for people to read, following the
+// user's locale or the one picked in the config. Machine-readable output such
+// as JSON, CSV, perfdata and file names keeps the fixed formats.
+package locale
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the separators and date layouts of a locale
+type Locale struct {
+	Name string
+	// Decimal separates the fraction, Group the thousands, empty for none
+	Decimal string
+	Group   string
+	// Date is a full date, DayMonth a date without the year
+	Date     string
+	DayMonth string
+	// Clock is a time of day in minutes
+	Clock string
+}
+
+// C is the fixed locale: ISO dates, a 24 hour clock and a decimal point
+var C = Locale{Name: "C", Decimal: ".", Date: "2006-01-02", DayMonth: "02.01", Clock: "15:04"}
+
+// nbsp groups thousands where the locale uses a space, so numbers don't wrap
+const nbsp = "\u00a0"
+
+// locales are the known locales, by BCP 47 tag
+var locales = map[string]Locale{
+	"en-US": {Decimal: ".", Group: ",", Date: "01/02/2006", DayMonth: "01/02", Clock: "3:04 PM"},
+	"en-GB": {Decimal: ".", Group: ",", Date: "02/01/2006", DayMonth: "02/01", Clock: "15:04"},
+	"de-DE": {Decimal: ",", Group: ".", Date: "02.01.2006", DayMonth: "02.01.", Clock: "15:04"},
+	"fr-FR": {Decimal: ",", Group: nbsp, Date: "02/01/2006", DayMonth: "02/01", Clock: "15:04"},
+	"es-ES": {Decimal: ",", Group: ".", Date: "02/01/2006", DayMonth: "02/01", Clock: "15:04"},
+	"it-IT": {Decimal: ",", Group: ".", Date: "02/01/2006", DayMonth: "02/01", Clock: "15:04"},
+	"nl-NL": {Decimal: ",", Group: ".", Date: "02-01-2006", DayMonth: "02-01", Clock: "15:04"},
+	"pt-BR": {Decimal: ",", Group: ".", Date: "02/01/2006", DayMonth: "02/01", Clock: "15:04"},
+	"pl-PL": {Decimal: ",", Group: nbsp, Date: "02.01.2006", DayMonth: "02.01", Clock: "15:04"},
+	"ru-RU": {Decimal: ",", Group: nbsp, Date: "02.01.2006", DayMonth: "02.01", Clock: "15:04"},
+	"sv-SE": {Decimal: ",", Group: nbsp, Date: "2006-01-02", DayMonth: "02/01", Clock: "15:04"},
+	"ja-JP": {Decimal: ".", Group: ",", Date: "2006/01/02", DayMonth: "01/02", Clock: "15:04"},
+	"zh-CN": {Decimal: ".", Group: ",", Date: "2006/01/02", DayMonth: "01/02", Clock: "15:04"},
+}
+
+// languages picks the locale of a language given without a known region
+var languages = map[string]string{
+	"en": "en-US", "de": "de-DE", "fr": "fr-FR", "es": "es-ES", "it": "it-IT", "nl": "nl-NL",
+	"pt": "pt-BR", "pl": "pl-PL", "ru": "ru-RU", "sv": "sv-SE", "ja": "ja-JP", "zh": "zh-CN",
+}
+
+// current is the process wide locale, set once at startup
+var current = C
+
+// Names returns the known locale names, sorted
+func Names() []string {
+	names := []string{C.Name}
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Lookup finds a locale by tag, case-insensitively and with "_" for "-". A
+// language alone or an unknown region picks the first locale of the language.
+func Lookup(tag string) (Locale, bool) {
+	// POSIX names carry an encoding and modifier, e.g. de_DE.UTF-8@euro
+	tag, _, _ = strings.Cut(tag, ".")
+	tag, _, _ = strings.Cut(tag, "@")
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if strings.EqualFold(tag, "C") || strings.EqualFold(tag, "POSIX") {
+		return C, true
+	}
+	for name, l := range locales {
+		if strings.EqualFold(name, tag) {
+			l.Name = name
+			return l, true
+		}
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	name, ok := languages[strings.ToLower(lang)]
+	if !ok {
+		return Locale{}, false
+	}
+	l := locales[name]
+	l.Name = name
+	return l, true
+}
+
+// Detect returns the locale of the user: LC_ALL, LC_NUMERIC or LANG when set,
+// else the user locale of Windows. Unknown locales fall back to C.
+func Detect() Locale {
+	tags := []string{os.Getenv("LC_ALL"), os.Getenv("LC_NUMERIC"), os.Getenv("LANG")}
+	tags = append(tags, systemLocale())
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		if l, ok := Lookup(tag); ok {
+			return l
+		}
+	}
+	return C
+}
+
+// Set selects the locale by name, "" or "auto" detects it
+func Set(name string) error {
+	if name == "" || strings.EqualFold(name, "auto") {
+		current = Detect()
+		return nil
+	}
+	l, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown locale %q, use auto or one of: %s", name, strings.Join(Names(), ", "))
+	}
+	current = l
+	return nil
+}
+
+// Current returns the selected locale
+func Current() Locale {
+	return current
+}
+
+// Float formats v with the given number of decimals
+func Float(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if current.Group != "" && len(whole) > 3 {
+		var b strings.Builder
+		for i, c := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteString(current.Group)
+			}
+			b.WriteRune(c)
+		}
+		whole = b.String()
+	}
+	if frac != "" {
+		return sign + whole + current.Decimal + frac
+	}
+	return sign + whole
+}
+
+// Signed formats v with the given number of decimals and always a sign
+func Signed(v float64, decimals int) string {
+	s := Float(v, decimals)
+	if strings.HasPrefix(s, "-") {
+		return s
+	}
+	return "+" + s
+}
+
+// Int formats a count with thousands separators
+func Int[T ~int | ~int64 | ~uint64 | ~uint32](n T) string {
+	return Float(float64(n), 0)
+}
+
+// Percent formats a percentage, e.g. "66.9%", or "66,9 %" where the locale
+// has a decimal comma
+func Percent(v float64, decimals int) string {
+	if current.Decimal == "," {
+		return Float(v, decimals) + nbsp + "%"
+	}
+	return Float(v, decimals) + "%"
+}
+
+// Date formats the date of t
+func Date(t time.Time) string {
+	return t.Format(current.Date)
+}
+
+// DateTime formats the date and time of t in minutes
+func DateTime(t time.Time) string {
+	return t.Format(current.Date + " " + current.Clock)
+}
+
+// Timestamp formats the date and time of t in seconds
+func Timestamp(t time.Time) string {
+	return t.Format(current.Date + " " + seconds(current.Clock))
+}
+
+// Short formats the day, month and time of t for narrow columns and axes
+func Short(t time.Time) string {
+	return t.Format(current.DayMonth + " " + current.Clock)
+}
+
+// DayMonth formats the day and month of t
+func DayMonth(t time.Time) string {
+	return t.Format(current.DayMonth)
+}
+
+// seconds adds the seconds to a clock layout
+func seconds(clock string) string {
+	return strings.Replace(clock, "04", "04:05", 1)
+}
@@ -0,0 +1,1008 @@
+// The gRPC API of the disk-monitor daemon. It is served next to the REST API
+// on the daemon's -listen address, over HTTP/2 without TLS, and every call
+// needs the api.token of the config file as "authorization: Bearer <token>"
+// metadata.
+//
+// Fields are only ever added within v1; a breaking change gets a v2 package.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: diskmonitor/v1/diskmonitor.proto
+
+package diskmonitorv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Drive is one drive, monitored folder or cloud account in a snapshot
+type Drive struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Drive      string                 `protobuf:"bytes,1,opt,name=drive,proto3" json:"drive,omitempty"`
+	TotalSpace uint64                 `protobuf:"varint,2,opt,name=total_space,json=totalSpace,proto3" json:"total_space,omitempty"`
+	FreeSpace  uint64                 `protobuf:"varint,3,opt,name=free_space,json=freeSpace,proto3" json:"free_space,omitempty"`
+	UsedSpace  uint64                 `protobuf:"varint,4,opt,name=used_space,json=usedSpace,proto3" json:"used_space,omitempty"`
+	// volume_free is the free space of the whole volume when a quota limits
+	// free_space, 0 when not recorded
+	VolumeFree       uint64 `protobuf:"varint,5,opt,name=volume_free,json=volumeFree,proto3" json:"volume_free,omitempty"`
+	FileSystem       string `protobuf:"bytes,6,opt,name=file_system,json=fileSystem,proto3" json:"file_system,omitempty"`
+	BlockClone       bool   `protobuf:"varint,7,opt,name=block_clone,json=blockClone,proto3" json:"block_clone,omitempty"`
+	IntegrityStreams bool   `protobuf:"varint,8,opt,name=integrity_streams,json=integrityStreams,proto3" json:"integrity_streams,omitempty"`
+	DevDrive         bool   `protobuf:"varint,9,opt,name=dev_drive,json=devDrive,proto3" json:"dev_drive,omitempty"`
+	// savings is the space deduplication saves
+	Savings uint64 `protobuf:"varint,10,opt,name=savings,proto3" json:"savings,omitempty"`
+	// files are the file records in use, MFT records on NTFS, and max_files the
+	// most the volume can have, 0 when not recorded
+	Files    uint64 `protobuf:"varint,11,opt,name=files,proto3" json:"files,omitempty"`
+	MaxFiles uint64 `protobuf:"varint,12,opt,name=max_files,json=maxFiles,proto3" json:"max_files,omitempty"`
+	// image is the kind of disk image the volume is mounted from: iso, vhd, vhdx
+	// or vhdset, empty for a volume on a disk
+	Image         string `protobuf:"bytes,13,opt,name=image,proto3" json:"image,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Drive) Reset() {
+	*x = Drive{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Drive) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Drive) ProtoMessage() {}
+
+func (x *Drive) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Drive.ProtoReflect.Descriptor instead.
+func (*Drive) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Drive) GetDrive() string {
+	if x != nil {
+		return x.Drive
+	}
+	return ""
+}
+
+func (x *Drive) GetTotalSpace() uint64 {
+	if x != nil {
+		return x.TotalSpace
+	}
+	return 0
+}
+
+func (x *Drive) GetFreeSpace() uint64 {
+	if x != nil {
+		return x.FreeSpace
+	}
+	return 0
+}
+
+func (x *Drive) GetUsedSpace() uint64 {
+	if x != nil {
+		return x.UsedSpace
+	}
+	return 0
+}
+
+func (x *Drive) GetVolumeFree() uint64 {
+	if x != nil {
+		return x.VolumeFree
+	}
+	return 0
+}
+
+func (x *Drive) GetFileSystem() string {
+	if x != nil {
+		return x.FileSystem
+	}
+	return ""
+}
+
+func (x *Drive) GetBlockClone() bool {
+	if x != nil {
+		return x.BlockClone
+	}
+	return false
+}
+
+func (x *Drive) GetIntegrityStreams() bool {
+	if x != nil {
+		return x.IntegrityStreams
+	}
+	return false
+}
+
+func (x *Drive) GetDevDrive() bool {
+	if x != nil {
+		return x.DevDrive
+	}
+	return false
+}
+
+func (x *Drive) GetSavings() uint64 {
+	if x != nil {
+		return x.Savings
+	}
+	return 0
+}
+
+func (x *Drive) GetFiles() uint64 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+func (x *Drive) GetMaxFiles() uint64 {
+	if x != nil {
+		return x.MaxFiles
+	}
+	return 0
+}
+
+func (x *Drive) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+// Snapshot is the state of all drives of one machine at a point in time
+type Snapshot struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Host      string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Note      string                 `protobuf:"bytes,3,opt,name=note,proto3" json:"note,omitempty"`
+	Drives    []*Drive               `protobuf:"bytes,4,rep,name=drives,proto3" json:"drives,omitempty"`
+	// details are the health, pool and other readings that have no fields here,
+	// as a JSON object like a snapshot of the history file, unset without any
+	Details       []byte `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Snapshot) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Snapshot) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Snapshot) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *Snapshot) GetDrives() []*Drive {
+	if x != nil {
+		return x.Drives
+	}
+	return nil
+}
+
+func (x *Snapshot) GetDetails() []byte {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+// Alert is a condition the latest snapshot raises
+type Alert struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// kind is threshold, anomaly, health, pool, fragmentation or onedrive
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// severity is warning or critical
+	Severity      string                 `protobuf:"bytes,2,opt,name=severity,proto3" json:"severity,omitempty"`
+	Drive         string                 `protobuf:"bytes,3,opt,name=drive,proto3" json:"drive,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Alert) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Alert) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Alert) GetDrive() string {
+	if x != nil {
+		return x.Drive
+	}
+	return ""
+}
+
+func (x *Alert) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Alert) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{3}
+}
+
+type Status struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Host    string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// latest is unset before the first collection
+	Latest *Snapshot `protobuf:"bytes,3,opt,name=latest,proto3" json:"latest,omitempty"`
+	Alerts []*Alert  `protobuf:"bytes,4,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	// forecasts are those of the drives of latest with enough history
+	Forecasts     []*Forecast `protobuf:"bytes,5,rep,name=forecasts,proto3" json:"forecasts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Status) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Status) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Status) GetLatest() *Snapshot {
+	if x != nil {
+		return x.Latest
+	}
+	return nil
+}
+
+func (x *Status) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+func (x *Status) GetForecasts() []*Forecast {
+	if x != nil {
+		return x.Forecasts
+	}
+	return nil
+}
+
+// Forecast is when a drive will be full at its trend over forecast.window,
+// like the forecast command says
+type Forecast struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Drive string                 `protobuf:"bytes,1,opt,name=drive,proto3" json:"drive,omitempty"`
+	// rate is the change of free space in bytes per day
+	Rate float64 `protobuf:"fixed64,2,opt,name=rate,proto3" json:"rate,omitempty"`
+	// filling is false when the trend never reaches forecast.reserve, which
+	// leaves the fields below unset
+	Filling bool `protobuf:"varint,3,opt,name=filling,proto3" json:"filling,omitempty"`
+	// days_until_full counts from the time of the latest snapshot
+	DaysUntilFull     float64                `protobuf:"fixed64,4,opt,name=days_until_full,json=daysUntilFull,proto3" json:"days_until_full,omitempty"`
+	EstimatedFullDate *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=estimated_full_date,json=estimatedFullDate,proto3" json:"estimated_full_date,omitempty"`
+	// earliest_full_date and latest_full_date bound the estimate at 95%
+	// confidence; latest_full_date is unset when the slow end never fills up
+	EarliestFullDate *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=earliest_full_date,json=earliestFullDate,proto3" json:"earliest_full_date,omitempty"`
+	LatestFullDate   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=latest_full_date,json=latestFullDate,proto3" json:"latest_full_date,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Forecast) Reset() {
+	*x = Forecast{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Forecast) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Forecast) ProtoMessage() {}
+
+func (x *Forecast) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Forecast.ProtoReflect.Descriptor instead.
+func (*Forecast) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Forecast) GetDrive() string {
+	if x != nil {
+		return x.Drive
+	}
+	return ""
+}
+
+func (x *Forecast) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *Forecast) GetFilling() bool {
+	if x != nil {
+		return x.Filling
+	}
+	return false
+}
+
+func (x *Forecast) GetDaysUntilFull() float64 {
+	if x != nil {
+		return x.DaysUntilFull
+	}
+	return 0
+}
+
+func (x *Forecast) GetEstimatedFullDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EstimatedFullDate
+	}
+	return nil
+}
+
+func (x *Forecast) GetEarliestFullDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EarliestFullDate
+	}
+	return nil
+}
+
+func (x *Forecast) GetLatestFullDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LatestFullDate
+	}
+	return nil
+}
+
+type StreamSnapshotsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamSnapshotsRequest) Reset() {
+	*x = StreamSnapshotsRequest{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamSnapshotsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamSnapshotsRequest) ProtoMessage() {}
+
+func (x *StreamSnapshotsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamSnapshotsRequest.ProtoReflect.Descriptor instead.
+func (*StreamSnapshotsRequest) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{6}
+}
+
+type QueryHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// from and to bound the snapshots, unset leaves that end open
+	From *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// drive keeps only that drive, e.g. "C:", empty keeps all
+	Drive string `protobuf:"bytes,3,opt,name=drive,proto3" json:"drive,omitempty"`
+	// host keeps only that machine's snapshots, empty keeps all
+	Host          string `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryHistoryRequest) Reset() {
+	*x = QueryHistoryRequest{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryHistoryRequest) ProtoMessage() {}
+
+func (x *QueryHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryHistoryRequest.ProtoReflect.Descriptor instead.
+func (*QueryHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *QueryHistoryRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *QueryHistoryRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *QueryHistoryRequest) GetDrive() string {
+	if x != nil {
+		return x.Drive
+	}
+	return ""
+}
+
+func (x *QueryHistoryRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type QueryHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Snapshots     []*Snapshot            `protobuf:"bytes,1,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryHistoryResponse) Reset() {
+	*x = QueryHistoryResponse{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryHistoryResponse) ProtoMessage() {}
+
+func (x *QueryHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryHistoryResponse.ProtoReflect.Descriptor instead.
+func (*QueryHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *QueryHistoryResponse) GetSnapshots() []*Snapshot {
+	if x != nil {
+		return x.Snapshots
+	}
+	return nil
+}
+
+type TriggerCollectRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// note is attached to the snapshot
+	Note          string `protobuf:"bytes,1,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerCollectRequest) Reset() {
+	*x = TriggerCollectRequest{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerCollectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerCollectRequest) ProtoMessage() {}
+
+func (x *TriggerCollectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerCollectRequest.ProtoReflect.Descriptor instead.
+func (*TriggerCollectRequest) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TriggerCollectRequest) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+// UploadRequest is the body of POST /api/upload, gzipped with
+// "Content-Encoding: gzip"
+type UploadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// snapshots need a host, they are stored as that machine's
+	Snapshots     []*Snapshot `protobuf:"bytes,1,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadRequest) Reset() {
+	*x = UploadRequest{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadRequest) ProtoMessage() {}
+
+func (x *UploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadRequest.ProtoReflect.Descriptor instead.
+func (*UploadRequest) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UploadRequest) GetSnapshots() []*Snapshot {
+	if x != nil {
+		return x.Snapshots
+	}
+	return nil
+}
+
+type UploadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// stored is how many snapshots were new
+	Stored uint32 `protobuf:"varint,1,opt,name=stored,proto3" json:"stored,omitempty"`
+	// duplicates is how many were already stored, by an upload whose response
+	// was lost
+	Duplicates    uint32 `protobuf:"varint,2,opt,name=duplicates,proto3" json:"duplicates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResponse) ProtoMessage() {}
+
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_diskmonitor_v1_diskmonitor_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UploadResponse) GetStored() uint32 {
+	if x != nil {
+		return x.Stored
+	}
+	return 0
+}
+
+func (x *UploadResponse) GetDuplicates() uint32 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+var File_diskmonitor_v1_diskmonitor_proto protoreflect.FileDescriptor
+
+const file_diskmonitor_v1_diskmonitor_proto_rawDesc = "" +
+	"\n" +
+	" diskmonitor/v1/diskmonitor.proto\x12\x0ediskmonitor.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8c\x03\n" +
+	"\x05Drive\x12\x14\n" +
+	"\x05drive\x18\x01 \x01(\tR\x05drive\x12\x1f\n" +
+	"\vtotal_space\x18\x02 \x01(\x04R\n" +
+	"totalSpace\x12\x1d\n" +
+	"\n" +
+	"free_space\x18\x03 \x01(\x04R\tfreeSpace\x12\x1d\n" +
+	"\n" +
+	"used_space\x18\x04 \x01(\x04R\tusedSpace\x12\x1f\n" +
+	"\vvolume_free\x18\x05 \x01(\x04R\n" +
+	"volumeFree\x12\x1f\n" +
+	"\vfile_system\x18\x06 \x01(\tR\n" +
+	"fileSystem\x12\x1f\n" +
+	"\vblock_clone\x18\a \x01(\bR\n" +
+	"blockClone\x12+\n" +
+	"\x11integrity_streams\x18\b \x01(\bR\x10integrityStreams\x12\x1b\n" +
+	"\tdev_drive\x18\t \x01(\bR\bdevDrive\x12\x18\n" +
+	"\asavings\x18\n" +
+	" \x01(\x04R\asavings\x12\x14\n" +
+	"\x05files\x18\v \x01(\x04R\x05files\x12\x1b\n" +
+	"\tmax_files\x18\f \x01(\x04R\bmaxFiles\x12\x14\n" +
+	"\x05image\x18\r \x01(\tR\x05image\"\xb5\x01\n" +
+	"\bSnapshot\x128\n" +
+	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04note\x18\x03 \x01(\tR\x04note\x12-\n" +
+	"\x06drives\x18\x04 \x03(\v2\x15.diskmonitor.v1.DriveR\x06drives\x12\x18\n" +
+	"\adetails\x18\x05 \x01(\fR\adetails\"\x97\x01\n" +
+	"\x05Alert\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1a\n" +
+	"\bseverity\x18\x02 \x01(\tR\bseverity\x12\x14\n" +
+	"\x05drive\x18\x03 \x01(\tR\x05drive\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"\x12\n" +
+	"\x10GetStatusRequest\"\xcf\x01\n" +
+	"\x06Status\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x120\n" +
+	"\x06latest\x18\x03 \x01(\v2\x18.diskmonitor.v1.SnapshotR\x06latest\x12-\n" +
+	"\x06alerts\x18\x04 \x03(\v2\x15.diskmonitor.v1.AlertR\x06alerts\x126\n" +
+	"\tforecasts\x18\x05 \x03(\v2\x18.diskmonitor.v1.ForecastR\tforecasts\"\xd2\x02\n" +
+	"\bForecast\x12\x14\n" +
+	"\x05drive\x18\x01 \x01(\tR\x05drive\x12\x12\n" +
+	"\x04rate\x18\x02 \x01(\x01R\x04rate\x12\x18\n" +
+	"\afilling\x18\x03 \x01(\bR\afilling\x12&\n" +
+	"\x0fdays_until_full\x18\x04 \x01(\x01R\rdaysUntilFull\x12J\n" +
+	"\x13estimated_full_date\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x11estimatedFullDate\x12H\n" +
+	"\x12earliest_full_date\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x10earliestFullDate\x12D\n" +
+	"\x10latest_full_date\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x0elatestFullDate\"\x18\n" +
+	"\x16StreamSnapshotsRequest\"\x9b\x01\n" +
+	"\x13QueryHistoryRequest\x12.\n" +
+	"\x04from\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05drive\x18\x03 \x01(\tR\x05drive\x12\x12\n" +
+	"\x04host\x18\x04 \x01(\tR\x04host\"N\n" +
+	"\x14QueryHistoryResponse\x126\n" +
+	"\tsnapshots\x18\x01 \x03(\v2\x18.diskmonitor.v1.SnapshotR\tsnapshots\"+\n" +
+	"\x15TriggerCollectRequest\x12\x12\n" +
+	"\x04note\x18\x01 \x01(\tR\x04note\"G\n" +
+	"\rUploadRequest\x126\n" +
+	"\tsnapshots\x18\x01 \x03(\v2\x18.diskmonitor.v1.SnapshotR\tsnapshots\"H\n" +
+	"\x0eUploadResponse\x12\x16\n" +
+	"\x06stored\x18\x01 \x01(\rR\x06stored\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x02 \x01(\rR\n" +
+	"duplicates2\xa2\x03\n" +
+	"\vDiskMonitor\x12E\n" +
+	"\tGetStatus\x12 .diskmonitor.v1.GetStatusRequest\x1a\x16.diskmonitor.v1.Status\x12U\n" +
+	"\x0fStreamSnapshots\x12&.diskmonitor.v1.StreamSnapshotsRequest\x1a\x18.diskmonitor.v1.Snapshot0\x01\x12Y\n" +
+	"\fQueryHistory\x12#.diskmonitor.v1.QueryHistoryRequest\x1a$.diskmonitor.v1.QueryHistoryResponse\x12Q\n" +
+	"\x0eTriggerCollect\x12%.diskmonitor.v1.TriggerCollectRequest\x1a\x18.diskmonitor.v1.Snapshot\x12G\n" +
+	"\x06Upload\x12\x1d.diskmonitor.v1.UploadRequest\x1a\x1e.diskmonitor.v1.UploadResponseBEZCgithub.com/valsaven/disk-monitor/proto/diskmonitor/v1;diskmonitorv1b\x06proto3"
+
+var (
+	file_diskmonitor_v1_diskmonitor_proto_rawDescOnce sync.Once
+	file_diskmonitor_v1_diskmonitor_proto_rawDescData []byte
+)
+
+func file_diskmonitor_v1_diskmonitor_proto_rawDescGZIP() []byte {
+	file_diskmonitor_v1_diskmonitor_proto_rawDescOnce.Do(func() {
+		file_diskmonitor_v1_diskmonitor_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_diskmonitor_v1_diskmonitor_proto_rawDesc), len(file_diskmonitor_v1_diskmonitor_proto_rawDesc)))
+	})
+	return file_diskmonitor_v1_diskmonitor_proto_rawDescData
+}
+
+var file_diskmonitor_v1_diskmonitor_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_diskmonitor_v1_diskmonitor_proto_goTypes = []any{
+	(*Drive)(nil),                  // 0: diskmonitor.v1.Drive
+	(*Snapshot)(nil),               // 1: diskmonitor.v1.Snapshot
+	(*Alert)(nil),                  // 2: diskmonitor.v1.Alert
+	(*GetStatusRequest)(nil),       // 3: diskmonitor.v1.GetStatusRequest
+	(*Status)(nil),                 // 4: diskmonitor.v1.Status
+	(*Forecast)(nil),               // 5: diskmonitor.v1.Forecast
+	(*StreamSnapshotsRequest)(nil), // 6: diskmonitor.v1.StreamSnapshotsRequest
+	(*QueryHistoryRequest)(nil),    // 7: diskmonitor.v1.QueryHistoryRequest
+	(*QueryHistoryResponse)(nil),   // 8: diskmonitor.v1.QueryHistoryResponse
+	(*TriggerCollectRequest)(nil),  // 9: diskmonitor.v1.TriggerCollectRequest
+	(*UploadRequest)(nil),          // 10: diskmonitor.v1.UploadRequest
+	(*UploadResponse)(nil),         // 11: diskmonitor.v1.UploadResponse
+	(*timestamppb.Timestamp)(nil),  // 12: google.protobuf.Timestamp
+}
+var file_diskmonitor_v1_diskmonitor_proto_depIdxs = []int32{
+	12, // 0: diskmonitor.v1.Snapshot.timestamp:type_name -> google.protobuf.Timestamp
+	0,  // 1: diskmonitor.v1.Snapshot.drives:type_name -> diskmonitor.v1.Drive
+	12, // 2: diskmonitor.v1.Alert.time:type_name -> google.protobuf.Timestamp
+	1,  // 3: diskmonitor.v1.Status.latest:type_name -> diskmonitor.v1.Snapshot
+	2,  // 4: diskmonitor.v1.Status.alerts:type_name -> diskmonitor.v1.Alert
+	5,  // 5: diskmonitor.v1.Status.forecasts:type_name -> diskmonitor.v1.Forecast
+	12, // 6: diskmonitor.v1.Forecast.estimated_full_date:type_name -> google.protobuf.Timestamp
+	12, // 7: diskmonitor.v1.Forecast.earliest_full_date:type_name -> google.protobuf.Timestamp
+	12, // 8: diskmonitor.v1.Forecast.latest_full_date:type_name -> google.protobuf.Timestamp
+	12, // 9: diskmonitor.v1.QueryHistoryRequest.from:type_name -> google.protobuf.Timestamp
+	12, // 10: diskmonitor.v1.QueryHistoryRequest.to:type_name -> google.protobuf.Timestamp
+	1,  // 11: diskmonitor.v1.QueryHistoryResponse.snapshots:type_name -> diskmonitor.v1.Snapshot
+	1,  // 12: diskmonitor.v1.UploadRequest.snapshots:type_name -> diskmonitor.v1.Snapshot
+	3,  // 13: diskmonitor.v1.DiskMonitor.GetStatus:input_type -> diskmonitor.v1.GetStatusRequest
+	6,  // 14: diskmonitor.v1.DiskMonitor.StreamSnapshots:input_type -> diskmonitor.v1.StreamSnapshotsRequest
+	7,  // 15: diskmonitor.v1.DiskMonitor.QueryHistory:input_type -> diskmonitor.v1.QueryHistoryRequest
+	9,  // 16: diskmonitor.v1.DiskMonitor.TriggerCollect:input_type -> diskmonitor.v1.TriggerCollectRequest
+	10, // 17: diskmonitor.v1.DiskMonitor.Upload:input_type -> diskmonitor.v1.UploadRequest
+	4,  // 18: diskmonitor.v1.DiskMonitor.GetStatus:output_type -> diskmonitor.v1.Status
+	1,  // 19: diskmonitor.v1.DiskMonitor.StreamSnapshots:output_type -> diskmonitor.v1.Snapshot
+	8,  // 20: diskmonitor.v1.DiskMonitor.QueryHistory:output_type -> diskmonitor.v1.QueryHistoryResponse
+	1,  // 21: diskmonitor.v1.DiskMonitor.TriggerCollect:output_type -> diskmonitor.v1.Snapshot
+	11, // 22: diskmonitor.v1.DiskMonitor.Upload:output_type -> diskmonitor.v1.UploadResponse
+	18, // [18:23] is the sub-list for method output_type
+	13, // [13:18] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_diskmonitor_v1_diskmonitor_proto_init() }
+func file_diskmonitor_v1_diskmonitor_proto_init() {
+	if File_diskmonitor_v1_diskmonitor_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_diskmonitor_v1_diskmonitor_proto_rawDesc), len(file_diskmonitor_v1_diskmonitor_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_diskmonitor_v1_diskmonitor_proto_goTypes,
+		DependencyIndexes: file_diskmonitor_v1_diskmonitor_proto_depIdxs,
+		MessageInfos:      file_diskmonitor_v1_diskmonitor_proto_msgTypes,
+	}.Build()
+	File_diskmonitor_v1_diskmonitor_proto = out.File
+	file_diskmonitor_v1_diskmonitor_proto_goTypes = nil
+	file_diskmonitor_v1_diskmonitor_proto_depIdxs = nil
+}
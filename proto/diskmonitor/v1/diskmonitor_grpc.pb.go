@@ -0,0 +1,298 @@
+// The gRPC API of the disk-monitor daemon. It is served next to the REST API
+// on the daemon's -listen address, over HTTP/2 without TLS, and every call
+// needs the api.token of the config file as "authorization: Bearer <token>"
+// metadata.
+//
+// Fields are only ever added within v1; a breaking change gets a v2 package.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.1
+// - protoc             (unknown)
+// source: diskmonitor/v1/diskmonitor.proto
+
+package diskmonitorv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DiskMonitor_GetStatus_FullMethodName       = "/diskmonitor.v1.DiskMonitor/GetStatus"
+	DiskMonitor_StreamSnapshots_FullMethodName = "/diskmonitor.v1.DiskMonitor/StreamSnapshots"
+	DiskMonitor_QueryHistory_FullMethodName    = "/diskmonitor.v1.DiskMonitor/QueryHistory"
+	DiskMonitor_TriggerCollect_FullMethodName  = "/diskmonitor.v1.DiskMonitor/TriggerCollect"
+	DiskMonitor_Upload_FullMethodName          = "/diskmonitor.v1.DiskMonitor/Upload"
+)
+
+// DiskMonitorClient is the client API for DiskMonitor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DiskMonitorClient interface {
+	// GetStatus returns the daemon's version and the latest snapshot of its
+	// machine with the alerts it raises and when its drives will be full
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// StreamSnapshots sends every snapshot the daemon collects from now on,
+	// until the client cancels
+	StreamSnapshots(ctx context.Context, in *StreamSnapshotsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Snapshot], error)
+	// QueryHistory returns the recorded snapshots in [from, to)
+	QueryHistory(ctx context.Context, in *QueryHistoryRequest, opts ...grpc.CallOption) (*QueryHistoryResponse, error)
+	// TriggerCollect collects right away and returns the snapshot
+	TriggerCollect(ctx context.Context, in *TriggerCollectRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	// Upload stores snapshots taken by an agent, like POST /api/upload
+	Upload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadResponse, error)
+}
+
+type diskMonitorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDiskMonitorClient(cc grpc.ClientConnInterface) DiskMonitorClient {
+	return &diskMonitorClient{cc}
+}
+
+func (c *diskMonitorClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Status)
+	err := c.cc.Invoke(ctx, DiskMonitor_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *diskMonitorClient) StreamSnapshots(ctx context.Context, in *StreamSnapshotsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Snapshot], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DiskMonitor_ServiceDesc.Streams[0], DiskMonitor_StreamSnapshots_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamSnapshotsRequest, Snapshot]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DiskMonitor_StreamSnapshotsClient = grpc.ServerStreamingClient[Snapshot]
+
+func (c *diskMonitorClient) QueryHistory(ctx context.Context, in *QueryHistoryRequest, opts ...grpc.CallOption) (*QueryHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryHistoryResponse)
+	err := c.cc.Invoke(ctx, DiskMonitor_QueryHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *diskMonitorClient) TriggerCollect(ctx context.Context, in *TriggerCollectRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, DiskMonitor_TriggerCollect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *diskMonitorClient) Upload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadResponse)
+	err := c.cc.Invoke(ctx, DiskMonitor_Upload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DiskMonitorServer is the server API for DiskMonitor service.
+// All implementations must embed UnimplementedDiskMonitorServer
+// for forward compatibility.
+type DiskMonitorServer interface {
+	// GetStatus returns the daemon's version and the latest snapshot of its
+	// machine with the alerts it raises and when its drives will be full
+	GetStatus(context.Context, *GetStatusRequest) (*Status, error)
+	// StreamSnapshots sends every snapshot the daemon collects from now on,
+	// until the client cancels
+	StreamSnapshots(*StreamSnapshotsRequest, grpc.ServerStreamingServer[Snapshot]) error
+	// QueryHistory returns the recorded snapshots in [from, to)
+	QueryHistory(context.Context, *QueryHistoryRequest) (*QueryHistoryResponse, error)
+	// TriggerCollect collects right away and returns the snapshot
+	TriggerCollect(context.Context, *TriggerCollectRequest) (*Snapshot, error)
+	// Upload stores snapshots taken by an agent, like POST /api/upload
+	Upload(context.Context, *UploadRequest) (*UploadResponse, error)
+	mustEmbedUnimplementedDiskMonitorServer()
+}
+
+// UnimplementedDiskMonitorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDiskMonitorServer struct{}
+
+func (UnimplementedDiskMonitorServer) GetStatus(context.Context, *GetStatusRequest) (*Status, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedDiskMonitorServer) StreamSnapshots(*StreamSnapshotsRequest, grpc.ServerStreamingServer[Snapshot]) error {
+	return status.Error(codes.Unimplemented, "method StreamSnapshots not implemented")
+}
+func (UnimplementedDiskMonitorServer) QueryHistory(context.Context, *QueryHistoryRequest) (*QueryHistoryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryHistory not implemented")
+}
+func (UnimplementedDiskMonitorServer) TriggerCollect(context.Context, *TriggerCollectRequest) (*Snapshot, error) {
+	return nil, status.Error(codes.Unimplemented, "method TriggerCollect not implemented")
+}
+func (UnimplementedDiskMonitorServer) Upload(context.Context, *UploadRequest) (*UploadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedDiskMonitorServer) mustEmbedUnimplementedDiskMonitorServer() {}
+func (UnimplementedDiskMonitorServer) testEmbeddedByValue()                     {}
+
+// UnsafeDiskMonitorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DiskMonitorServer will
+// result in compilation errors.
+type UnsafeDiskMonitorServer interface {
+	mustEmbedUnimplementedDiskMonitorServer()
+}
+
+func RegisterDiskMonitorServer(s grpc.ServiceRegistrar, srv DiskMonitorServer) {
+	// If the following call panics, it indicates UnimplementedDiskMonitorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DiskMonitor_ServiceDesc, srv)
+}
+
+func _DiskMonitor_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiskMonitorServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DiskMonitor_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiskMonitorServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DiskMonitor_StreamSnapshots_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSnapshotsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DiskMonitorServer).StreamSnapshots(m, &grpc.GenericServerStream[StreamSnapshotsRequest, Snapshot]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DiskMonitor_StreamSnapshotsServer = grpc.ServerStreamingServer[Snapshot]
+
+func _DiskMonitor_QueryHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiskMonitorServer).QueryHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DiskMonitor_QueryHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiskMonitorServer).QueryHistory(ctx, req.(*QueryHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DiskMonitor_TriggerCollect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerCollectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiskMonitorServer).TriggerCollect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DiskMonitor_TriggerCollect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiskMonitorServer).TriggerCollect(ctx, req.(*TriggerCollectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DiskMonitor_Upload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiskMonitorServer).Upload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DiskMonitor_Upload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiskMonitorServer).Upload(ctx, req.(*UploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DiskMonitor_ServiceDesc is the grpc.ServiceDesc for DiskMonitor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DiskMonitor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "diskmonitor.v1.DiskMonitor",
+	HandlerType: (*DiskMonitorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _DiskMonitor_GetStatus_Handler,
+		},
+		{
+			MethodName: "QueryHistory",
+			Handler:    _DiskMonitor_QueryHistory_Handler,
+		},
+		{
+			MethodName: "TriggerCollect",
+			Handler:    _DiskMonitor_TriggerCollect_Handler,
+		},
+		{
+			MethodName: "Upload",
+			Handler:    _DiskMonitor_Upload_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSnapshots",
+			Handler:       _DiskMonitor_StreamSnapshots_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "diskmonitor/v1/diskmonitor.proto",
+}